@@ -15,6 +15,33 @@ int kepler_sched_switch_trace(u64 *ctx)
 		prev_task->pid, next_task->pid, prev_task->tgid, next_task->tgid);
 }
 
+SEC("tp_btf/sched_switch")
+int kepler_hw_counters_trace(u64 *ctx)
+{
+	struct task_struct *prev_task;
+
+	prev_task = (struct task_struct *)ctx[1];
+
+	return do_kepler_hw_counters_trace(prev_task->tgid);
+}
+
+SEC("tp_btf/sched_process_exit")
+int kepler_sched_process_exit_trace(u64 *ctx)
+{
+	struct task_struct *task, *leader, *parent;
+
+	task = (struct task_struct *)ctx[0];
+	// the process is identified by its leader, which is a zombie until the
+	// last thread exits if it exits first
+	leader = task->group_leader;
+	// the parent of an orphan is the reaper it was reparented to
+	parent = leader->real_parent;
+
+	return do_kepler_sched_process_exit_trace(
+		task->signal->live.counter, leader->tgid, leader->start_boottime,
+		parent->tgid, parent->start_boottime);
+}
+
 SEC("tp_btf/softirq_entry")
 int kepler_irq_trace(u64 *ctx)
 {
@@ -52,4 +79,67 @@ int kepler_write_page_trace(void *ctx)
 	return 0;
 }
 
+// count the bytes sent, tcp_sendmsg runs in the context of the process that
+// owns the socket
+SEC("fexit/tcp_sendmsg")
+int kepler_tcp_sendmsg_trace(u64 *ctx)
+{
+	u32 curr_tgid;
+	struct tcp_sock *tp;
+	int sent;
+
+	tp = (struct tcp_sock *)ctx[0];
+	sent = (int)ctx[3];
+	if (sent <= 0)
+		return 0;
+
+	curr_tgid = bpf_get_current_pid_tgid() >> 32;
+	do_net_tx_increment(curr_tgid, sent, tp->mss_cache);
+	return 0;
+}
+
+// count the bytes received, tcp_cleanup_rbuf runs in the context of the
+// process that owns the socket once the bytes are copied to the process
+SEC("fexit/tcp_cleanup_rbuf")
+int kepler_tcp_cleanup_rbuf_trace(u64 *ctx)
+{
+	u32 curr_tgid;
+	struct inet_connection_sock *icsk;
+	int copied;
+
+	icsk = (struct inet_connection_sock *)ctx[0];
+	copied = (int)ctx[1];
+	if (copied <= 0)
+		return 0;
+
+	curr_tgid = bpf_get_current_pid_tgid() >> 32;
+	do_net_rx_increment(curr_tgid, copied, icsk->icsk_ack.rcv_mss);
+	return 0;
+}
+
+// the tracepoint passes the request without its queue since Linux 5.11
+// the request is the first argument since Linux 5.11, the exporter does not
+// attach it to the earlier kernels. The requests of the buffered writes are
+// issued by the flusher kworkers, and are counted to the kworker.
+SEC("tp_btf/block_rq_issue")
+int kepler_block_rq_issue_trace(u64 *ctx)
+{
+	u32 curr_tgid;
+	struct request *rq;
+
+	rq = (struct request *)ctx[0];
+	curr_tgid = bpf_get_current_pid_tgid() >> 32;
+	do_block_rq_issue(
+		(u64)rq, curr_tgid, rq->cmd_flags, rq->__data_len,
+		bpf_ktime_get_ns());
+	return 0;
+}
+
+SEC("tp_btf/block_rq_complete")
+int kepler_block_rq_complete_trace(u64 *ctx)
+{
+	do_block_rq_complete(ctx[0], bpf_ktime_get_ns());
+	return 0;
+}
+
 char __license[] SEC("license") = "Dual BSD/GPL";
@@ -23,6 +23,9 @@ typedef __u32 __be32;
 typedef __u64 __be64;
 typedef __u32 __wsum;
 typedef int pid_t;
+typedef struct {
+	int counter;
+} atomic_t;
 typedef struct pid_time_t {
 	__u32 pid;
 } pid_time_t;
@@ -94,9 +97,6 @@ typedef struct process_metrics_t {
 	u64 cgroup_id;
 	u64 pid; // pid is the kernel space view of the thread id
 	u64 process_run_time;
-	u64 cpu_cycles;
-	u64 cpu_instr;
-	u64 cache_miss;
 	u64 page_cache_hit;
 	u16 vec_nr[10];
 	char comm[16];
@@ -116,56 +116,126 @@ struct {
 	__uint(max_entries, MAP_SIZE);
 } pid_time_map SEC(".maps");
 
+// the network traffic of the processes that own the sockets
+typedef struct process_net_metrics_t {
+	u64 bytes_tx;
+	u64 bytes_rx;
+	u64 packets_tx;
+	u64 packets_rx;
+} process_net_metrics_t;
+
 struct {
-	__uint(type, BPF_MAP_TYPE_PERF_EVENT_ARRAY);
-	__type(key, int);
-	__type(value, u32);
-	__uint(max_entries, NUM_CPUS);
-} cpu_cycles_event_reader SEC(".maps");
+	__uint(type, BPF_MAP_TYPE_LRU_HASH);
+	__type(key, u32);
+	__type(value, process_net_metrics_t);
+	__uint(max_entries, MAP_SIZE);
+} process_net SEC(".maps");
+
+// the block I/O requests issued by the processes
+typedef struct process_block_metrics_t {
+	u64 read_bytes;
+	u64 write_bytes;
+	u64 read_requests;
+	u64 write_requests;
+	u64 service_time_us;
+} process_block_metrics_t;
 
 struct {
-	__uint(type, BPF_MAP_TYPE_ARRAY);
+	__uint(type, BPF_MAP_TYPE_LRU_HASH);
 	__type(key, u32);
-	__type(value, u64);
-	__uint(max_entries, NUM_CPUS);
-} cpu_cycles SEC(".maps");
+	__type(value, process_block_metrics_t);
+	__uint(max_entries, MAP_SIZE);
+} process_block SEC(".maps");
+
+// a request is completed in an interrupt, possibly on another CPU, so the
+// issuing process is kept per request pointer until the request completes
+typedef struct block_request_t {
+	u64 issue_ts;
+	u64 bytes;
+	u32 tgid;
+	u32 op;
+} block_request_t;
 
 struct {
-	__uint(type, BPF_MAP_TYPE_PERF_EVENT_ARRAY);
-	__type(key, int);
-	__type(value, u32);
-	__uint(max_entries, NUM_CPUS);
-} cpu_instructions_event_reader SEC(".maps");
+	__uint(type, BPF_MAP_TYPE_LRU_HASH);
+	__type(key, u64);
+	__type(value, block_request_t);
+	__uint(max_entries, MAP_SIZE);
+} block_requests SEC(".maps");
+
+// the start time identifies the process together with its pid, since the pids
+// are reused, and the parent likewise
+typedef struct process_exit_event_t {
+	u32 pid;
+	u32 tgid;
+	u64 start_time; // nanoseconds since boot
+	u32 ppid;
+	u32 pad;
+	u64 parent_start_time; // nanoseconds since boot
+} process_exit_event_t;
 
+struct {
+	__uint(type, BPF_MAP_TYPE_RINGBUF);
+	__uint(max_entries, 256 * 1024);
+} process_exit_events SEC(".maps");
+
+// the exit events that were dropped because the ring buffer was full
 struct {
 	__uint(type, BPF_MAP_TYPE_ARRAY);
 	__type(key, u32);
 	__type(value, u64);
-	__uint(max_entries, NUM_CPUS);
-} cpu_instructions SEC(".maps");
+	__uint(max_entries, 1);
+} process_exit_dropped SEC(".maps");
 
-struct {
+#ifndef MAX_HW_COUNTERS
+# define MAX_HW_COUNTERS 8
+#endif
+
+// the configured hardware counters are opened on every CPU, the events of
+// counter i are in the perf event array at index i, since a perf event array
+// has at most an entry per CPU
+struct hw_counter_events {
 	__uint(type, BPF_MAP_TYPE_PERF_EVENT_ARRAY);
 	__type(key, int);
 	__type(value, u32);
 	__uint(max_entries, NUM_CPUS);
-} cache_miss_event_reader SEC(".maps");
+};
+
+struct {
+	__uint(type, BPF_MAP_TYPE_ARRAY_OF_MAPS);
+	__type(key, u32);
+	__uint(max_entries, MAX_HW_COUNTERS);
+	__array(values, struct hw_counter_events);
+} hw_counter_event_reader SEC(".maps");
+
+// the value of counter i on a CPU is at index cpu * MAX_HW_COUNTERS + i
 
 struct {
 	__uint(type, BPF_MAP_TYPE_ARRAY);
 	__type(key, u32);
-	__type(value, u64);
-	__uint(max_entries, NUM_CPUS);
-} cache_miss SEC(".maps");
+	__type(value, struct bpf_perf_event_value);
+	__uint(max_entries, NUM_CPUS * MAX_HW_COUNTERS);
+} hw_counter_values SEC(".maps");
+
+typedef struct process_hw_counters_t {
+	u64 values[MAX_HW_COUNTERS];
+} process_hw_counters_t;
+
+struct {
+	__uint(type, BPF_MAP_TYPE_LRU_HASH);
+	__type(key, u32);
+	__type(value, process_hw_counters_t);
+	__uint(max_entries, MAP_SIZE);
+} process_hw_counters SEC(".maps");
 
 // Test mode skips unsupported helpers
 SEC(".rodata.config")
 __attribute__((btf_decl_tag("Test"))) static volatile const int TEST = 0;
 
-// Test mode skips unsupported helpers
+// The number of configured hardware counters
 SEC(".rodata.config")
 __attribute__((btf_decl_tag(
-	"Hardware Events Enabled"))) static volatile const int HW = 1;
+	"Hardware Counters"))) static volatile const int HW_COUNTERS = 0;
 
 // The sampling rate should be disabled by default because its impact on the
 // measurements is unknown.
@@ -175,9 +245,37 @@ __attribute__((
 
 int counter_sched_switch = 0;
 
+struct signal_struct {
+	atomic_t live;
+} __attribute__((preserve_access_index));
+
 struct task_struct {
 	int pid;
 	unsigned int tgid;
+	u64 start_boottime;
+	struct task_struct *real_parent;
+	struct task_struct *group_leader;
+	struct signal_struct *signal;
+} __attribute__((preserve_access_index));
+
+#define REQ_OP_BITS 8
+#define REQ_OP_MASK ((1 << REQ_OP_BITS) - 1)
+#define REQ_OP_READ 0
+#define REQ_OP_WRITE 1
+
+struct request {
+	unsigned int cmd_flags;
+	unsigned int __data_len;
+} __attribute__((preserve_access_index));
+
+struct tcp_sock {
+	u32 mss_cache;
+} __attribute__((preserve_access_index));
+
+struct inet_connection_sock {
+	struct {
+		u16 rcv_mss;
+	} icsk_ack;
 } __attribute__((preserve_access_index));
 
 static inline u64 calc_delta(u64 *prev_val, u64 val)
@@ -205,60 +303,59 @@ static inline u64 get_on_cpu_elapsed_time_us(u32 prev_pid, u64 curr_ts)
 	return cpu_time;
 }
 
-static inline u64 get_on_cpu_cycles(u32 *cpu_id)
+// The events are multiplexed when there are more events than the PMU can count
+// at once, so an event counts only while it is running. The counter delta is
+// scaled by the time the event was enabled over the time it was running.
+static inline u64 calc_scaled_delta(
+	struct bpf_perf_event_value *prev_val, struct bpf_perf_event_value *val)
 {
-	u64 delta, val, *prev_val;
-	long error;
-	struct bpf_perf_event_value c = {};
+	u64 delta, enabled, running;
 
-	error = bpf_perf_event_read_value(
-		&cpu_cycles_event_reader, *cpu_id, &c, sizeof(c));
-	if (error)
+	if (!prev_val)
 		return 0;
 
-	val = c.counter;
-	prev_val = bpf_map_lookup_elem(&cpu_cycles, cpu_id);
-	delta = calc_delta(prev_val, val);
-	bpf_map_update_elem(&cpu_cycles, cpu_id, &val, BPF_ANY);
+	delta = calc_delta(&prev_val->counter, val->counter);
+	enabled = calc_delta(&prev_val->enabled, val->enabled);
+	running = calc_delta(&prev_val->running, val->running);
+	if (running == 0 || running >= enabled)
+		return delta;
 
-	return delta;
+	// delta * enabled overflows for the large deltas, e.g. the cycles of
+	// seconds at GHz times the enabled nanoseconds
+	return delta / running * enabled + (delta % running) * enabled / running;
 }
 
-static inline u64 get_on_cpu_instr(u32 *cpu_id)
+// the maps are passed as pointers, so the function must be inlined for the
+// verifier to know the maps
+static __always_inline u64 get_on_cpu_perf_event(
+	void *event_reader, u32 cpu_id, void *values, u32 index)
 {
-	u64 delta, val, *prev_val;
+	u64 delta;
 	long error;
-	struct bpf_perf_event_value c = {};
+	struct bpf_perf_event_value c = {}, *prev_val;
 
-	error = bpf_perf_event_read_value(
-		&cpu_instructions_event_reader, *cpu_id, &c, sizeof(c));
+	error = bpf_perf_event_read_value(event_reader, cpu_id, &c, sizeof(c));
 	if (error)
 		return 0;
 
-	val = c.counter;
-	prev_val = bpf_map_lookup_elem(&cpu_instructions, cpu_id);
-	delta = calc_delta(prev_val, val);
-	bpf_map_update_elem(&cpu_instructions, cpu_id, &val, BPF_ANY);
+	prev_val = bpf_map_lookup_elem(values, &index);
+	delta = calc_scaled_delta(prev_val, &c);
+	bpf_map_update_elem(values, &index, &c, BPF_ANY);
 
 	return delta;
 }
 
-static inline u64 get_on_cpu_cache_miss(u32 *cpu_id)
+static inline u64 get_on_cpu_hw_counter(u32 counter, u32 cpu_id)
 {
-	u64 delta, val, *prev_val;
-	long error;
-	struct bpf_perf_event_value c = {};
+	void *event_reader;
 
-	error = bpf_perf_event_read_value(
-		&cache_miss_event_reader, *cpu_id, &c, sizeof(c));
-	if (error)
+	event_reader = bpf_map_lookup_elem(&hw_counter_event_reader, &counter);
+	if (!event_reader)
 		return 0;
-	val = c.counter;
-	prev_val = bpf_map_lookup_elem(&cache_miss, cpu_id);
-	delta = calc_delta(prev_val, val);
-	bpf_map_update_elem(&cache_miss, cpu_id, &val, BPF_ANY);
 
-	return delta;
+	return get_on_cpu_perf_event(
+		event_reader, cpu_id, &hw_counter_values,
+		cpu_id * MAX_HW_COUNTERS + counter);
 }
 
 static inline void register_new_process_if_not_exist(u32 tgid)
@@ -286,13 +383,8 @@ static inline void register_new_process_if_not_exist(u32 tgid)
 }
 
 static inline void collect_metrics_and_reset_counters(
-	struct process_metrics_t *buf, u32 prev_pid, u64 curr_ts, u32 cpu_id)
+	struct process_metrics_t *buf, u32 prev_pid, u64 curr_ts)
 {
-	if (HW) {
-		buf->cpu_cycles = get_on_cpu_cycles(&cpu_id);
-		buf->cpu_instr = get_on_cpu_instr(&cpu_id);
-		buf->cache_miss = get_on_cpu_cache_miss(&cpu_id);
-	}
 	// Get current time to calculate the previous task on-CPU time
 	buf->process_run_time = get_on_cpu_elapsed_time_us(prev_pid, curr_ts);
 }
@@ -306,24 +398,172 @@ static inline void do_page_cache_hit_increment(u32 curr_pid)
 		process_metrics->page_cache_hit++;
 }
 
+// the packets are estimated from the segment size, since the bytes are counted
+// when they are copied from and to the process
+static inline u64 segments(u64 bytes, u64 mss)
+{
+	if (mss == 0)
+		return 1;
+	return (bytes + mss - 1) / mss;
+}
+
+static inline struct process_net_metrics_t *lookup_process_net(u32 tgid)
+{
+	struct process_net_metrics_t *net;
+
+	net = bpf_map_lookup_elem(&process_net, &tgid);
+	if (!net) {
+		process_net_metrics_t new_net = {};
+
+		bpf_map_update_elem(&process_net, &tgid, &new_net, BPF_NOEXIST);
+		net = bpf_map_lookup_elem(&process_net, &tgid);
+	}
+	return net;
+}
+
+static inline void do_net_tx_increment(u32 tgid, u64 bytes, u64 mss)
+{
+	struct process_net_metrics_t *net;
+
+	net = lookup_process_net(tgid);
+	if (!net)
+		return;
+	__sync_fetch_and_add(&net->bytes_tx, bytes);
+	__sync_fetch_and_add(&net->packets_tx, segments(bytes, mss));
+}
+
+static inline void do_net_rx_increment(u32 tgid, u64 bytes, u64 mss)
+{
+	struct process_net_metrics_t *net;
+
+	net = lookup_process_net(tgid);
+	if (!net)
+		return;
+	__sync_fetch_and_add(&net->bytes_rx, bytes);
+	__sync_fetch_and_add(&net->packets_rx, segments(bytes, mss));
+}
+
+static inline void
+do_block_rq_issue(u64 rq, u32 tgid, u32 cmd_flags, u64 bytes, u64 curr_ts)
+{
+	block_request_t req = {
+		.issue_ts = curr_ts,
+		.bytes = bytes,
+		.tgid = tgid,
+		.op = cmd_flags & REQ_OP_MASK,
+	};
+
+	if (req.op != REQ_OP_READ && req.op != REQ_OP_WRITE)
+		return;
+	bpf_map_update_elem(&block_requests, &rq, &req, BPF_ANY);
+}
+
+static inline void do_block_rq_complete(u64 rq, u64 curr_ts)
+{
+	struct block_request_t *req;
+	struct process_block_metrics_t *block;
+
+	req = bpf_map_lookup_elem(&block_requests, &rq);
+	if (!req)
+		return;
+
+	block = bpf_map_lookup_elem(&process_block, &req->tgid);
+	if (!block) {
+		process_block_metrics_t new_block = {};
+
+		bpf_map_update_elem(
+			&process_block, &req->tgid, &new_block, BPF_NOEXIST);
+		block = bpf_map_lookup_elem(&process_block, &req->tgid);
+	}
+	if (block) {
+		if (req->op == REQ_OP_WRITE) {
+			__sync_fetch_and_add(&block->write_bytes, req->bytes);
+			__sync_fetch_and_add(&block->write_requests, 1);
+		} else {
+			__sync_fetch_and_add(&block->read_bytes, req->bytes);
+			__sync_fetch_and_add(&block->read_requests, 1);
+		}
+		__sync_fetch_and_add(
+			&block->service_time_us,
+			calc_delta(&req->issue_ts, curr_ts) / 1000);
+	}
+	bpf_map_delete_elem(&block_requests, &rq);
+}
+
+// the exit of the last thread of the group is the exit of the process, the
+// leader can exit before the other threads. live is the number of threads of
+// the group that did not exit, the exiting thread is already not counted.
+static inline int do_kepler_sched_process_exit_trace(
+	int live, u32 tgid, u64 start_time, u32 ppid, u64 parent_start_time)
+{
+	struct process_exit_event_t *event;
+
+	if (live > 0)
+		return 0;
+
+	event = bpf_ringbuf_reserve(&process_exit_events, sizeof(*event), 0);
+	if (!event) {
+		u32 key = 0;
+		u64 *dropped = bpf_map_lookup_elem(&process_exit_dropped, &key);
+
+		if (dropped)
+			__sync_fetch_and_add(dropped, 1);
+		return 0;
+	}
+
+	event->pid = tgid;
+	event->tgid = tgid;
+	event->start_time = start_time;
+	event->ppid = ppid;
+	event->pad = 0;
+	event->parent_start_time = parent_start_time;
+	bpf_ringbuf_submit(event, 0);
+	return 0;
+}
+
+// the counters since the last switch on the CPU are accounted to the task that
+// is switched out
+static inline int do_kepler_hw_counters_trace(u32 prev_tgid)
+{
+	u32 cpu_id = bpf_get_smp_processor_id();
+	u64 deltas[MAX_HW_COUNTERS] = {};
+	struct process_hw_counters_t *counters;
+
+	for (int i = 0; i < MAX_HW_COUNTERS && i < HW_COUNTERS; i++)
+		deltas[i] = get_on_cpu_hw_counter(i, cpu_id);
+
+	counters = bpf_map_lookup_elem(&process_hw_counters, &prev_tgid);
+	if (!counters) {
+		process_hw_counters_t new_counters = {};
+
+		bpf_map_update_elem(
+			&process_hw_counters, &prev_tgid, &new_counters,
+			BPF_NOEXIST);
+		counters = bpf_map_lookup_elem(&process_hw_counters, &prev_tgid);
+	}
+	if (!counters)
+		return 0;
+
+	for (int i = 0; i < MAX_HW_COUNTERS && i < HW_COUNTERS; i++)
+		__sync_fetch_and_add(&counters->values[i], deltas[i]);
+	return 0;
+}
+
 static inline int do_kepler_sched_switch_trace(
 	u32 prev_pid, u32 next_pid, u32 prev_tgid, u32 next_tgid)
 {
-	u32 cpu_id;
 	u64 curr_ts = bpf_ktime_get_ns();
 
 	struct process_metrics_t *curr_tgid_metrics, *prev_tgid_metrics;
 	struct process_metrics_t buf = {};
 
-	cpu_id = bpf_get_smp_processor_id();
-
 	// Skip some samples to minimize overhead
 	if (SAMPLE_RATE > 0) {
 		if (counter_sched_switch > 0) {
-			// update hardware counters to be used when sample is taken
+			// reset the on-CPU time to be used when sample is taken
 			if (counter_sched_switch == 1) {
 				collect_metrics_and_reset_counters(
-					&buf, prev_pid, curr_ts, cpu_id);
+					&buf, prev_pid, curr_ts);
 				// Add task on-cpu running start time
 				bpf_map_update_elem(
 					&pid_time_map, &next_pid, &curr_ts,
@@ -337,20 +577,14 @@ static inline int do_kepler_sched_switch_trace(
 		counter_sched_switch = SAMPLE_RATE;
 	}
 
-	collect_metrics_and_reset_counters(&buf, prev_pid, curr_ts, cpu_id);
+	collect_metrics_and_reset_counters(&buf, prev_pid, curr_ts);
 
 	// The process_run_time is 0 if we do not have the previous timestamp of
-	// the task or due to a clock issue. In either case, we skip collecting
-	// all metrics to avoid discrepancies between the hardware counter and CPU
-	// time.
+	// the task or due to a clock issue.
 	if (buf.process_run_time > 0) {
 		prev_tgid_metrics = bpf_map_lookup_elem(&processes, &prev_tgid);
-		if (prev_tgid_metrics) {
+		if (prev_tgid_metrics)
 			prev_tgid_metrics->process_run_time += buf.process_run_time;
-			prev_tgid_metrics->cpu_cycles += buf.cpu_cycles;
-			prev_tgid_metrics->cpu_instr += buf.cpu_instr;
-			prev_tgid_metrics->cache_miss += buf.cache_miss;
-		}
 	}
 
 	// create new process metrics
@@ -25,4 +25,43 @@ int test_kepler_sched_switch_trace(u64 *ctx)
 	return 0;
 }
 
+SEC("raw_tp/sched_switch")
+int test_kepler_hw_counters_trace(u64 *ctx)
+{
+	do_kepler_hw_counters_trace(42);
+
+	return 0;
+}
+
+// scales the counter delta from the previous value at index 0 of
+// hw_counter_values to the value at index 1, into the first counter of the
+// process 42
+SEC("raw_tp")
+int test_calc_scaled_delta(void *ctx)
+{
+	u32 prev_index = 0, index = 1, tgid = 42;
+	struct bpf_perf_event_value *prev_val, *val;
+	process_hw_counters_t counters = {};
+
+	prev_val = bpf_map_lookup_elem(&hw_counter_values, &prev_index);
+	val = bpf_map_lookup_elem(&hw_counter_values, &index);
+	if (!prev_val || !val)
+		return 1;
+
+	counters.values[0] = calc_scaled_delta(prev_val, val);
+	bpf_map_update_elem(&process_hw_counters, &tgid, &counters, BPF_ANY);
+	return 0;
+}
+
+// the threads of a process with three threads exit, the leader first
+SEC("raw_tp/sched_process_exit")
+int test_kepler_sched_process_exit_trace(u64 *ctx)
+{
+	do_kepler_sched_process_exit_trace(2, 42, 1000, 1, 10);
+	do_kepler_sched_process_exit_trace(1, 42, 1000, 1, 10);
+	do_kepler_sched_process_exit_trace(0, 42, 1000, 1, 10);
+
+	return 0;
+}
+
 char __license[] SEC("license") = "Dual BSD/GPL";
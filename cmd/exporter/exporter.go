@@ -18,6 +18,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
@@ -29,18 +30,24 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/sustainable-computing-io/kepler/pkg/api"
 	"github.com/sustainable-computing-io/kepler/pkg/bpf"
 	"github.com/sustainable-computing-io/kepler/pkg/build"
 	"github.com/sustainable-computing-io/kepler/pkg/config"
 	"github.com/sustainable-computing-io/kepler/pkg/manager"
+	"github.com/sustainable-computing-io/kepler/pkg/measure"
 	"github.com/sustainable-computing-io/kepler/pkg/metrics"
+	"github.com/sustainable-computing-io/kepler/pkg/metrics/otlp"
+	"github.com/sustainable-computing-io/kepler/pkg/metrics/remotewrite"
+	"github.com/sustainable-computing-io/kepler/pkg/metrics/telemetry"
+	"github.com/sustainable-computing-io/kepler/pkg/node"
 	"github.com/sustainable-computing-io/kepler/pkg/sensors/accelerator"
 	"github.com/sustainable-computing-io/kepler/pkg/sensors/components"
 	"github.com/sustainable-computing-io/kepler/pkg/sensors/platform"
-	"gopkg.in/yaml.v3"
+	"github.com/sustainable-computing-io/kepler/pkg/top"
+	"github.com/sustainable-computing-io/kepler/pkg/web"
 
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"k8s.io/klog/v2"
 )
@@ -52,18 +59,10 @@ const (
 	startedMsg   = "Started Kepler in %s"
 )
 
-type TLSConfig struct {
-	CertFile string `yaml:"cert_file"`
-	KeyFile  string `yaml:"key_file"`
-}
-
-type TLSServerConfig struct {
-	TLSConfig TLSConfig `yaml:"tls_server_config"`
-}
-
 // AppConfig holds the configuration info for the application.
 type AppConfig struct {
 	BaseDir                      string
+	ConfigFile                   string
 	Address                      string
 	MetricsPath                  string
 	EnableGPU                    bool
@@ -77,12 +76,14 @@ type AppConfig struct {
 	MachineSpecFilePath          string
 	DisablePowerMeter            bool
 	TLSFilePath                  string
+	ConfigWatchInterval          time.Duration
 }
 
 func newAppConfig() *AppConfig {
 	// Initialize flags
 	cfg := &AppConfig{}
 	flag.StringVar(&cfg.BaseDir, "config-dir", config.BaseDir, "path to config base directory")
+	flag.StringVar(&cfg.ConfigFile, "config-file", "", "path to a YAML or JSON config file, overridden by the config-dir files, env vars and explicitly set flags")
 	flag.StringVar(&cfg.Address, "address", "0.0.0.0:8888", "bind address")
 	flag.StringVar(&cfg.MetricsPath, "metrics-path", "/metrics", "metrics path")
 	flag.BoolVar(&cfg.EnableGPU, "enable-gpu", false, "whether enable gpu (need to have libnvidia-ml installed)")
@@ -95,7 +96,8 @@ func newAppConfig() *AppConfig {
 	flag.BoolVar(&cfg.ExposeEstimatedIdlePower, "expose-estimated-idle-power", false, "Whether to expose the estimated idle power as a metric")
 	flag.StringVar(&cfg.MachineSpecFilePath, "machine-spec", "", "path to the machine spec file in json format")
 	flag.BoolVar(&cfg.DisablePowerMeter, "disable-power-meter", false, "whether manually disable power meter read and forcefully apply the estimator for node powers")
-	flag.StringVar(&cfg.TLSFilePath, "web.config.file", "", "path to TLS web config file")
+	flag.StringVar(&cfg.TLSFilePath, "web.config.file", "", "path to the web config file with the TLS, client certificate, basic auth and bearer token settings, in the Prometheus exporter-toolkit format")
+	flag.DurationVar(&cfg.ConfigWatchInterval, "config-watch-interval", 30*time.Second, "interval to check the config file and config-dir for changes to reload, 0 disables it (SIGHUP always triggers a reload)")
 
 	return cfg
 }
@@ -109,12 +111,22 @@ func healthProbe(w http.ResponseWriter, req *http.Request) {
 }
 
 func main() {
+	// subcommands have their own flags
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "top":
+			os.Exit(top.Main(os.Args[2:]))
+		case "measure":
+			os.Exit(measure.Main(os.Args[2:]))
+		}
+	}
+
 	start := time.Now()
 	klog.InitFlags(nil)
 	appConfig := newAppConfig() // Initialize appConfig and define flags
 	flag.Parse()                // Parse command-line flags
 
-	if _, err := config.Initialize(appConfig.BaseDir); err != nil {
+	if _, err := config.InitializeWithConfigFile(appConfig.BaseDir, appConfig.ConfigFile); err != nil {
 		klog.Fatalf("Failed to initialize config: %v", err)
 	}
 
@@ -135,18 +147,39 @@ func main() {
 		},
 		func() float64 { return 1 },
 	))
+	// the self-metrics of the collector
+	registry.MustRegister(telemetry.Collectors()...)
 
 	platform.SetIsSystemCollectionSupported(!appConfig.DisablePowerMeter)
 	components.SetIsSystemCollectionSupported(!appConfig.DisablePowerMeter)
 
-	config.SetEnabledEBPFCgroupID(appConfig.EnableEBPFCgroupID)
-	config.SetEnabledHardwareCounterMetrics(appConfig.ExposeHardwareCounterMetrics)
-	config.SetEnabledGPU(appConfig.EnableGPU)
-	config.SetEnabledMSR(appConfig.EnableMSR)
-	config.SetEnabledIdlePower(appConfig.ExposeEstimatedIdlePower)
-
-	config.SetKubeConfig(appConfig.Kubeconfig)
-	config.SetEnableAPIServer(appConfig.ApiserverEnabled)
+	// flags only override the config sources when they are explicitly set
+	flagSet := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { flagSet[f.Name] = true })
+	if flagSet["enable-cgroup-id"] {
+		config.SetEnabledEBPFCgroupID(appConfig.EnableEBPFCgroupID)
+	} else {
+		// the configured value is still verified against the kernel and cgroup versions
+		config.SetEnabledEBPFCgroupID(config.EnabledEBPFCgroupID())
+	}
+	if flagSet["expose-hardware-counter-metrics"] {
+		config.SetEnabledHardwareCounterMetrics(appConfig.ExposeHardwareCounterMetrics)
+	}
+	if flagSet["enable-gpu"] {
+		config.SetEnabledGPU(appConfig.EnableGPU)
+	}
+	if flagSet["enable-msr"] {
+		config.SetEnabledMSR(appConfig.EnableMSR)
+	}
+	if flagSet["expose-estimated-idle-power"] {
+		config.SetEnabledIdlePower(appConfig.ExposeEstimatedIdlePower)
+	}
+	if flagSet["kubeconfig"] {
+		config.SetKubeConfig(appConfig.Kubeconfig)
+	}
+	if flagSet["apiserver"] {
+		config.SetEnableAPIServer(appConfig.ApiserverEnabled)
+	}
 
 	// set redfish credential file path
 	if appConfig.RedfishCredFilePath != "" {
@@ -186,54 +219,59 @@ func main() {
 	}
 	defer m.Stop()
 
-	// starting a CollectorManager instance to collect data and report metrics
-	if startErr := m.Start(); startErr != nil {
-		klog.Infof("%s", fmt.Sprintf("failed to start : %v", startErr))
+	if otlpConfig := config.OTLP(); otlpConfig.Endpoint != "" {
+		otlpExporter, err := otlp.NewExporter(otlpConfig, metrics.GetRegistry(), node.Name())
+		if err != nil {
+			klog.Fatalf("failed to create OTLP exporter: %v", err)
+		}
+		defer otlpExporter.Shutdown()
+		m.AddPusher("otlp", otlpExporter)
 	}
-	metricPathConfig := config.GetMetricPath(appConfig.MetricsPath)
-	bindAddressConfig := config.GetBindAddress(appConfig.Address)
-
-	var certFile, keyFile string
-	tlsConfigured := false
 
-	// Retrieve the TLS config
-	if appConfig.TLSFilePath != "" {
-		configPath := appConfig.TLSFilePath
-
-		configFile, err := os.Open(configPath)
+	if remoteWriteConfig := config.RemoteWrite(); remoteWriteConfig.URL != "" {
+		remoteWriter, err := remotewrite.NewWriter(remoteWriteConfig, metrics.GetRegistry(), node.Name())
 		if err != nil {
-			klog.Errorf("Error opening config file: %v\n", err)
+			klog.Fatalf("failed to create remote-write client: %v", err)
 		}
-		defer configFile.Close()
+		defer remoteWriter.Shutdown()
+		metrics.GetRegistry().MustRegister(remoteWriter)
+		m.AddPusher("remote-write", remoteWriter)
+	}
 
-		var tlsServerConfig TLSServerConfig
-		decoder := yaml.NewDecoder(configFile)
-		if err := decoder.Decode(&tlsServerConfig); err != nil {
-			klog.Errorf("Error parsing config file: %v\n", err)
-		}
+	// starting a CollectorManager instance to collect data and report metrics, until the collection is canceled on shutdown
+	collectCtx, stopCollection := context.WithCancel(context.Background())
+	defer stopCollection()
+	if startErr := m.Start(collectCtx); startErr != nil {
+		klog.Infof("%s", fmt.Sprintf("failed to start : %v", startErr))
+	}
+	metricPathConfig := config.GetMetricPath(appConfig.MetricsPath)
+	bindAddressConfig := config.GetBindAddress(appConfig.Address)
 
-		if tlsServerConfig.TLSConfig.CertFile != "" && tlsServerConfig.TLSConfig.KeyFile != "" {
-			certFile = tlsServerConfig.TLSConfig.CertFile
-			keyFile = tlsServerConfig.TLSConfig.KeyFile
-			tlsConfigured = true
+	// secure the server with the web config file
+	var webServer *web.Server
+	if appConfig.TLSFilePath != "" {
+		var err error
+		if webServer, err = web.NewServer(appConfig.TLSFilePath); err != nil {
+			klog.Fatalf("failed to load the web config: %v", err)
 		}
 	}
 
 	handler := http.ServeMux{}
 	reg := m.PrometheusCollector.RegisterMetrics()
-	handler.Handle(metricPathConfig, promhttp.HandlerFor(
-		reg,
-		promhttp.HandlerOpts{
-			Registry: reg,
-		},
-	))
+	handler.Handle(metricPathConfig, m.MetricsHandler(reg))
 	handler.HandleFunc("/healthz", healthProbe)
+	handler.HandleFunc("/readyz", readyzHandler(m))
+	handler.HandleFunc("/configz", configzHandler)
+	handler.Handle(api.Prefix, api.NewHandler(m.StatsCollector.Snapshot))
 	handler.HandleFunc("/", rootHandler(metricPathConfig))
 	handler.HandleFunc("/debug/pprof/", http.DefaultServeMux.ServeHTTP)
 	srv := &http.Server{
 		Addr:    bindAddressConfig,
 		Handler: &handler,
 	}
+	if webServer != nil {
+		srv.Handler = webServer.Handler(&handler)
+	}
 
 	klog.Infof("starting to listen on %s", bindAddressConfig)
 	errChan := make(chan error)
@@ -241,16 +279,32 @@ func main() {
 	signalChan := make(chan os.Signal, 1)
 	signal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM)
 
+	// reload the runtime-safe config on SIGHUP or when the config files change
+	reloadChan := make(chan os.Signal, 1)
+	signal.Notify(reloadChan, syscall.SIGHUP)
+	stopWatch := make(chan struct{})
+	defer close(stopWatch)
+	if appConfig.ConfigWatchInterval > 0 {
+		go config.WatchConfigFiles(appConfig.ConfigWatchInterval, stopWatch, func() {
+			reloadChan <- syscall.SIGHUP
+		})
+	}
+	go func() {
+		for range reloadChan {
+			klog.Infof("Reloading config")
+			if _, err := m.Reload(); err != nil {
+				klog.Errorf("failed to reload config: %v", err)
+			}
+		}
+	}()
+
 	wg := &sync.WaitGroup{}
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		if tlsConfigured {
-			// Run server in TLS mode
-			klog.Infof("Starting server with TLS")
-			err = srv.ListenAndServeTLS(certFile, keyFile)
+		if webServer != nil {
+			err = webServer.ListenAndServe(srv)
 		} else {
-			// Fall back to non-TLS mode
 			klog.Infof("Starting server without TLS")
 			err = srv.ListenAndServe()
 		}
@@ -270,6 +324,9 @@ func main() {
 		klog.Fatalf("%s", fmt.Sprintf("failed to listen and serve: %v", err))
 	case <-signalChan:
 		klog.Infof("Received shutdown signal")
+		// run the last collection cycle and flush the pushers before the eBPF programs are detached
+		stopCollection()
+		m.Wait()
 		ctx, cancel := context.WithDeadline(ctx, time.Now().Add(5*time.Second))
 		defer cancel()
 		if err := srv.Shutdown(ctx); err != nil {
@@ -278,7 +335,34 @@ func main() {
 	}
 	wg.Wait()
 	klog.Infoln(finishingMsg)
-	klog.FlushAndExit(klog.ExitFlushTimeout, 0)
+	// return instead of exiting, so that the deferred functions detach the eBPF programs and stop the power meters
+	klog.Flush()
+}
+
+// readyzHandler reports the readiness of each subsystem, with a 503 status if kepler is not ready
+func readyzHandler(m *manager.CollectorManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		readiness := m.Readiness()
+		w.Header().Set("Content-Type", "application/json")
+		if !readiness.Ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(readiness); err != nil {
+			klog.Errorf("%s", fmt.Sprintf("failed to write http response: %v", err))
+		}
+	}
+}
+
+// configzHandler returns the effective configuration with the source of each value
+func configzHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(config.EffectiveConfig()); err != nil {
+		klog.Errorf("%s", fmt.Sprintf("failed to write http response: %v", err))
+	}
 }
 
 func rootHandler(metricPathConfig string) http.HandlerFunc {
@@ -104,9 +104,17 @@ func updateNodePower(wg *sync.WaitGroup) {
 	defer wg.Done()
 
 	for i := 0; i < *sampleCount; i++ {
-		pre := components.GetAbsEnergyFromNodeComponents()
+		pre, err := components.GetAbsEnergyFromNodeComponents()
+		if err != nil {
+			fmt.Printf("failed to read the node components energy: %v\n", err)
+			return
+		}
 		time.Sleep(time.Duration(*sampleDuration) * time.Second)
-		cur := components.GetAbsEnergyFromNodeComponents()
+		cur, err := components.GetAbsEnergyFromNodeComponents()
+		if err != nil {
+			fmt.Printf("failed to read the node components energy: %v\n", err)
+			return
+		}
 		fmt.Printf("Sample %d:\n", i+1)
 		fmt.Printf("pre: %v\ncur: %v\n", pre, cur)
 		calculateNodeComponentsPower(i, pre, cur)
@@ -0,0 +1,322 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package api builds and serves JSON snapshots of the node, container, process and VM
+// energy and resource usage collected by kepler.
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sustainable-computing-io/kepler/pkg/collector/stats"
+	"github.com/sustainable-computing-io/kepler/pkg/config"
+	"github.com/sustainable-computing-io/kepler/pkg/node"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// Prefix is the path of the v1 API
+	Prefix = "/api/v1/"
+
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+// Snapshotter builds the snapshots of the collector stats. Every snapshot is built from the
+// stats published after a collection cycle, so that it reflects a single cycle without
+// holding the lock of the collector.
+type Snapshotter struct {
+	snapshot func() *stats.Snapshot
+}
+
+// NewSnapshotter creates a Snapshotter for the stats returned by snapshot, e.g. Collector.Snapshot.
+// Every snapshot is released once it is read.
+func NewSnapshotter(snapshot func() *stats.Snapshot) *Snapshotter {
+	return &Snapshotter{snapshot: snapshot}
+}
+
+// Filter selects the items by namespace and pod, and the page to return.
+// A zero Limit returns all the items.
+type Filter struct {
+	Namespace string
+	Pod       string
+	Offset    int
+	Limit     int
+}
+
+func (f Filter) matches(namespace, pod string) bool {
+	return (f.Namespace == "" || f.Namespace == namespace) && (f.Pod == "" || f.Pod == pod)
+}
+
+// page returns the bounds of the page in a list of total items
+func (f Filter) page(total int) (start, end int) {
+	start = f.Offset
+	if start > total {
+		start = total
+	}
+	end = total
+	if f.Limit > 0 && start+f.Limit < total {
+		end = start + f.Limit
+	}
+	return start, end
+}
+
+// Node returns the snapshot of the node
+func (s *Snapshotter) Node() NodeSnapshot {
+	snapshot := s.snapshot()
+	defer snapshot.Release()
+	return NodeSnapshot{
+		Timestamp:       time.Now(),
+		SamplePeriodSec: config.SamplePeriodSec(),
+		NodeName:        node.Name(),
+		Energy:          newEnergyUsage(&snapshot.NodeStats.Stats, interval(snapshot), true),
+		ResourceUsage:   newResourceUsage(&snapshot.NodeStats.Stats),
+	}
+}
+
+// Containers returns the snapshots of the containers matching the filter, sorted by container ID
+func (s *Snapshotter) Containers(f Filter) List[ContainerSnapshot] {
+	snapshot := s.snapshot()
+	defer snapshot.Release()
+	list := List[ContainerSnapshot]{
+		Timestamp:       time.Now(),
+		SamplePeriodSec: config.SamplePeriodSec(),
+		Offset:          f.Offset,
+		Limit:           f.Limit,
+		Items:           []ContainerSnapshot{},
+	}
+	ids := []string{}
+	for id, c := range snapshot.ContainerStats {
+		if f.matches(c.Namespace, c.PodName) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	list.Total = len(ids)
+	start, end := f.page(len(ids))
+	for _, id := range ids[start:end] {
+		list.Items = append(list.Items, newContainerSnapshot(snapshot.ContainerStats[id], interval(snapshot)))
+	}
+	return list
+}
+
+// Processes returns the snapshots of the processes matching the filter, sorted by PID.
+// The namespace and pod of a process are the ones of its container.
+func (s *Snapshotter) Processes(f Filter) List[ProcessSnapshot] {
+	snapshot := s.snapshot()
+	defer snapshot.Release()
+	list := List[ProcessSnapshot]{
+		Timestamp:       time.Now(),
+		SamplePeriodSec: config.SamplePeriodSec(),
+		Offset:          f.Offset,
+		Limit:           f.Limit,
+		Items:           []ProcessSnapshot{},
+	}
+	pids := []uint64{}
+	for pid, p := range snapshot.ProcessStats {
+		namespace, pod := "", ""
+		if c := container(snapshot, p); c != nil {
+			namespace, pod = c.Namespace, c.PodName
+		}
+		if f.matches(namespace, pod) {
+			pids = append(pids, pid)
+		}
+	}
+	sort.Slice(pids, func(i, j int) bool { return pids[i] < pids[j] })
+	list.Total = len(pids)
+	start, end := f.page(len(pids))
+	for _, pid := range pids[start:end] {
+		p := snapshot.ProcessStats[pid]
+		list.Items = append(list.Items, newProcessSnapshot(p, container(snapshot, p), interval(snapshot)))
+	}
+	return list
+}
+
+// Process returns the snapshot of a process, or false if it does not exist
+func (s *Snapshotter) Process(pid uint64) (Process, bool) {
+	snapshot := s.snapshot()
+	defer snapshot.Release()
+	p, exists := snapshot.ProcessStats[pid]
+	if !exists {
+		return Process{}, false
+	}
+	return Process{
+		Timestamp:       time.Now(),
+		SamplePeriodSec: config.SamplePeriodSec(),
+		ProcessSnapshot: newProcessSnapshot(p, container(snapshot, p), interval(snapshot)),
+	}, true
+}
+
+// VMs returns the snapshots of the virtual machines, sorted by VM ID
+func (s *Snapshotter) VMs(f Filter) List[VMSnapshot] {
+	snapshot := s.snapshot()
+	defer snapshot.Release()
+	list := List[VMSnapshot]{
+		Timestamp:       time.Now(),
+		SamplePeriodSec: config.SamplePeriodSec(),
+		Offset:          f.Offset,
+		Limit:           f.Limit,
+		Items:           []VMSnapshot{},
+	}
+	ids := make([]string, 0, len(snapshot.VMStats))
+	for id := range snapshot.VMStats {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	list.Total = len(ids)
+	start, end := f.page(len(ids))
+	for _, id := range ids[start:end] {
+		list.Items = append(list.Items, newVMSnapshot(snapshot.VMStats[id], interval(snapshot)))
+	}
+	return list
+}
+
+// interval returns the measured interval of the last collection cycle, or the sample period before the first cycle
+func interval(snapshot *stats.Snapshot) time.Duration {
+	if snapshot.Interval > 0 {
+		return snapshot.Interval
+	}
+	return time.Duration(config.SamplePeriodSec()) * time.Second
+}
+
+// container returns the container of a process, or nil if it does not run in a known container
+func container(snapshot *stats.Snapshot, p *stats.ProcessStats) *stats.ContainerStats {
+	if p.ContainerID == "" {
+		return nil
+	}
+	return snapshot.ContainerStats[p.ContainerID]
+}
+
+// Handler serves the snapshots as JSON.
+type Handler struct {
+	snapshotter *Snapshotter
+	mux         *http.ServeMux
+}
+
+// NewHandler creates the API handler for the stats returned by snapshot, e.g. Collector.Snapshot
+func NewHandler(snapshot func() *stats.Snapshot) *Handler {
+	h := &Handler{
+		snapshotter: NewSnapshotter(snapshot),
+		mux:         http.NewServeMux(),
+	}
+	h.mux.HandleFunc(Prefix+"node", h.node)
+	h.mux.HandleFunc(Prefix+"containers", h.containers)
+	h.mux.HandleFunc(Prefix+"processes", h.processes)
+	h.mux.HandleFunc(Prefix+"processes/", h.process)
+	h.mux.HandleFunc(Prefix+"vms", h.vms)
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	h.mux.ServeHTTP(w, r)
+}
+
+// parseFilter reads the filter from the query, the limit defaults to defaultLimit
+func parseFilter(query url.Values) (Filter, error) {
+	f := Filter{
+		Namespace: query.Get("namespace"),
+		Pod:       query.Get("pod"),
+		Limit:     defaultLimit,
+	}
+	var err error
+	if v := query.Get("offset"); v != "" {
+		if f.Offset, err = strconv.Atoi(v); err != nil || f.Offset < 0 {
+			return f, fmt.Errorf("invalid offset %q, must be a non-negative integer", v)
+		}
+	}
+	if v := query.Get("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil || f.Limit <= 0 || f.Limit > maxLimit {
+			return f, fmt.Errorf("invalid limit %q, must be between 1 and %d", v, maxLimit)
+		}
+	}
+	return f, nil
+}
+
+func (h *Handler) node(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, h.snapshotter.Node())
+}
+
+func (h *Handler) containers(w http.ResponseWriter, r *http.Request) {
+	f, err := parseFilter(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, h.snapshotter.Containers(f))
+}
+
+func (h *Handler) processes(w http.ResponseWriter, r *http.Request) {
+	f, err := parseFilter(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, h.snapshotter.Processes(f))
+}
+
+func (h *Handler) process(w http.ResponseWriter, r *http.Request) {
+	v := strings.TrimPrefix(r.URL.Path, Prefix+"processes/")
+	pid, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid pid %q", v))
+		return
+	}
+	process, exists := h.snapshotter.Process(pid)
+	if !exists {
+		writeError(w, http.StatusNotFound, fmt.Errorf("process %d not found", pid))
+		return
+	}
+	writeJSON(w, process)
+}
+
+func (h *Handler) vms(w http.ResponseWriter, r *http.Request) {
+	f, err := parseFilter(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if f.Namespace != "" || f.Pod != "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("VMs cannot be filtered by namespace or pod"))
+		return
+	}
+	writeJSON(w, h.snapshotter.VMs(f))
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		klog.Errorf("%s", fmt.Sprintf("failed to write http response: %v", err))
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(map[string]string{"error": err.Error()}); err != nil {
+		klog.Errorf("%s", fmt.Sprintf("failed to write http response: %v", err))
+	}
+}
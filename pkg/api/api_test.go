@@ -0,0 +1,152 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/sustainable-computing-io/kepler/pkg/bpf"
+	"github.com/sustainable-computing-io/kepler/pkg/collector"
+	"github.com/sustainable-computing-io/kepler/pkg/collector/stats"
+	"github.com/sustainable-computing-io/kepler/pkg/config"
+)
+
+var _ = Describe("Test API", func() {
+	var (
+		c       *collector.Collector
+		handler *Handler
+	)
+
+	BeforeEach(func() {
+		_, err := config.Initialize(".")
+		Expect(err).NotTo(HaveOccurred())
+
+		c = collector.NewCollector(bpf.NewMockExporter(bpf.DefaultSupportedMetrics()))
+		c.NodeStats = stats.CreateMockedNodeStats()
+		for i := 1; i <= 5; i++ {
+			id := fmt.Sprintf("container%d", i)
+			namespace := "ns-a"
+			if i > 3 {
+				namespace = "ns-b"
+			}
+			container := stats.NewContainerStats(id, fmt.Sprintf("pod%d", i), namespace, id)
+			container.EnergyUsage[config.DynEnergyInPkg].SetDeltaStat(stats.MockedSocketID, 6000)
+			c.ContainerStats[id] = container
+
+			process := stats.NewProcessStats(uint64(i), uint64(i), id, "", fmt.Sprintf("command%d", i))
+			process.EnergyUsage[config.DynEnergyInPkg].SetDeltaStat(stats.MockedSocketID, 3000)
+			c.ProcessStats[uint64(i)] = process
+		}
+		c.VMStats["vm1"] = stats.NewVMStats(10, "vm1")
+		c.PublishSnapshot()
+		handler = NewHandler(c.Snapshot)
+	})
+
+	get := func(path string, v interface{}) int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, http.NoBody))
+		if v != nil && rec.Code == http.StatusOK {
+			Expect(json.Unmarshal(rec.Body.Bytes(), v)).To(Succeed())
+		}
+		return rec.Code
+	}
+
+	It("Should return the node energy in joules and watts", func() {
+		var snapshot NodeSnapshot
+		Expect(get("/api/v1/node", &snapshot)).To(Equal(http.StatusOK))
+		Expect(snapshot.SamplePeriodSec).To(Equal(config.SamplePeriodSec()))
+		// the mocked node consumed 45000mJ during the last sample period, 35000mJ of them are dynamic
+		Expect(snapshot.Energy.Absolute["package"].DeltaJoules).To(Equal(45.0))
+		Expect(snapshot.Energy.Absolute["package"].Watts).To(Equal(45.0 / float64(config.SamplePeriodSec())))
+		Expect(snapshot.Energy.Dynamic["package"].DeltaJoules).To(Equal(35.0))
+		Expect(snapshot.Energy.Idle).To(HaveKey("package"))
+		Expect(snapshot.Energy.Absolute).NotTo(HaveKey("gpu"))
+	})
+
+	It("Should convert the energy to watts with the measured interval of the last cycle", func() {
+		// the update was late, the last cycle lasted 4.5 seconds
+		snapshot := stats.NewSnapshot()
+		snapshot.Update(&c.NodeStats, c.ProcessStats, c.ContainerStats, c.VMStats)
+		snapshot.Interval = 4500 * time.Millisecond
+		handler = NewHandler(func() *stats.Snapshot { return snapshot })
+
+		var node NodeSnapshot
+		Expect(get("/api/v1/node", &node)).To(Equal(http.StatusOK))
+		Expect(node.Energy.Absolute["package"].Watts).To(Equal(10.0))
+		var process Process
+		Expect(get("/api/v1/processes/1", &process)).To(Equal(http.StatusOK))
+		Expect(process.Energy.Dynamic["package"].Watts).To(BeNumerically("~", 3.0/4.5))
+	})
+
+	It("Should filter and paginate the containers", func() {
+		var list List[ContainerSnapshot]
+		Expect(get("/api/v1/containers?namespace=ns-a&limit=2&offset=1", &list)).To(Equal(http.StatusOK))
+		Expect(list.Total).To(Equal(3))
+		Expect(list.Items).To(HaveLen(2))
+		Expect(list.Items[0].ContainerID).To(Equal("container2"))
+		Expect(list.Items[1].ContainerID).To(Equal("container3"))
+		Expect(list.Items[0].Energy.Dynamic["package"].Joules).To(Equal(6.0))
+		Expect(list.Items[0].Energy.Absolute).To(BeNil())
+
+		Expect(get("/api/v1/containers?pod=pod5", &list)).To(Equal(http.StatusOK))
+		Expect(list.Total).To(Equal(1))
+		Expect(list.Items[0].Namespace).To(Equal("ns-b"))
+
+		Expect(get("/api/v1/containers?offset=10", &list)).To(Equal(http.StatusOK))
+		Expect(list.Total).To(Equal(5))
+		Expect(list.Items).To(BeEmpty())
+	})
+
+	It("Should filter the processes by the namespace of their container", func() {
+		var list List[ProcessSnapshot]
+		Expect(get("/api/v1/processes?namespace=ns-b", &list)).To(Equal(http.StatusOK))
+		Expect(list.Total).To(Equal(2))
+		Expect(list.Items[0].PID).To(Equal(uint64(4)))
+		Expect(list.Items[0].PodName).To(Equal("pod4"))
+		Expect(list.Items[0].Energy.Dynamic["package"].DeltaJoules).To(Equal(3.0))
+	})
+
+	It("Should return a single process", func() {
+		var process Process
+		Expect(get("/api/v1/processes/3", &process)).To(Equal(http.StatusOK))
+		Expect(process.Command).To(Equal("command3"))
+		Expect(process.Namespace).To(Equal("ns-a"))
+
+		Expect(get("/api/v1/processes/42", nil)).To(Equal(http.StatusNotFound))
+		Expect(get("/api/v1/processes/abc", nil)).To(Equal(http.StatusBadRequest))
+	})
+
+	It("Should return the VMs", func() {
+		var list List[VMSnapshot]
+		Expect(get("/api/v1/vms", &list)).To(Equal(http.StatusOK))
+		Expect(list.Items).To(HaveLen(1))
+		Expect(list.Items[0].VMID).To(Equal("vm1"))
+	})
+
+	It("Should reject invalid pagination", func() {
+		Expect(get("/api/v1/containers?limit=0", nil)).To(Equal(http.StatusBadRequest))
+		Expect(get(fmt.Sprintf("/api/v1/processes?limit=%d", maxLimit+1), nil)).To(Equal(http.StatusBadRequest))
+		Expect(get("/api/v1/vms?offset=-1", nil)).To(Equal(http.StatusBadRequest))
+	})
+})
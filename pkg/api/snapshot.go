@@ -0,0 +1,205 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"time"
+
+	"github.com/sustainable-computing-io/kepler/pkg/collector/stats"
+	"github.com/sustainable-computing-io/kepler/pkg/config"
+)
+
+// component maps the name of a power component in the API to its energy stats
+type component struct {
+	name string
+	abs  string
+	dyn  string
+	idle string
+}
+
+var components = []component{
+	{"package", config.AbsEnergyInPkg, config.DynEnergyInPkg, config.IdleEnergyInPkg},
+	{"core", config.AbsEnergyInCore, config.DynEnergyInCore, config.IdleEnergyInCore},
+	{"uncore", config.AbsEnergyInUnCore, config.DynEnergyInUnCore, config.IdleEnergyInUnCore},
+	{"dram", config.AbsEnergyInDRAM, config.DynEnergyInDRAM, config.IdleEnergyInDRAM},
+	{"gpu", config.AbsEnergyInGPU, config.DynEnergyInGPU, config.IdleEnergyInGPU},
+	{"other", config.AbsEnergyInOther, config.DynEnergyInOther, config.IdleEnergyInOther},
+	{"platform", config.AbsEnergyInPlatform, config.DynEnergyInPlatform, config.IdleEnergyInPlatform},
+}
+
+// Energy is the energy consumed by a power component
+type Energy struct {
+	// Joules is the energy consumed since kepler started
+	Joules float64 `json:"joules"`
+	// DeltaJoules is the energy consumed during the last sample period
+	DeltaJoules float64 `json:"delta_joules"`
+	// Watts is the average power during the last sample period
+	Watts float64 `json:"watts"`
+}
+
+// EnergyUsage holds the energy of each power component, e.g. package, dram or platform
+type EnergyUsage struct {
+	// Absolute is the energy measured on the node, it is only reported for the node
+	Absolute map[string]Energy `json:"absolute,omitempty"`
+	Dynamic  map[string]Energy `json:"dynamic"`
+	Idle     map[string]Energy `json:"idle"`
+}
+
+// NodeSnapshot is the energy and resource usage of the node
+type NodeSnapshot struct {
+	Timestamp       time.Time         `json:"timestamp"`
+	SamplePeriodSec uint64            `json:"sample_period_sec"`
+	NodeName        string            `json:"node_name"`
+	Energy          EnergyUsage       `json:"energy"`
+	ResourceUsage   map[string]uint64 `json:"resource_usage"`
+}
+
+// ContainerSnapshot is the energy and resource usage of a container
+type ContainerSnapshot struct {
+	ContainerID   string            `json:"container_id"`
+	ContainerName string            `json:"container_name"`
+	PodName       string            `json:"pod_name"`
+	Namespace     string            `json:"namespace"`
+	Energy        EnergyUsage       `json:"energy"`
+	ResourceUsage map[string]uint64 `json:"resource_usage"`
+}
+
+// ProcessSnapshot is the energy and resource usage of a process
+type ProcessSnapshot struct {
+	PID           uint64            `json:"pid"`
+	Command       string            `json:"command"`
+	ContainerID   string            `json:"container_id,omitempty"`
+	PodName       string            `json:"pod_name,omitempty"`
+	Namespace     string            `json:"namespace,omitempty"`
+	VMID          string            `json:"vm_id,omitempty"`
+	Energy        EnergyUsage       `json:"energy"`
+	ResourceUsage map[string]uint64 `json:"resource_usage"`
+}
+
+// VMSnapshot is the energy and resource usage of a virtual machine
+type VMSnapshot struct {
+	VMID          string            `json:"vm_id"`
+	PID           uint64            `json:"pid"`
+	Energy        EnergyUsage       `json:"energy"`
+	ResourceUsage map[string]uint64 `json:"resource_usage"`
+}
+
+// List is a page of snapshots
+type List[T any] struct {
+	Timestamp       time.Time `json:"timestamp"`
+	SamplePeriodSec uint64    `json:"sample_period_sec"`
+	// Total is the number of items matching the filters, before pagination
+	Total  int `json:"total"`
+	Offset int `json:"offset"`
+	Limit  int `json:"limit"`
+	Items  []T `json:"items"`
+}
+
+// Process is a single process snapshot
+type Process struct {
+	Timestamp       time.Time `json:"timestamp"`
+	SamplePeriodSec uint64    `json:"sample_period_sec"`
+	ProcessSnapshot
+}
+
+// newEnergyUsage converts the energy stats, in millijoules, to joules and watts. The watts are the
+// energy of the last collection cycle divided by its measured interval. The absolute energy is
+// only reported when withAbsolute is set.
+func newEnergyUsage(s *stats.Stats, interval time.Duration, withAbsolute bool) EnergyUsage {
+	usage := EnergyUsage{
+		Dynamic: map[string]Energy{},
+		Idle:    map[string]Energy{},
+	}
+	if withAbsolute {
+		usage.Absolute = map[string]Energy{}
+	}
+	for _, c := range components {
+		if withAbsolute {
+			if e, ok := newEnergy(s, c.abs, interval); ok {
+				usage.Absolute[c.name] = e
+			}
+		}
+		if e, ok := newEnergy(s, c.dyn, interval); ok {
+			usage.Dynamic[c.name] = e
+		}
+		if e, ok := newEnergy(s, c.idle, interval); ok {
+			usage.Idle[c.name] = e
+		}
+	}
+	return usage
+}
+
+// newEnergy returns false if the component has no energy stats, e.g. the node has no GPU
+func newEnergy(s *stats.Stats, metric string, interval time.Duration) (Energy, bool) {
+	collection, exists := s.EnergyUsage[metric]
+	if !exists || len(collection) == 0 {
+		return Energy{}, false
+	}
+	deltaJoules := float64(collection.SumAllDeltaValues()) / 1000
+	e := Energy{
+		Joules:      float64(collection.SumAllAggrValues()) / 1000,
+		DeltaJoules: deltaJoules,
+	}
+	if interval > 0 {
+		e.Watts = deltaJoules / interval.Seconds()
+	}
+	return e, true
+}
+
+func newResourceUsage(s *stats.Stats) map[string]uint64 {
+	usage := make(map[string]uint64, len(s.ResourceUsage))
+	for metric, collection := range s.ResourceUsage {
+		usage[metric] = collection.SumAllDeltaValues()
+	}
+	return usage
+}
+
+func newContainerSnapshot(c *stats.ContainerStats, interval time.Duration) ContainerSnapshot {
+	return ContainerSnapshot{
+		ContainerID:   c.ContainerID,
+		ContainerName: c.ContainerName,
+		PodName:       c.PodName,
+		Namespace:     c.Namespace,
+		Energy:        newEnergyUsage(&c.Stats, interval, false),
+		ResourceUsage: newResourceUsage(&c.Stats),
+	}
+}
+
+func newProcessSnapshot(p *stats.ProcessStats, container *stats.ContainerStats, interval time.Duration) ProcessSnapshot {
+	snapshot := ProcessSnapshot{
+		PID:           p.PID,
+		Command:       p.Command,
+		ContainerID:   p.ContainerID,
+		VMID:          p.VMID,
+		Energy:        newEnergyUsage(&p.Stats, interval, false),
+		ResourceUsage: newResourceUsage(&p.Stats),
+	}
+	if container != nil {
+		snapshot.PodName = container.PodName
+		snapshot.Namespace = container.Namespace
+	}
+	return snapshot
+}
+
+func newVMSnapshot(vm *stats.VMStats, interval time.Duration) VMSnapshot {
+	return VMSnapshot{
+		VMID:          vm.VMID,
+		PID:           vm.PID,
+		Energy:        newEnergyUsage(&vm.Stats, interval, false),
+		ResourceUsage: newResourceUsage(&vm.Stats),
+	}
+}
@@ -0,0 +1,318 @@
+//go:build !darwin
+// +build !darwin
+
+package bpf
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"reflect"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+	"github.com/cilium/ebpf/rlimit"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.org/x/sys/unix"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/sustainable-computing-io/kepler/pkg/config"
+)
+
+var _ = Describe("Hardware counter events", func() {
+	It("opens the named counters as generic hardware events", func() {
+		event, err := hardwareCounterEvent(config.StalledCycleBackend)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(event).To(Equal(perfEvent{unix.PERF_TYPE_HARDWARE, unix.PERF_COUNT_HW_STALLED_CYCLES_BACKEND}))
+	})
+
+	It("opens the reference cycles apart from the cycles", func() {
+		event, err := hardwareCounterEvent(config.CPURefCycle)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(event).To(Equal(perfEvent{unix.PERF_TYPE_HARDWARE, unix.PERF_COUNT_HW_REF_CPU_CYCLES}))
+	})
+
+	It("opens the raw PMU event codes", func() {
+		event, err := hardwareCounterEvent("raw_0x11")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(event).To(Equal(perfEvent{unix.PERF_TYPE_RAW, 0x11}))
+
+		_, err = hardwareCounterEvent("raw_0xzz")
+		Expect(err).To(HaveOccurred())
+		_, err = hardwareCounterEvent("llc_misses")
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("Kernel version", func() {
+	It("compares the version of the running kernel", func() {
+		var utsname unix.Utsname
+		Expect(unix.Uname(&utsname)).To(Succeed())
+		var major, minor int
+		_, err := fmt.Sscanf(unix.ByteSliceToString(utsname.Release[:]), "%d.%d", &major, &minor)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(kernelVersionAtLeast(major, minor)).To(BeTrue())
+		Expect(kernelVersionAtLeast(major-1, minor+1)).To(BeTrue())
+		Expect(kernelVersionAtLeast(major, minor+1)).To(BeFalse())
+		Expect(kernelVersionAtLeast(major+1, 0)).To(BeFalse())
+	})
+})
+
+var _ = Describe("Hardware counter multiplexing", func() {
+	It("reports the share of the time the counters were running", func() {
+		// a software event is never multiplexed
+		fds, err := unixOpenPerfEvent(unix.PERF_TYPE_SOFTWARE, unix.PERF_COUNT_SW_CPU_CLOCK, 1)
+		if err != nil {
+			Skip("perf events are not supported: " + err.Error())
+		}
+		e := &exporter{
+			hwCounters:      []string{config.CPUCycle, ""},
+			hwCounterEvents: [][]int{fds, nil},
+			perfEventTimes:  map[int]perfEventTimes{},
+		}
+		defer e.detachHardwareCounters()
+
+		ratios, err := e.CollectMultiplexingRatios()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ratios).To(Equal(map[string]float64{config.CPUCycle: 1}))
+		Expect(e.perfEventTimes).To(HaveKey(fds[0]))
+	})
+})
+
+// objectNames returns the names of the programs and maps assigned to the fields of obj
+func objectNames(obj interface{}) (programs, maps []string) {
+	t := reflect.TypeOf(obj).Elem()
+	for i := 0; i < t.NumField(); i++ {
+		name := t.Field(i).Tag.Get("ebpf")
+		if t.Field(i).Type.Elem().Name() == "Program" {
+			programs = append(programs, name)
+		} else {
+			maps = append(maps, name)
+		}
+	}
+	return programs, maps
+}
+
+var _ = Describe("eBPF objects", func() {
+	BeforeEach(func() {
+		_, err := config.Initialize(".")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("embeds the programs and maps of the optional objects", func() {
+		specs, err := loadSpecs(1)
+		Expect(err).NotTo(HaveOccurred())
+		for _, obj := range []interface{}{&processExitObjects{}, &netObjects{}, &blockObjects{}, &hwCounterObjects{}} {
+			programs, maps := objectNames(obj)
+			for _, name := range programs {
+				Expect(specs.Programs).To(HaveKey(name))
+			}
+			for _, name := range maps {
+				Expect(specs.Maps).To(HaveKey(name))
+			}
+		}
+		Expect(specs.Maps).To(HaveKey(processNetMap))
+		Expect(specs.Maps).To(HaveKey(processBlockMap))
+		Expect(specs.Maps).To(HaveKey(hwCounterEventMap))
+	})
+
+	It("pushes the exit events of the processes", func() {
+		Expect(rlimit.RemoveMemlock()).To(Succeed())
+		specs, err := loadSpecs(getCPUCores())
+		Expect(err).NotTo(HaveOccurred())
+		e := &exporter{}
+		defer e.detachProcessExit()
+		if err := e.attachProcessExit(specs); err != nil {
+			Skip("the eBPF programs cannot be loaded: " + err.Error())
+		}
+
+		cmd := exec.Command("true")
+		Expect(cmd.Run()).To(Succeed())
+		pid := uint32(cmd.Process.Pid)
+
+		var received []ProcessExit
+		Eventually(func() []ProcessExit {
+			exits, err := e.CollectProcessExits()
+			Expect(err).NotTo(HaveOccurred())
+			received = append(received, exits...)
+			return received
+		}).Should(ContainElement(And(
+			HaveField("Tgid", pid),
+			HaveField("Ppid", uint32(os.Getpid())),
+			HaveField("ParentStartTime", Not(BeZero())),
+		)))
+		Expect(e.CollectDroppedProcessExits()).To(BeZero())
+	})
+
+	It("keeps the enabled and running times of the counters to scale the multiplexed counters", func() {
+		specs, err := loadSpecs(1)
+		Expect(err).NotTo(HaveOccurred())
+		// the values are struct bpf_perf_event_value, the counter followed by the enabled and running times
+		Expect(specs.Maps).To(HaveKey("hw_counter_values"))
+		Expect(specs.Maps["hw_counter_values"].ValueSize).To(Equal(uint32(24)))
+	})
+
+	It("traces the exits of the tcp functions that run in the context of the socket owner", func() {
+		specs, err := loadSpecs(1)
+		Expect(err).NotTo(HaveOccurred())
+		for program, function := range map[string]string{
+			"kepler_tcp_sendmsg_trace":      "tcp_sendmsg",
+			"kepler_tcp_cleanup_rbuf_trace": "tcp_cleanup_rbuf",
+		} {
+			Expect(specs.Programs).To(HaveKey(program))
+			Expect(specs.Programs[program].AttachType).To(Equal(ebpf.AttachTraceFExit))
+			Expect(specs.Programs[program].AttachTo).To(Equal(function))
+		}
+	})
+
+	It("counts the tcp bytes of the socket owner", func() {
+		Expect(rlimit.RemoveMemlock()).To(Succeed())
+		specs, err := loadSpecs(getCPUCores())
+		Expect(err).NotTo(HaveOccurred())
+		e := &exporter{}
+		defer e.detachNet()
+		if err := e.attachNet(specs); err != nil {
+			Skip("the eBPF programs cannot be loaded: " + err.Error())
+		}
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		defer listener.Close()
+		client, err := net.Dial("tcp", listener.Addr().String())
+		Expect(err).NotTo(HaveOccurred())
+		defer client.Close()
+		server, err := listener.Accept()
+		Expect(err).NotTo(HaveOccurred())
+		defer server.Close()
+		_, err = client.Write(make([]byte, 1000))
+		Expect(err).NotTo(HaveOccurred())
+		_, err = io.ReadFull(server, make([]byte, 1000))
+		Expect(err).NotTo(HaveOccurred())
+
+		metrics := map[uint32]processNetMetrics{}
+		Expect(collectPerProcess(e.netObjects.ProcessNet, metrics)).To(Succeed())
+		Expect(metrics).To(HaveKey(uint32(os.Getpid())))
+		Expect(metrics[uint32(os.Getpid())].BytesTx).To(BeNumerically(">=", 1000))
+		Expect(metrics[uint32(os.Getpid())].BytesRx).To(BeNumerically(">=", 1000))
+	})
+
+	It("counts the block I/O of the issuing process", func() {
+		Expect(rlimit.RemoveMemlock()).To(Succeed())
+		specs, err := loadSpecs(getCPUCores())
+		Expect(err).NotTo(HaveOccurred())
+		e := &exporter{}
+		defer e.detachBlock()
+		if err := e.attachBlock(specs); err != nil {
+			Skip("the eBPF programs cannot be loaded: " + err.Error())
+		}
+
+		// the dirty pages are written back in the context of the process that syncs the file
+		f, err := os.CreateTemp(GinkgoT().TempDir(), "block")
+		Expect(err).NotTo(HaveOccurred())
+		defer f.Close()
+		_, err = f.Write(make([]byte, 1<<20))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(f.Sync()).To(Succeed())
+
+		metrics := map[uint32]processBlockMetrics{}
+		Expect(collectPerProcess(e.blockObjects.ProcessBlock, metrics)).To(Succeed())
+		Expect(metrics).To(HaveKey(uint32(os.Getpid())))
+		Expect(metrics[uint32(os.Getpid())].WriteBytes).To(BeNumerically(">=", 1<<20))
+		Expect(metrics[uint32(os.Getpid())].WriteRequests).To(BeNumerically(">", 0))
+	})
+
+	It("attaches the configurable hardware counters", func() {
+		Expect(rlimit.RemoveMemlock()).To(Succeed())
+		specs, err := loadSpecs(getCPUCores())
+		Expect(err).NotTo(HaveOccurred())
+		e := &exporter{enabledHardwareCounters: sets.New[string]()}
+		defer e.detachHardwareCounters()
+		if err := e.attachHardwareCounters(specs, getCPUCores()); err != nil {
+			Skip("the eBPF programs cannot be loaded: " + err.Error())
+		}
+		Expect(e.hwCountersLink).NotTo(BeNil())
+		Expect(e.hwCounters).To(HaveLen(len(config.HardwareCounters())))
+		// the counters that the node does not support are not collected
+		for i, counter := range e.hwCounters {
+			if counter == "" {
+				Expect(e.hwCounterEvents[i]).To(BeNil())
+				continue
+			}
+			Expect(e.hwCounterEvents[i]).To(HaveLen(getCPUCores()))
+			Expect(e.enabledHardwareCounters.Has(counter)).To(BeTrue())
+		}
+	})
+
+	It("holds the events of every counter on every CPU", func() {
+		Expect(rlimit.RemoveMemlock()).To(Succeed())
+		numCPU := getCPUCores()
+		specs, err := loadSpecs(numCPU)
+		Expect(err).NotTo(HaveOccurred())
+		e := &exporter{}
+		defer e.detachHardwareCounters()
+		if err := specs.LoadAndAssign(&e.hwCounterObjects, nil); err != nil {
+			Skip("the eBPF programs cannot be loaded: " + err.Error())
+		}
+		fds, err := unixOpenPerfEvent(unix.PERF_TYPE_SOFTWARE, unix.PERF_COUNT_SW_CPU_CLOCK, numCPU)
+		if err != nil {
+			Skip("perf events are not supported: " + err.Error())
+		}
+		e.hwCounterEvents = [][]int{fds}
+		// the perf event arrays have at most an entry per CPU
+		Expect(e.setHardwareCounterEvents(specs.Maps[hwCounterEventMap].InnerMap, config.MaxHardwareCounters-1, fds)).To(Succeed())
+	})
+
+	It("accounts the cycles and the reference cycles of the processes independently", func() {
+		Expect(rlimit.RemoveMemlock()).To(Succeed())
+		numCPU := getCPUCores()
+		specs, err := loadSpecs(numCPU)
+		Expect(err).NotTo(HaveOccurred())
+		e := &exporter{}
+		defer e.detachHardwareCounters()
+		if err := specs.LoadAndAssign(&e.hwCounterObjects, nil); err != nil {
+			Skip("the eBPF programs cannot be loaded: " + err.Error())
+		}
+
+		// the nodes without a PMU have no hardware events, software events are opened at the indexes of the counters
+		e.hwCounters = []string{config.CPUCycle, config.CPURefCycle}
+		for i, event := range []int{unix.PERF_COUNT_SW_CPU_CLOCK, unix.PERF_COUNT_SW_CONTEXT_SWITCHES} {
+			fds, err := unixOpenPerfEvent(unix.PERF_TYPE_SOFTWARE, event, numCPU)
+			if err != nil {
+				Skip("perf events are not supported: " + err.Error())
+			}
+			e.hwCounterEvents = append(e.hwCounterEvents, fds)
+			Expect(e.setHardwareCounterEvents(specs.Maps[hwCounterEventMap].InnerMap, i, fds)).To(Succeed())
+		}
+		e.hwCountersLink, err = link.AttachTracing(link.TracingOptions{
+			Program:    e.hwCounterObjects.Program,
+			AttachType: ebpf.AttachTraceRawTp,
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		// the counters are accounted to the process when it is switched out
+		for i := 0; i < 10; i++ {
+			time.Sleep(time.Millisecond)
+		}
+
+		hw := map[uint32]processHardwareCounters{}
+		Expect(collectPerProcess(e.hwCounterObjects.ProcessCounters, hw)).To(Succeed())
+		metrics := mergeProcessMetrics(nil, nil, nil, hw, e.hwCounters)
+		var counters map[string]uint64
+		for _, m := range metrics {
+			if m.Pid == uint64(os.Getpid()) {
+				counters = m.HardwareCounters
+			}
+		}
+		Expect(counters).To(HaveKey(config.CPUCycle))
+		Expect(counters).To(HaveKey(config.CPURefCycle))
+		Expect(counters[config.CPUCycle]).To(BeNumerically(">", 0))
+		Expect(counters[config.CPURefCycle]).To(BeNumerically(">", 0))
+		// the clock counts nanoseconds and the context switches count the switches
+		Expect(counters[config.CPUCycle]).To(BeNumerically(">", counters[config.CPURefCycle]))
+	})
+})
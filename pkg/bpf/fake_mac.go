@@ -20,6 +20,8 @@ limitations under the License.
 package bpf
 
 import (
+	"bytes"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"os"
@@ -28,6 +30,7 @@ import (
 
 	"github.com/cilium/ebpf"
 	"github.com/cilium/ebpf/link"
+	"github.com/cilium/ebpf/ringbuf"
 	"github.com/cilium/ebpf/rlimit"
 	"github.com/jaypipes/ghw"
 	"github.com/sustainable-computing-io/kepler/pkg/config"
@@ -36,15 +39,148 @@ import (
 	"k8s.io/klog/v2"
 )
 
+const (
+	processNetMap     = "process_net"
+	processBlockMap   = "process_block"
+	hwCounterEventMap = "hw_counter_event_reader"
+
+	// the block_rq_issue tracepoint passes the request as its first argument since Linux 5.11,
+	// the earlier kernels pass the request queue first
+	blockRequestMinKernelMajor = 5
+	blockRequestMinKernelMinor = 11
+)
+
+// processExitObjects are loaded apart from the kepler objects, so that the other tracepoints
+// are attached with objects that were built without the sched_process_exit tracepoint
+type processExitObjects struct {
+	Program *ebpf.Program `ebpf:"kepler_sched_process_exit_trace"`
+	Events  *ebpf.Map     `ebpf:"process_exit_events"`
+	Dropped *ebpf.Map     `ebpf:"process_exit_dropped"`
+}
+
+func (o *processExitObjects) close() {
+	if o.Program != nil {
+		o.Program.Close()
+		o.Program = nil
+	}
+	if o.Events != nil {
+		o.Events.Close()
+		o.Events = nil
+	}
+	if o.Dropped != nil {
+		o.Dropped.Close()
+		o.Dropped = nil
+	}
+}
+
+// netObjects count the network traffic of the processes, they are loaded apart from the kepler objects like processExitObjects
+type netObjects struct {
+	SendmsgProgram     *ebpf.Program `ebpf:"kepler_tcp_sendmsg_trace"`
+	CleanupRbufProgram *ebpf.Program `ebpf:"kepler_tcp_cleanup_rbuf_trace"`
+	ProcessNet         *ebpf.Map     `ebpf:"process_net"`
+}
+
+func (o *netObjects) close() {
+	if o.SendmsgProgram != nil {
+		o.SendmsgProgram.Close()
+		o.SendmsgProgram = nil
+	}
+	if o.CleanupRbufProgram != nil {
+		o.CleanupRbufProgram.Close()
+		o.CleanupRbufProgram = nil
+	}
+	if o.ProcessNet != nil {
+		o.ProcessNet.Close()
+		o.ProcessNet = nil
+	}
+}
+
+// blockObjects count the block I/O of the processes, they are loaded apart from the kepler objects like processExitObjects
+type blockObjects struct {
+	IssueProgram    *ebpf.Program `ebpf:"kepler_block_rq_issue_trace"`
+	CompleteProgram *ebpf.Program `ebpf:"kepler_block_rq_complete_trace"`
+	ProcessBlock    *ebpf.Map     `ebpf:"process_block"`
+	BlockRequests   *ebpf.Map     `ebpf:"block_requests"`
+}
+
+func (o *blockObjects) close() {
+	if o.IssueProgram != nil {
+		o.IssueProgram.Close()
+		o.IssueProgram = nil
+	}
+	if o.CompleteProgram != nil {
+		o.CompleteProgram.Close()
+		o.CompleteProgram = nil
+	}
+	if o.ProcessBlock != nil {
+		o.ProcessBlock.Close()
+		o.ProcessBlock = nil
+	}
+	if o.BlockRequests != nil {
+		o.BlockRequests.Close()
+		o.BlockRequests = nil
+	}
+}
+
+// hwCounterObjects count the configured hardware counters of the processes, they are loaded apart from the kepler
+// objects like processExitObjects
+type hwCounterObjects struct {
+	Program         *ebpf.Program `ebpf:"kepler_hw_counters_trace"`
+	EventReader     *ebpf.Map     `ebpf:"hw_counter_event_reader"`
+	Values          *ebpf.Map     `ebpf:"hw_counter_values"`
+	ProcessCounters *ebpf.Map     `ebpf:"process_hw_counters"`
+}
+
+func (o *hwCounterObjects) close() {
+	if o.Program != nil {
+		o.Program.Close()
+		o.Program = nil
+	}
+	if o.EventReader != nil {
+		o.EventReader.Close()
+		o.EventReader = nil
+	}
+	if o.Values != nil {
+		o.Values.Close()
+		o.Values = nil
+	}
+	if o.ProcessCounters != nil {
+		o.ProcessCounters.Close()
+		o.ProcessCounters = nil
+	}
+}
+
 type exporter struct {
-	bpfObjects keplerObjects
+	bpfObjects         keplerObjects
+	processExitObjects processExitObjects
+	netObjects         netObjects
+	blockObjects       blockObjects
+	hwCounterObjects   hwCounterObjects
 
 	schedSwitchLink link.Link
+	processExitLink link.Link
 	irqLink         link.Link
 	pageWriteLink   link.Link
 	pageReadLink    link.Link
-
-	perfEvents *hardwarePerfEvents
+	tcpSendmsgLink  link.Link
+	tcpRbufLink     link.Link
+	blockIssueLink  link.Link
+	blockDoneLink   link.Link
+	hwCountersLink  link.Link
+
+	processExitReader *ringbuf.Reader
+	// processExitsDropped is the number of dropped exit events at the previous read
+	processExitsDropped uint64
+
+	// hwCounterEvents are the perf events of the configured hardware counters on each CPU, and hwCounters
+	// are the names of the counters by index, with an empty name if the counter could not be opened
+	hwCounterEvents [][]int
+	hwCounters      []string
+	// hwCounterEventArrays are the perf event arrays of the counters in hw_counter_event_reader, which are kept
+	// open since the kernel clears a perf event array when its last file descriptor is closed
+	hwCounterEventArrays []*ebpf.Map
+	// perfEventTimes are the enabled and running times of the perf events at the previous multiplexing read
+	perfEventTimes map[int]perfEventTimes
 
 	enabledHardwareCounters sets.Set[string]
 	enabledSoftwareCounters sets.Set[string]
@@ -52,8 +188,9 @@ type exporter struct {
 
 func NewExporter() (Exporter, error) {
 	e := &exporter{
-		enabledHardwareCounters: sets.New[string](config.BPFHwCounters()...),
+		enabledHardwareCounters: sets.New[string](),
 		enabledSoftwareCounters: sets.New[string](config.BPFSwCounters()...),
+		perfEventTimes:          map[int]perfEventTimes{},
 	}
 	err := e.attach()
 	if err != nil {
@@ -75,28 +212,11 @@ func (e *exporter) attach() error {
 		return fmt.Errorf("error removing memlock: %v", err)
 	}
 
-	// Load eBPF Specs
-	specs, err := loadKepler()
-	if err != nil {
-		return fmt.Errorf("error loading eBPF specs: %v", err)
-	}
-
-	// Adjust map sizes to the number of available CPUs
 	numCPU := getCPUCores()
 	klog.Infof("Number of CPUs: %d", numCPU)
-	for _, m := range specs.Maps {
-		// Only resize maps that have a MaxEntries of NUM_CPUS constant
-		if m.MaxEntries == 128 {
-			m.MaxEntries = uint32(numCPU)
-		}
-	}
-
-	// Set program global variables
-	err = specs.RewriteConstants(map[string]interface{}{
-		"SAMPLE_RATE": int32(config.GetBPFSampleRate()),
-	})
+	specs, err := loadSpecs(numCPU)
 	if err != nil {
-		return fmt.Errorf("error rewriting program constants: %v", err)
+		return err
 	}
 
 	// Load the eBPF program(s)
@@ -113,6 +233,11 @@ func (e *exporter) attach() error {
 		return fmt.Errorf("error attaching sched_switch tracepoint: %v", err)
 	}
 
+	if err := e.attachProcessExit(specs); err != nil {
+		klog.Warningf("failed to attach sched_process_exit tracepoint: %v. Kepler will probe the idle processes to remove the processes that exited.", err)
+		e.detachProcessExit()
+	}
+
 	if config.ExposeIRQCounterMetrics() {
 		e.irqLink, err = link.AttachTracing(link.TracingOptions{
 			Program:    e.bpfObjects.KeplerIrqTrace,
@@ -123,6 +248,16 @@ func (e *exporter) attach() error {
 		}
 	}
 
+	if config.ExposeBlockIOCounterMetrics() {
+		if err := e.attachBlock(specs); err != nil {
+			klog.Warningf("failed to attach block_rq_issue and block_rq_complete: %v. Kepler will not collect the block I/O of the processes.", err)
+			e.detachBlock()
+		} else {
+			e.enabledSoftwareCounters.Insert(config.BlockReadBytes, config.BlockWriteBytes,
+				config.BlockReadRequests, config.BlockWriteRequests, config.BlockServiceTime)
+		}
+	}
+
 	group := "writeback"
 	name := "writeback_dirty_page"
 	if _, err := os.Stat(config.SysDir() + "/kernel/debug/tracing/events/writeback/writeback_dirty_folio"); err == nil {
@@ -143,25 +278,245 @@ func (e *exporter) attach() error {
 		klog.Warningf("failed to attach fentry/mark_page_accessed: %v. Kepler will not collect page cache read events. This will affect the DRAM power model estimation on VMs.", err)
 	}
 
+	if err := e.attachNet(specs); err != nil {
+		klog.Warningf("failed to attach fexit/tcp_sendmsg and fexit/tcp_cleanup_rbuf: %v. Kepler will not collect the network traffic of the processes.", err)
+		e.detachNet()
+	} else {
+		e.enabledSoftwareCounters.Insert(config.BytesTx, config.BytesRx, config.PacketsTx, config.PacketsRx)
+	}
+
 	// Return early if hardware counters are not enabled
 	if !config.ExposeHardwareCounterMetrics() {
 		klog.Infof("Hardware counter metrics are disabled")
 		return nil
 	}
 
-	e.perfEvents, err = createHardwarePerfEvents(
-		e.bpfObjects.CpuInstructionsEventReader,
-		e.bpfObjects.CpuCyclesEventReader,
-		e.bpfObjects.CacheMissEventReader,
-		numCPU,
-	)
+	if err := e.attachHardwareCounters(specs, numCPU); err != nil {
+		klog.Warningf("failed to attach the hardware counters: %v. Kepler will not collect the hardware counters of the processes.", err)
+		e.detachHardwareCounters()
+	}
+	return nil
+}
+
+// loadSpecs loads the embedded eBPF specs with the map sizes and the program global variables of the node
+func loadSpecs(numCPU int) (*ebpf.CollectionSpec, error) {
+	specs, err := loadKepler()
 	if err != nil {
-		return nil
+		return nil, fmt.Errorf("error loading eBPF specs: %v", err)
+	}
+
+	// Adjust map sizes to the number of available CPUs
+	for _, m := range specs.Maps {
+		// Only resize maps that have a MaxEntries of NUM_CPUS constant
+		if m.MaxEntries == 128 {
+			m.MaxEntries = uint32(numCPU)
+		}
+		// and maps that have an entry per CPU for each hardware counter
+		if m.MaxEntries == 128*config.MaxHardwareCounters {
+			m.MaxEntries = uint32(numCPU * config.MaxHardwareCounters)
+		}
+		// and the perf event arrays of the hardware counters
+		if m.InnerMap != nil && m.InnerMap.MaxEntries == 128 {
+			m.InnerMap.MaxEntries = uint32(numCPU)
+		}
+	}
+
+	// Set program global variables
+	constants := map[string]interface{}{
+		"SAMPLE_RATE": int32(config.GetBPFSampleRate()),
+		"HW_COUNTERS": int32(len(config.HardwareCounters())),
 	}
+	if err := specs.RewriteConstants(constants); err != nil {
+		return nil, fmt.Errorf("error rewriting program constants: %v", err)
+	}
+	return specs, nil
+}
 
+// attachProcessExit attaches the sched_process_exit tracepoint, which pushes the exit events into a ring buffer
+func (e *exporter) attachProcessExit(specs *ebpf.CollectionSpec) error {
+	if err := specs.LoadAndAssign(&e.processExitObjects, nil); err != nil {
+		return fmt.Errorf("error loading eBPF objects: %v", err)
+	}
+	var err error
+	e.processExitLink, err = link.AttachTracing(link.TracingOptions{
+		Program:    e.processExitObjects.Program,
+		AttachType: ebpf.AttachTraceRawTp,
+	})
+	if err != nil {
+		return err
+	}
+	e.processExitReader, err = ringbuf.NewReader(e.processExitObjects.Events)
+	if err != nil {
+		return fmt.Errorf("error opening the process exit ring buffer: %v", err)
+	}
 	return nil
 }
 
+func (e *exporter) detachProcessExit() {
+	if e.processExitReader != nil {
+		e.processExitReader.Close()
+		e.processExitReader = nil
+	}
+	if e.processExitLink != nil {
+		e.processExitLink.Close()
+		e.processExitLink = nil
+	}
+	e.processExitObjects.close()
+}
+
+// attachNet attaches the tcp fexits, which count the bytes and packets sent and received by the process that owns the socket
+func (e *exporter) attachNet(specs *ebpf.CollectionSpec) error {
+	if _, exists := specs.Maps[processNetMap]; !exists {
+		return fmt.Errorf("the eBPF objects do not include %s", processNetMap)
+	}
+	if err := specs.LoadAndAssign(&e.netObjects, nil); err != nil {
+		return fmt.Errorf("error loading eBPF objects: %v", err)
+	}
+	var err error
+	e.tcpSendmsgLink, err = link.AttachTracing(link.TracingOptions{
+		Program:    e.netObjects.SendmsgProgram,
+		AttachType: ebpf.AttachTraceFExit,
+	})
+	if err != nil {
+		return err
+	}
+	e.tcpRbufLink, err = link.AttachTracing(link.TracingOptions{
+		Program:    e.netObjects.CleanupRbufProgram,
+		AttachType: ebpf.AttachTraceFExit,
+	})
+	return err
+}
+
+func (e *exporter) detachNet() {
+	if e.tcpSendmsgLink != nil {
+		e.tcpSendmsgLink.Close()
+		e.tcpSendmsgLink = nil
+	}
+	if e.tcpRbufLink != nil {
+		e.tcpRbufLink.Close()
+		e.tcpRbufLink = nil
+	}
+	e.netObjects.close()
+}
+
+// attachBlock attaches the block request tracepoints, which count the block I/O of the process that issued the requests.
+// The buffered writes that are written back by the flusher threads are counted to the kworker, not to the process that
+// wrote them, only the direct and synced writes are counted to the writing process.
+func (e *exporter) attachBlock(specs *ebpf.CollectionSpec) error {
+	if !kernelVersionAtLeast(blockRequestMinKernelMajor, blockRequestMinKernelMinor) {
+		return fmt.Errorf("the block request tracepoints require Linux %d.%d or later",
+			blockRequestMinKernelMajor, blockRequestMinKernelMinor)
+	}
+	if _, exists := specs.Maps[processBlockMap]; !exists {
+		return fmt.Errorf("the eBPF objects do not include %s", processBlockMap)
+	}
+	if err := specs.LoadAndAssign(&e.blockObjects, nil); err != nil {
+		return fmt.Errorf("error loading eBPF objects: %v", err)
+	}
+	var err error
+	e.blockIssueLink, err = link.AttachTracing(link.TracingOptions{
+		Program:    e.blockObjects.IssueProgram,
+		AttachType: ebpf.AttachTraceRawTp,
+	})
+	if err != nil {
+		return err
+	}
+	e.blockDoneLink, err = link.AttachTracing(link.TracingOptions{
+		Program:    e.blockObjects.CompleteProgram,
+		AttachType: ebpf.AttachTraceRawTp,
+	})
+	return err
+}
+
+func (e *exporter) detachBlock() {
+	if e.blockIssueLink != nil {
+		e.blockIssueLink.Close()
+		e.blockIssueLink = nil
+	}
+	if e.blockDoneLink != nil {
+		e.blockDoneLink.Close()
+		e.blockDoneLink = nil
+	}
+	e.blockObjects.close()
+}
+
+// attachHardwareCounters opens the configured hardware counters on every CPU and attaches the sched_switch
+// tracepoint that accounts them to the processes. The counters that cannot be opened are not collected.
+func (e *exporter) attachHardwareCounters(specs *ebpf.CollectionSpec, numCPU int) error {
+	if err := specs.LoadAndAssign(&e.hwCounterObjects, nil); err != nil {
+		return fmt.Errorf("error loading eBPF objects: %v", err)
+	}
+	counters := config.HardwareCounters()
+	e.hwCounters = make([]string, len(counters))
+	e.hwCounterEvents = make([][]int, len(counters))
+	for i, counter := range counters {
+		fds, err := openHardwareCounter(counter, numCPU)
+		if err != nil {
+			klog.Warningf("failed to open the hardware counter %s: %v", counter, err)
+			continue
+		}
+		e.hwCounterEvents[i] = fds
+		if err := e.setHardwareCounterEvents(specs.Maps[hwCounterEventMap].InnerMap, i, fds); err != nil {
+			return err
+		}
+		e.hwCounters[i] = counter
+	}
+	var err error
+	e.hwCountersLink, err = link.AttachTracing(link.TracingOptions{
+		Program:    e.hwCounterObjects.Program,
+		AttachType: ebpf.AttachTraceRawTp,
+	})
+	if err != nil {
+		return err
+	}
+	for _, counter := range e.hwCounters {
+		if counter != "" {
+			e.enabledHardwareCounters.Insert(counter)
+		}
+	}
+	return nil
+}
+
+// setHardwareCounterEvents puts the perf events of the counter at index i on each CPU into a perf event array,
+// which is the entry i of hw_counter_event_reader, since a perf event array has at most an entry per CPU
+func (e *exporter) setHardwareCounterEvents(spec *ebpf.MapSpec, i int, fds []int) error {
+	events, err := ebpf.NewMap(spec)
+	if err != nil {
+		return fmt.Errorf("failed to create the perf event array of the counter %d: %v", i, err)
+	}
+	e.hwCounterEventArrays = append(e.hwCounterEventArrays, events)
+	for cpu, fd := range fds {
+		if err := events.Update(uint32(cpu), uint32(fd), ebpf.UpdateAny); err != nil {
+			return fmt.Errorf("failed to update the perf event array of the counter %d: %v", i, err)
+		}
+	}
+	if err := e.hwCounterObjects.EventReader.Update(uint32(i), events, ebpf.UpdateAny); err != nil {
+		return fmt.Errorf("failed to update %s map: %v", hwCounterEventMap, err)
+	}
+	return nil
+}
+
+func (e *exporter) detachHardwareCounters() {
+	if e.hwCountersLink != nil {
+		e.hwCountersLink.Close()
+		e.hwCountersLink = nil
+	}
+	for _, events := range e.hwCounterEventArrays {
+		events.Close()
+	}
+	e.hwCounterEventArrays = nil
+	for _, fds := range e.hwCounterEvents {
+		for _, fd := range fds {
+			delete(e.perfEventTimes, fd)
+		}
+		unixClosePerfEvents(fds)
+	}
+	e.hwCounterEvents = nil
+	e.hwCounters = nil
+	e.hwCounterObjects.close()
+	e.enabledHardwareCounters = sets.New[string]()
+}
+
 func (e *exporter) Detach() {
 	// Links
 	if e.schedSwitchLink != nil {
@@ -169,6 +524,11 @@ func (e *exporter) Detach() {
 		e.schedSwitchLink = nil
 	}
 
+	e.detachProcessExit()
+	e.detachNet()
+	e.detachBlock()
+	e.detachHardwareCounters()
+
 	if e.irqLink != nil {
 		e.irqLink.Close()
 		e.irqLink = nil
@@ -184,10 +544,6 @@ func (e *exporter) Detach() {
 		e.pageReadLink = nil
 	}
 
-	// Perf events
-	e.perfEvents.close()
-	e.perfEvents = nil
-
 	// Objects
 	e.bpfObjects.Close()
 }
@@ -198,7 +554,7 @@ func (e *exporter) CollectProcesses() ([]ProcessMetrics, error) {
 	maxEntries := e.bpfObjects.Processes.MaxEntries()
 	total := 0
 	deleteKeys := make([]uint32, maxEntries)
-	deleteValues := make([]ProcessMetrics, maxEntries)
+	deleteValues := make([]keplerProcessMetricsT, maxEntries)
 	var cursor ebpf.MapBatchCursor
 	for {
 		count, err := e.bpfObjects.Processes.BatchLookupAndDelete(
@@ -215,8 +571,129 @@ func (e *exporter) CollectProcesses() ([]ProcessMetrics, error) {
 			return nil, fmt.Errorf("failed to batch lookup and delete: %v", err)
 		}
 	}
+	net := map[uint32]processNetMetrics{}
+	if err := collectPerProcess(e.netObjects.ProcessNet, net); err != nil {
+		return nil, fmt.Errorf("failed to collect the network metrics: %v", err)
+	}
+	block := map[uint32]processBlockMetrics{}
+	if err := collectPerProcess(e.blockObjects.ProcessBlock, block); err != nil {
+		return nil, fmt.Errorf("failed to collect the block I/O metrics: %v", err)
+	}
+	var hw map[uint32]processHardwareCounters
+	if e.hwCounters != nil {
+		hw = map[uint32]processHardwareCounters{}
+		if err := collectPerProcess(e.hwCounterObjects.ProcessCounters, hw); err != nil {
+			return nil, fmt.Errorf("failed to collect the hardware counters: %v", err)
+		}
+	}
 	klog.V(5).Infof("collected %d process samples in %v", total, time.Since(start))
-	return deleteValues[:total], nil
+	return mergeProcessMetrics(deleteValues[:total], net, block, hw, e.hwCounters), nil
+}
+
+// collectPerProcess reads and deletes the values of a map keyed by the process ID, if the map is loaded
+func collectPerProcess[V any](m *ebpf.Map, values map[uint32]V) error {
+	if m == nil {
+		return nil
+	}
+	maxEntries := m.MaxEntries()
+	total := 0
+	keys := make([]uint32, maxEntries)
+	batch := make([]V, maxEntries)
+	var cursor ebpf.MapBatchCursor
+	for {
+		count, err := m.BatchLookupAndDelete(
+			&cursor,
+			keys[total:],
+			batch[total:],
+			&ebpf.BatchOptions{},
+		)
+		total += count
+		if errors.Is(err, ebpf.ErrKeyNotExist) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to batch lookup and delete: %v", err)
+		}
+	}
+	for i := 0; i < total; i++ {
+		values[keys[i]] = batch[i]
+	}
+	return nil
+}
+
+func (e *exporter) CollectProcessExits() ([]ProcessExit, error) {
+	if e.processExitReader == nil {
+		return nil, ErrProcessExitsNotSupported
+	}
+	// read the events in the ring buffer without waiting for new events
+	e.processExitReader.SetDeadline(time.Now())
+	exits := []ProcessExit{}
+	var record ringbuf.Record
+	for {
+		err := e.processExitReader.ReadInto(&record)
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return exits, nil
+		}
+		if err != nil {
+			return exits, fmt.Errorf("failed to read the process exit events: %v", err)
+		}
+		var exit ProcessExit
+		if err := binary.Read(bytes.NewReader(record.RawSample), binary.NativeEndian, &exit); err != nil {
+			klog.V(5).Infof("failed to decode a process exit event: %v", err)
+			continue
+		}
+		exits = append(exits, exit)
+	}
+}
+
+// CollectDroppedProcessExits returns the number of exit events that were dropped since the previous call,
+// because the ring buffer was full
+func (e *exporter) CollectDroppedProcessExits() uint64 {
+	if e.processExitObjects.Dropped == nil {
+		return 0
+	}
+	var total uint64
+	if err := e.processExitObjects.Dropped.Lookup(uint32(0), &total); err != nil {
+		klog.V(5).Infof("failed to read the number of dropped process exit events: %v", err)
+		return 0
+	}
+	dropped := total - e.processExitsDropped
+	e.processExitsDropped = total
+	return dropped
+}
+
+// CollectMultiplexingRatios returns the share of the time each hardware counter was running while it was enabled
+// since the previous call, over all CPUs. The counters that are not collected are not returned.
+func (e *exporter) CollectMultiplexingRatios() (map[string]float64, error) {
+	ratios := map[string]float64{}
+	for counter, fds := range e.openedHardwareCounters() {
+		var enabled, running uint64
+		for _, fd := range fds {
+			times, err := readPerfEventTimes(fd)
+			if err != nil {
+				return ratios, fmt.Errorf("failed to read the perf event of %s: %v", counter, err)
+			}
+			prev := e.perfEventTimes[fd]
+			enabled += times.enabled - prev.enabled
+			running += times.running - prev.running
+			e.perfEventTimes[fd] = times
+		}
+		if enabled > 0 {
+			ratios[counter] = float64(running) / float64(enabled)
+		}
+	}
+	return ratios, nil
+}
+
+// openedHardwareCounters returns the perf events of the hardware counters that are collected
+func (e *exporter) openedHardwareCounters() map[string][]int {
+	events := map[string][]int{}
+	for i, counter := range e.hwCounters {
+		if counter != "" {
+			events[counter] = e.hwCounterEvents[i]
+		}
+	}
+	return events
 }
 
 ///////////////////////////////////////////////////////////////////////////
@@ -226,6 +703,16 @@ func unixOpenPerfEvent(typ, conf, cpuCores int) ([]int, error) {
 	return []int{}, nil
 }
 
+// perfEventTimes are the times in nanoseconds that a perf event was enabled and running
+type perfEventTimes struct {
+	enabled uint64
+	running uint64
+}
+
+func readPerfEventTimes(fd int) (perfEventTimes, error) {
+	return perfEventTimes{}, nil
+}
+
 func unixClosePerfEvents(fds []int) {
 	for _, fd := range fds {
 		_ = unix.SetNonblock(fd, true)
@@ -233,6 +720,22 @@ func unixClosePerfEvents(fds []int) {
 	}
 }
 
+// kernelVersionAtLeast returns true if the version of the running kernel is at least major.minor
+func kernelVersionAtLeast(major, minor int) bool {
+	var utsname unix.Utsname
+	if err := unix.Uname(&utsname); err != nil {
+		klog.V(5).Infof("failed to read the kernel version: %v", err)
+		return false
+	}
+	release := unix.ByteSliceToString(utsname.Release[:])
+	var kernelMajor, kernelMinor int
+	if _, err := fmt.Sscanf(release, "%d.%d", &kernelMajor, &kernelMinor); err != nil {
+		klog.V(5).Infof("failed to parse the kernel version %q: %v", release, err)
+		return false
+	}
+	return kernelMajor > major || (kernelMajor == major && kernelMinor >= minor)
+}
+
 func getCPUCores() int {
 	cores := runtime.NumCPU()
 	if cpu, err := ghw.CPU(); err == nil {
@@ -243,51 +746,10 @@ func getCPUCores() int {
 	return cores
 }
 
-type hardwarePerfEvents struct {
-	cpuCyclesPerfEvents       []int
-	cpuInstructionsPerfEvents []int
-	cacheMissPerfEvents       []int
-}
-
-func (h *hardwarePerfEvents) close() {
-	unixClosePerfEvents(h.cpuCyclesPerfEvents)
-	unixClosePerfEvents(h.cpuInstructionsPerfEvents)
-	unixClosePerfEvents(h.cacheMissPerfEvents)
-}
-
-// CreateHardwarePerfEvents creates perf events for CPU cycles, CPU instructions, and cache misses
-// and updates the corresponding eBPF maps.
-func createHardwarePerfEvents(cpuInstructionsMap, cpuCyclesMap, cacheMissMap *ebpf.Map, numCPU int) (*hardwarePerfEvents, error) {
-	var err error
-	events := &hardwarePerfEvents{
-		cpuCyclesPerfEvents:       []int{},
-		cpuInstructionsPerfEvents: []int{},
-		cacheMissPerfEvents:       []int{},
-	}
-	defer func() {
-		if err != nil {
-			unixClosePerfEvents(events.cpuCyclesPerfEvents)
-			unixClosePerfEvents(events.cpuInstructionsPerfEvents)
-			unixClosePerfEvents(events.cacheMissPerfEvents)
-		}
-	}()
-	for i, fd := range events.cpuCyclesPerfEvents {
-		if err = cpuCyclesMap.Update(uint32(i), uint32(fd), ebpf.UpdateAny); err != nil {
-			klog.Warningf("Failed to update cpu_cycles_event_reader map: %v", err)
-			return nil, err
-		}
-	}
-	for i, fd := range events.cpuInstructionsPerfEvents {
-		if err = cpuInstructionsMap.Update(uint32(i), uint32(fd), ebpf.UpdateAny); err != nil {
-			klog.Warningf("Failed to update cpu_instructions_event_reader map: %v", err)
-			return nil, err
-		}
-	}
-	for i, fd := range events.cacheMissPerfEvents {
-		if err = cacheMissMap.Update(uint32(i), uint32(fd), ebpf.UpdateAny); err != nil {
-			klog.Warningf("Failed to update cache_miss_event_reader map: %v", err)
-			return nil, err
-		}
+// openHardwareCounter opens the perf event of a hardware counter on every CPU
+func openHardwareCounter(counter string, numCPU int) ([]int, error) {
+	if !config.IsKnownHardwareCounter(counter) {
+		return nil, fmt.Errorf("unknown hardware counter %q", counter)
 	}
-	return events, nil
+	return unixOpenPerfEvent(0, 0, numCPU)
 }
@@ -12,19 +12,46 @@ import (
 	"github.com/cilium/ebpf"
 )
 
+type keplerBlockRequestT struct {
+	IssueTs uint64
+	Bytes   uint64
+	Tgid    uint32
+	Op      uint32
+}
+
+type keplerBpfPerfEventValue struct {
+	Counter uint64
+	Enabled uint64
+	Running uint64
+}
+
+type keplerProcessBlockMetricsT struct {
+	ReadBytes     uint64
+	WriteBytes    uint64
+	ReadRequests  uint64
+	WriteRequests uint64
+	ServiceTimeUs uint64
+}
+
+type keplerProcessHwCountersT struct{ Values [8]uint64 }
+
 type keplerProcessMetricsT struct {
 	CgroupId       uint64
 	Pid            uint64
 	ProcessRunTime uint64
-	CpuCycles      uint64
-	CpuInstr       uint64
-	CacheMiss      uint64
 	PageCacheHit   uint64
 	VecNr          [10]uint16
 	Comm           [16]int8
 	_              [4]byte
 }
 
+type keplerProcessNetMetricsT struct {
+	BytesTx   uint64
+	BytesRx   uint64
+	PacketsTx uint64
+	PacketsRx uint64
+}
+
 // loadKepler returns the embedded CollectionSpec for kepler.
 func loadKepler() (*ebpf.CollectionSpec, error) {
 	reader := bytes.NewReader(_KeplerBytes)
@@ -66,24 +93,32 @@ type keplerSpecs struct {
 //
 // It can be passed ebpf.CollectionSpec.Assign.
 type keplerProgramSpecs struct {
-	KeplerIrqTrace         *ebpf.ProgramSpec `ebpf:"kepler_irq_trace"`
-	KeplerReadPageTrace    *ebpf.ProgramSpec `ebpf:"kepler_read_page_trace"`
-	KeplerSchedSwitchTrace *ebpf.ProgramSpec `ebpf:"kepler_sched_switch_trace"`
-	KeplerWritePageTrace   *ebpf.ProgramSpec `ebpf:"kepler_write_page_trace"`
+	KeplerBlockRqCompleteTrace  *ebpf.ProgramSpec `ebpf:"kepler_block_rq_complete_trace"`
+	KeplerBlockRqIssueTrace     *ebpf.ProgramSpec `ebpf:"kepler_block_rq_issue_trace"`
+	KeplerHwCountersTrace       *ebpf.ProgramSpec `ebpf:"kepler_hw_counters_trace"`
+	KeplerIrqTrace              *ebpf.ProgramSpec `ebpf:"kepler_irq_trace"`
+	KeplerReadPageTrace         *ebpf.ProgramSpec `ebpf:"kepler_read_page_trace"`
+	KeplerSchedProcessExitTrace *ebpf.ProgramSpec `ebpf:"kepler_sched_process_exit_trace"`
+	KeplerSchedSwitchTrace      *ebpf.ProgramSpec `ebpf:"kepler_sched_switch_trace"`
+	KeplerTcpCleanupRbufTrace   *ebpf.ProgramSpec `ebpf:"kepler_tcp_cleanup_rbuf_trace"`
+	KeplerTcpSendmsgTrace       *ebpf.ProgramSpec `ebpf:"kepler_tcp_sendmsg_trace"`
+	KeplerWritePageTrace        *ebpf.ProgramSpec `ebpf:"kepler_write_page_trace"`
 }
 
 // keplerMapSpecs contains maps before they are loaded into the kernel.
 //
 // It can be passed ebpf.CollectionSpec.Assign.
 type keplerMapSpecs struct {
-	CacheMiss                  *ebpf.MapSpec `ebpf:"cache_miss"`
-	CacheMissEventReader       *ebpf.MapSpec `ebpf:"cache_miss_event_reader"`
-	CpuCycles                  *ebpf.MapSpec `ebpf:"cpu_cycles"`
-	CpuCyclesEventReader       *ebpf.MapSpec `ebpf:"cpu_cycles_event_reader"`
-	CpuInstructions            *ebpf.MapSpec `ebpf:"cpu_instructions"`
-	CpuInstructionsEventReader *ebpf.MapSpec `ebpf:"cpu_instructions_event_reader"`
-	PidTimeMap                 *ebpf.MapSpec `ebpf:"pid_time_map"`
-	Processes                  *ebpf.MapSpec `ebpf:"processes"`
+	BlockRequests        *ebpf.MapSpec `ebpf:"block_requests"`
+	HwCounterEventReader *ebpf.MapSpec `ebpf:"hw_counter_event_reader"`
+	HwCounterValues      *ebpf.MapSpec `ebpf:"hw_counter_values"`
+	PidTimeMap           *ebpf.MapSpec `ebpf:"pid_time_map"`
+	ProcessBlock         *ebpf.MapSpec `ebpf:"process_block"`
+	ProcessExitDropped   *ebpf.MapSpec `ebpf:"process_exit_dropped"`
+	ProcessExitEvents    *ebpf.MapSpec `ebpf:"process_exit_events"`
+	ProcessHwCounters    *ebpf.MapSpec `ebpf:"process_hw_counters"`
+	ProcessNet           *ebpf.MapSpec `ebpf:"process_net"`
+	Processes            *ebpf.MapSpec `ebpf:"processes"`
 }
 
 // keplerObjects contains all objects after they have been loaded into the kernel.
@@ -105,25 +140,29 @@ func (o *keplerObjects) Close() error {
 //
 // It can be passed to loadKeplerObjects or ebpf.CollectionSpec.LoadAndAssign.
 type keplerMaps struct {
-	CacheMiss                  *ebpf.Map `ebpf:"cache_miss"`
-	CacheMissEventReader       *ebpf.Map `ebpf:"cache_miss_event_reader"`
-	CpuCycles                  *ebpf.Map `ebpf:"cpu_cycles"`
-	CpuCyclesEventReader       *ebpf.Map `ebpf:"cpu_cycles_event_reader"`
-	CpuInstructions            *ebpf.Map `ebpf:"cpu_instructions"`
-	CpuInstructionsEventReader *ebpf.Map `ebpf:"cpu_instructions_event_reader"`
-	PidTimeMap                 *ebpf.Map `ebpf:"pid_time_map"`
-	Processes                  *ebpf.Map `ebpf:"processes"`
+	BlockRequests        *ebpf.Map `ebpf:"block_requests"`
+	HwCounterEventReader *ebpf.Map `ebpf:"hw_counter_event_reader"`
+	HwCounterValues      *ebpf.Map `ebpf:"hw_counter_values"`
+	PidTimeMap           *ebpf.Map `ebpf:"pid_time_map"`
+	ProcessBlock         *ebpf.Map `ebpf:"process_block"`
+	ProcessExitDropped   *ebpf.Map `ebpf:"process_exit_dropped"`
+	ProcessExitEvents    *ebpf.Map `ebpf:"process_exit_events"`
+	ProcessHwCounters    *ebpf.Map `ebpf:"process_hw_counters"`
+	ProcessNet           *ebpf.Map `ebpf:"process_net"`
+	Processes            *ebpf.Map `ebpf:"processes"`
 }
 
 func (m *keplerMaps) Close() error {
 	return _KeplerClose(
-		m.CacheMiss,
-		m.CacheMissEventReader,
-		m.CpuCycles,
-		m.CpuCyclesEventReader,
-		m.CpuInstructions,
-		m.CpuInstructionsEventReader,
+		m.BlockRequests,
+		m.HwCounterEventReader,
+		m.HwCounterValues,
 		m.PidTimeMap,
+		m.ProcessBlock,
+		m.ProcessExitDropped,
+		m.ProcessExitEvents,
+		m.ProcessHwCounters,
+		m.ProcessNet,
 		m.Processes,
 	)
 }
@@ -132,17 +171,29 @@ func (m *keplerMaps) Close() error {
 //
 // It can be passed to loadKeplerObjects or ebpf.CollectionSpec.LoadAndAssign.
 type keplerPrograms struct {
-	KeplerIrqTrace         *ebpf.Program `ebpf:"kepler_irq_trace"`
-	KeplerReadPageTrace    *ebpf.Program `ebpf:"kepler_read_page_trace"`
-	KeplerSchedSwitchTrace *ebpf.Program `ebpf:"kepler_sched_switch_trace"`
-	KeplerWritePageTrace   *ebpf.Program `ebpf:"kepler_write_page_trace"`
+	KeplerBlockRqCompleteTrace  *ebpf.Program `ebpf:"kepler_block_rq_complete_trace"`
+	KeplerBlockRqIssueTrace     *ebpf.Program `ebpf:"kepler_block_rq_issue_trace"`
+	KeplerHwCountersTrace       *ebpf.Program `ebpf:"kepler_hw_counters_trace"`
+	KeplerIrqTrace              *ebpf.Program `ebpf:"kepler_irq_trace"`
+	KeplerReadPageTrace         *ebpf.Program `ebpf:"kepler_read_page_trace"`
+	KeplerSchedProcessExitTrace *ebpf.Program `ebpf:"kepler_sched_process_exit_trace"`
+	KeplerSchedSwitchTrace      *ebpf.Program `ebpf:"kepler_sched_switch_trace"`
+	KeplerTcpCleanupRbufTrace   *ebpf.Program `ebpf:"kepler_tcp_cleanup_rbuf_trace"`
+	KeplerTcpSendmsgTrace       *ebpf.Program `ebpf:"kepler_tcp_sendmsg_trace"`
+	KeplerWritePageTrace        *ebpf.Program `ebpf:"kepler_write_page_trace"`
 }
 
 func (p *keplerPrograms) Close() error {
 	return _KeplerClose(
+		p.KeplerBlockRqCompleteTrace,
+		p.KeplerBlockRqIssueTrace,
+		p.KeplerHwCountersTrace,
 		p.KeplerIrqTrace,
 		p.KeplerReadPageTrace,
+		p.KeplerSchedProcessExitTrace,
 		p.KeplerSchedSwitchTrace,
+		p.KeplerTcpCleanupRbufTrace,
+		p.KeplerTcpSendmsgTrace,
 		p.KeplerWritePageTrace,
 	)
 }
@@ -8,6 +8,7 @@ import (
 type mockExporter struct {
 	softwareCounters sets.Set[string]
 	hardwareCounters sets.Set[string]
+	processes        []ProcessMetrics
 }
 
 func DefaultSupportedMetrics() SupportedMetrics {
@@ -33,6 +34,13 @@ func NewMockExporter(bpfSupportedMetrics SupportedMetrics) Exporter {
 	}
 }
 
+// NewMockExporterWithProcesses returns a mock exporter that collects the given processes
+func NewMockExporterWithProcesses(bpfSupportedMetrics SupportedMetrics, processes []ProcessMetrics) Exporter {
+	m := NewMockExporter(bpfSupportedMetrics).(*mockExporter)
+	m.processes = processes
+	return m
+}
+
 func (m *mockExporter) SupportedMetrics() SupportedMetrics {
 	return SupportedMetrics{
 		HardwareCounters: m.hardwareCounters,
@@ -43,17 +51,40 @@ func (m *mockExporter) SupportedMetrics() SupportedMetrics {
 func (m *mockExporter) Detach() {}
 
 func (m *mockExporter) CollectProcesses() ([]ProcessMetrics, error) {
+	if m.processes != nil {
+		return m.processes, nil
+	}
+	hardwareCounters := map[string]uint64{}
+	for counter := range m.hardwareCounters {
+		hardwareCounters[counter] = 0
+	}
 	return []ProcessMetrics{
 		{
-			CgroupId:       0,
-			Pid:            0,
-			ProcessRunTime: 0,
-			CpuCycles:      0,
-			CpuInstr:       0,
-			CacheMiss:      0,
-			PageCacheHit:   0,
-			VecNr:          [10]uint16{},
-			Comm:           [16]int8{},
+			keplerProcessMetricsT: keplerProcessMetricsT{
+				CgroupId:       0,
+				Pid:            0,
+				ProcessRunTime: 0,
+				PageCacheHit:   0,
+				VecNr:          [10]uint16{},
+				Comm:           [16]int8{},
+			},
+			HardwareCounters: hardwareCounters,
 		},
 	}, nil
 }
+
+func (m *mockExporter) CollectProcessExits() ([]ProcessExit, error) {
+	return []ProcessExit{}, nil
+}
+
+func (m *mockExporter) CollectDroppedProcessExits() uint64 {
+	return 0
+}
+
+func (m *mockExporter) CollectMultiplexingRatios() (map[string]float64, error) {
+	ratios := map[string]float64{}
+	for counter := range m.hardwareCounters {
+		ratios[counter] = 1
+	}
+	return ratios, nil
+}
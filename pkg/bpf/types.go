@@ -17,6 +17,9 @@ limitations under the License.
 package bpf
 
 import (
+	"errors"
+
+	"github.com/sustainable-computing-io/kepler/pkg/config"
 	"k8s.io/apimachinery/pkg/util/sets"
 )
 
@@ -30,12 +33,118 @@ const (
 	IRQBlock = 4
 )
 
-type ProcessMetrics = keplerProcessMetricsT
+// ProcessMetrics are the metrics of a process in the processes map, with the network traffic of the
+// sockets that the process owns in the process_net map, the block I/O that the process issued in
+// the process_block map and the configured hardware counters in the process_hw_counters map
+type ProcessMetrics struct {
+	keplerProcessMetricsT
+	// HardwareCounters are the values of the hardware counters by counter name
+	HardwareCounters map[string]uint64
+
+	BytesTx   uint64
+	BytesRx   uint64
+	PacketsTx uint64
+	PacketsRx uint64
+
+	BlockReadBytes uint64
+	// BlockWriteBytes counts the direct and synced writes of the process, the buffered writes that the
+	// flusher threads write back are counted to the kworker
+	BlockWriteBytes    uint64
+	BlockReadRequests  uint64
+	BlockWriteRequests uint64
+	// BlockServiceTime is the time from the issue to the completion of the requests in microseconds
+	BlockServiceTime uint64
+}
+
+// processNetMetrics matches process_net_metrics_t
+type processNetMetrics struct {
+	BytesTx   uint64
+	BytesRx   uint64
+	PacketsTx uint64
+	PacketsRx uint64
+}
+
+// processBlockMetrics matches process_block_metrics_t
+type processBlockMetrics struct {
+	ReadBytes     uint64
+	WriteBytes    uint64
+	ReadRequests  uint64
+	WriteRequests uint64
+	ServiceTimeUs uint64
+}
+
+// processHardwareCounters matches process_hw_counters_t, the value at index i is the value of the
+// counter opened at index i
+type processHardwareCounters [config.MaxHardwareCounters]uint64
+
+// mergeProcessMetrics adds the network and block I/O metrics and the hardware counters to the metrics of
+// the processes, a process that is not in the processes map is added with its PID. The hardware counters
+// are named by hwCounters.
+func mergeProcessMetrics(processes []keplerProcessMetricsT, net map[uint32]processNetMetrics, block map[uint32]processBlockMetrics,
+	hw map[uint32]processHardwareCounters, hwCounters []string) []ProcessMetrics {
+	metrics := make([]ProcessMetrics, 0, len(processes))
+	index := make(map[uint32]int, len(processes))
+	add := func(p keplerProcessMetricsT) {
+		index[uint32(p.Pid)] = len(metrics)
+		metrics = append(metrics, ProcessMetrics{keplerProcessMetricsT: p, HardwareCounters: map[string]uint64{}})
+	}
+	for i := range processes {
+		add(processes[i])
+	}
+	process := func(pid uint32) *ProcessMetrics {
+		if _, exists := index[pid]; !exists {
+			add(keplerProcessMetricsT{Pid: uint64(pid)})
+		}
+		return &metrics[index[pid]]
+	}
+	for pid, n := range net {
+		m := process(pid)
+		m.BytesTx, m.BytesRx, m.PacketsTx, m.PacketsRx = n.BytesTx, n.BytesRx, n.PacketsTx, n.PacketsRx
+	}
+	for pid, b := range block {
+		m := process(pid)
+		m.BlockReadBytes, m.BlockWriteBytes = b.ReadBytes, b.WriteBytes
+		m.BlockReadRequests, m.BlockWriteRequests = b.ReadRequests, b.WriteRequests
+		m.BlockServiceTime = b.ServiceTimeUs
+	}
+	for pid, values := range hw {
+		m := process(pid)
+		for i, counter := range hwCounters {
+			// the counters that were not opened are not named
+			if counter != "" {
+				m.HardwareCounters[counter] = values[i]
+			}
+		}
+	}
+	return metrics
+}
+
+// ProcessExit is the exit event of a process, it matches process_exit_event_t.
+// The start times are the times since boot in nanoseconds. The parent of an orphan
+// is the process it was reparented to.
+type ProcessExit struct {
+	Pid             uint32
+	Tgid            uint32
+	StartTime       uint64
+	Ppid            uint32
+	_               uint32
+	ParentStartTime uint64
+}
+
+// ErrProcessExitsNotSupported is returned when the sched_process_exit tracepoint is not attached
+var ErrProcessExitsNotSupported = errors.New("the process exit events are not supported")
 
 type Exporter interface {
 	SupportedMetrics() SupportedMetrics
 	Detach()
 	CollectProcesses() ([]ProcessMetrics, error)
+	// CollectProcessExits returns the processes that exited since the previous call
+	CollectProcessExits() ([]ProcessExit, error)
+	// CollectDroppedProcessExits returns the number of exit events that were dropped since the previous call
+	CollectDroppedProcessExits() uint64
+	// CollectMultiplexingRatios returns the share of the time each hardware counter was counting since the previous
+	// call, the counter values are scaled up when the ratio is below 1
+	CollectMultiplexingRatios() (map[string]float64, error)
 }
 
 type SupportedMetrics struct {
@@ -0,0 +1,55 @@
+package bpf
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/sustainable-computing-io/kepler/pkg/config"
+)
+
+var _ = Describe("Process network and block I/O metrics", func() {
+	It("adds the network and block I/O metrics to the process with the same PID", func() {
+		processes := []keplerProcessMetricsT{{Pid: 10, CgroupId: 5, ProcessRunTime: 100}, {Pid: 11}}
+		net := map[uint32]processNetMetrics{
+			10: {BytesTx: 3000, BytesRx: 1500, PacketsTx: 3, PacketsRx: 2},
+			12: {BytesRx: 100, PacketsRx: 1},
+		}
+
+		block := map[uint32]processBlockMetrics{
+			11: {ReadBytes: 4096, ReadRequests: 1, ServiceTimeUs: 250},
+			12: {WriteBytes: 8192, WriteRequests: 2},
+		}
+
+		metrics := mergeProcessMetrics(processes, net, block, nil, nil)
+		Expect(metrics).To(HaveLen(3))
+		Expect(metrics[0].CgroupId).To(Equal(uint64(5)))
+		Expect(metrics[0].ProcessRunTime).To(Equal(uint64(100)))
+		Expect(metrics[0].BytesTx).To(Equal(uint64(3000)))
+		Expect(metrics[0].PacketsRx).To(Equal(uint64(2)))
+		Expect(metrics[0].BlockReadBytes).To(BeZero())
+		Expect(metrics[1].BytesTx).To(BeZero())
+		Expect(metrics[1].BlockReadBytes).To(Equal(uint64(4096)))
+		Expect(metrics[1].BlockReadRequests).To(Equal(uint64(1)))
+		Expect(metrics[1].BlockServiceTime).To(Equal(uint64(250)))
+		// a process that was not scheduled since the previous collection
+		Expect(metrics[2].Pid).To(Equal(uint64(12)))
+		Expect(metrics[2].BytesRx).To(Equal(uint64(100)))
+		Expect(metrics[2].PacketsRx).To(Equal(uint64(1)))
+		Expect(metrics[2].BlockWriteBytes).To(Equal(uint64(8192)))
+		Expect(metrics[2].BlockWriteRequests).To(Equal(uint64(2)))
+	})
+
+	It("names the configured hardware counters by their index", func() {
+		processes := []keplerProcessMetricsT{{Pid: 10}}
+		hw := map[uint32]processHardwareCounters{
+			10: {1000, 7, 30},
+			11: {2000, 9, 60},
+		}
+
+		metrics := mergeProcessMetrics(processes, nil, nil, hw, []string{config.CPUCycle, "", config.BranchMiss})
+		Expect(metrics).To(HaveLen(2))
+		Expect(metrics[0].HardwareCounters).To(Equal(map[string]uint64{config.CPUCycle: 1000, config.BranchMiss: 30}))
+		Expect(metrics[1].Pid).To(Equal(uint64(11)))
+		Expect(metrics[1].HardwareCounters).To(Equal(map[string]uint64{config.CPUCycle: 2000, config.BranchMiss: 60}))
+	})
+})
@@ -4,18 +4,26 @@
 package bpftest
 
 import (
+	"bytes"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"math/big"
+	"os"
 	"syscall"
 	"testing"
 	"time"
 	"unsafe"
 
 	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/ringbuf"
 	"github.com/cilium/ebpf/rlimit"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/onsi/gomega/gmeasure"
 	"golang.org/x/sys/unix"
+
+	"github.com/sustainable-computing-io/kepler/pkg/bpf"
 )
 
 func TestBpf(t *testing.T) {
@@ -49,9 +57,6 @@ var _ = Describe("BPF Exporter", func() {
 			CgroupId:       0,
 			Pid:            0,
 			ProcessRunTime: 0,
-			CpuCycles:      0,
-			CpuInstr:       0,
-			CacheMiss:      0,
 			PageCacheHit:   0,
 			VecNr:          [10]uint16{},
 			Comm:           [16]int8{},
@@ -108,6 +113,48 @@ var _ = Describe("BPF Exporter", func() {
 		Expect(err).NotTo(HaveOccurred())
 	})
 
+	It("emits the exit of a multi-threaded process once its last thread exits", func() {
+		// Remove resource limits for kernels <5.11.
+		err := rlimit.RemoveMemlock()
+		Expect(err).NotTo(HaveOccurred())
+
+		// Load eBPF Specs
+		specs, err := loadTest()
+		Expect(err).NotTo(HaveOccurred())
+
+		var obj testObjects
+		// Load eBPF objects
+		err = specs.LoadAndAssign(&obj, nil)
+		Expect(err).NotTo(HaveOccurred())
+		defer obj.Close()
+
+		reader, err := ringbuf.NewReader(obj.ProcessExitEvents)
+		Expect(err).NotTo(HaveOccurred())
+		defer reader.Close()
+
+		out, err := obj.TestKeplerSchedProcessExitTrace.Run(&ebpf.RunOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).To(BeNumerically("==", uint32(0)))
+
+		// the three threads exit, but only the exit of the last thread is emitted
+		reader.SetDeadline(time.Now())
+		var exits []bpf.ProcessExit
+		for {
+			record, err := reader.Read()
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				break
+			}
+			Expect(err).NotTo(HaveOccurred())
+			var exit bpf.ProcessExit
+			err = binary.Read(bytes.NewReader(record.RawSample), binary.NativeEndian, &exit)
+			Expect(err).NotTo(HaveOccurred())
+			exits = append(exits, exit)
+		}
+		Expect(exits).To(Equal([]bpf.ProcessExit{
+			{Pid: 42, Tgid: 42, StartTime: 1000, Ppid: 1, ParentStartTime: 10},
+		}))
+	})
+
 	It("should increment the page hit counter efficiently", func() {
 		experiment := gmeasure.NewExperiment("Increment the page hit counter")
 		AddReportEntry(experiment.Name, experiment)
@@ -135,9 +182,6 @@ var _ = Describe("BPF Exporter", func() {
 			CgroupId:       0,
 			Pid:            0,
 			ProcessRunTime: 0,
-			CpuCycles:      0,
-			CpuInstr:       0,
-			CacheMiss:      0,
 			PageCacheHit:   0,
 			VecNr:          [10]uint16{},
 			Comm:           [16]int8{},
@@ -153,7 +197,7 @@ var _ = Describe("BPF Exporter", func() {
 		}, gmeasure.SamplingConfig{N: 1000000, Duration: 10 * time.Second})
 	})
 
-	It("collects hardware counter metrics for sched_switch events", Label("perf_event"), func() {
+	It("collects the configured hardware counters of the switched out process", Label("perf_event"), func() {
 		// Remove resource limits for kernels <5.11.
 		err := rlimit.RemoveMemlock()
 		Expect(err).NotTo(HaveOccurred())
@@ -163,8 +207,8 @@ var _ = Describe("BPF Exporter", func() {
 		Expect(err).NotTo(HaveOccurred())
 
 		err = specs.RewriteConstants(map[string]interface{}{
-			"TEST": int32(1),
-			"HW":   int32(1),
+			"TEST":        int32(1),
+			"HW_COUNTERS": int32(3),
 		})
 		Expect(err).NotTo(HaveOccurred())
 
@@ -172,57 +216,34 @@ var _ = Describe("BPF Exporter", func() {
 		// Load eBPF objects
 		err = specs.LoadAndAssign(&obj, nil)
 		Expect(err).NotTo(HaveOccurred())
+		defer obj.Close()
 
-		perfEvents, err := createHardwarePerfEvents(
-			obj.CpuInstructionsEventReader,
-			obj.CpuCyclesEventReader,
-			obj.CacheMissEventReader,
-		)
+		closeCounters, err := createHardwareCounters(specs, &obj, 3)
 		Expect(err).NotTo(HaveOccurred())
-		defer func() {
-			for _, fd := range perfEvents {
-				unix.Close(fd)
-			}
-		}()
+		defer closeCounters()
 
-		// Register TGID 42 - This would be done by register_new_process_if_not_exist
-		// when we get a sched_switch event for a new process
-		key := uint32(42)
-		nsecs := getNSecs()
-		err = obj.Processes.Put(key, testProcessMetricsT{
-			CgroupId:       0,
-			Pid:            42,
-			ProcessRunTime: nsecs,
-			CpuCycles:      0,
-			CpuInstr:       0,
-			CacheMiss:      0,
-			PageCacheHit:   0,
-			VecNr:          [10]uint16{},
-			Comm:           [16]int8{},
-		})
-		Expect(err).NotTo(HaveOccurred())
-		err = obj.PidTimeMap.Put(key, nsecs)
-		Expect(err).NotTo(HaveOccurred())
-
-		out, err := obj.TestKeplerSchedSwitchTrace.Run(&ebpf.RunOptions{
+		out, err := obj.TestKeplerHwCountersTrace.Run(&ebpf.RunOptions{
 			Flags: uint32(1), // BPF_F_TEST_RUN_ON_CPU
 			CPU:   uint32(0),
 		})
 		Expect(err).NotTo(HaveOccurred())
 		Expect(out).To(Equal(uint32(0)))
 
-		var res testProcessMetricsT
-		err = obj.Processes.Lookup(key, &res)
+		var res testProcessHwCountersT
+		key := uint32(42)
+		err = obj.ProcessHwCounters.Lookup(key, &res)
 		Expect(err).NotTo(HaveOccurred())
-		Expect(res.CpuCycles).To(BeNumerically(">", uint64(0)))
-		Expect(res.CpuInstr).To(BeNumerically(">", uint64(0)))
-		Expect(res.CacheMiss).To(BeNumerically(">", uint64(0)))
+		for i := 0; i < 3; i++ {
+			Expect(res.Values[i]).To(BeNumerically(">", uint64(0)))
+		}
+		// the counters that are not configured are not read
+		Expect(res.Values[3]).To(BeZero())
 
-		err = obj.Processes.Delete(key)
+		err = obj.ProcessHwCounters.Delete(key)
 		Expect(err).NotTo(HaveOccurred())
 	})
 
-	It("collects metrics for sched_switch events when no hardware events are enabled", Label("perf_event"), func() {
+	It("scales the multiplexed counters without overflowing", func() {
 		// Remove resource limits for kernels <5.11.
 		err := rlimit.RemoveMemlock()
 		Expect(err).NotTo(HaveOccurred())
@@ -231,47 +252,67 @@ var _ = Describe("BPF Exporter", func() {
 		specs, err := loadTest()
 		Expect(err).NotTo(HaveOccurred())
 
-		err = specs.RewriteConstants(map[string]interface{}{
-			"TEST": int32(1),
-			"HW":   int32(0),
-		})
-		Expect(err).NotTo(HaveOccurred())
-
 		var obj testObjects
 		// Load eBPF objects
 		err = specs.LoadAndAssign(&obj, nil)
 		Expect(err).NotTo(HaveOccurred())
+		defer obj.Close()
 
-		// Register TGID 42 - This would be done by register_new_process_if_not_exist
-		// when we get a sched_switch event for a new process
-		key := uint32(42)
-		nsecs := getNSecs()
-		err = obj.Processes.Put(key, testProcessMetricsT{
-			CgroupId:       0,
-			Pid:            42,
-			ProcessRunTime: nsecs,
-			CpuCycles:      0,
-			CpuInstr:       0,
-			CacheMiss:      0,
-			PageCacheHit:   0,
-			VecNr:          [10]uint16{},
-			Comm:           [16]int8{},
-		})
+		scale := func(prev, val testBpfPerfEventValue) uint64 {
+			Expect(obj.HwCounterValues.Put(uint32(0), prev)).To(Succeed())
+			Expect(obj.HwCounterValues.Put(uint32(1), val)).To(Succeed())
+			out, err := obj.TestCalcScaledDelta.Run(&ebpf.RunOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(out).To(Equal(uint32(0)))
+			var res testProcessHwCountersT
+			Expect(obj.ProcessHwCounters.Lookup(uint32(42), &res)).To(Succeed())
+			return res.Values[0]
+		}
+
+		// a counter that ran half of the time
+		Expect(scale(
+			testBpfPerfEventValue{Counter: 100, Enabled: 1000, Running: 500},
+			testBpfPerfEventValue{Counter: 1100, Enabled: 3000, Running: 1500},
+		)).To(Equal(uint64(2000)))
+
+		// the cycles of seconds at GHz, delta * enabled overflows 64 bits
+		delta, enabled, running := uint64(1)<<40+12345, uint64(3)<<31, uint64(1)<<31+7
+		expected := new(big.Int).Mul(new(big.Int).SetUint64(delta), new(big.Int).SetUint64(enabled))
+		expected.Quo(expected, new(big.Int).SetUint64(running))
+		Expect(expected.IsUint64()).To(BeTrue())
+		Expect(scale(
+			testBpfPerfEventValue{},
+			testBpfPerfEventValue{Counter: delta, Enabled: enabled, Running: running},
+		)).To(Equal(expected.Uint64()))
+	})
+
+	It("collects metrics for sched_switch events", func() {
+		// Remove resource limits for kernels <5.11.
+		err := rlimit.RemoveMemlock()
 		Expect(err).NotTo(HaveOccurred())
-		err = obj.PidTimeMap.Put(key, nsecs)
+
+		// Load eBPF Specs
+		specs, err := loadTest()
 		Expect(err).NotTo(HaveOccurred())
 
-		out, err := obj.TestKeplerSchedSwitchTrace.Run(&ebpf.RunOptions{
-			Flags: uint32(1), // BPF_F_TEST_RUN_ON_CPU
-			CPU:   uint32(0),
+		err = specs.RewriteConstants(map[string]interface{}{
+			"TEST": int32(1),
 		})
 		Expect(err).NotTo(HaveOccurred())
-		Expect(out).To(Equal(uint32(0)))
+
+		var obj testObjects
+		// Load eBPF objects
+		err = specs.LoadAndAssign(&obj, nil)
+		Expect(err).NotTo(HaveOccurred())
+		defer obj.Close()
+
+		preRunSchedSwitchTracepoint(&obj)
+		runSchedSwitchTracepoint(&obj)
 
 		var res testProcessMetricsT
+		key := uint32(42)
 		err = obj.Processes.Lookup(key, &res)
 		Expect(err).NotTo(HaveOccurred())
-		Expect(res.CpuCycles).To(BeNumerically("==", uint64(0)))
 		Expect(res.ProcessRunTime).To(BeNumerically(">", uint64(0)))
 
 		err = obj.Processes.Delete(key)
@@ -290,7 +331,8 @@ var _ = Describe("BPF Exporter", func() {
 		Expect(err).NotTo(HaveOccurred())
 
 		err = specs.RewriteConstants(map[string]interface{}{
-			"TEST": int32(1),
+			"TEST":        int32(1),
+			"HW_COUNTERS": int32(3),
 		})
 		Expect(err).NotTo(HaveOccurred())
 
@@ -298,29 +340,23 @@ var _ = Describe("BPF Exporter", func() {
 		// Load eBPF objects
 		err = specs.LoadAndAssign(&obj, nil)
 		Expect(err).NotTo(HaveOccurred())
+		defer obj.Close()
 
-		perfEvents, err := createHardwarePerfEvents(
-			obj.CpuInstructionsEventReader,
-			obj.CpuCyclesEventReader,
-			obj.CacheMissEventReader,
-		)
+		closeCounters, err := createHardwareCounters(specs, &obj, 3)
 		Expect(err).NotTo(HaveOccurred())
-		defer func() {
-			for _, fd := range perfEvents {
-				unix.Close(fd)
-			}
-		}()
+		defer closeCounters()
 		experiment.Sample(func(idx int) {
 			preRunSchedSwitchTracepoint(&obj)
-			experiment.MeasureDuration("sampled sched_switch tracepoint", func() {
+			experiment.MeasureDuration("sched_switch tracepoint", func() {
 				runSchedSwitchTracepoint(&obj)
+				runHardwareCountersTracepoint(&obj)
 			}, gmeasure.Precision(time.Nanosecond))
 			err = obj.Processes.Delete(uint32(42))
 			Expect(err).NotTo(HaveOccurred())
 		}, gmeasure.SamplingConfig{N: 1000000, Duration: 10 * time.Second})
 	})
 
-	It("uses sample rate to reduce CPU time", Label("perf_event"), func() {
+	It("uses sample rate to reduce CPU time", func() {
 		experiment := gmeasure.NewExperiment("sampled sched_switch tracepoint")
 		AddReportEntry(experiment.Name, experiment)
 		// Remove resource limits for kernels <5.11.
@@ -341,18 +377,8 @@ var _ = Describe("BPF Exporter", func() {
 		// Load eBPF objects
 		err = specs.LoadAndAssign(&obj, nil)
 		Expect(err).NotTo(HaveOccurred())
+		defer obj.Close()
 
-		perfEvents, err := createHardwarePerfEvents(
-			obj.CpuInstructionsEventReader,
-			obj.CpuCyclesEventReader,
-			obj.CacheMissEventReader,
-		)
-		Expect(err).NotTo(HaveOccurred())
-		defer func() {
-			for _, fd := range perfEvents {
-				unix.Close(fd)
-			}
-		}()
 		experiment.Sample(func(idx int) {
 			preRunSchedSwitchTracepoint(&obj)
 			experiment.MeasureDuration("sampled sched_switch tracepoint", func() {
@@ -382,9 +408,6 @@ func preRunSchedSwitchTracepoint(obj *testObjects) {
 		CgroupId:       0,
 		Pid:            42,
 		ProcessRunTime: nsecs,
-		CpuCycles:      0,
-		CpuInstr:       0,
-		CacheMiss:      0,
 		PageCacheHit:   0,
 		VecNr:          [10]uint16{},
 		Comm:           [16]int8{},
@@ -403,6 +426,15 @@ func runSchedSwitchTracepoint(obj *testObjects) {
 	Expect(out).To(Equal(uint32(0)))
 }
 
+func runHardwareCountersTracepoint(obj *testObjects) {
+	out, err := obj.TestKeplerHwCountersTrace.Run(&ebpf.RunOptions{
+		Flags: uint32(1), // BPF_F_TEST_RUN_ON_CPU
+		CPU:   uint32(0),
+	})
+	Expect(err).NotTo(HaveOccurred())
+	Expect(out).To(Equal(uint32(0)))
+}
+
 func unixOpenPerfEvent(typ, conf int) (int, error) {
 	sysAttr := &unix.PerfEventAttr{
 		Type:   uint32(typ),
@@ -419,35 +451,41 @@ func unixOpenPerfEvent(typ, conf int) (int, error) {
 	return fd, nil
 }
 
-// This function is used to create hardware perf events for CPU cycles, instructions and cache misses.
-// Instead of using hardware perf events, we use the software perf event for testing purposes.
-func createHardwarePerfEvents(cpuCyclesMap, cpuInstructionsMap, cacheMissMap *ebpf.Map) ([]int, error) {
-	cpuCyclesFd, err := unixOpenPerfEvent(unix.PERF_TYPE_SOFTWARE, unix.PERF_COUNT_SW_CPU_CLOCK)
-	if err != nil {
-		return nil, err
-	}
-	err = cpuCyclesMap.Update(uint32(0), uint32(cpuCyclesFd), ebpf.UpdateAny)
-	if err != nil {
-		return nil, err
+// createHardwareCounters opens n counters on CPU 0 and puts each into its perf event array in
+// hw_counter_event_reader. Instead of hardware perf events, software perf events are used for testing
+// purposes. The returned function closes the perf events and their arrays.
+func createHardwareCounters(specs *ebpf.CollectionSpec, obj *testObjects, n int) (func(), error) {
+	var fds []int
+	var arrays []*ebpf.Map
+	closeCounters := func() {
+		for _, events := range arrays {
+			events.Close()
+		}
+		for _, fd := range fds {
+			unix.Close(fd)
+		}
 	}
-
-	cpuInstructionsFd, err := unixOpenPerfEvent(unix.PERF_TYPE_SOFTWARE, unix.PERF_COUNT_SW_CPU_CLOCK)
-	if err != nil {
-		return nil, err
+	for i := 0; i < n; i++ {
+		fd, err := unixOpenPerfEvent(unix.PERF_TYPE_SOFTWARE, unix.PERF_COUNT_SW_CPU_CLOCK)
+		if err != nil {
+			closeCounters()
+			return nil, err
+		}
+		fds = append(fds, fd)
+		events, err := ebpf.NewMap(specs.Maps["hw_counter_event_reader"].InnerMap)
+		if err != nil {
+			closeCounters()
+			return nil, err
+		}
+		arrays = append(arrays, events)
+		if err := events.Update(uint32(0), uint32(fd), ebpf.UpdateAny); err != nil {
+			closeCounters()
+			return nil, err
+		}
+		if err := obj.HwCounterEventReader.Update(uint32(i), events, ebpf.UpdateAny); err != nil {
+			closeCounters()
+			return nil, err
+		}
 	}
-	err = cpuInstructionsMap.Update(uint32(0), uint32(cpuInstructionsFd), ebpf.UpdateAny)
-	if err != nil {
-		return nil, err
-	}
-
-	cacheMissFd, err := unixOpenPerfEvent(unix.PERF_TYPE_SOFTWARE, unix.PERF_COUNT_SW_CPU_CLOCK)
-	if err != nil {
-		return nil, err
-	}
-	err = cacheMissMap.Update(uint32(0), uint32(cacheMissFd), ebpf.UpdateAny)
-	if err != nil {
-		return nil, err
-	}
-
-	return []int{cpuCyclesFd, cpuInstructionsFd, cacheMissFd}, nil
+	return closeCounters, nil
 }
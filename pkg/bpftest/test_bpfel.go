@@ -12,19 +12,46 @@ import (
 	"github.com/cilium/ebpf"
 )
 
+type testBlockRequestT struct {
+	IssueTs uint64
+	Bytes   uint64
+	Tgid    uint32
+	Op      uint32
+}
+
+type testBpfPerfEventValue struct {
+	Counter uint64
+	Enabled uint64
+	Running uint64
+}
+
+type testProcessBlockMetricsT struct {
+	ReadBytes     uint64
+	WriteBytes    uint64
+	ReadRequests  uint64
+	WriteRequests uint64
+	ServiceTimeUs uint64
+}
+
+type testProcessHwCountersT struct{ Values [8]uint64 }
+
 type testProcessMetricsT struct {
 	CgroupId       uint64
 	Pid            uint64
 	ProcessRunTime uint64
-	CpuCycles      uint64
-	CpuInstr       uint64
-	CacheMiss      uint64
 	PageCacheHit   uint64
 	VecNr          [10]uint16
 	Comm           [16]int8
 	_              [4]byte
 }
 
+type testProcessNetMetricsT struct {
+	BytesTx   uint64
+	BytesRx   uint64
+	PacketsTx uint64
+	PacketsRx uint64
+}
+
 // loadTest returns the embedded CollectionSpec for test.
 func loadTest() (*ebpf.CollectionSpec, error) {
 	reader := bytes.NewReader(_TestBytes)
@@ -66,6 +93,9 @@ type testSpecs struct {
 //
 // It can be passed ebpf.CollectionSpec.Assign.
 type testProgramSpecs struct {
+	TestCalcScaledDelta              *ebpf.ProgramSpec `ebpf:"test_calc_scaled_delta"`
+	TestKeplerHwCountersTrace        *ebpf.ProgramSpec `ebpf:"test_kepler_hw_counters_trace"`
+	TestKeplerSchedProcessExitTrace  *ebpf.ProgramSpec `ebpf:"test_kepler_sched_process_exit_trace"`
 	TestKeplerSchedSwitchTrace       *ebpf.ProgramSpec `ebpf:"test_kepler_sched_switch_trace"`
 	TestKeplerWritePageTrace         *ebpf.ProgramSpec `ebpf:"test_kepler_write_page_trace"`
 	TestRegisterNewProcessIfNotExist *ebpf.ProgramSpec `ebpf:"test_register_new_process_if_not_exist"`
@@ -75,14 +105,16 @@ type testProgramSpecs struct {
 //
 // It can be passed ebpf.CollectionSpec.Assign.
 type testMapSpecs struct {
-	CacheMiss                  *ebpf.MapSpec `ebpf:"cache_miss"`
-	CacheMissEventReader       *ebpf.MapSpec `ebpf:"cache_miss_event_reader"`
-	CpuCycles                  *ebpf.MapSpec `ebpf:"cpu_cycles"`
-	CpuCyclesEventReader       *ebpf.MapSpec `ebpf:"cpu_cycles_event_reader"`
-	CpuInstructions            *ebpf.MapSpec `ebpf:"cpu_instructions"`
-	CpuInstructionsEventReader *ebpf.MapSpec `ebpf:"cpu_instructions_event_reader"`
-	PidTimeMap                 *ebpf.MapSpec `ebpf:"pid_time_map"`
-	Processes                  *ebpf.MapSpec `ebpf:"processes"`
+	BlockRequests        *ebpf.MapSpec `ebpf:"block_requests"`
+	HwCounterEventReader *ebpf.MapSpec `ebpf:"hw_counter_event_reader"`
+	HwCounterValues      *ebpf.MapSpec `ebpf:"hw_counter_values"`
+	PidTimeMap           *ebpf.MapSpec `ebpf:"pid_time_map"`
+	ProcessBlock         *ebpf.MapSpec `ebpf:"process_block"`
+	ProcessExitDropped   *ebpf.MapSpec `ebpf:"process_exit_dropped"`
+	ProcessExitEvents    *ebpf.MapSpec `ebpf:"process_exit_events"`
+	ProcessHwCounters    *ebpf.MapSpec `ebpf:"process_hw_counters"`
+	ProcessNet           *ebpf.MapSpec `ebpf:"process_net"`
+	Processes            *ebpf.MapSpec `ebpf:"processes"`
 }
 
 // testObjects contains all objects after they have been loaded into the kernel.
@@ -104,25 +136,29 @@ func (o *testObjects) Close() error {
 //
 // It can be passed to loadTestObjects or ebpf.CollectionSpec.LoadAndAssign.
 type testMaps struct {
-	CacheMiss                  *ebpf.Map `ebpf:"cache_miss"`
-	CacheMissEventReader       *ebpf.Map `ebpf:"cache_miss_event_reader"`
-	CpuCycles                  *ebpf.Map `ebpf:"cpu_cycles"`
-	CpuCyclesEventReader       *ebpf.Map `ebpf:"cpu_cycles_event_reader"`
-	CpuInstructions            *ebpf.Map `ebpf:"cpu_instructions"`
-	CpuInstructionsEventReader *ebpf.Map `ebpf:"cpu_instructions_event_reader"`
-	PidTimeMap                 *ebpf.Map `ebpf:"pid_time_map"`
-	Processes                  *ebpf.Map `ebpf:"processes"`
+	BlockRequests        *ebpf.Map `ebpf:"block_requests"`
+	HwCounterEventReader *ebpf.Map `ebpf:"hw_counter_event_reader"`
+	HwCounterValues      *ebpf.Map `ebpf:"hw_counter_values"`
+	PidTimeMap           *ebpf.Map `ebpf:"pid_time_map"`
+	ProcessBlock         *ebpf.Map `ebpf:"process_block"`
+	ProcessExitDropped   *ebpf.Map `ebpf:"process_exit_dropped"`
+	ProcessExitEvents    *ebpf.Map `ebpf:"process_exit_events"`
+	ProcessHwCounters    *ebpf.Map `ebpf:"process_hw_counters"`
+	ProcessNet           *ebpf.Map `ebpf:"process_net"`
+	Processes            *ebpf.Map `ebpf:"processes"`
 }
 
 func (m *testMaps) Close() error {
 	return _TestClose(
-		m.CacheMiss,
-		m.CacheMissEventReader,
-		m.CpuCycles,
-		m.CpuCyclesEventReader,
-		m.CpuInstructions,
-		m.CpuInstructionsEventReader,
+		m.BlockRequests,
+		m.HwCounterEventReader,
+		m.HwCounterValues,
 		m.PidTimeMap,
+		m.ProcessBlock,
+		m.ProcessExitDropped,
+		m.ProcessExitEvents,
+		m.ProcessHwCounters,
+		m.ProcessNet,
 		m.Processes,
 	)
 }
@@ -131,6 +167,9 @@ func (m *testMaps) Close() error {
 //
 // It can be passed to loadTestObjects or ebpf.CollectionSpec.LoadAndAssign.
 type testPrograms struct {
+	TestCalcScaledDelta              *ebpf.Program `ebpf:"test_calc_scaled_delta"`
+	TestKeplerHwCountersTrace        *ebpf.Program `ebpf:"test_kepler_hw_counters_trace"`
+	TestKeplerSchedProcessExitTrace  *ebpf.Program `ebpf:"test_kepler_sched_process_exit_trace"`
 	TestKeplerSchedSwitchTrace       *ebpf.Program `ebpf:"test_kepler_sched_switch_trace"`
 	TestKeplerWritePageTrace         *ebpf.Program `ebpf:"test_kepler_write_page_trace"`
 	TestRegisterNewProcessIfNotExist *ebpf.Program `ebpf:"test_register_new_process_if_not_exist"`
@@ -138,6 +177,9 @@ type testPrograms struct {
 
 func (p *testPrograms) Close() error {
 	return _TestClose(
+		p.TestCalcScaledDelta,
+		p.TestKeplerHwCountersTrace,
+		p.TestKeplerSchedProcessExitTrace,
 		p.TestKeplerSchedSwitchTrace,
 		p.TestKeplerWritePageTrace,
 		p.TestRegisterNewProcessIfNotExist,
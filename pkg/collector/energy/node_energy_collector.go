@@ -19,6 +19,8 @@ package energy
 import (
 	"fmt"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/sustainable-computing-io/kepler/pkg/collector/stats"
 	"github.com/sustainable-computing-io/kepler/pkg/config"
@@ -30,32 +32,106 @@ import (
 	"k8s.io/klog/v2"
 )
 
-// UpdatePlatformEnergy updates the node platform power consumption, i.e, the node total power consumption
-func UpdatePlatformEnergy(nodeStats *stats.NodeStats) {
+// componentsSource names the power meter of the node components, e.g. RAPL
+const componentsSource = "components"
+
+var (
+	// the samplers read the power meters at every collection until StartSamplers starts the ones with a polling interval
+	componentsSampler = NewSampler(componentsSource, 0, true, readNodeComponentsEnergy)
+	platformSampler   = NewSampler(config.PLATFORM, 0, false, readPlatformEnergy)
+	gpuSampler        = NewSampler(config.GPU, 0, false, readGPUEnergy)
+)
+
+// StartSamplers reads the power meters that have a polling interval in the background, instead of at every collection
+func StartSamplers() {
+	StopSamplers()
+	pollConfig := config.PowerPoll()
+	componentsSampler = NewSampler(componentsSource, time.Duration(pollConfig.ComponentsIntervalMs)*time.Millisecond, true, readNodeComponentsEnergy)
+	platformSampler = NewSampler(config.PLATFORM, time.Duration(pollConfig.PlatformIntervalMs)*time.Millisecond, false, readPlatformEnergy)
+	gpuSampler = NewSampler(config.GPU, time.Duration(pollConfig.AcceleratorIntervalMs)*time.Millisecond, false, readGPUEnergy)
+	if components.IsSystemCollectionSupported() {
+		componentsSampler.Start()
+	}
 	if platform.IsSystemCollectionSupported() {
-		nodePlatformEnergy, _ := platform.GetAbsEnergyFromPlatform()
-		for sourceID, energy := range nodePlatformEnergy {
-			nodeStats.EnergyUsage[config.AbsEnergyInPlatform].SetDeltaStat(sourceID, uint64(energy))
+		platformSampler.Start()
+	}
+	if config.IsGPUEnabled() && acc.GetActiveAcceleratorByType(config.GPU) != nil {
+		gpuSampler.Start()
+	}
+}
+
+// StopSamplers stops reading the power meters in the background
+func StopSamplers() {
+	componentsSampler.Stop()
+	platformSampler.Stop()
+	gpuSampler.Stop()
+}
+
+// componentKey is the source ID of the energy of a node component in a socket
+func componentKey(metric, socketID string) string {
+	return metric + "/" + socketID
+}
+
+func readNodeComponentsEnergy() (map[string]float64, error) {
+	// the RAPL metrics return counter metrics not gauge
+	componentsEnergy, err := components.GetAbsEnergyFromNodeComponents()
+	if err != nil {
+		return nil, err
+	}
+	energy := map[string]float64{}
+	for socket, e := range componentsEnergy {
+		strID := strconv.Itoa(socket)
+		energy[componentKey(config.AbsEnergyInPkg, strID)] = float64(e.Pkg)
+		energy[componentKey(config.AbsEnergyInCore, strID)] = float64(e.Core)
+		energy[componentKey(config.AbsEnergyInUnCore, strID)] = float64(e.Uncore)
+		energy[componentKey(config.AbsEnergyInDRAM, strID)] = float64(e.DRAM)
+	}
+	return energy, nil
+}
+
+func readPlatformEnergy() (map[string]float64, error) {
+	return platform.GetAbsEnergyFromPlatform()
+}
+
+func readGPUEnergy() (map[string]float64, error) {
+	gpu := acc.GetActiveAcceleratorByType(config.GPU)
+	if gpu == nil {
+		return nil, fmt.Errorf("no active GPU")
+	}
+	energy := map[string]float64{}
+	for id, e := range gpu.Device().AbsEnergyFromDevice() {
+		energy[fmt.Sprintf("%d", id)] = float64(e)
+	}
+	return energy, nil
+}
+
+// UpdatePlatformEnergy updates the node platform power consumption, i.e, the node total power consumption.
+// The interval is the measured time since the previous collection, which the estimated power is converted with.
+func UpdatePlatformEnergy(nodeStats *stats.NodeStats, interval time.Duration) {
+	if platform.IsSystemCollectionSupported() {
+		readings, staleness := platformSampler.Sample(time.Now())
+		for sourceID, r := range readings {
+			nodeStats.EnergyUsage[config.AbsEnergyInPlatform].SetDeltaStat(sourceID, uint64(r.Delta))
 		}
+		nodeStats.Staleness[config.PLATFORM] = staleness.Seconds()
 	} else if model.IsNodePlatformPowerModelEnabled() {
-		model.UpdateNodePlatformEnergy(nodeStats)
+		model.UpdateNodePlatformEnergy(nodeStats, interval)
 	}
 }
 
 // UpdateNodeComponentsEnergy updates each node component power consumption, i.e., the CPU core, uncore, package/socket and DRAM
-func UpdateNodeComponentsEnergy(nodeStats *stats.NodeStats) {
+func UpdateNodeComponentsEnergy(nodeStats *stats.NodeStats, interval time.Duration) {
 	if components.IsSystemCollectionSupported() {
-		nodeComponentsEnergy := components.GetAbsEnergyFromNodeComponents()
-		// the RAPL metrics return counter metrics not gauge
-		for socket, energy := range nodeComponentsEnergy {
-			strID := strconv.Itoa(socket)
-			nodeStats.EnergyUsage[config.AbsEnergyInPkg].SetAggrStat(strID, energy.Pkg)
-			nodeStats.EnergyUsage[config.AbsEnergyInCore].SetAggrStat(strID, energy.Core)
-			nodeStats.EnergyUsage[config.AbsEnergyInUnCore].SetAggrStat(strID, energy.Uncore)
-			nodeStats.EnergyUsage[config.AbsEnergyInDRAM].SetAggrStat(strID, energy.DRAM)
+		readings, staleness := componentsSampler.Sample(time.Now())
+		for key, r := range readings {
+			metric, strID, _ := strings.Cut(key, "/")
+			nodeStats.EnergyUsage[metric].SetAggrStat(strID, uint64(r.Energy))
+		}
+		for _, component := range []string{config.PKG, config.CORE, config.UNCORE, config.DRAM} {
+			nodeStats.Staleness[component] = staleness.Seconds()
 		}
 	} else if model.IsNodeComponentPowerModelEnabled() {
-		model.UpdateNodeComponentEnergy(nodeStats)
+		model.UpdateNodeComponentEnergy(nodeStats, interval)
 	} else {
 		klog.V(5).Info("No nodeComponentsEnergy found, node components energy metrics is not exposed ")
 	}
@@ -70,15 +146,16 @@ func UpdateNodeGPUEnergy(nodeStats *stats.NodeStats) {
 	if gpu == nil {
 		return
 	}
-	gpuEnergy := gpu.Device().AbsEnergyFromDevice()
-	for gpu, energy := range gpuEnergy {
-		nodeStats.EnergyUsage[config.AbsEnergyInGPU].SetDeltaStat(fmt.Sprintf("%d", gpu), uint64(energy))
+	readings, staleness := gpuSampler.Sample(time.Now())
+	for gpuID, r := range readings {
+		nodeStats.EnergyUsage[config.AbsEnergyInGPU].SetDeltaStat(gpuID, uint64(r.Delta))
 	}
+	nodeStats.Staleness[config.GPU] = staleness.Seconds()
 }
 
 // UpdateNodeIdleEnergy calculates the node idle energy consumption based on the minimum power consumption when real-time system power metrics are accessible.
 // When the node power model estimator is utilized, the idle power is updated with the estimated power considering minimal resource utilization.
-func UpdateNodeIdleEnergy(nodeStats *stats.NodeStats) {
+func UpdateNodeIdleEnergy(nodeStats *stats.NodeStats, interval time.Duration) {
 	isComponentsSystemCollectionSupported := components.IsSystemCollectionSupported()
 	// the idle energy is only updated if we find the node using less resources than previously observed
 	// TODO: Use regression to estimate the idle power when real-time system power metrics are available, instead of relying on the minimum power consumption.
@@ -86,22 +163,22 @@ func UpdateNodeIdleEnergy(nodeStats *stats.NodeStats) {
 	if !isComponentsSystemCollectionSupported {
 		// if power collection on components is not supported, try using estimator to update idle energy
 		if model.IsNodeComponentPowerModelEnabled() {
-			model.UpdateNodeComponentIdleEnergy(nodeStats)
+			model.UpdateNodeComponentIdleEnergy(nodeStats, interval)
 		}
 		if model.IsNodePlatformPowerModelEnabled() {
-			model.UpdateNodePlatformIdleEnergy(nodeStats)
+			model.UpdateNodePlatformIdleEnergy(nodeStats, interval)
 		}
 	}
 }
 
-// UpdateNodeEnergyMetrics updates the node energy consumption of each component
-func UpdateNodeEnergyMetrics(nodeStats *stats.NodeStats) {
-	UpdateNodeComponentsEnergy(nodeStats)
+// UpdateNodeEnergyMetrics updates the node energy consumption of each component during the measured interval since the previous collection
+func UpdateNodeEnergyMetrics(nodeStats *stats.NodeStats, interval time.Duration) {
+	UpdateNodeComponentsEnergy(nodeStats, interval)
 	UpdateNodeGPUEnergy(nodeStats)
-	UpdatePlatformEnergy(nodeStats)
+	UpdatePlatformEnergy(nodeStats, interval)
 	// after updating the total energy we calculate the idle, dynamic and other components energy
 	if config.IsIdlePowerEnabled() {
-		UpdateNodeIdleEnergy(nodeStats)
+		UpdateNodeIdleEnergy(nodeStats, interval)
 	}
 	nodeStats.UpdateDynEnergy()
 	nodeStats.SetNodeOtherComponentsEnergy()
@@ -17,11 +17,13 @@ limitations under the License.
 package energy
 
 import (
+	"time"
+
 	"github.com/sustainable-computing-io/kepler/pkg/collector/stats"
 	"github.com/sustainable-computing-io/kepler/pkg/model"
 )
 
-// UpdateProcessEnergy matches the process resource usage with the node energy consumption
-func UpdateProcessEnergy(processStats map[uint64]*stats.ProcessStats, nodeStats *stats.NodeStats) {
-	model.UpdateProcessEnergy(processStats, nodeStats)
+// UpdateProcessEnergy matches the process resource usage with the node energy consumption during the measured interval
+func UpdateProcessEnergy(processStats map[uint64]*stats.ProcessStats, nodeStats *stats.NodeStats, interval time.Duration) {
+	model.UpdateProcessEnergy(processStats, nodeStats, interval)
 }
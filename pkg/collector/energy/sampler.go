@@ -0,0 +1,214 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package energy
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sustainable-computing-io/kepler/pkg/metrics/telemetry"
+	"k8s.io/klog/v2"
+)
+
+// maxSamples is the number of samples kept per source ID, only the samples around the collection time are used
+const maxSamples = 16
+
+// Reading is the energy of a source ID resampled at a collection time
+type Reading struct {
+	// Energy is the cumulative energy in mJ
+	Energy float64
+	// Delta is the energy in whole mJ since the previous collection
+	Delta float64
+}
+
+type sample struct {
+	time   time.Time
+	energy float64
+}
+
+// series buffers the latest samples of the cumulative energy of a source ID
+type series struct {
+	samples []sample
+	// reported is the energy of the previous collection, the reported energy never decreases so that
+	// the energy that was extrapolated too early is not reported twice
+	reported float64
+	// started is false until the first collection of a counter, whose initial value is not a delta
+	started bool
+}
+
+func (s *series) add(t time.Time, energy float64) {
+	if n := len(s.samples); n > 0 && energy < s.samples[n-1].energy {
+		// the counter was reset or has overflowed, restart from the new value
+		s.samples = s.samples[:0]
+		s.started = false
+	}
+	if len(s.samples) == maxSamples {
+		copy(s.samples, s.samples[1:])
+		s.samples = s.samples[:maxSamples-1]
+	}
+	s.samples = append(s.samples, sample{time: t, energy: energy})
+}
+
+// at returns the energy at t, interpolated between the samples around t. After the last sample, the energy is
+// extrapolated with the power between the last two samples for up to maxAhead, and then held.
+func (s *series) at(t time.Time, maxAhead time.Duration) float64 {
+	n := len(s.samples)
+	if n == 0 {
+		return s.reported
+	}
+	last := s.samples[n-1]
+	if !t.Before(last.time) {
+		if n == 1 {
+			return last.energy
+		}
+		prev := s.samples[n-2]
+		interval := last.time.Sub(prev.time)
+		if interval <= 0 {
+			return last.energy
+		}
+		ahead := t.Sub(last.time)
+		if ahead > maxAhead {
+			ahead = maxAhead
+		}
+		return last.energy + (last.energy-prev.energy)*ahead.Seconds()/interval.Seconds()
+	}
+	i := sort.Search(n, func(i int) bool { return s.samples[i].time.After(t) })
+	if i == 0 {
+		return s.samples[0].energy
+	}
+	prev, next := s.samples[i-1], s.samples[i]
+	return prev.energy + (next.energy-prev.energy)*t.Sub(prev.time).Seconds()/next.time.Sub(prev.time).Seconds()
+}
+
+// Sampler reads a power meter at its own polling interval and resamples the energy at the collection times, so that
+// the meters slower than the sample period are not reported as flat steps followed by jumps. A sampler with a
+// polling interval of 0 reads the meter at every collection.
+type Sampler struct {
+	// name identifies the power meter in the self-metrics
+	name     string
+	interval time.Duration
+	// read returns the energy in mJ per source ID, which is a counter if cumulative is true,
+	// or the energy since the previous read otherwise
+	read       func() (map[string]float64, error)
+	cumulative bool
+
+	mx       sync.Mutex
+	series   map[string]*series
+	lastRead time.Time
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+func NewSampler(name string, interval time.Duration, cumulative bool, read func() (map[string]float64, error)) *Sampler {
+	return &Sampler{
+		name:       name,
+		interval:   interval,
+		read:       read,
+		cumulative: cumulative,
+		series:     map[string]*series{},
+	}
+}
+
+// Start reads the power meter every polling interval until Stop is called, it does nothing if the interval is 0
+func (s *Sampler) Start() {
+	if s.interval <= 0 || s.stopCh != nil {
+		return
+	}
+	s.stopCh = make(chan struct{})
+	s.poll(time.Now())
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stopCh:
+				return
+			case now := <-ticker.C:
+				s.poll(now)
+			}
+		}
+	}()
+}
+
+// Stop stops reading the power meter in the background
+func (s *Sampler) Stop() {
+	if s.stopCh == nil {
+		return
+	}
+	close(s.stopCh)
+	s.wg.Wait()
+	s.stopCh = nil
+}
+
+func (s *Sampler) poll(now time.Time) {
+	energy, err := s.read()
+	if err != nil {
+		telemetry.IncPowerSourceErrors(s.name)
+		klog.V(5).Infof("failed to read the %s power meter: %v", s.name, err)
+		return
+	}
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	for id, value := range energy {
+		ss, exists := s.series[id]
+		if !exists {
+			ss = &series{started: !s.cumulative}
+			s.series[id] = ss
+		}
+		if !s.cumulative {
+			// accumulate the energy since the previous read into a counter
+			if n := len(ss.samples); n > 0 {
+				value += ss.samples[n-1].energy
+			}
+		}
+		ss.add(now, value)
+	}
+	s.lastRead = now
+}
+
+// Sample returns the energy of each source ID at now, and the staleness of the power meter, which is the time
+// since it was last read. The power meter is read first if it has no polling interval.
+func (s *Sampler) Sample(now time.Time) (readings map[string]Reading, staleness time.Duration) {
+	if s.interval <= 0 {
+		s.poll(now)
+	}
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	readings = make(map[string]Reading, len(s.series))
+	for id, ss := range s.series {
+		energy := ss.at(now, s.interval)
+		if !ss.started {
+			ss.started = true
+			ss.reported = energy
+		}
+		if energy < ss.reported {
+			energy = ss.reported
+		}
+		// the deltas are reported in whole mJ, the fractions are carried over to the next collection
+		readings[id] = Reading{Energy: energy, Delta: math.Floor(energy) - math.Floor(ss.reported)}
+		ss.reported = energy
+	}
+	if !s.lastRead.IsZero() {
+		staleness = now.Sub(s.lastRead)
+	}
+	return readings, staleness
+}
@@ -0,0 +1,153 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package energy
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/sustainable-computing-io/kepler/pkg/metrics/telemetry"
+)
+
+// fakeMeter returns the queued readings, one per read, a nil reading fails the read
+type fakeMeter struct {
+	readings []map[string]float64
+}
+
+func (m *fakeMeter) read() (map[string]float64, error) {
+	r := m.readings[0]
+	m.readings = m.readings[1:]
+	if r == nil {
+		return nil, errors.New("failed to read the meter")
+	}
+	return r, nil
+}
+
+// powerSourceErrors returns the failed reads of a power meter counted in the self-metrics
+func powerSourceErrors(source string) float64 {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(telemetry.Collectors()...)
+	families, err := reg.Gather()
+	Expect(err).NotTo(HaveOccurred())
+	for _, f := range families {
+		if f.GetName() != "kepler_collector_power_source_errors_total" {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "source" && l.GetValue() == source {
+					return m.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	return 0
+}
+
+var _ = Describe("Sampler", func() {
+	start := time.Unix(1000, 0)
+	at := func(sec float64) time.Time {
+		return start.Add(time.Duration(sec * float64(time.Second)))
+	}
+
+	It("reads the meter at every collection without polling interval", func() {
+		meter := &fakeMeter{readings: []map[string]float64{{"0": 3000}, {"0": 6000}}}
+		s := NewSampler("test", 0, false, meter.read)
+
+		readings, staleness := s.Sample(at(3))
+		Expect(readings["0"]).To(Equal(Reading{Energy: 3000, Delta: 3000}))
+		Expect(staleness).To(BeZero())
+		readings, _ = s.Sample(at(6))
+		Expect(readings["0"]).To(Equal(Reading{Energy: 9000, Delta: 6000}))
+	})
+
+	It("interpolates and extrapolates a slow meter onto the collection times", func() {
+		// a 1W meter read every 10s
+		meter := &fakeMeter{readings: []map[string]float64{{"0": 10000}, {"0": 10000}, {"0": 10000}}}
+		s := NewSampler("test", 10*time.Second, false, meter.read)
+		s.poll(at(0))
+		s.poll(at(10))
+
+		// the first collection sets the baseline of the counter
+		readings, _ := s.Sample(at(5))
+		Expect(readings["0"].Energy).To(BeNumerically("~", 15000))
+		readings, staleness := s.Sample(at(13))
+		Expect(readings["0"].Delta).To(BeNumerically("~", 8000))
+		Expect(staleness).To(Equal(3 * time.Second))
+		// the extrapolation stops one polling interval after the last read
+		readings, _ = s.Sample(at(25))
+		Expect(readings["0"].Delta).To(BeNumerically("~", 7000))
+		readings, staleness = s.Sample(at(28))
+		Expect(readings["0"].Delta).To(BeZero())
+		Expect(staleness).To(Equal(18 * time.Second))
+
+		// the meter reports that the power dropped to 0.5W, the energy already reported is not reported again
+		s.poll(at(30))
+		readings, _ = s.Sample(at(31))
+		Expect(readings["0"].Energy).To(BeNumerically("~", 30500))
+		Expect(readings["0"].Delta).To(BeNumerically("~", 500))
+	})
+
+	It("does not report the energy extrapolated too early twice", func() {
+		// the power drops from 1W to 0W
+		meter := &fakeMeter{readings: []map[string]float64{{"0": 0}, {"0": 10000}, {"0": 0}}}
+		s := NewSampler("test", 10*time.Second, false, meter.read)
+		s.poll(at(0))
+		s.poll(at(10))
+		s.Sample(at(10))
+		readings, _ := s.Sample(at(15))
+		Expect(readings["0"].Delta).To(BeNumerically("~", 5000))
+
+		s.poll(at(20))
+		readings, _ = s.Sample(at(20))
+		Expect(readings["0"].Delta).To(BeZero())
+		Expect(readings["0"].Energy).To(BeNumerically("~", 15000))
+	})
+
+	It("restarts a counter that was reset", func() {
+		meter := &fakeMeter{readings: []map[string]float64{{"pkg": 5000}, {"pkg": 8000}, {"pkg": 1000}, {"pkg": 4000}}}
+		s := NewSampler("test", 0, true, meter.read)
+
+		readings, _ := s.Sample(at(3))
+		Expect(readings["pkg"]).To(Equal(Reading{Energy: 5000, Delta: 0}))
+		readings, _ = s.Sample(at(6))
+		Expect(readings["pkg"]).To(Equal(Reading{Energy: 8000, Delta: 3000}))
+		readings, _ = s.Sample(at(9))
+		Expect(readings["pkg"]).To(Equal(Reading{Energy: 1000, Delta: 0}))
+		readings, _ = s.Sample(at(12))
+		Expect(readings["pkg"]).To(Equal(Reading{Energy: 4000, Delta: 3000}))
+	})
+
+	It("counts the failed reads of the meter and keeps the previous readings", func() {
+		meter := &fakeMeter{readings: []map[string]float64{{"pkg": 5000}, nil, {"pkg": 8000}}}
+		s := NewSampler(componentsSource, 0, true, meter.read)
+		errs := powerSourceErrors(componentsSource)
+
+		readings, _ := s.Sample(at(3))
+		Expect(readings["pkg"]).To(Equal(Reading{Energy: 5000, Delta: 0}))
+		readings, staleness := s.Sample(at(6))
+		Expect(readings["pkg"]).To(Equal(Reading{Energy: 5000, Delta: 0}))
+		Expect(staleness).To(Equal(3 * time.Second))
+		Expect(powerSourceErrors(componentsSource)).To(Equal(errs + 1))
+		readings, _ = s.Sample(at(9))
+		Expect(readings["pkg"]).To(Equal(Reading{Energy: 8000, Delta: 3000}))
+	})
+})
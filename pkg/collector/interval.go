@@ -0,0 +1,41 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"time"
+
+	"github.com/sustainable-computing-io/kepler/pkg/config"
+)
+
+// IntervalTimer measures the time between collection cycles. The ticks can be late or skipped when an
+// update overruns the sample period, so the energy is converted with the measured interval instead.
+type IntervalTimer struct {
+	last time.Time
+}
+
+// Next returns the time elapsed since the previous call, using the monotonic clock reading of now.
+// The first call returns the sample period, since the usage of the first cycle is read since the
+// eBPF programs were attached.
+func (t *IntervalTimer) Next(now time.Time) time.Duration {
+	interval := time.Duration(config.SamplePeriodSec()) * time.Second
+	if !t.last.IsZero() && now.After(t.last) {
+		interval = now.Sub(t.last)
+	}
+	t.last = now
+	return interval
+}
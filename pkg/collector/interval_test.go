@@ -0,0 +1,42 @@
+package collector
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/sustainable-computing-io/kepler/pkg/config"
+)
+
+var _ = Describe("Test IntervalTimer", func() {
+	BeforeEach(func() {
+		_, err := config.Initialize(".")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("returns the sample period on the first cycle", func() {
+		var timer IntervalTimer
+		Expect(timer.Next(time.Now())).To(Equal(time.Duration(config.SamplePeriodSec()) * time.Second))
+	})
+
+	It("returns the measured interval when the ticks are irregular", func() {
+		var timer IntervalTimer
+		start := time.Now()
+		timer.Next(start)
+		// a late tick, an early tick after it, and a skipped tick after an overrun
+		now := start
+		for _, interval := range []time.Duration{3400 * time.Millisecond, 2600 * time.Millisecond, 6 * time.Second, 3 * time.Second} {
+			now = now.Add(interval)
+			Expect(timer.Next(now)).To(Equal(interval))
+		}
+		Expect(now.Sub(start)).To(Equal(15 * time.Second))
+	})
+
+	It("ignores a time that is not after the previous cycle", func() {
+		var timer IntervalTimer
+		now := time.Now()
+		timer.Next(now)
+		Expect(timer.Next(now)).To(Equal(time.Duration(config.SamplePeriodSec()) * time.Second))
+	})
+})
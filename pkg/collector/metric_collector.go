@@ -18,6 +18,8 @@ package collector
 
 import (
 	"os"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -28,8 +30,8 @@ import (
 	resourceBpf "github.com/sustainable-computing-io/kepler/pkg/collector/resourceutilization/bpf"
 	"github.com/sustainable-computing-io/kepler/pkg/collector/stats"
 	"github.com/sustainable-computing-io/kepler/pkg/config"
+	"github.com/sustainable-computing-io/kepler/pkg/metrics/telemetry"
 	"github.com/sustainable-computing-io/kepler/pkg/model"
-	acc "github.com/sustainable-computing-io/kepler/pkg/sensors/accelerator"
 	"github.com/sustainable-computing-io/kepler/pkg/utils"
 
 	"k8s.io/klog/v2"
@@ -38,6 +40,9 @@ import (
 const (
 	maxInactiveContainers = 10
 	maxInactiveVM         = 3
+	// maxIdleCyclesWithExits is the number of idle cycles after which a process is probed even though the exits
+	// are reported, since the exit events are dropped when the ring buffer is full
+	maxIdleCyclesWithExits = 10
 )
 
 type Collector struct {
@@ -53,38 +58,111 @@ type Collector struct {
 	// VMStats holds the aggregated processes metrics for all virtual machines
 	VMStats map[string]*stats.VMStats
 
-	// bpfExporter handles gathering metrics from bpf probes
-	bpfExporter bpf.Exporter
-	// bpfSupportedMetrics holds the supported metrics by the bpf exporter
-	bpfSupportedMetrics bpf.SupportedMetrics
+	// TerminatedProcessStats holds the values of the removed processes per container
+	TerminatedProcessStats map[string]*stats.ProcessStats
+
+	// TerminatedContainerStats holds the values of the removed containers per container name, pod or namespace
+	TerminatedContainerStats map[string]*stats.ContainerStats
+
+	// terminatingProcesses and terminatingContainers hold the removed entries, which are exported during the grace period
+	terminatingProcesses  map[uint64]*terminatingProcess
+	terminatingContainers map[string]*terminatingContainer
+
+	// restoredContainers and restoredVMs hold the last activity time of the entries restored from the state file,
+	// until they are active again
+	restoredContainers map[string]time.Time
+	restoredVMs        map[string]time.Time
+	// stateEnabled is true when the collector restores and saves the state file, only the exporter keeps the state
+	stateEnabled bool
+	// checkpointCycles counts the collection cycles since the state file was saved
+	checkpointCycles int
+
+	// bpfErr is the error of the last read of the bpf tables
+	bpfErr error
+
+	// interval is the measured duration of the last collection cycle
+	interval time.Duration
+
+	// exitedProcesses are the processes that exited during the current update, they are removed at the end of the update
+	exitedProcesses []stats.ProcessExit
+	// processExitsTracked is true when a source reported the exited processes, otherwise the idle processes are probed
+	processExitsTracked bool
+
+	// snapshot is the copy of the stats published after the last update, and idle is the snapshot of the
+	// previous update, which the next update is copied into once no reader holds it
+	snapshot   *stats.Snapshot
+	idle       *stats.Snapshot
+	snapshotMx sync.Mutex
+
+	// scrapes counts the scrapes of the metrics endpoint since the last update
+	scrapes atomic.Int64
 }
 
 func NewCollector(bpfExporter bpf.Exporter) *Collector {
-	bpfSupportedMetrics := bpfExporter.SupportedMetrics()
+	// the sources must be registered before the stats are created, since the stats hold the metrics of the sources
+	registerResourceSources(bpfExporter)
 	c := &Collector{
-		NodeStats:           *stats.NewNodeStats(),
-		ContainerStats:      map[string]*stats.ContainerStats{},
-		ProcessStats:        map[uint64]*stats.ProcessStats{},
-		VMStats:             map[string]*stats.VMStats{},
-		bpfExporter:         bpfExporter,
-		bpfSupportedMetrics: bpfSupportedMetrics,
+		NodeStats:      *stats.NewNodeStats(),
+		ContainerStats: map[string]*stats.ContainerStats{},
+		ProcessStats:   map[uint64]*stats.ProcessStats{},
+		VMStats:        map[string]*stats.VMStats{},
+
+		TerminatedProcessStats:   map[string]*stats.ProcessStats{},
+		TerminatedContainerStats: map[string]*stats.ContainerStats{},
+		terminatingProcesses:     map[uint64]*terminatingProcess{},
+		terminatingContainers:    map[string]*terminatingContainer{},
+		restoredContainers:       map[string]time.Time{},
+		restoredVMs:              map[string]time.Time{},
 	}
+	c.PublishSnapshot()
 	return c
 }
 
+// registerResourceSources registers the sources of the process resource utilization, the eBPF counters are
+// collected first, so that the processes are identified by the bpf metrics before the other sources
+func registerResourceSources(bpfExporter bpf.Exporter) {
+	if err := stats.RegisterResourceSource(resourceBpf.NewSource(bpfExporter)); err != nil {
+		klog.Errorln(err)
+	}
+	if config.IsGPUEnabled() {
+		if err := stats.RegisterResourceSource(accelerator.NewSource()); err != nil {
+			klog.Errorln(err)
+		}
+	}
+}
+
 func (c *Collector) Initialize() error {
+	c.CreatePowerModels()
+	// the power meters with a polling interval are read in the background and resampled at every update
+	energy.StartSamplers()
+
+	return nil
+}
+
+// CreatePowerModels creates the power models from the model config, the reload recreates them without
+// restarting the samplers of the power meters
+func (c *Collector) CreatePowerModels() {
 	// For local estimator, there is endpoint provided, thus we should let
 	// model component decide whether/how to init
 	model.CreatePowerEstimatorModels(
 		stats.GetProcessFeatureNames(),
 	)
+}
 
-	return nil
+// Close stops reading the power meters in the background and closes the resource sources
+func (c *Collector) Close() {
+	energy.StopSamplers()
+	stats.CloseResourceSources()
 }
 
-// Update updates the node and container energy and resource usage metrics
-func (c *Collector) Update() {
+// Update updates the node and container energy and resource usage metrics. The interval is the measured
+// time since the previous update, which the resource usage and the estimated power are converted with.
+func (c *Collector) Update(interval time.Duration) {
 	start := time.Now()
+	c.interval = interval
+	// the removed processes and containers that were exported during their grace period are moved to the terminated buckets
+	c.expireTerminated(int(c.scrapes.Swap(0)))
+
 	// reset the previous collected value because not all process will have new data
 	// that is, a process that was inactive will not have any update but we need to set its metrics to 0
 	c.resetDeltaValue()
@@ -93,12 +171,61 @@ func (c *Collector) Update() {
 	c.updateResourceUtilizationMetrics()
 
 	// collect node power and estimate process power
-	c.UpdateEnergyUtilizationMetrics()
+	c.UpdateEnergyUtilizationMetrics(interval)
 
 	c.printDebugMetrics()
+	c.PublishSnapshot()
+	// the processes that exited are exported with their final values before they are removed
+	c.removeExitedProcesses()
+	c.saveStateIfDue()
+	telemetry.SetTrackedEntries(len(c.ProcessStats), len(c.ContainerStats), len(c.VMStats))
+	telemetry.ObservePhase(telemetry.PhaseTotal, start)
 	klog.V(5).Infof("Collector Update elapsed time: %s", time.Since(start))
 }
 
+// PublishSnapshot copies the stats into the idle snapshot and publishes it, it is called at the end
+// of every update. The caller must hold the lock that guards the stats.
+func (c *Collector) PublishSnapshot() {
+	snapshot := c.idle
+	if snapshot == nil || snapshot.Held() {
+		// a reader still holds the snapshot of the previous update, which is left to it rather than waited for
+		snapshot = stats.NewSnapshot()
+	}
+	snapshot.Update(&c.NodeStats, c.ProcessStats, c.ContainerStats, c.VMStats)
+	snapshot.Interval = c.interval
+	snapshot.ProcessExits = append(snapshot.ProcessExits[:0], c.exitedProcesses...)
+	c.addTerminatedToSnapshot(snapshot)
+
+	c.snapshotMx.Lock()
+	c.snapshot, c.idle = snapshot, c.snapshot
+	c.snapshotMx.Unlock()
+}
+
+// Snapshot returns the stats published after the last update, which must not be modified. It does not
+// require the lock, so that reading the stats does not delay the updates. The reader calls Release on the
+// snapshot when it is done with it, so that the collector can reuse it.
+func (c *Collector) Snapshot() *stats.Snapshot {
+	c.snapshotMx.Lock()
+	defer c.snapshotMx.Unlock()
+	c.snapshot.Acquire()
+	return c.snapshot
+}
+
+// MarkScraped counts a scrape of the metrics endpoint, the scrapes end the grace period of the removed entries
+func (c *Collector) MarkScraped() {
+	c.scrapes.Add(1)
+}
+
+// Scrapes returns the number of scrapes of the metrics endpoint since the last update
+func (c *Collector) Scrapes() int {
+	return int(c.scrapes.Load())
+}
+
+// BPFError returns the error of the last read of the bpf tables, or nil if it succeeded
+func (c *Collector) BPFError() error {
+	return c.bpfErr
+}
+
 // resetDeltaValue resets existing podEnergy previous curr value
 func (c *Collector) resetDeltaValue() {
 	c.NodeStats.ResetDeltaValues()
@@ -115,24 +242,50 @@ func (c *Collector) resetDeltaValue() {
 			v.ResetDeltaValues()
 		}
 	}
+	// the removed entries export their final aggregated values during the grace period, but no new delta
+	for _, v := range c.terminatingProcesses {
+		v.stats.ResetDeltaValues()
+	}
+	for _, v := range c.terminatingContainers {
+		v.stats.ResetDeltaValues()
+	}
+	for _, v := range c.TerminatedProcessStats {
+		v.ResetDeltaValues()
+	}
+	for _, v := range c.TerminatedContainerStats {
+		v.ResetDeltaValues()
+	}
 }
 
-func (c *Collector) UpdateEnergyUtilizationMetrics() {
-	c.UpdateNodeEnergyUtilizationMetrics()
-	c.UpdateProcessEnergyUtilizationMetrics()
+func (c *Collector) UpdateEnergyUtilizationMetrics(interval time.Duration) {
+	start := time.Now()
+	c.UpdateNodeEnergyUtilizationMetrics(interval)
+	telemetry.ObservePhase(telemetry.PhaseNodeEnergy, start)
+
+	start = time.Now()
+	c.UpdateProcessEnergyUtilizationMetrics(interval)
+	telemetry.ObservePhase(telemetry.PhaseProcessEnergy, start)
+
+	// compare the process energy with the node energy before it is aggregated per container and VM
+	start = time.Now()
+	c.ReconcileProcessEnergy()
+	telemetry.ObservePhase(telemetry.PhaseReconcile, start)
+
 	// aggregate the process metrics per container and/or VMs
+	start = time.Now()
 	c.AggregateProcessEnergyUtilizationMetrics()
+	telemetry.ObservePhase(telemetry.PhaseEnergyAggregation, start)
 }
 
 // UpdateNodeEnergyUtilizationMetrics collects real-time node resource power utilization
 // if there is no real-time power meter, use the container resource usage metrics to estimate the node's resource power
-func (c *Collector) UpdateNodeEnergyUtilizationMetrics() {
-	energy.UpdateNodeEnergyMetrics(&c.NodeStats)
+func (c *Collector) UpdateNodeEnergyUtilizationMetrics(interval time.Duration) {
+	energy.UpdateNodeEnergyMetrics(&c.NodeStats, interval)
 }
 
 // UpdateProcessEnergyUtilizationMetrics estimates the process energy consumption using its resource utilization and the node components energy consumption
-func (c *Collector) UpdateProcessEnergyUtilizationMetrics() {
-	energy.UpdateProcessEnergy(c.ProcessStats, &c.NodeStats)
+func (c *Collector) UpdateProcessEnergyUtilizationMetrics(interval time.Duration) {
+	energy.UpdateProcessEnergy(c.ProcessStats, &c.NodeStats, interval)
 }
 
 func (c *Collector) updateResourceUtilizationMetrics() {
@@ -140,20 +293,93 @@ func (c *Collector) updateResourceUtilizationMetrics() {
 	c.updateProcessResourceUtilizationMetrics()
 
 	// aggregate processes' resource utilization metrics to containers, virtual machines and nodes
+	start := time.Now()
 	c.AggregateProcessResourceUtilizationMetrics()
+	telemetry.ObservePhase(telemetry.PhaseResourceAggregation, start)
 }
 
 func (c *Collector) updateProcessResourceUtilizationMetrics() {
 	// update process metrics regarding the resource utilization to be used to calculate the energy consumption
-	// we first updates the bpf which is responsible to include new processes in the ProcessStats collection
-	resourceBpf.UpdateProcessBPFMetrics(c.bpfExporter, c.ProcessStats)
-	if config.IsGPUEnabled() {
-		if acc.GetActiveAcceleratorByType(config.GPU) != nil {
-			accelerator.UpdateProcessGPUUtilizationMetrics(c.ProcessStats)
+	// the sources are collected in the registration order, the bpf source first includes the new processes in the ProcessStats collection
+	c.bpfErr = nil
+	c.processExitsTracked = false
+	for _, source := range stats.ResourceSources() {
+		start := time.Now()
+		c.collectProcessExits(source)
+		err := source.Collect(c.ProcessStats)
+		c.collectMultiplexingRatios(source)
+		telemetry.ObservePhase(source.Name(), start)
+		if err == nil {
+			continue
+		}
+		if source.Name() == resourceBpf.SourceName {
+			c.bpfErr = err
+		} else {
+			klog.V(3).Infof("failed to collect the %s metrics: %v", source.Name(), err)
 		}
 	}
 }
 
+// collectProcessExits reads the processes that exited before the resource utilization of the source is collected,
+// so that the final resource utilization of the processes is collected before they are removed
+func (c *Collector) collectProcessExits(source stats.ResourceSource) {
+	exitSource, ok := source.(stats.ProcessExitSource)
+	if !ok {
+		return
+	}
+	exits, err := exitSource.CollectProcessExits()
+	if err != nil {
+		klog.V(5).Infof("failed to collect the processes that exited from the %s source: %v", source.Name(), err)
+		return
+	}
+	c.processExitsTracked = true
+	c.exitedProcesses = append(c.exitedProcesses, exits...)
+	c.splitReusedProcesses(exits)
+}
+
+// splitReusedProcesses removes the processes that exited and whose PID was already reused by a new process,
+// so that the resource utilization of the new process is not collected into the entry of the exited process
+func (c *Collector) splitReusedProcesses(exits []stats.ProcessExit) {
+	for _, exit := range exits {
+		process, exists := c.ProcessStats[exit.PID]
+		if !exists || process.StartTime != exit.StartTime {
+			continue
+		}
+		if startTime := utils.GetProcessStartTime(config.ProcDir(), exit.PID); startTime != 0 && startTime != exit.StartTime {
+			c.removeProcess(process)
+		}
+	}
+}
+
+// collectMultiplexingRatios reads how long the hardware counters of the source were counting, which tells if the
+// counter values were extrapolated
+func (c *Collector) collectMultiplexingRatios(source stats.ResourceSource) {
+	multiplexingSource, ok := source.(stats.MultiplexingSource)
+	if !ok {
+		return
+	}
+	ratios, err := multiplexingSource.CollectMultiplexingRatios()
+	if err != nil {
+		klog.V(5).Infof("failed to collect the multiplexing ratios from the %s source: %v", source.Name(), err)
+	}
+	for counter, ratio := range ratios {
+		c.NodeStats.MultiplexingRatio[counter] = ratio
+	}
+}
+
+// removeExitedProcesses removes the processes that exited. A process is identified by its PID and start time,
+// so that a new process with a reused PID is not removed.
+func (c *Collector) removeExitedProcesses() {
+	for _, exit := range c.exitedProcesses {
+		process, exists := c.ProcessStats[exit.PID]
+		if !exists || process.StartTime != exit.StartTime {
+			continue
+		}
+		c.removeProcess(process)
+	}
+	c.exitedProcesses = c.exitedProcesses[:0]
+}
+
 // AggregateProcessResourceUtilizationMetrics aggregates processes' resource utilization metrics to containers, virtual machines and nodes
 func (c *Collector) AggregateProcessResourceUtilizationMetrics() {
 	foundContainer := make(map[string]bool)
@@ -193,6 +419,9 @@ func (c *Collector) AggregateProcessResourceUtilizationMetrics() {
 		}
 	}
 
+	// the restored containers and VMs that do not exist anymore expire
+	c.expireRestored(foundContainer, foundVM, time.Now())
+
 	// clean up the cache
 	// TODO: improve the removal of deleted containers from ContainerStats. Currently we verify the maxInactiveContainers using the found map
 	if config.IsExposeContainerStatsEnabled() {
@@ -205,11 +434,24 @@ func (c *Collector) AggregateProcessResourceUtilizationMetrics() {
 
 // handleInactiveProcesses
 func (c *Collector) handleIdlingProcess(pStat *stats.ProcessStats) {
+	if pStat.StartTime != 0 {
+		if c.processExitsTracked && pStat.IdleCounter < maxIdleCyclesWithExits {
+			// the process is removed when it exits
+			return
+		}
+		// the process does not exist anymore, or its PID was reused by a new process
+		if utils.GetProcessStartTime(config.ProcDir(), pStat.PID) != pStat.StartTime {
+			c.removeProcess(pStat)
+			telemetry.IncIdleProcessesEvicted()
+		}
+		return
+	}
 	proc, _ := os.FindProcess(int(pStat.PID))
 	err := proc.Signal(syscall.Signal(0))
 	if err != nil {
 		// delete if the process does not exist anymore
-		delete(c.ProcessStats, pStat.PID)
+		c.removeProcess(pStat)
+		telemetry.IncIdleProcessesEvicted()
 		return
 	}
 }
@@ -228,7 +470,7 @@ func (c *Collector) handleInactiveContainers(foundContainer map[string]bool) {
 				continue
 			}
 			if _, found := aliveContainers[containerID]; !found {
-				delete(c.ContainerStats, containerID)
+				c.removeContainer(c.ContainerStats[containerID])
 			}
 		}
 	}
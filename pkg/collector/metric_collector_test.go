@@ -1,11 +1,14 @@
 package collector
 
 import (
+	"time"
+
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
 	"github.com/sustainable-computing-io/kepler/pkg/bpf"
 	"github.com/sustainable-computing-io/kepler/pkg/cgroup"
+	resourceBpf "github.com/sustainable-computing-io/kepler/pkg/collector/resourceutilization/bpf"
 	"github.com/sustainable-computing-io/kepler/pkg/collector/stats"
 	"github.com/sustainable-computing-io/kepler/pkg/config"
 	"github.com/sustainable-computing-io/kepler/pkg/model"
@@ -52,7 +55,7 @@ var _ = Describe("Test Collector Unit", func() {
 		// The default estimator model is the ratio
 		model.CreatePowerEstimatorModels(stats.GetProcessFeatureNames())
 		// update container and node metrics
-		metricCollector.UpdateProcessEnergyUtilizationMetrics()
+		metricCollector.UpdateProcessEnergyUtilizationMetrics(time.Duration(config.SamplePeriodSec()) * time.Second)
 		metricCollector.AggregateProcessEnergyUtilizationMetrics()
 		dynEnergyInPkg := metricCollector.ContainerStats["container1"].EnergyUsage[config.DynEnergyInPkg].SumAllDeltaValues()
 		// The node components dynamic power were set to 35000mJ, since the kepler interval is 3s, the power is 11667mJ
@@ -73,4 +76,33 @@ var _ = Describe("Test Collector Unit", func() {
 		Expect(len(metricCollector.ContainerStats)).Should(Equal(2))
 	})
 
+	It("reuses the previous snapshot once its readers released it", func() {
+		bpfExporter := bpf.NewMockExporter(bpf.DefaultSupportedMetrics())
+		metricCollector := newMockCollector(bpfExporter)
+		metricCollector.PublishSnapshot()
+		first := metricCollector.Snapshot()
+		first.Release()
+		metricCollector.PublishSnapshot()
+		second := metricCollector.Snapshot()
+		Expect(second).NotTo(BeIdenticalTo(first))
+
+		// the first snapshot is not held anymore, the update is copied into it
+		metricCollector.PublishSnapshot()
+		Expect(metricCollector.Snapshot()).To(BeIdenticalTo(first))
+
+		// the second snapshot is still held, so the next update does not modify it
+		delete(metricCollector.ProcessStats, 1)
+		metricCollector.PublishSnapshot()
+		Expect(metricCollector.Snapshot()).NotTo(BeIdenticalTo(second))
+		Expect(second.ProcessStats).To(HaveKey(uint64(1)))
+	})
+
+	It("records the multiplexing ratios of the hardware counters", func() {
+		bpfExporter := bpf.NewMockExporter(bpf.DefaultSupportedMetrics())
+		metricCollector := newMockCollector(bpfExporter)
+		metricCollector.collectMultiplexingRatios(resourceBpf.NewSource(bpfExporter))
+		Expect(metricCollector.NodeStats.MultiplexingRatio).To(HaveKeyWithValue(config.CPUCycle, float64(1)))
+		Expect(metricCollector.NodeStats.MultiplexingRatio).To(HaveKeyWithValue(config.CacheMiss, float64(1)))
+	})
+
 })
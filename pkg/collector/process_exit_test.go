@@ -0,0 +1,120 @@
+package collector
+
+import (
+	"os"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/sustainable-computing-io/kepler/pkg/bpf"
+	"github.com/sustainable-computing-io/kepler/pkg/collector/stats"
+	"github.com/sustainable-computing-io/kepler/pkg/config"
+)
+
+// exitSource reports the exits of the processes without collecting any resource utilization
+type exitSource struct {
+	exits []stats.ProcessExit
+	err   error
+}
+
+func (s *exitSource) Name() string               { return "exits" }
+func (s *exitSource) Init() error                { return nil }
+func (s *exitSource) SupportedMetrics() []string { return nil }
+func (s *exitSource) Close()                     {}
+
+func (s *exitSource) Collect(map[uint64]*stats.ProcessStats) error {
+	return nil
+}
+
+func (s *exitSource) CollectProcessExits() ([]stats.ProcessExit, error) {
+	exits := s.exits
+	s.exits = nil
+	return exits, s.err
+}
+
+var _ = Describe("Test process exits", func() {
+	var c *Collector
+
+	BeforeEach(func() {
+		_, err := config.Initialize(".")
+		Expect(err).NotTo(HaveOccurred())
+		c = newMockCollector(bpf.NewMockExporter(bpf.DefaultSupportedMetrics()))
+		config.SetTerminatedConfig(config.TerminatedBucketContainer, 0)
+	})
+
+	AfterEach(func() {
+		config.SetTerminatedConfig("", 0)
+	})
+
+	addProcess := func(pid, startTime, energy uint64) *stats.ProcessStats {
+		p := stats.NewProcessStats(pid, 0, "container1", "", "process")
+		p.StartTime = startTime
+		p.EnergyUsage[config.DynEnergyInPkg].AddDeltaStat(stats.MockedSocketID, energy)
+		c.ProcessStats[pid] = p
+		return p
+	}
+
+	It("removes the process that exited after its final values are accounted", func() {
+		addProcess(exitedPID, 100, 1000)
+		exits := []stats.ProcessExit{{PID: exitedPID, StartTime: 100, ParentPID: 1, ParentStartTime: 1}}
+		c.collectProcessExits(&exitSource{exits: exits})
+		Expect(c.processExitsTracked).To(BeTrue())
+		Expect(c.ProcessStats).To(HaveKey(uint64(exitedPID)))
+
+		// the snapshot of the cycle has the exits and the final values of the processes
+		c.PublishSnapshot()
+		Expect(c.Snapshot().ProcessExits).To(Equal(exits))
+		Expect(c.Snapshot().ProcessStats).To(HaveKey(uint64(exitedPID)))
+
+		c.removeExitedProcesses()
+		Expect(c.ProcessStats).NotTo(HaveKey(uint64(exitedPID)))
+		Expect(c.exitedProcesses).To(BeEmpty())
+		bucket := c.TerminatedProcessStats["container1/"]
+		Expect(bucket).NotTo(BeNil())
+		Expect(bucket.EnergyUsage[config.DynEnergyInPkg].SumAllAggrValues()).To(Equal(uint64(1000)))
+	})
+
+	It("does not remove a new process that reuses the PID of the process that exited", func() {
+		addProcess(exitedPID, 200, 1000)
+		c.collectProcessExits(&exitSource{exits: []stats.ProcessExit{{PID: exitedPID, StartTime: 100}}})
+		c.removeExitedProcesses()
+		Expect(c.ProcessStats).To(HaveKey(uint64(exitedPID)))
+		Expect(c.TerminatedProcessStats).To(BeEmpty())
+	})
+
+	It("removes the process that exited when a new process already reuses its PID", func() {
+		// the test process reuses the PID with a different start time
+		pid := uint64(os.Getpid())
+		addProcess(pid, 1, 1000)
+		addProcess(exitedPID, 100, 1000)
+		c.collectProcessExits(&exitSource{exits: []stats.ProcessExit{{PID: pid, StartTime: 1}, {PID: exitedPID, StartTime: 100}}})
+		Expect(c.ProcessStats).NotTo(HaveKey(pid))
+		Expect(c.TerminatedProcessStats).To(HaveKey("container1/"))
+		// the PID of the other process was not reused, it collects its final values before it is removed
+		Expect(c.ProcessStats).To(HaveKey(uint64(exitedPID)))
+	})
+
+	It("probes the idle processes only when the exits are not reported", func() {
+		p := addProcess(exitedPID, 100, 0)
+		p.IdleCounter = 1
+		c.processExitsTracked = true
+		c.handleIdlingProcess(p)
+		Expect(c.ProcessStats).To(HaveKey(uint64(exitedPID)))
+
+		c.processExitsTracked = false
+		c.collectProcessExits(&exitSource{err: bpf.ErrProcessExitsNotSupported})
+		Expect(c.processExitsTracked).To(BeFalse())
+		// the process does not exist, so its start time is not found
+		c.handleIdlingProcess(p)
+		Expect(c.ProcessStats).NotTo(HaveKey(uint64(exitedPID)))
+	})
+
+	It("probes the processes that stay idle although the exits are reported", func() {
+		// the exit event of the process was dropped
+		p := addProcess(exitedPID, 100, 0)
+		p.IdleCounter = maxIdleCyclesWithExits
+		c.processExitsTracked = true
+		c.handleIdlingProcess(p)
+		Expect(c.ProcessStats).NotTo(HaveKey(uint64(exitedPID)))
+	})
+})
@@ -0,0 +1,91 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"math/bits"
+	"sort"
+
+	"github.com/sustainable-computing-io/kepler/pkg/collector/stats/types"
+	"github.com/sustainable-computing-io/kepler/pkg/config"
+	"k8s.io/klog/v2"
+)
+
+// share is the energy of a process for a metric, which is scaled to reconcile the process energy with the node energy
+type share struct {
+	stat      *types.UInt64Stat
+	energy    uint64
+	remainder uint64
+}
+
+// ReconcileProcessEnergy compares the energy attributed to the processes with the node energy of each dynamic and idle
+// energy metric, and stores the residual in the node stats. The residual comes from the rounding of the power models,
+// and from the models that do not split the node power. If RECONCILE_ENERGY is enabled, the residual is redistributed
+// to the processes proportionally to their energy, so that the process energy sums up to the node energy.
+func (c *Collector) ReconcileProcessEnergy() {
+	metrics := append([]string{}, c.NodeStats.DynEnergyMetrics()...)
+	metrics = append(metrics, c.NodeStats.IdleEnergyMetrics()...)
+	for _, metric := range metrics {
+		nodeEnergy := c.NodeStats.EnergyUsage[metric].SumAllDeltaValues()
+		processEnergy := uint64(0)
+		for _, p := range c.ProcessStats {
+			processEnergy += p.EnergyUsage[metric].SumAllDeltaValues()
+		}
+		residual := int64(nodeEnergy) - int64(processEnergy)
+		c.NodeStats.AttributionResidual[metric] = residual
+		// the residual cannot be redistributed proportionally if no energy was attributed
+		if residual == 0 || !config.IsReconcileEnergyEnabled() || nodeEnergy == 0 || processEnergy == 0 {
+			continue
+		}
+		c.redistribute(metric, nodeEnergy, processEnergy)
+		klog.V(5).Infof("Redistributed the residual of %s: %d mJ", metric, residual)
+	}
+}
+
+// redistribute scales the energy of the processes for the metric so that it sums up to the node energy. The energy is
+// rounded down, and the mJ that are left are given to the processes with the largest remainders.
+func (c *Collector) redistribute(metric string, nodeEnergy, processEnergy uint64) {
+	pids := make([]uint64, 0, len(c.ProcessStats))
+	for pid := range c.ProcessStats {
+		pids = append(pids, pid)
+	}
+	// the order is deterministic, so that the same process gets the mJ left on ties
+	sort.Slice(pids, func(i, j int) bool { return pids[i] < pids[j] })
+
+	shares := []share{}
+	assigned := uint64(0)
+	for _, pid := range pids {
+		for _, stat := range c.ProcessStats[pid].EnergyUsage[metric] {
+			if stat.GetDelta() == 0 {
+				continue
+			}
+			// a share is not greater than the total process energy, so the scaled share fits in 64 bits
+			hi, lo := bits.Mul64(stat.GetDelta(), nodeEnergy)
+			energy, remainder := bits.Div64(hi, lo, processEnergy)
+			shares = append(shares, share{stat: stat, energy: energy, remainder: remainder})
+			assigned += energy
+		}
+	}
+	sort.SliceStable(shares, func(i, j int) bool { return shares[i].remainder > shares[j].remainder })
+	for i := range shares {
+		if assigned < nodeEnergy {
+			shares[i].energy++
+			assigned++
+		}
+		shares[i].stat.ReplaceDelta(shares[i].energy)
+	}
+}
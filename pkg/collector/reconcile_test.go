@@ -0,0 +1,80 @@
+package collector
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/sustainable-computing-io/kepler/pkg/bpf"
+	"github.com/sustainable-computing-io/kepler/pkg/collector/stats"
+	"github.com/sustainable-computing-io/kepler/pkg/config"
+	"github.com/sustainable-computing-io/kepler/pkg/model"
+)
+
+// processDelta returns the energy of the last collection cycle of all processes for the metric
+func processDelta(c *Collector, metric string) uint64 {
+	energy := uint64(0)
+	for _, p := range c.ProcessStats {
+		energy += p.EnergyUsage[metric].SumAllDeltaValues()
+	}
+	return energy
+}
+
+var _ = Describe("Test energy reconciliation", func() {
+	var c *Collector
+
+	BeforeEach(func() {
+		_, err := config.Initialize(".")
+		Expect(err).NotTo(HaveOccurred())
+		c = newMockCollector(bpf.NewMockExporter(bpf.DefaultSupportedMetrics()))
+		model.CreatePowerEstimatorModels(stats.GetProcessFeatureNames())
+		c.UpdateProcessEnergyUtilizationMetrics(time.Duration(config.SamplePeriodSec()) * time.Second)
+	})
+
+	AfterEach(func() {
+		config.SetEnabledReconcileEnergy(false)
+	})
+
+	It("reports the residual of the rounded process energy", func() {
+		nodeEnergy := c.NodeStats.EnergyUsage[config.DynEnergyInPkg].SumAllDeltaValues()
+		processEnergy := processDelta(c, config.DynEnergyInPkg)
+		Expect(processEnergy).NotTo(Equal(nodeEnergy))
+
+		c.ReconcileProcessEnergy()
+		Expect(c.NodeStats.AttributionResidual[config.DynEnergyInPkg]).To(Equal(int64(nodeEnergy) - int64(processEnergy)))
+		// the residual is only reported
+		Expect(processDelta(c, config.DynEnergyInPkg)).To(Equal(processEnergy))
+	})
+
+	It("redistributes the residual so that the process energy sums up to the node energy", func() {
+		config.SetEnabledReconcileEnergy(true)
+		nodeEnergy := c.NodeStats.EnergyUsage[config.DynEnergyInPkg].SumAllDeltaValues()
+
+		c.ReconcileProcessEnergy()
+		Expect(processDelta(c, config.DynEnergyInPkg)).To(Equal(nodeEnergy))
+		for _, p := range c.ProcessStats {
+			stat := p.EnergyUsage[config.DynEnergyInPkg][stats.MockedSocketID]
+			Expect(stat.GetAggr()).To(Equal(stat.GetDelta()))
+		}
+	})
+
+	It("gives the mJ left to the processes with the largest remainders", func() {
+		config.SetEnabledReconcileEnergy(true)
+		c.NodeStats.EnergyUsage[config.DynEnergyInDRAM].SetDeltaStat(stats.MockedSocketID, 10)
+		for pid := uint64(1); pid <= 3; pid++ {
+			if _, exists := c.ProcessStats[pid]; !exists {
+				c.ProcessStats[pid] = stats.NewProcessStats(pid, pid, "container1", "", "command")
+			}
+			c.ProcessStats[pid].EnergyUsage[config.DynEnergyInDRAM].SetDeltaStat(stats.MockedSocketID, 1)
+		}
+
+		c.ReconcileProcessEnergy()
+		Expect(c.NodeStats.AttributionResidual[config.DynEnergyInDRAM]).To(Equal(int64(7)))
+		energy := []uint64{}
+		for pid := uint64(1); pid <= 3; pid++ {
+			energy = append(energy, c.ProcessStats[pid].EnergyUsage[config.DynEnergyInDRAM].SumAllDeltaValues())
+		}
+		Expect(energy).To(Equal([]uint64{4, 3, 3}))
+	})
+})
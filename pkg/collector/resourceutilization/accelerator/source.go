@@ -0,0 +1,64 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package accelerator
+
+import (
+	"errors"
+
+	"github.com/sustainable-computing-io/kepler/pkg/collector/stats"
+	"github.com/sustainable-computing-io/kepler/pkg/config"
+	acc "github.com/sustainable-computing-io/kepler/pkg/sensors/accelerator"
+)
+
+// source collects the GPU compute and memory utilization of the processes
+type source struct {
+	name string
+}
+
+// NewSource returns the resource source of the GPU utilization, the GPU accelerator
+// is registered and shut down by its owner
+func NewSource() stats.ResourceSource {
+	return &source{name: config.GPU}
+}
+
+// Name returns the name of the GPU device, which is the source label of the GPU metrics
+func (s *source) Name() string {
+	return s.name
+}
+
+func (s *source) Init() error {
+	if !config.IsGPUEnabled() {
+		return errors.New("the GPU is not enabled")
+	}
+	gpu := acc.GetActiveAcceleratorByType(config.GPU)
+	if gpu == nil {
+		return errors.New("no active GPU accelerator")
+	}
+	s.name = gpu.Device().Name()
+	return nil
+}
+
+func (s *source) SupportedMetrics() []string {
+	return []string{config.GPUComputeUtilization, config.GPUMemUtilization}
+}
+
+func (s *source) Collect(processStats map[uint64]*stats.ProcessStats) error {
+	UpdateProcessGPUUtilizationMetrics(processStats)
+	return nil
+}
+
+func (s *source) Close() {}
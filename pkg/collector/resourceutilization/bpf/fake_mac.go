@@ -8,6 +8,6 @@ import (
 	"github.com/sustainable-computing-io/kepler/pkg/collector/stats"
 )
 
-func UpdateProcessBPFMetrics(bpfExporter bpf.Exporter, processStats map[uint64]*stats.ProcessStats) {
-
+func UpdateProcessBPFMetrics(bpfExporter bpf.Exporter, processStats map[uint64]*stats.ProcessStats) error {
+	return nil
 }
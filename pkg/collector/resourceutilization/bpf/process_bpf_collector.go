@@ -28,6 +28,7 @@ import (
 	"github.com/sustainable-computing-io/kepler/pkg/collector/stats"
 	"github.com/sustainable-computing-io/kepler/pkg/config"
 	"github.com/sustainable-computing-io/kepler/pkg/libvirt"
+	"github.com/sustainable-computing-io/kepler/pkg/metrics/telemetry"
 	"github.com/sustainable-computing-io/kepler/pkg/utils"
 
 	"k8s.io/klog/v2"
@@ -51,6 +52,24 @@ func updateSWCounters(key uint64, ct *ProcessBPFMetrics, processStats map[uint64
 			processStats[key].ResourceUsage[config.IRQNetRXLabel].AddDeltaStat(utils.GenericSocketID, uint64(ct.VecNr[bpf.IRQNetRX]))
 		case config.IRQBlockLabel:
 			processStats[key].ResourceUsage[config.IRQBlockLabel].AddDeltaStat(utils.GenericSocketID, uint64(ct.VecNr[bpf.IRQBlock]))
+		case config.BytesTx:
+			processStats[key].ResourceUsage[config.BytesTx].AddDeltaStat(utils.GenericSocketID, ct.BytesTx)
+		case config.BytesRx:
+			processStats[key].ResourceUsage[config.BytesRx].AddDeltaStat(utils.GenericSocketID, ct.BytesRx)
+		case config.PacketsTx:
+			processStats[key].ResourceUsage[config.PacketsTx].AddDeltaStat(utils.GenericSocketID, ct.PacketsTx)
+		case config.PacketsRx:
+			processStats[key].ResourceUsage[config.PacketsRx].AddDeltaStat(utils.GenericSocketID, ct.PacketsRx)
+		case config.BlockReadBytes:
+			processStats[key].ResourceUsage[config.BlockReadBytes].AddDeltaStat(utils.GenericSocketID, ct.BlockReadBytes)
+		case config.BlockWriteBytes:
+			processStats[key].ResourceUsage[config.BlockWriteBytes].AddDeltaStat(utils.GenericSocketID, ct.BlockWriteBytes)
+		case config.BlockReadRequests:
+			processStats[key].ResourceUsage[config.BlockReadRequests].AddDeltaStat(utils.GenericSocketID, ct.BlockReadRequests)
+		case config.BlockWriteRequests:
+			processStats[key].ResourceUsage[config.BlockWriteRequests].AddDeltaStat(utils.GenericSocketID, ct.BlockWriteRequests)
+		case config.BlockServiceTime:
+			processStats[key].ResourceUsage[config.BlockServiceTime].AddDeltaStat(utils.GenericSocketID, ct.BlockServiceTime)
 		default:
 			klog.Errorf("counter %s is not supported\n", counterKey)
 		}
@@ -60,35 +79,18 @@ func updateSWCounters(key uint64, ct *ProcessBPFMetrics, processStats map[uint64
 // update hardware counter metrics
 func updateHWCounters(key uint64, ct *ProcessBPFMetrics, processStats map[uint64]*stats.ProcessStats, bpfSupportedMetrics bpf.SupportedMetrics) {
 	for counterKey := range bpfSupportedMetrics.HardwareCounters {
-		var val uint64
-		var event string
-		switch counterKey {
-		case config.CPUCycle:
-			val = ct.CpuCycles
-			event = config.CPUCycle
-		case config.CPURefCycle:
-			val = ct.CpuCycles
-			event = config.CPURefCycle
-		case config.CPUInstruction:
-			val = ct.CpuInstr
-			event = config.CPUInstruction
-		case config.CacheMiss:
-			val = ct.CacheMiss
-			event = config.CacheMiss
-		default:
-			klog.Errorf("counter %s is not supported\n", counterKey)
-		}
-		processStats[key].ResourceUsage[event].AddDeltaStat(utils.GenericSocketID, val)
+		processStats[key].ResourceUsage[counterKey].AddDeltaStat(utils.GenericSocketID, ct.HardwareCounters[counterKey])
 	}
 }
 
 // UpdateProcessBPFMetrics reads the BPF tables with process/pid/cgroupid metrics (CPU time, available HW counters)
-func UpdateProcessBPFMetrics(bpfExporter bpf.Exporter, processStats map[uint64]*stats.ProcessStats) {
+func UpdateProcessBPFMetrics(bpfExporter bpf.Exporter, processStats map[uint64]*stats.ProcessStats) error {
 	processesData, err := bpfExporter.CollectProcesses()
 	if err != nil {
 		klog.Errorln("could not collect ebpf metrics")
-		return
+		return err
 	}
+	telemetry.SetBPFMapEntriesRead(len(processesData))
 	for _, ct := range processesData {
 		comm := C.GoString((*C.char)(unsafe.Pointer(&ct.Comm)))
 
@@ -98,8 +100,8 @@ func UpdateProcessBPFMetrics(bpfExporter bpf.Exporter, processStats map[uint64]*
 		}
 
 		if ct.Pid != 0 {
-			klog.V(6).Infof("process %s (pid=%d, cgroup=%d) has %d process run time, %d CPU cycles, %d instructions, %d cache misses, %d page cache hits",
-				comm, ct.Pid, ct.CgroupId, ct.ProcessRunTime, ct.CpuCycles, ct.CpuInstr, ct.CacheMiss, ct.PageCacheHit)
+			klog.V(6).Infof("process %s (pid=%d, cgroup=%d) has %d process run time, %d CPU cycles, %d instructions, %d cache misses, %d page cache hits, %d/%d bytes sent/received",
+				comm, ct.Pid, ct.CgroupId, ct.ProcessRunTime, ct.HardwareCounters[config.CPUCycle], ct.HardwareCounters[config.CPUInstruction], ct.HardwareCounters[config.CacheMiss], ct.PageCacheHit, ct.BytesTx, ct.BytesRx)
 		}
 
 		// if the pid is within a container, it will have a container ID
@@ -131,6 +133,10 @@ func UpdateProcessBPFMetrics(bpfExporter bpf.Exporter, processStats map[uint64]*
 		var pStat *stats.ProcessStats
 		if pStat, ok = processStats[mapKey]; !ok {
 			pStat = stats.NewProcessStats(mapKey, ct.CgroupId, containerID, vmID, process)
+			// the start time identifies the process together with its PID, it is unknown for the aggregated kernel processes
+			if mapKey == ct.Pid {
+				pStat.StartTime = utils.GetProcessStartTime(config.ProcDir(), mapKey)
+			}
 			processStats[mapKey] = pStat
 		} else if pStat.Command == "" {
 			pStat.Command = comm
@@ -141,4 +147,5 @@ func UpdateProcessBPFMetrics(bpfExporter bpf.Exporter, processStats map[uint64]*
 		updateSWCounters(mapKey, &ct, processStats, bpfSupportedMetrics)
 		updateHWCounters(mapKey, &ct, processStats, bpfSupportedMetrics)
 	}
+	return nil
 }
@@ -5,7 +5,40 @@ package bpf
 
 import (
 	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/sustainable-computing-io/kepler/pkg/bpf"
+	"github.com/sustainable-computing-io/kepler/pkg/collector/stats"
+	"github.com/sustainable-computing-io/kepler/pkg/config"
+	"github.com/sustainable-computing-io/kepler/pkg/utils"
 )
 
 var _ = Describe("Test hc collector", func() {
+	BeforeEach(func() {
+		_, err := config.Initialize(".")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		stats.CloseResourceSources()
+	})
+
+	It("collects the cycles and the reference cycles independently", func() {
+		process := bpf.ProcessMetrics{
+			HardwareCounters: map[string]uint64{
+				config.CPUCycle:    3000,
+				config.CPURefCycle: 2000,
+			},
+		}
+		process.Pid = 10
+		exporter := bpf.NewMockExporterWithProcesses(bpf.DefaultSupportedMetrics(), []bpf.ProcessMetrics{process})
+		Expect(stats.RegisterResourceSource(NewSource(exporter))).To(Succeed())
+
+		processStats := map[uint64]*stats.ProcessStats{}
+		Expect(UpdateProcessBPFMetrics(exporter, processStats)).To(Succeed())
+		Expect(processStats).To(HaveKey(uint64(10)))
+		usage := processStats[10].ResourceUsage
+		Expect(usage[config.CPUCycle][utils.GenericSocketID].GetDelta()).To(Equal(uint64(3000)))
+		Expect(usage[config.CPURefCycle][utils.GenericSocketID].GetDelta()).To(Equal(uint64(2000)))
+	})
 })
@@ -0,0 +1,91 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bpf
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+
+	"github.com/sustainable-computing-io/kepler/pkg/bpf"
+	"github.com/sustainable-computing-io/kepler/pkg/collector/stats"
+	"github.com/sustainable-computing-io/kepler/pkg/metrics/telemetry"
+	"github.com/sustainable-computing-io/kepler/pkg/utils"
+)
+
+// SourceName is the name of the bpf source, and the source label of its metrics
+const SourceName = "bpf"
+
+// clockTicksPerSecond is USER_HZ, the unit of the start time in /proc/<pid>/stat
+var clockTicksPerSecond = utils.ClockTicksPerSecond()
+
+// source collects the hardware and software counters of the processes from the BPF tables
+type source struct {
+	bpfExporter bpf.Exporter
+}
+
+// NewSource returns the resource source of the counters supported by the bpf exporter,
+// the exporter is attached and closed by its owner
+func NewSource(bpfExporter bpf.Exporter) stats.ResourceSource {
+	return &source{bpfExporter: bpfExporter}
+}
+
+func (s *source) Name() string {
+	return SourceName
+}
+
+func (s *source) Init() error {
+	return nil
+}
+
+func (s *source) SupportedMetrics() []string {
+	supported := s.bpfExporter.SupportedMetrics()
+	return append(sets.List(supported.HardwareCounters), sets.List(supported.SoftwareCounters)...)
+}
+
+func (s *source) Collect(processStats map[uint64]*stats.ProcessStats) error {
+	return UpdateProcessBPFMetrics(s.bpfExporter, processStats)
+}
+
+func (s *source) Close() {}
+
+// CollectProcessExits returns the processes that exited, whose start times are converted from
+// nanoseconds since boot to clock ticks since boot
+func (s *source) CollectProcessExits() ([]stats.ProcessExit, error) {
+	events, err := s.bpfExporter.CollectProcessExits()
+	if dropped := s.bpfExporter.CollectDroppedProcessExits(); dropped > 0 {
+		klog.V(3).Infof("%d process exit events were dropped, the processes will be removed when they are found idle", dropped)
+		telemetry.AddProcessExitsDropped(dropped)
+	}
+	nsPerTick := uint64(time.Second) / clockTicksPerSecond
+	exits := make([]stats.ProcessExit, 0, len(events))
+	for _, event := range events {
+		exits = append(exits, stats.ProcessExit{
+			PID:             uint64(event.Tgid),
+			StartTime:       event.StartTime / nsPerTick,
+			ParentPID:       uint64(event.Ppid),
+			ParentStartTime: event.ParentStartTime / nsPerTick,
+		})
+	}
+	return exits, err
+}
+
+// CollectMultiplexingRatios returns the share of the time each hardware counter was counting
+func (s *source) CollectMultiplexingRatios() (map[string]float64, error) {
+	return s.bpfExporter.CollectMultiplexingRatios()
+}
@@ -0,0 +1,16 @@
+//go:build !darwin
+// +build !darwin
+
+package bpf
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestBpfCollector(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Bpf Collector Suite")
+}
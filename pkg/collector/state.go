@@ -0,0 +1,317 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sustainable-computing-io/kepler/pkg/collector/stats"
+	"github.com/sustainable-computing-io/kepler/pkg/collector/stats/types"
+	"github.com/sustainable-computing-io/kepler/pkg/config"
+	"k8s.io/klog/v2"
+)
+
+// stateVersion is the schema version of the state file, it must be increased when the schema changes
+// and the previous versions must be migrated in readState
+const stateVersion = 1
+
+// checkpoint is the content of the state file
+type checkpoint struct {
+	Version    int                        `json:"version"`
+	NodeName   string                     `json:"node_name"`
+	Time       time.Time                  `json:"time"`
+	Node       aggrValues                 `json:"node"`
+	Containers map[string]*containerState `json:"containers"`
+	VMs        map[string]*vmState        `json:"vms"`
+}
+
+// aggrValues are the aggregated values in a checkpoint per metric and source ID
+type aggrValues struct {
+	EnergyUsage   map[string]map[string]uint64 `json:"energy_usage"`
+	ResourceUsage map[string]map[string]uint64 `json:"resource_usage"`
+}
+
+type containerState struct {
+	aggrValues
+	ContainerName string    `json:"container_name"`
+	PodName       string    `json:"pod_name"`
+	Namespace     string    `json:"namespace"`
+	LastSeen      time.Time `json:"last_seen"`
+}
+
+type vmState struct {
+	aggrValues
+	PID      uint64    `json:"pid"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+func newAggrValues(s *stats.Stats, energyMetrics []string) aggrValues {
+	v := aggrValues{
+		EnergyUsage:   make(map[string]map[string]uint64, len(energyMetrics)),
+		ResourceUsage: make(map[string]map[string]uint64, len(s.ResourceUsage)),
+	}
+	for _, metric := range energyMetrics {
+		v.EnergyUsage[metric] = collectionAggrValues(s.EnergyUsage[metric])
+	}
+	for metric, collection := range s.ResourceUsage {
+		v.ResourceUsage[metric] = collectionAggrValues(collection)
+	}
+	return v
+}
+
+func collectionAggrValues(collection types.UInt64StatCollection) map[string]uint64 {
+	values := make(map[string]uint64, len(collection))
+	for id, stat := range collection {
+		values[id] = stat.GetAggr()
+	}
+	return values
+}
+
+// restore sets the aggregated values of the metrics that exist in the stats, the delta values are 0
+// so that the next delta values are added to the restored aggregated values
+func (v *aggrValues) restore(s *stats.Stats) {
+	restoreCollections(s.EnergyUsage, v.EnergyUsage)
+	restoreCollections(s.ResourceUsage, v.ResourceUsage)
+}
+
+func restoreCollections(dst map[string]types.UInt64StatCollection, src map[string]map[string]uint64) {
+	for metric, values := range src {
+		collection, exists := dst[metric]
+		if !exists {
+			// the metric is not collected anymore
+			continue
+		}
+		for id, aggr := range values {
+			collection[id] = types.NewUInt64Stat(aggr, 0)
+		}
+	}
+}
+
+// EnableState restores the aggregated values of the state file, if it is configured, so that the counters continue
+// from the values saved before the restart, and saves the state every STATE_CHECKPOINT_CYCLES collection cycles.
+// It must be called before the collection starts. The standalone subcommands do not enable it, they start from zero.
+func (c *Collector) EnableState() {
+	c.stateEnabled = true
+	c.restoreState()
+	c.PublishSnapshot()
+}
+
+// SaveState writes the aggregated values of the node, containers and VMs into the state file, if it is enabled and
+// configured. The caller must hold the lock that guards the stats.
+func (c *Collector) SaveState() {
+	file := config.State().File
+	if !c.stateEnabled || file == "" {
+		return
+	}
+	c.checkpointCycles = 0
+	if err := writeState(file, c.newCheckpoint(time.Now())); err != nil {
+		klog.Errorf("failed to save the state: %v", err)
+	}
+}
+
+// saveStateIfDue saves the state every STATE_CHECKPOINT_CYCLES collection cycles
+func (c *Collector) saveStateIfDue() {
+	if !c.stateEnabled || config.State().File == "" {
+		return
+	}
+	c.checkpointCycles++
+	if c.checkpointCycles >= config.State().CheckpointCycles {
+		c.SaveState()
+	}
+}
+
+// newCheckpoint copies the aggregated values. The node checkpoints its dynamic and idle energy, and its platform and
+// GPU energy, which are accumulated from the deltas of the power meters. The absolute energy of the node components
+// is the counter of the power meter, which is read again. The containers and VMs that were restored keep their last
+// activity time until they are active again, so that they expire if they do not exist anymore.
+func (c *Collector) newCheckpoint(now time.Time) *checkpoint {
+	nodeEnergyMetrics := append([]string{}, c.NodeStats.DynEnergyMetrics()...)
+	nodeEnergyMetrics = append(nodeEnergyMetrics, c.NodeStats.IdleEnergyMetrics()...)
+	nodeEnergyMetrics = append(nodeEnergyMetrics, config.AbsEnergyInPlatform, config.AbsEnergyInGPU)
+	cp := &checkpoint{
+		Version:    stateVersion,
+		NodeName:   c.NodeStats.NodeName(),
+		Time:       now,
+		Node:       newAggrValues(&c.NodeStats.Stats, nodeEnergyMetrics),
+		Containers: make(map[string]*containerState, len(c.ContainerStats)),
+		VMs:        make(map[string]*vmState, len(c.VMStats)),
+	}
+	for id, container := range c.ContainerStats {
+		lastSeen, restored := c.restoredContainers[id]
+		if !restored {
+			lastSeen = now
+		}
+		cp.Containers[id] = &containerState{
+			aggrValues:    newAggrValues(&container.Stats, energyMetrics(&container.Stats)),
+			ContainerName: container.ContainerName,
+			PodName:       container.PodName,
+			Namespace:     container.Namespace,
+			LastSeen:      lastSeen,
+		}
+	}
+	for id, vm := range c.VMStats {
+		lastSeen, restored := c.restoredVMs[id]
+		if !restored {
+			lastSeen = now
+		}
+		cp.VMs[id] = &vmState{
+			aggrValues: newAggrValues(&vm.Stats, energyMetrics(&vm.Stats)),
+			PID:        vm.PID,
+			LastSeen:   lastSeen,
+		}
+	}
+	return cp
+}
+
+func energyMetrics(s *stats.Stats) []string {
+	metrics := make([]string, 0, len(s.EnergyUsage))
+	for metric := range s.EnergyUsage {
+		metrics = append(metrics, metric)
+	}
+	return metrics
+}
+
+// writeState writes the checkpoint into a temporary file that replaces the state file, so that
+// the state file is never partially written
+func writeState(file string, cp *checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return fmt.Errorf("failed to encode the state: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return fmt.Errorf("failed to create the state directory: %w", err)
+	}
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to write the state: %w", err)
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to commit the state: %w", err)
+	}
+	return nil
+}
+
+// readState reads the state file and returns nil if it does not exist
+func readState(file string) (*checkpoint, error) {
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read the state: %w", err)
+	}
+	var header struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, fmt.Errorf("failed to decode the state: %w", err)
+	}
+	if header.Version != stateVersion {
+		return nil, fmt.Errorf("unsupported state version %d, expected %d", header.Version, stateVersion)
+	}
+	cp := &checkpoint{}
+	if err := json.Unmarshal(data, cp); err != nil {
+		return nil, fmt.Errorf("failed to decode the state: %w", err)
+	}
+	return cp, nil
+}
+
+// restoreState restores the aggregated values of the state file, if it is configured. The containers and VMs
+// that were not active for longer than STATE_MAX_AGE_SEC are not restored, and a state of another node is ignored.
+func (c *Collector) restoreState() {
+	file := config.State().File
+	if file == "" {
+		return
+	}
+	cp, err := readState(file)
+	if err != nil {
+		klog.Errorf("the counters start from zero, %v", err)
+		return
+	}
+	if cp == nil {
+		return
+	}
+	if cp.NodeName != c.NodeStats.NodeName() {
+		klog.Warningf("the counters start from zero, the state file %s belongs to the node %s", file, cp.NodeName)
+		return
+	}
+	cp.Node.restore(&c.NodeStats.Stats)
+
+	maxAge := time.Duration(config.State().MaxAgeSec) * time.Second
+	now := time.Now()
+	expired := 0
+	if config.IsExposeContainerStatsEnabled() {
+		for id, state := range cp.Containers {
+			if now.Sub(state.LastSeen) > maxAge {
+				expired++
+				continue
+			}
+			container := stats.NewContainerStats(state.ContainerName, state.PodName, state.Namespace, id)
+			state.restore(&container.Stats)
+			c.ContainerStats[id] = container
+			c.restoredContainers[id] = state.LastSeen
+		}
+	}
+	if config.IsExposeVMStatsEnabled() {
+		for id, state := range cp.VMs {
+			if now.Sub(state.LastSeen) > maxAge {
+				expired++
+				continue
+			}
+			vm := stats.NewVMStats(state.PID, id)
+			state.restore(&vm.Stats)
+			c.VMStats[id] = vm
+			c.restoredVMs[id] = state.LastSeen
+		}
+	}
+	klog.Infof("restored the state of %s saved at %s: %d containers, %d VMs, %d expired",
+		file, cp.Time.Format(time.RFC3339), len(c.restoredContainers), len(c.restoredVMs), expired)
+}
+
+// expireRestored removes the restored containers and VMs that were not active since the restore for longer
+// than STATE_MAX_AGE_SEC, the found containers and VMs are active and do not expire anymore
+func (c *Collector) expireRestored(foundContainer, foundVM map[string]bool, now time.Time) {
+	maxAge := time.Duration(config.State().MaxAgeSec) * time.Second
+	for id, lastSeen := range c.restoredContainers {
+		if foundContainer[id] {
+			delete(c.restoredContainers, id)
+			continue
+		}
+		if now.Sub(lastSeen) > maxAge {
+			delete(c.restoredContainers, id)
+			if container, exists := c.ContainerStats[id]; exists {
+				c.removeContainer(container)
+			}
+		}
+	}
+	for id, lastSeen := range c.restoredVMs {
+		if foundVM[id] {
+			delete(c.restoredVMs, id)
+			continue
+		}
+		if now.Sub(lastSeen) > maxAge {
+			delete(c.restoredVMs, id)
+			delete(c.VMStats, id)
+		}
+	}
+}
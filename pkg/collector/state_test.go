@@ -0,0 +1,124 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/sustainable-computing-io/kepler/pkg/bpf"
+	"github.com/sustainable-computing-io/kepler/pkg/collector/stats"
+	"github.com/sustainable-computing-io/kepler/pkg/config"
+)
+
+var _ = Describe("Test state file", func() {
+	var (
+		c    *Collector
+		file string
+	)
+
+	BeforeEach(func() {
+		_, err := config.Initialize(".")
+		Expect(err).NotTo(HaveOccurred())
+		file = filepath.Join(GinkgoT().TempDir(), "state.json")
+		config.SetStateConfig(file, 2, 3600)
+		c = newMockCollector(bpf.NewMockExporter(bpf.DefaultSupportedMetrics()))
+		c.EnableState()
+	})
+
+	AfterEach(func() {
+		config.SetStateConfig("", 0, 0)
+	})
+
+	restart := func() *Collector {
+		restored := NewCollector(bpf.NewMockExporter(bpf.DefaultSupportedMetrics()))
+		restored.EnableState()
+		return restored
+	}
+
+	It("restores the aggregated values of the node and containers", func() {
+		c.NodeStats.EnergyUsage[config.DynEnergyInPkg].SetDeltaStat(stats.MockedSocketID, 1000)
+		c.NodeStats.EnergyUsage[config.AbsEnergyInPkg].SetAggrStat(stats.MockedSocketID, 5000)
+		c.NodeStats.EnergyUsage[config.AbsEnergyInPlatform].SetDeltaStat(stats.MockedSocketID, 2000)
+		c.NodeStats.EnergyUsage[config.AbsEnergyInGPU].SetDeltaStat("0", 700)
+		platformEnergy := c.NodeStats.EnergyUsage[config.AbsEnergyInPlatform].SumAllAggrValues()
+		c.ContainerStats["container1"].EnergyUsage[config.DynEnergyInPkg].AddDeltaStat(stats.MockedSocketID, 300)
+		nodeEnergy := c.NodeStats.EnergyUsage[config.DynEnergyInPkg].SumAllAggrValues()
+		containerEnergy := c.ContainerStats["container1"].EnergyUsage[config.DynEnergyInPkg].SumAllAggrValues()
+		c.SaveState()
+
+		restored := restart()
+		Expect(restored.NodeStats.EnergyUsage[config.DynEnergyInPkg].SumAllAggrValues()).To(Equal(nodeEnergy))
+		Expect(restored.NodeStats.EnergyUsage[config.DynEnergyInPkg].SumAllDeltaValues()).To(BeZero())
+		// the absolute energy of the components is read from the power meters, the platform and GPU energy are
+		// accumulated from the deltas of the power meters
+		Expect(restored.NodeStats.EnergyUsage[config.AbsEnergyInPkg]).To(BeEmpty())
+		Expect(restored.NodeStats.EnergyUsage[config.AbsEnergyInPlatform].SumAllAggrValues()).To(Equal(platformEnergy))
+		Expect(restored.NodeStats.EnergyUsage[config.AbsEnergyInGPU].SumAllAggrValues()).To(Equal(uint64(700)))
+		container := restored.ContainerStats["container1"]
+		Expect(container).NotTo(BeNil())
+		Expect(container.ContainerName).To(Equal(c.ContainerStats["container1"].ContainerName))
+		Expect(container.EnergyUsage[config.DynEnergyInPkg].SumAllAggrValues()).To(Equal(containerEnergy))
+
+		// the counters continue from the restored values
+		container.EnergyUsage[config.DynEnergyInPkg].AddDeltaStat(stats.MockedSocketID, 10)
+		Expect(container.EnergyUsage[config.DynEnergyInPkg].SumAllAggrValues()).To(Equal(containerEnergy + 10))
+		Expect(restored.Snapshot().ContainerStats).To(HaveKey("container1"))
+	})
+
+	It("saves the state every checkpoint cycles", func() {
+		c.saveStateIfDue()
+		Expect(file).NotTo(BeAnExistingFile())
+		c.saveStateIfDue()
+		Expect(file).To(BeAnExistingFile())
+		Expect(file + ".tmp").NotTo(BeAnExistingFile())
+	})
+
+	It("neither restores nor saves the state unless it is enabled", func() {
+		c.NodeStats.EnergyUsage[config.DynEnergyInPkg].SetDeltaStat(stats.MockedSocketID, 1000)
+		nodeEnergy := c.NodeStats.EnergyUsage[config.DynEnergyInPkg].SumAllAggrValues()
+		c.SaveState()
+		Expect(file).To(BeAnExistingFile())
+
+		standalone := newMockCollector(bpf.NewMockExporter(bpf.DefaultSupportedMetrics()))
+		Expect(standalone.NodeStats.EnergyUsage[config.DynEnergyInPkg].SumAllAggrValues()).To(BeNumerically("<", nodeEnergy))
+		Expect(os.Remove(file)).To(Succeed())
+		standalone.saveStateIfDue()
+		standalone.saveStateIfDue()
+		standalone.SaveState()
+		Expect(file).NotTo(BeAnExistingFile())
+	})
+
+	It("expires the containers that were not active for longer than the max age", func() {
+		now := time.Now()
+		cp := c.newCheckpoint(now)
+		cp.Containers["stale"] = &containerState{LastSeen: now.Add(-2 * time.Hour)}
+		cp.Containers["idle"] = &containerState{LastSeen: now.Add(-50 * time.Minute)}
+		cp.Containers["active"] = &containerState{LastSeen: now.Add(-50 * time.Minute)}
+		Expect(writeState(file, cp)).To(Succeed())
+
+		restored := restart()
+		Expect(restored.ContainerStats).NotTo(HaveKey("stale"))
+		Expect(restored.ContainerStats).To(HaveKey("idle"))
+
+		restored.expireRestored(map[string]bool{"active": true}, nil, now)
+		Expect(restored.ContainerStats).To(HaveKey("idle"))
+		restored.expireRestored(map[string]bool{}, nil, now.Add(20*time.Minute))
+		Expect(restored.ContainerStats).NotTo(HaveKey("idle"))
+		Expect(restored.ContainerStats).To(HaveKey("active"))
+
+		// an active container is saved with the time of the checkpoint
+		Expect(restored.newCheckpoint(now).Containers["active"].LastSeen).To(Equal(now))
+	})
+
+	It("ignores a state file with another schema version", func() {
+		Expect(os.WriteFile(file, []byte(`{"version": 2, "node": {"energy_usage": {}}}`), 0o600)).To(Succeed())
+		_, err := readState(file)
+		Expect(err).To(MatchError(ContainSubstring("unsupported state version 2")))
+
+		restored := restart()
+		Expect(restored.ContainerStats).To(BeEmpty())
+	})
+})
@@ -17,6 +17,7 @@ package stats_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/sustainable-computing-io/kepler/pkg/bpf"
 	"github.com/sustainable-computing-io/kepler/pkg/collector"
@@ -45,7 +46,7 @@ func benchmarkNtesting(b *testing.B, processNumber int) {
 	// update container and node metrics
 	b.ReportAllocs()
 	b.ResetTimer()
-	metricCollector.UpdateProcessEnergyUtilizationMetrics()
+	metricCollector.UpdateProcessEnergyUtilizationMetrics(time.Duration(config.SamplePeriodSec()) * time.Second)
 	metricCollector.AggregateProcessEnergyUtilizationMetrics()
 	b.StopTimer()
 }
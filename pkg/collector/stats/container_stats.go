@@ -18,6 +18,8 @@ package stats
 
 import (
 	"fmt"
+
+	"github.com/sustainable-computing-io/kepler/pkg/utils"
 )
 
 type ContainerStats struct {
@@ -44,6 +46,28 @@ func NewContainerStats(containerName, podName, podNamespace, containerID string)
 	return c
 }
 
+// NewTerminatedContainerStats creates the bucket of the removed containers of a container name, pod or namespace,
+// the names that are not part of the bucket are empty
+func NewTerminatedContainerStats(containerName, podName, podNamespace string) *ContainerStats {
+	return NewContainerStats(containerName, podName, podNamespace, utils.TerminatedName)
+}
+
+// copyFrom sets the container stats to the values of src, it reuses the stats and the PIDs of the container
+func (c *ContainerStats) copyFrom(src *ContainerStats) {
+	s, pids := c.Stats, c.PIDS
+	*c = *src
+	c.Stats = s
+	c.Stats.copyFrom(&src.Stats)
+	if pids == nil {
+		pids = make(map[uint64]bool, len(src.PIDS))
+	}
+	clear(pids)
+	for pid := range src.PIDS {
+		pids[pid] = true
+	}
+	c.PIDS = pids
+}
+
 // ResetCurr reset all current value to 0
 func (c *ContainerStats) ResetDeltaValues() {
 	c.Stats.ResetDeltaValues()
@@ -31,6 +31,18 @@ type NodeStats struct {
 	// IdleResUtilization is used to determine idle pmap[string]eriods
 	IdleResUtilization map[string]uint64
 
+	// Staleness is the time in seconds since the power meter of each component (e.g. package, platform) was read,
+	// it is 0 for the meters read at every collection
+	Staleness map[string]float64
+
+	// AttributionResidual is the energy in mJ of the last collection cycle that was not attributed to the processes, per
+	// dynamic and idle energy metric. It is negative if the processes were attributed more energy than the node consumed.
+	AttributionResidual map[string]int64
+
+	// MultiplexingRatio is the share of the time each hardware counter was counting during the last collection cycle,
+	// the counter values are extrapolated if it is below 1 because the PMU was oversubscribed
+	MultiplexingRatio map[string]float64
+
 	// nodeInfo allows access to node information
 	nodeInfo node.Node
 }
@@ -39,8 +51,33 @@ func NewNodeStats() *NodeStats {
 	return &NodeStats{
 		Stats:              *NewStats(),
 		IdleResUtilization: map[string]uint64{},
+		Staleness:          map[string]float64{},
 		nodeInfo:           node.NewNodeInfo(),
+
+		AttributionResidual: map[string]int64{},
+		MultiplexingRatio:   map[string]float64{},
+	}
+}
+
+// copyFrom sets the node stats to the values of src, it reuses the stats and the maps of the node
+func (ne *NodeStats) copyFrom(src *NodeStats) {
+	ne.Stats.copyFrom(&src.Stats)
+	ne.IdleResUtilization = copyMap(ne.IdleResUtilization, src.IdleResUtilization)
+	ne.Staleness = copyMap(ne.Staleness, src.Staleness)
+	ne.AttributionResidual = copyMap(ne.AttributionResidual, src.AttributionResidual)
+	ne.MultiplexingRatio = copyMap(ne.MultiplexingRatio, src.MultiplexingRatio)
+	ne.nodeInfo = src.nodeInfo
+}
+
+func copyMap[V any](dst, src map[string]V) map[string]V {
+	if dst == nil {
+		dst = make(map[string]V, len(src))
+	}
+	clear(dst)
+	for key, value := range src {
+		dst[key] = value
 	}
+	return dst
 }
 
 // ResetDeltaValues reset all delta values to 0
@@ -18,6 +18,8 @@ package stats
 
 import (
 	"fmt"
+
+	"github.com/sustainable-computing-io/kepler/pkg/utils"
 )
 
 type ProcessStats struct {
@@ -27,7 +29,11 @@ type ProcessStats struct {
 	ContainerID string
 	VMID        string
 	Command     string
+	// StartTime is the start time of the process in clock ticks since boot, or 0 if it is unknown
+	StartTime   uint64
 	IdleCounter int
+	// Terminated is true for the bucket that holds the values of the removed processes of a container
+	Terminated bool
 }
 
 // NewProcessStats creates a new ProcessStats instance
@@ -43,6 +49,21 @@ func NewProcessStats(pid, cGroupID uint64, containerID, vmID, command string) *P
 	return p
 }
 
+// NewTerminatedProcessStats creates the bucket of the removed processes of a container
+func NewTerminatedProcessStats(containerID, vmID string) *ProcessStats {
+	p := NewProcessStats(0, 0, containerID, vmID, utils.TerminatedName)
+	p.Terminated = true
+	return p
+}
+
+// copyFrom sets the process stats to the values of src, it reuses the stats of the process
+func (p *ProcessStats) copyFrom(src *ProcessStats) {
+	s := p.Stats
+	*p = *src
+	p.Stats = s
+	p.Stats.copyFrom(&src.Stats)
+}
+
 // ResetDeltaValues reset all delta values to 0
 func (p *ProcessStats) ResetDeltaValues() {
 	p.Stats.ResetDeltaValues()
@@ -0,0 +1,113 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stats
+
+import (
+	"fmt"
+	"sync"
+)
+
+// ResourceSource collects a set of resource utilization metrics of the processes, e.g. the eBPF counters or the GPU utilization.
+// The resource usage of the stats, the power model features and the exported metrics are derived from the registered sources.
+type ResourceSource interface {
+	// Name is the source label of the exported metrics
+	Name() string
+	// Init prepares the source, a source that fails to initialize is not registered
+	Init() error
+	// SupportedMetrics returns the resource utilization metrics that the source adds to the process stats
+	SupportedMetrics() []string
+	// Collect adds the resource utilization since the previous collection to the process stats,
+	// and creates the stats of the processes that are not in the map yet
+	Collect(processStats map[uint64]*ProcessStats) error
+	// Close releases the resources of the source
+	Close()
+}
+
+// ProcessExit identifies a process that exited, the PID and the start time identify the process
+// since the PIDs are reused. The start time is in clock ticks since boot, as in /proc/<pid>/stat.
+type ProcessExit struct {
+	PID       uint64
+	StartTime uint64
+	// ParentPID and ParentStartTime identify the parent, or the reaper the process was reparented to,
+	// they are 0 if the source does not report the parent
+	ParentPID       uint64
+	ParentStartTime uint64
+}
+
+// ProcessExitSource is implemented by the sources that report the processes that exited
+type ProcessExitSource interface {
+	// CollectProcessExits returns the processes that exited since the previous collection,
+	// or an error if the source cannot report them
+	CollectProcessExits() ([]ProcessExit, error)
+}
+
+// MultiplexingSource is implemented by the sources that count hardware events, which the PMU multiplexes
+// when there are more events than it can count at once
+type MultiplexingSource interface {
+	// CollectMultiplexingRatios returns the share of the time each counter was counting since the previous collection
+	CollectMultiplexingRatios() (map[string]float64, error)
+}
+
+var (
+	sourcesMx sync.RWMutex
+	sources   []ResourceSource
+)
+
+// RegisterResourceSource initializes the source and adds it to the registry. A registered source with
+// the same name is closed and replaced. The sources must be registered before the stats are created.
+func RegisterResourceSource(s ResourceSource) error {
+	if err := s.Init(); err != nil {
+		return fmt.Errorf("failed to initialize the resource source %s: %w", s.Name(), err)
+	}
+	sourcesMx.Lock()
+	defer sourcesMx.Unlock()
+	for i, registered := range sources {
+		if registered.Name() == s.Name() {
+			registered.Close()
+			sources[i] = s
+			return nil
+		}
+	}
+	sources = append(sources, s)
+	return nil
+}
+
+// ResourceSources returns the registered sources in the registration order
+func ResourceSources() []ResourceSource {
+	sourcesMx.RLock()
+	defer sourcesMx.RUnlock()
+	return append([]ResourceSource{}, sources...)
+}
+
+// CloseResourceSources closes and unregisters all sources
+func CloseResourceSources() {
+	sourcesMx.Lock()
+	defer sourcesMx.Unlock()
+	for _, s := range sources {
+		s.Close()
+	}
+	sources = nil
+}
+
+// AvailableResourceMetrics returns the resource utilization metrics of all registered sources
+func AvailableResourceMetrics() []string {
+	metrics := []string{}
+	for _, s := range ResourceSources() {
+		metrics = append(metrics, s.SupportedMetrics()...)
+	}
+	return metrics
+}
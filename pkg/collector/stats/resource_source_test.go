@@ -0,0 +1,69 @@
+package stats
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/sustainable-computing-io/kepler/pkg/config"
+)
+
+type fakeResourceSource struct {
+	name    string
+	metrics []string
+	initErr error
+	closed  bool
+}
+
+func (s *fakeResourceSource) Name() string               { return s.name }
+func (s *fakeResourceSource) Init() error                { return s.initErr }
+func (s *fakeResourceSource) SupportedMetrics() []string { return s.metrics }
+func (s *fakeResourceSource) Close()                     { s.closed = true }
+
+func (s *fakeResourceSource) Collect(processStats map[uint64]*ProcessStats) error {
+	for _, p := range processStats {
+		for _, metric := range s.metrics {
+			p.ResourceUsage[metric].AddDeltaStat(MockedSocketID, 1)
+		}
+	}
+	return nil
+}
+
+var _ = Describe("ResourceSource registry", func() {
+
+	BeforeEach(func() {
+		_, err := config.Initialize(".")
+		Expect(err).NotTo(HaveOccurred())
+		CloseResourceSources()
+	})
+
+	AfterEach(func() {
+		CloseResourceSources()
+		SetMockedCollectorMetrics()
+	})
+
+	It("derives the resource usage and the features from the registered sources", func() {
+		Expect(RegisterResourceSource(&fakeResourceSource{name: "bpf", metrics: []string{config.CPUTime}})).To(Succeed())
+		Expect(RegisterResourceSource(&fakeResourceSource{name: "fake", metrics: []string{"fake_metric"}})).To(Succeed())
+		Expect(AvailableResourceMetrics()).To(Equal([]string{config.CPUTime, "fake_metric"}))
+		Expect(GetProcessFeatureNames()).To(Equal([]string{config.CPUTime, "fake_metric"}))
+
+		p := NewProcessStats(1, 1, "container1", "", "command1")
+		Expect(p.ResourceUsage).To(HaveKey("fake_metric"))
+		Expect(p.ResourceUsage).NotTo(HaveKey(config.CPUCycle))
+		for _, source := range ResourceSources() {
+			Expect(source.Collect(map[uint64]*ProcessStats{1: p})).To(Succeed())
+		}
+		Expect(p.ResourceUsage["fake_metric"].SumAllDeltaValues()).To(Equal(uint64(1)))
+	})
+
+	It("replaces a source with the same name and skips the sources that fail to initialize", func() {
+		first := &fakeResourceSource{name: "fake", metrics: []string{"first"}}
+		Expect(RegisterResourceSource(first)).To(Succeed())
+		Expect(RegisterResourceSource(&fakeResourceSource{name: "fake", metrics: []string{"second"}})).To(Succeed())
+		Expect(first.closed).To(BeTrue())
+		Expect(RegisterResourceSource(&fakeResourceSource{name: "broken", initErr: errors.New("no device")})).
+			To(MatchError(ContainSubstring("broken")))
+		Expect(AvailableResourceMetrics()).To(Equal([]string{"second"}))
+	})
+})
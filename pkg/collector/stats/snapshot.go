@@ -0,0 +1,126 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stats
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Snapshot is a copy of the stats of a collection cycle. It is published after every update, so that it
+// can be read without holding the lock of the collector. The collector keeps two snapshots: it publishes one
+// and copies the next cycle into the other, which is reused once no reader holds it.
+type Snapshot struct {
+	// Interval is the measured duration of the collection cycle, which the deltas were collected over.
+	// It is 0 before the first cycle.
+	Interval       time.Duration
+	NodeStats      *NodeStats
+	ProcessStats   map[uint64]*ProcessStats
+	ContainerStats map[string]*ContainerStats
+	VMStats        map[string]*VMStats
+	// TerminatedProcessStats and TerminatedContainerStats are the buckets that hold the values of the removed entries
+	TerminatedProcessStats   map[string]*ProcessStats
+	TerminatedContainerStats map[string]*ContainerStats
+	// ProcessExits are the processes that exited during the cycle, their final values are in ProcessStats
+	ProcessExits []ProcessExit
+
+	// readers counts the readers that hold the snapshot, it is not updated while they read it
+	readers atomic.Int32
+}
+
+// NewSnapshot creates an empty snapshot, which Update copies the stats into
+func NewSnapshot() *Snapshot {
+	return &Snapshot{
+		NodeStats:      &NodeStats{},
+		ProcessStats:   map[uint64]*ProcessStats{},
+		ContainerStats: map[string]*ContainerStats{},
+		VMStats:        map[string]*VMStats{},
+
+		TerminatedProcessStats:   map[string]*ProcessStats{},
+		TerminatedContainerStats: map[string]*ContainerStats{},
+	}
+}
+
+// Update copies the stats into the snapshot. The entries of the previous copy are reused, so that the entries
+// that exist in every cycle are not allocated again, and the entries that do not exist anymore are removed.
+// The caller must hold the lock of the collector, and no reader may hold the snapshot.
+func (s *Snapshot) Update(nodeStats *NodeStats, processStats map[uint64]*ProcessStats,
+	containerStats map[string]*ContainerStats, vmStats map[string]*VMStats) {
+	s.NodeStats.copyFrom(nodeStats)
+	copyEntries(s.ProcessStats, processStats)
+	copyEntries(s.ContainerStats, containerStats)
+	copyEntries(s.VMStats, vmStats)
+}
+
+// UpdateTerminated copies the terminated buckets into the snapshot, like Update
+func (s *Snapshot) UpdateTerminated(processBuckets map[string]*ProcessStats, containerBuckets map[string]*ContainerStats) {
+	copyEntries(s.TerminatedProcessStats, processBuckets)
+	copyEntries(s.TerminatedContainerStats, containerBuckets)
+}
+
+// AddProcess copies a process that is not in the stats of the collector into the snapshot, e.g. a removed
+// process during its grace period. It is called after Update.
+func (s *Snapshot) AddProcess(pid uint64, p *ProcessStats) {
+	copyEntry(s.ProcessStats, pid, p)
+}
+
+// AddContainer copies a container that is not in the stats of the collector into the snapshot, like AddProcess
+func (s *Snapshot) AddContainer(id string, c *ContainerStats) {
+	copyEntry(s.ContainerStats, id, c)
+}
+
+// Acquire marks the snapshot as held by a reader, the collector acquires it for the reader when it returns it
+func (s *Snapshot) Acquire() {
+	s.readers.Add(1)
+}
+
+// Release marks the snapshot as no longer held by the reader
+func (s *Snapshot) Release() {
+	s.readers.Add(-1)
+}
+
+// Held returns true if a reader holds the snapshot
+func (s *Snapshot) Held() bool {
+	return s.readers.Load() > 0
+}
+
+// entry is a pointer to the stats of a process, container or VM, which are copied into a snapshot
+type entry[T any] interface {
+	*T
+	copyFrom(src *T)
+}
+
+// copyEntries copies the entries of src into dst, it reuses the entries of dst and removes the ones that are not in src
+func copyEntries[K comparable, T any, P entry[T]](dst, src map[K]P) {
+	for key := range dst {
+		if _, exists := src[key]; !exists {
+			delete(dst, key)
+		}
+	}
+	for key, e := range src {
+		copyEntry(dst, key, e)
+	}
+}
+
+func copyEntry[K comparable, T any, P entry[T]](dst map[K]P, key K, src P) {
+	e, exists := dst[key]
+	if !exists {
+		e = P(new(T))
+		dst[key] = e
+	}
+	e.copyFrom(src)
+}
@@ -0,0 +1,61 @@
+package stats
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/sustainable-computing-io/kepler/pkg/config"
+)
+
+var _ = Describe("Snapshot", func() {
+
+	BeforeEach(func() {
+		_, err := config.Initialize(".")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("Test the snapshot is not modified by the next updates", func() {
+		SetMockedCollectorMetrics()
+		processStats := CreateMockedProcessStats(2)
+		nodeStats := CreateMockedNodeStats()
+		containerStats := map[string]*ContainerStats{"container1": NewContainerStats("container1", "pod1", "123", "test")}
+		containerStats["container1"].PIDS[1] = true
+
+		s := NewSnapshot()
+		s.Update(&nodeStats, processStats, containerStats, map[string]*VMStats{})
+		Expect(s.ProcessStats).To(HaveLen(2))
+		Expect(s.ProcessStats[1].ResourceUsage[config.CPUTime].SumAllDeltaValues()).To(Equal(uint64(30000)))
+		nodeEnergy := nodeStats.EnergyUsage[config.AbsEnergyInPkg].SumAllDeltaValues()
+		Expect(s.NodeStats.EnergyUsage[config.AbsEnergyInPkg].SumAllDeltaValues()).To(Equal(nodeEnergy))
+
+		processStats[1].ResetDeltaValues()
+		processStats[3] = NewProcessStats(3, 3, "container3", "vm3", "command3")
+		nodeStats.EnergyUsage[config.AbsEnergyInPkg].SetDeltaStat(MockedSocketID, 1)
+		containerStats["container1"].PIDS[2] = true
+		Expect(s.ProcessStats).To(HaveLen(2))
+		Expect(s.ProcessStats[1].ResourceUsage[config.CPUTime].SumAllDeltaValues()).To(Equal(uint64(30000)))
+		Expect(s.NodeStats.EnergyUsage[config.AbsEnergyInPkg].SumAllDeltaValues()).To(Equal(nodeEnergy))
+		Expect(s.ContainerStats["container1"].PIDS).To(Equal(map[uint64]bool{1: true}))
+	})
+
+	It("Test the update reuses the entries of the snapshot and removes the old ones", func() {
+		SetMockedCollectorMetrics()
+		processStats := CreateMockedProcessStats(2)
+		nodeStats := CreateMockedNodeStats()
+
+		s := NewSnapshot()
+		s.Update(&nodeStats, processStats, map[string]*ContainerStats{}, map[string]*VMStats{})
+		s.AddProcess(10, NewProcessStats(10, 10, "container10", "", "removed"))
+		process := s.ProcessStats[1]
+		cpuTime := process.ResourceUsage[config.CPUTime][MockedSocketID]
+
+		processStats[1].ResetDeltaValues()
+		delete(processStats, 2)
+		s.Update(&nodeStats, processStats, map[string]*ContainerStats{}, map[string]*VMStats{})
+		Expect(s.ProcessStats).To(HaveLen(1))
+		Expect(s.ProcessStats[1]).To(BeIdenticalTo(process))
+		Expect(s.ProcessStats[1].ResourceUsage[config.CPUTime][MockedSocketID]).To(BeIdenticalTo(cpuTime))
+		Expect(s.ProcessStats[1].ResourceUsage[config.CPUTime].SumAllDeltaValues()).To(BeZero())
+		Expect(s.ProcessStats[1].ResourceUsage[config.CPUTime].SumAllAggrValues()).To(Equal(uint64(30000)))
+		Expect(s.ProcessStats[1].IdleCounter).To(Equal(1))
+	})
+})
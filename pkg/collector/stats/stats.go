@@ -19,6 +19,7 @@ package stats
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/sustainable-computing-io/kepler/pkg/collector/stats/types"
 	"github.com/sustainable-computing-io/kepler/pkg/config"
@@ -31,7 +32,7 @@ type metricSets struct {
 	absEnergyMetrics  []string
 	dynEnergyMetrics  []string
 	idleEnergyMetrics []string
-	bpfMetrics        []string
+	resourceMetrics   []string
 }
 
 type Stats struct {
@@ -55,7 +56,7 @@ func newMetricSets() *metricSets {
 			config.IdleEnergyInCore, config.IdleEnergyInDRAM, config.IdleEnergyInUnCore, config.IdleEnergyInPkg,
 			config.IdleEnergyInGPU, config.IdleEnergyInOther, config.IdleEnergyInPlatform,
 		},
-		bpfMetrics: AvailableBPFMetrics(),
+		resourceMetrics: AvailableResourceMetrics(),
 	}
 }
 
@@ -75,16 +76,13 @@ func NewStats() *Stats {
 		stats.EnergyUsage[metricName] = types.NewUInt64StatCollection()
 	}
 
-	// initialize the resource utilization metrics in the map
-	resMetrics := append([]string{}, AvailableBPFMetrics()...)
-	for _, metricName := range resMetrics {
+	// initialize the resource utilization metrics of the registered sources in the map
+	for _, metricName := range stats.ResourceMetrics() {
 		stats.ResourceUsage[metricName] = types.NewUInt64StatCollection()
 	}
 
 	if config.IsGPUEnabled() {
 		if acc.GetActiveAcceleratorByType(config.GPU) != nil {
-			stats.ResourceUsage[config.GPUComputeUtilization] = types.NewUInt64StatCollection()
-			stats.ResourceUsage[config.GPUMemUtilization] = types.NewUInt64StatCollection()
 			stats.ResourceUsage[config.IdleEnergyInGPU] = types.NewUInt64StatCollection()
 		}
 	}
@@ -105,6 +103,49 @@ func (s *Stats) ResetDeltaValues() {
 	}
 }
 
+// copyFrom sets the stats to the values of src, it reuses the collections of the stats
+func (s *Stats) copyFrom(src *Stats) {
+	s.ResourceUsage = copyCollections(s.ResourceUsage, src.ResourceUsage)
+	s.EnergyUsage = copyCollections(s.EnergyUsage, src.EnergyUsage)
+	s.availableMetrics = src.availableMetrics
+}
+
+func copyCollections(dst, src map[string]types.UInt64StatCollection) map[string]types.UInt64StatCollection {
+	if dst == nil {
+		dst = make(map[string]types.UInt64StatCollection, len(src))
+	}
+	for metric := range dst {
+		if _, exists := src[metric]; !exists {
+			delete(dst, metric)
+		}
+	}
+	for metric, collection := range src {
+		if _, exists := dst[metric]; !exists {
+			dst[metric] = types.NewUInt64StatCollection()
+		}
+		dst[metric].CopyFrom(collection)
+	}
+	return dst
+}
+
+// AddAggrValues adds the aggregated values of other to the stats, it accounts the values of
+// a removed process or container into a terminated bucket.
+func (s *Stats) AddAggrValues(other *Stats) {
+	addAggrValues(s.ResourceUsage, other.ResourceUsage)
+	addAggrValues(s.EnergyUsage, other.EnergyUsage)
+}
+
+func addAggrValues(dst, src map[string]types.UInt64StatCollection) {
+	for metric, collection := range src {
+		if _, exists := dst[metric]; !exists {
+			dst[metric] = types.NewUInt64StatCollection()
+		}
+		for id, stat := range collection {
+			dst[metric].AddDeltaStat(id, stat.GetAggr())
+		}
+	}
+}
+
 func (s *Stats) String() string {
 	return fmt.Sprintf(
 		"\tDyn ePkg (mJ): %s (eCore: %s eDram: %s eUncore: %s) eGPU (mJ): %s eOther (mJ): %s platform (mJ): %s \n"+
@@ -174,26 +215,27 @@ func calcDynEnergy(totalE, idleE uint64) uint64 {
 	return totalE - idleE
 }
 
-// normalize normalizes the value if required.
-func normalize(val float64, shouldNormalize bool) float64 {
-	if shouldNormalize {
-		return val / float64(config.SamplePeriodSec())
+// normalize normalizes the value by the interval in seconds, an interval of 0 leaves the value unchanged.
+func normalize(val float64, interval time.Duration) float64 {
+	if interval > 0 {
+		return val / interval.Seconds()
 	}
 	return val
 }
 
-// ToEstimatorValues returns values for the specified metric names, normalized if required.
+// ToEstimatorValues returns values for the specified metric names, normalized by the interval if it is not 0.
 // The metrics can be related to resource utilization or power consumption.
 // Since Kepler collects metrics at intervals of SamplePeriodSec, which is greater than 1 second,
 // and the power models are trained to estimate power in 1 second interval. It is necessary to
-// normalize the resource utilization by the SamplePeriodSec. This is important because the power
-// curve can be different for higher or lower resource usage within 1 second interval.
-func (s *Stats) ToEstimatorValues(featuresName []string, shouldNormalize bool) []float64 {
+// normalize the resource utilization by the measured interval since the previous collection, which
+// can differ from the SamplePeriodSec when the ticker drifts or an update overruns. This is important
+// because the power curve can be different for higher or lower resource usage within 1 second interval.
+func (s *Stats) ToEstimatorValues(featuresName []string, interval time.Duration) []float64 {
 	featureValues := []float64{}
 	for _, feature := range featuresName {
 		// Verify all metrics that are part of the node resource usage metrics.
 		if value, exists := s.ResourceUsage[feature]; exists {
-			featureValues = append(featureValues, normalize(float64(value.SumAllDeltaValues()), shouldNormalize))
+			featureValues = append(featureValues, normalize(float64(value.SumAllDeltaValues()), interval))
 			continue
 		}
 		// Some features are not related to resource utilization, such as power metrics.
@@ -202,59 +244,59 @@ func (s *Stats) ToEstimatorValues(featuresName []string, shouldNormalize bool) [
 			featureValues = append(featureValues, 0)
 
 		case config.DynEnergyInPkg: // For dynamic PKG power consumption.
-			value := normalize(float64(s.EnergyUsage[config.DynEnergyInPkg].SumAllDeltaValues()), shouldNormalize)
+			value := normalize(float64(s.EnergyUsage[config.DynEnergyInPkg].SumAllDeltaValues()), interval)
 			featureValues = append(featureValues, value)
 
 		case config.DynEnergyInCore: // For dynamic CORE power consumption.
-			value := normalize(float64(s.EnergyUsage[config.DynEnergyInCore].SumAllDeltaValues()), shouldNormalize)
+			value := normalize(float64(s.EnergyUsage[config.DynEnergyInCore].SumAllDeltaValues()), interval)
 			featureValues = append(featureValues, value)
 
 		case config.DynEnergyInDRAM: // For dynamic DRAM power consumption.
-			value := normalize(float64(s.EnergyUsage[config.DynEnergyInDRAM].SumAllDeltaValues()), shouldNormalize)
+			value := normalize(float64(s.EnergyUsage[config.DynEnergyInDRAM].SumAllDeltaValues()), interval)
 			featureValues = append(featureValues, value)
 
 		case config.DynEnergyInUnCore: // For dynamic UNCORE power consumption.
-			value := normalize(float64(s.EnergyUsage[config.DynEnergyInUnCore].SumAllDeltaValues()), shouldNormalize)
+			value := normalize(float64(s.EnergyUsage[config.DynEnergyInUnCore].SumAllDeltaValues()), interval)
 			featureValues = append(featureValues, value)
 
 		case config.DynEnergyInOther: // For dynamic OTHER power consumption.
-			value := normalize(float64(s.EnergyUsage[config.DynEnergyInOther].SumAllDeltaValues()), shouldNormalize)
+			value := normalize(float64(s.EnergyUsage[config.DynEnergyInOther].SumAllDeltaValues()), interval)
 			featureValues = append(featureValues, value)
 
 		case config.DynEnergyInPlatform: // For dynamic PLATFORM power consumption.
-			value := normalize(float64(s.EnergyUsage[config.DynEnergyInPlatform].SumAllDeltaValues()), shouldNormalize)
+			value := normalize(float64(s.EnergyUsage[config.DynEnergyInPlatform].SumAllDeltaValues()), interval)
 			featureValues = append(featureValues, value)
 
 		case config.DynEnergyInGPU: // For dynamic GPU power consumption.
-			value := normalize(float64(s.EnergyUsage[config.DynEnergyInGPU].SumAllDeltaValues()), shouldNormalize)
+			value := normalize(float64(s.EnergyUsage[config.DynEnergyInGPU].SumAllDeltaValues()), interval)
 			featureValues = append(featureValues, value)
 
 		case config.IdleEnergyInPkg: // For idle PKG power consumption.
-			value := normalize(float64(s.EnergyUsage[config.IdleEnergyInPkg].SumAllDeltaValues()), shouldNormalize)
+			value := normalize(float64(s.EnergyUsage[config.IdleEnergyInPkg].SumAllDeltaValues()), interval)
 			featureValues = append(featureValues, value)
 
 		case config.IdleEnergyInCore: // For idle CORE power consumption.
-			value := normalize(float64(s.EnergyUsage[config.IdleEnergyInCore].SumAllDeltaValues()), shouldNormalize)
+			value := normalize(float64(s.EnergyUsage[config.IdleEnergyInCore].SumAllDeltaValues()), interval)
 			featureValues = append(featureValues, value)
 
 		case config.IdleEnergyInDRAM: // For idle DRAM power consumption.
-			value := normalize(float64(s.EnergyUsage[config.IdleEnergyInDRAM].SumAllDeltaValues()), shouldNormalize)
+			value := normalize(float64(s.EnergyUsage[config.IdleEnergyInDRAM].SumAllDeltaValues()), interval)
 			featureValues = append(featureValues, value)
 
 		case config.IdleEnergyInUnCore: // For idle UNCORE power consumption.
-			value := normalize(float64(s.EnergyUsage[config.IdleEnergyInUnCore].SumAllDeltaValues()), shouldNormalize)
+			value := normalize(float64(s.EnergyUsage[config.IdleEnergyInUnCore].SumAllDeltaValues()), interval)
 			featureValues = append(featureValues, value)
 
 		case config.IdleEnergyInOther: // For idle OTHER power consumption.
-			value := normalize(float64(s.EnergyUsage[config.IdleEnergyInOther].SumAllDeltaValues()), shouldNormalize)
+			value := normalize(float64(s.EnergyUsage[config.IdleEnergyInOther].SumAllDeltaValues()), interval)
 			featureValues = append(featureValues, value)
 
 		case config.IdleEnergyInPlatform: // For idle PLATFORM power consumption.
-			value := normalize(float64(s.EnergyUsage[config.IdleEnergyInPlatform].SumAllDeltaValues()), shouldNormalize)
+			value := normalize(float64(s.EnergyUsage[config.IdleEnergyInPlatform].SumAllDeltaValues()), interval)
 			featureValues = append(featureValues, value)
 
 		case config.IdleEnergyInGPU: // For idle GPU power consumption.
-			value := normalize(float64(s.EnergyUsage[config.IdleEnergyInGPU].SumAllDeltaValues()), shouldNormalize)
+			value := normalize(float64(s.EnergyUsage[config.IdleEnergyInGPU].SumAllDeltaValues()), interval)
 			featureValues = append(featureValues, value)
 
 		default:
@@ -277,11 +319,6 @@ func (s *Stats) IdleEnergyMetrics() []string {
 	return s.availableMetrics.idleEnergyMetrics
 }
 
-func (s *Stats) BPFMetrics() []string {
-	return s.availableMetrics.bpfMetrics
-}
-
-func AvailableBPFMetrics() []string {
-	metrics := append(config.BPFHwCounters(), config.BPFSwCounters()...)
-	return metrics
+func (s *Stats) ResourceMetrics() []string {
+	return s.availableMetrics.resourceMetrics
 }
@@ -1,6 +1,8 @@
 package stats
 
 import (
+	"time"
+
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/sustainable-computing-io/kepler/pkg/config"
@@ -15,4 +17,19 @@ var _ = Describe("Stats", func() {
 		exp := []string{}
 		Expect(len(GetProcessFeatureNames()) >= len(exp)).To(BeTrue())
 	})
+
+	It("Test ToEstimatorValues normalizes the usage by the measured interval", func() {
+		_, err := config.Initialize(".")
+		Expect(err).NotTo(HaveOccurred())
+		SetMockedCollectorMetrics()
+		node := CreateMockedNodeStats()
+		node.ResourceUsage[config.CPUInstruction].SetDeltaStat(MockedSocketID, 60000)
+		features := []string{config.CPUInstruction}
+
+		Expect(node.ToEstimatorValues(features, 0)).To(Equal([]float64{60000}))
+		Expect(node.ToEstimatorValues(features, 3*time.Second)).To(Equal([]float64{20000}))
+		// a late tick spreads the same usage over a longer interval
+		Expect(node.ToEstimatorValues(features, 4*time.Second)).To(Equal([]float64{15000}))
+		Expect(node.ToEstimatorValues(features, 1500*time.Millisecond)).To(Equal([]float64{40000}))
+	})
 })
@@ -35,8 +35,32 @@ func SetMockedCollectorMetrics() {
 		err := gpu.Device().Init() // create structure instances that will be accessed to create a processMetric
 		klog.Fatalln(err)
 	}
+	if err := RegisterResourceSource(&mockedResourceSource{}); err != nil {
+		klog.Fatalln(err)
+	}
+}
+
+// mockedResourceSource supports all eBPF counters, it replaces the source of the eBPF counters in the tests
+type mockedResourceSource struct{}
+
+func (s *mockedResourceSource) Name() string {
+	return "bpf"
+}
+
+func (s *mockedResourceSource) Init() error {
+	return nil
+}
+
+func (s *mockedResourceSource) SupportedMetrics() []string {
+	return append(config.BPFHwCounters(), config.BPFSwCounters()...)
 }
 
+func (s *mockedResourceSource) Collect(processStats map[uint64]*ProcessStats) error {
+	return nil
+}
+
+func (s *mockedResourceSource) Close() {}
+
 // CreateMockedProcessStats adds two containers with all metrics initialized
 func CreateMockedProcessStats(numContainers int) map[uint64]*ProcessStats {
 	processMetrics := map[uint64]*ProcessStats{}
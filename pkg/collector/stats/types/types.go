@@ -5,6 +5,7 @@ import (
 	"math"
 	"sync/atomic"
 
+	"github.com/sustainable-computing-io/kepler/pkg/metrics/telemetry"
 	"k8s.io/klog/v2"
 )
 
@@ -82,6 +83,18 @@ func (s *UInt64Stat) SetNewAggr(newAggr uint64) error {
 	return nil
 }
 
+// ReplaceDelta replaces the delta value that was already added to the aggregated value, and corrects the
+// aggregated value (e.g., when the process energy is reconciled with the node energy)
+func (s *UInt64Stat) ReplaceDelta(newDelta uint64) {
+	oldDelta := s.delta.Swap(newDelta)
+	if aggr := s.aggr.Load(); aggr < oldDelta {
+		// the aggregated value has overflowed since the delta was added
+		s.aggr.Store(newDelta)
+		return
+	}
+	s.aggr.Add(newDelta - oldDelta)
+}
+
 func (s *UInt64Stat) GetDelta() uint64 {
 	return s.delta.Load()
 }
@@ -90,6 +103,17 @@ func (s *UInt64Stat) GetAggr() uint64 {
 	return s.aggr.Load()
 }
 
+// Clone returns a copy of the stat
+func (s *UInt64Stat) Clone() *UInt64Stat {
+	return NewUInt64Stat(s.aggr.Load(), s.delta.Load())
+}
+
+// CopyFrom sets the values of the stat to the values of src
+func (s *UInt64Stat) CopyFrom(src *UInt64Stat) {
+	s.aggr.Store(src.aggr.Load())
+	s.delta.Store(src.delta.Load())
+}
+
 func NewUInt64StatCollection() UInt64StatCollection {
 	return make(map[string]*UInt64Stat)
 }
@@ -100,7 +124,12 @@ func (s UInt64StatCollection) SetAggrStat(key string, newAggr uint64) {
 	if instance, found := s[key]; !found {
 		s[key] = NewUInt64Stat(newAggr, 0)
 	} else {
+		if newAggr != 0 && newAggr < instance.GetAggr() {
+			// the counter read was reset, e.g. the energy counter has wrapped around
+			telemetry.IncCounterResets(telemetry.ResetDecrease)
+		}
 		if err := instance.SetNewAggr(newAggr); err != nil {
+			telemetry.IncCounterResets(telemetry.ResetOverflow)
 			klog.V(3).Infoln(err)
 		}
 	}
@@ -111,6 +140,7 @@ func (s UInt64StatCollection) AddDeltaStat(key string, newDelta uint64) {
 		s[key] = NewUInt64Stat(newDelta, newDelta)
 	} else {
 		if err := instance.AddNewDelta(newDelta); err != nil {
+			telemetry.IncCounterResets(telemetry.ResetOverflow)
 			klog.V(3).Infoln(err)
 		}
 	}
@@ -120,6 +150,7 @@ func (s UInt64StatCollection) SetDeltaStat(key string, newDelta uint64) {
 		s[key] = NewUInt64Stat(newDelta, newDelta)
 	} else {
 		if err := instance.SetNewDelta(newDelta); err != nil {
+			telemetry.IncCounterResets(telemetry.ResetOverflow)
 			klog.V(3).Infoln(err)
 		}
 	}
@@ -149,6 +180,23 @@ func (s UInt64StatCollection) ResetDeltaValues() {
 	}
 }
 
+// CopyFrom sets the collection to the stats of src, it reuses the stats of the collection and
+// removes the ones that are not in src
+func (s UInt64StatCollection) CopyFrom(src UInt64StatCollection) {
+	for key := range s {
+		if _, exists := src[key]; !exists {
+			delete(s, key)
+		}
+	}
+	for key, stat := range src {
+		if instance, found := s[key]; found {
+			instance.CopyFrom(stat)
+		} else {
+			s[key] = stat.Clone()
+		}
+	}
+}
+
 func (s UInt64StatCollection) String() string {
 	return fmt.Sprintf("%d (%d)", s.SumAllDeltaValues(), s.SumAllAggrValues())
 }
@@ -163,5 +163,14 @@ var _ = Describe("Types", func() {
 			Expect(instance["ResetDeltaValues"].GetDelta()).To(Equal(uint64(0)))
 			Expect(instance["ResetDeltaValues1"].GetDelta()).To(Equal(uint64(0)))
 		})
+		It("ReplaceDelta", func() {
+			instance.SetDeltaStat("ReplaceDelta", uint64(10))
+			instance.SetDeltaStat("ReplaceDelta", uint64(5))
+			instance["ReplaceDelta"].ReplaceDelta(uint64(7))
+			Expect(instance["ReplaceDelta"].GetDelta()).To(Equal(uint64(7)))
+			Expect(instance["ReplaceDelta"].GetAggr()).To(Equal(uint64(17)))
+			instance["ReplaceDelta"].ReplaceDelta(uint64(2))
+			Expect(instance["ReplaceDelta"].GetAggr()).To(Equal(uint64(12)))
+		})
 	})
 })
@@ -18,25 +18,14 @@ package stats
 
 import (
 	"k8s.io/klog/v2"
-
-	"github.com/sustainable-computing-io/kepler/pkg/config"
-	acc "github.com/sustainable-computing-io/kepler/pkg/sensors/accelerator"
 )
 
 func GetProcessFeatureNames() []string {
 	var metrics []string
-	// bpf counter metrics
-	metrics = append(metrics, AvailableBPFMetrics()...)
-	klog.V(3).Infof("Available ebpf counters: %v", metrics)
-
-	// gpu metric
-	if config.IsGPUEnabled() {
-		if acc.GetActiveAcceleratorByType(config.GPU) != nil {
-			gpuMetrics := []string{config.GPUComputeUtilization, config.GPUMemUtilization}
-			metrics = append(metrics, gpuMetrics...)
-			klog.V(3).Infof("Available GPU metrics: %v", gpuMetrics)
-		}
+	// the resource utilization metrics of the registered sources, e.g. the eBPF counters and the GPU utilization
+	for _, source := range ResourceSources() {
+		metrics = append(metrics, source.SupportedMetrics()...)
+		klog.V(3).Infof("Available %s metrics: %v", source.Name(), source.SupportedMetrics())
 	}
-
 	return metrics
 }
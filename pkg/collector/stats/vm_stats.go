@@ -32,6 +32,14 @@ func NewVMStats(pid uint64, vmID string) *VMStats {
 	return vm
 }
 
+// copyFrom sets the VM stats to the values of src, it reuses the stats of the VM
+func (vm *VMStats) copyFrom(src *VMStats) {
+	s := vm.Stats
+	*vm = *src
+	vm.Stats = s
+	vm.Stats.copyFrom(&src.Stats)
+}
+
 // ResetCurr reset all current value to 0
 func (vm *VMStats) ResetDeltaValues() {
 	vm.Stats.ResetDeltaValues()
@@ -0,0 +1,151 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"github.com/sustainable-computing-io/kepler/pkg/collector/stats"
+	"github.com/sustainable-computing-io/kepler/pkg/config"
+)
+
+// maxCyclesPerScrape bounds the grace period of the removed entries when the metrics are not scraped
+const maxCyclesPerScrape = 100
+
+// gracePeriod counts the scrapes that exported the final values of a removed entry
+type gracePeriod struct {
+	scrapes int
+	cycles  int
+}
+
+func newGracePeriod() gracePeriod {
+	n := config.Terminated().GracePeriodScrapes
+	return gracePeriod{scrapes: n, cycles: n * maxCyclesPerScrape}
+}
+
+// expired counts the scrapes of the last collection cycle and returns true when the grace period is over
+func (g *gracePeriod) expired(scrapes int) bool {
+	g.scrapes -= scrapes
+	g.cycles--
+	return g.scrapes <= 0 || g.cycles <= 0
+}
+
+type terminatingProcess struct {
+	stats *stats.ProcessStats
+	grace gracePeriod
+}
+
+type terminatingContainer struct {
+	stats *stats.ContainerStats
+	grace gracePeriod
+}
+
+// removeProcess removes a process that does not exist anymore. The process exports its final values during
+// the grace period, and then its values are accounted into the terminated bucket of its container.
+func (c *Collector) removeProcess(p *stats.ProcessStats) {
+	delete(c.ProcessStats, p.PID)
+	if config.Terminated().GracePeriodScrapes > 0 {
+		// a removed process with the same reused PID is accounted into its bucket before it is replaced
+		if previous, exists := c.terminatingProcesses[p.PID]; exists {
+			c.addToProcessBucket(previous.stats)
+		}
+		c.terminatingProcesses[p.PID] = &terminatingProcess{stats: p, grace: newGracePeriod()}
+		return
+	}
+	c.addToProcessBucket(p)
+}
+
+// removeContainer removes a container that does not exist anymore. The container exports its final values during
+// the grace period, and then its values are accounted into the terminated bucket of its container name, pod or namespace.
+func (c *Collector) removeContainer(container *stats.ContainerStats) {
+	delete(c.ContainerStats, container.ContainerID)
+	if config.Terminated().GracePeriodScrapes > 0 {
+		c.terminatingContainers[container.ContainerID] = &terminatingContainer{stats: container, grace: newGracePeriod()}
+		return
+	}
+	c.addToContainerBucket(container)
+}
+
+func (c *Collector) addToProcessBucket(p *stats.ProcessStats) {
+	if config.Terminated().BucketLevel == "" {
+		return
+	}
+	key := p.ContainerID + "/" + p.VMID
+	bucket, exists := c.TerminatedProcessStats[key]
+	if !exists {
+		bucket = stats.NewTerminatedProcessStats(p.ContainerID, p.VMID)
+		c.TerminatedProcessStats[key] = bucket
+	}
+	bucket.AddAggrValues(&p.Stats)
+}
+
+func (c *Collector) addToContainerBucket(container *stats.ContainerStats) {
+	var containerName, podName string
+	switch config.Terminated().BucketLevel {
+	case config.TerminatedBucketContainer:
+		containerName, podName = container.ContainerName, container.PodName
+	case config.TerminatedBucketPod:
+		podName = container.PodName
+	case config.TerminatedBucketNamespace:
+	default:
+		return
+	}
+	key := container.Namespace + "/" + podName + "/" + containerName
+	bucket, exists := c.TerminatedContainerStats[key]
+	if !exists {
+		bucket = stats.NewTerminatedContainerStats(containerName, podName, container.Namespace)
+		c.TerminatedContainerStats[key] = bucket
+	}
+	bucket.AddAggrValues(&container.Stats)
+}
+
+// expireTerminated accounts the removed entries whose grace period is over into the terminated buckets,
+// scrapes is the number of times the snapshot of the last collection cycle was exported
+func (c *Collector) expireTerminated(scrapes int) {
+	for pid, p := range c.terminatingProcesses {
+		if p.grace.expired(scrapes) {
+			delete(c.terminatingProcesses, pid)
+			c.addToProcessBucket(p.stats)
+		}
+	}
+	for id, container := range c.terminatingContainers {
+		if container.grace.expired(scrapes) {
+			delete(c.terminatingContainers, id)
+			c.addToContainerBucket(container.stats)
+		}
+	}
+}
+
+// addTerminatedToSnapshot adds the removed entries during their grace period and the terminated buckets to the snapshot.
+// A removed process whose PID was reused is accounted into its bucket right away, since both would have the same ID.
+func (c *Collector) addTerminatedToSnapshot(s *stats.Snapshot) {
+	for pid, p := range c.terminatingProcesses {
+		if _, reused := c.ProcessStats[pid]; reused {
+			delete(c.terminatingProcesses, pid)
+			c.addToProcessBucket(p.stats)
+			continue
+		}
+		s.AddProcess(pid, p.stats)
+	}
+	for id, container := range c.terminatingContainers {
+		if _, reused := c.ContainerStats[id]; reused {
+			delete(c.terminatingContainers, id)
+			c.addToContainerBucket(container.stats)
+			continue
+		}
+		s.AddContainer(id, container.stats)
+	}
+	s.UpdateTerminated(c.TerminatedProcessStats, c.TerminatedContainerStats)
+}
@@ -0,0 +1,149 @@
+package collector
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/sustainable-computing-io/kepler/pkg/bpf"
+	"github.com/sustainable-computing-io/kepler/pkg/collector/stats"
+	"github.com/sustainable-computing-io/kepler/pkg/config"
+)
+
+// exitedPID is above the maximum PID of linux, so that the process is never found
+const exitedPID = 1 << 23
+
+// processEnergy returns the exported dynamic package energy of the processes and the process buckets of a snapshot
+func processEnergy(s *stats.Snapshot) uint64 {
+	energy := uint64(0)
+	for _, p := range s.ProcessStats {
+		energy += p.EnergyUsage[config.DynEnergyInPkg].SumAllAggrValues()
+	}
+	for _, bucket := range s.TerminatedProcessStats {
+		energy += bucket.EnergyUsage[config.DynEnergyInPkg].SumAllAggrValues()
+	}
+	return energy
+}
+
+var _ = Describe("Test terminated processes and containers", func() {
+	var c *Collector
+
+	BeforeEach(func() {
+		_, err := config.Initialize(".")
+		Expect(err).NotTo(HaveOccurred())
+		c = newMockCollector(bpf.NewMockExporter(bpf.DefaultSupportedMetrics()))
+	})
+
+	AfterEach(func() {
+		config.SetTerminatedConfig("", 0)
+	})
+
+	addExitedProcess := func(energy uint64) {
+		p := stats.NewProcessStats(exitedPID, 0, "container1", "", "exited")
+		p.EnergyUsage[config.DynEnergyInPkg].AddDeltaStat(stats.MockedSocketID, energy)
+		p.IdleCounter = 1
+		c.ProcessStats[exitedPID] = p
+	}
+
+	It("accounts the values of a removed process into the bucket of its container", func() {
+		config.SetTerminatedConfig(config.TerminatedBucketContainer, 0)
+		addExitedProcess(1000)
+		c.PublishSnapshot()
+		before := processEnergy(c.Snapshot())
+
+		c.AggregateProcessResourceUtilizationMetrics()
+		c.PublishSnapshot()
+		Expect(c.ProcessStats).NotTo(HaveKey(uint64(exitedPID)))
+		bucket := c.Snapshot().TerminatedProcessStats["container1/"]
+		Expect(bucket).NotTo(BeNil())
+		Expect(bucket.Terminated).To(BeTrue())
+		Expect(bucket.EnergyUsage[config.DynEnergyInPkg].SumAllAggrValues()).To(Equal(uint64(1000)))
+		Expect(processEnergy(c.Snapshot())).To(Equal(before))
+	})
+
+	It("drops the values of a removed process without buckets", func() {
+		addExitedProcess(1000)
+		c.AggregateProcessResourceUtilizationMetrics()
+		c.PublishSnapshot()
+		Expect(c.Snapshot().ProcessStats).NotTo(HaveKey(uint64(exitedPID)))
+		Expect(c.Snapshot().TerminatedProcessStats).To(BeEmpty())
+	})
+
+	It("exports the final values of a removed process during the grace period", func() {
+		config.SetTerminatedConfig(config.TerminatedBucketContainer, 2)
+		addExitedProcess(1000)
+		c.PublishSnapshot()
+		before := processEnergy(c.Snapshot())
+
+		c.AggregateProcessResourceUtilizationMetrics()
+		c.PublishSnapshot()
+		Expect(c.ProcessStats).NotTo(HaveKey(uint64(exitedPID)))
+		Expect(c.Snapshot().ProcessStats).To(HaveKey(uint64(exitedPID)))
+
+		// the final values are exported until the second scrape, the cycles that are not scraped do not count
+		for _, scrapes := range []int{0, 1, 0} {
+			for i := 0; i < scrapes; i++ {
+				c.MarkScraped()
+			}
+			c.expireTerminated(int(c.scrapes.Swap(0)))
+			c.PublishSnapshot()
+			Expect(c.Snapshot().ProcessStats).To(HaveKey(uint64(exitedPID)))
+			Expect(c.Snapshot().TerminatedProcessStats).To(BeEmpty())
+			Expect(processEnergy(c.Snapshot())).To(Equal(before))
+		}
+		c.MarkScraped()
+		c.expireTerminated(int(c.scrapes.Swap(0)))
+		c.PublishSnapshot()
+		Expect(c.Snapshot().ProcessStats).NotTo(HaveKey(uint64(exitedPID)))
+		Expect(c.Snapshot().TerminatedProcessStats).To(HaveKey("container1/"))
+		Expect(processEnergy(c.Snapshot())).To(Equal(before))
+	})
+
+	It("exports no delta for the removed entries after their last cycle", func() {
+		config.SetTerminatedConfig(config.TerminatedBucketContainer, 2)
+		addExitedProcess(1000)
+		container := stats.NewContainerStats("container-a", "pod1", "ns1", "a")
+		container.EnergyUsage[config.DynEnergyInPkg].AddDeltaStat(stats.MockedSocketID, 100)
+		c.ContainerStats["a"] = container
+		c.removeContainer(container)
+		c.AggregateProcessResourceUtilizationMetrics()
+		c.PublishSnapshot()
+		Expect(c.Snapshot().ProcessStats[exitedPID].EnergyUsage[config.DynEnergyInPkg].SumAllDeltaValues()).To(Equal(uint64(1000)))
+		Expect(c.Snapshot().ContainerStats["a"].EnergyUsage[config.DynEnergyInPkg].SumAllDeltaValues()).To(Equal(uint64(100)))
+
+		// the next cycle exports the final aggregated values without the delta of the last cycle
+		c.resetDeltaValue()
+		c.PublishSnapshot()
+		p := c.Snapshot().ProcessStats[exitedPID]
+		Expect(p.EnergyUsage[config.DynEnergyInPkg].SumAllDeltaValues()).To(BeZero())
+		Expect(p.EnergyUsage[config.DynEnergyInPkg].SumAllAggrValues()).To(Equal(uint64(1000)))
+		Expect(c.Snapshot().ContainerStats["a"].EnergyUsage[config.DynEnergyInPkg].SumAllDeltaValues()).To(BeZero())
+		Expect(c.Snapshot().ContainerStats["a"].EnergyUsage[config.DynEnergyInPkg].SumAllAggrValues()).To(Equal(uint64(100)))
+	})
+
+	It("accounts a removed process right away when its PID is reused", func() {
+		config.SetTerminatedConfig(config.TerminatedBucketContainer, 2)
+		addExitedProcess(1000)
+		c.AggregateProcessResourceUtilizationMetrics()
+		c.ProcessStats[exitedPID] = stats.NewProcessStats(exitedPID, 0, "container2", "", "reused")
+		c.PublishSnapshot()
+		Expect(c.Snapshot().ProcessStats[exitedPID].Command).To(Equal("reused"))
+		Expect(c.Snapshot().TerminatedProcessStats["container1/"].EnergyUsage[config.DynEnergyInPkg].SumAllAggrValues()).To(Equal(uint64(1000)))
+	})
+
+	It("groups the removed containers per pod", func() {
+		config.SetTerminatedConfig(config.TerminatedBucketPod, 0)
+		for i, id := range []string{"a", "b"} {
+			container := stats.NewContainerStats("container-"+id, "pod1", "ns1", id)
+			container.EnergyUsage[config.DynEnergyInPkg].AddDeltaStat(stats.MockedSocketID, uint64(100*(i+1)))
+			c.ContainerStats[id] = container
+			c.removeContainer(container)
+		}
+		c.PublishSnapshot()
+		Expect(c.Snapshot().ContainerStats).NotTo(HaveKey("a"))
+		Expect(c.Snapshot().TerminatedContainerStats).To(HaveLen(1))
+		bucket := c.Snapshot().TerminatedContainerStats["ns1/pod1/"]
+		Expect(bucket.ContainerID).To(Equal("terminated"))
+		Expect(bucket.ContainerName).To(BeEmpty())
+		Expect(bucket.EnergyUsage[config.DynEnergyInPkg].SumAllAggrValues()).To(Equal(uint64(300)))
+	})
+})
@@ -34,6 +34,8 @@ var (
 	versionRegex = regexp.MustCompile(`^(\d+)\.(\d+).`)
 	instance     *Config
 	once         sync.Once
+	// reloadMx guards the options that Reload changes while the collectors read them
+	reloadMx sync.RWMutex
 )
 
 type Client interface {
@@ -43,76 +45,142 @@ type Client interface {
 
 // Configuration structs
 type KeplerConfig struct {
-	KeplerNamespace              string
-	EnabledEBPFCgroupID          bool
-	EnabledGPU                   bool
-	EnabledMSR                   bool
-	EnableProcessStats           bool
-	ExposeContainerStats         bool
-	ExposeVMStats                bool
-	ExposeHardwareCounterMetrics bool
-	ExposeIRQCounterMetrics      bool
-	ExposeBPFMetrics             bool
-	ExposeComponentPower         bool
-	ExposeIdlePowerMetrics       bool
-	EnableAPIServer              bool
-	MockACPIPowerPath            string
-	MaxLookupRetry               int
-	KubeConfig                   string
-	BPFSampleRate                int
-	EstimatorModel               string
-	EstimatorSelectFilter        string
-	CPUArchOverride              string
-	MachineSpecFilePath          string
-	ExcludeSwapperProcess        bool
+	KeplerNamespace              string `yaml:"namespace" env:"KEPLER_NAMESPACE"`
+	EnabledEBPFCgroupID          bool   `yaml:"enable_ebpf_cgroupid" env:"ENABLE_EBPF_CGROUPID"`
+	EnabledGPU                   bool   `yaml:"enable_gpu" env:"ENABLE_GPU"`
+	EnabledMSR                   bool   `yaml:"enable_msr" env:"ENABLE_MSR"`
+	EnableProcessStats           bool   `yaml:"enable_process_metrics" env:"ENABLE_PROCESS_METRICS"`
+	ExposeContainerStats         bool   `yaml:"expose_container_metrics" env:"EXPOSE_CONTAINER_METRICS"`
+	ExposeVMStats                bool   `yaml:"expose_vm_metrics" env:"EXPOSE_VM_METRICS"`
+	ExposeHardwareCounterMetrics bool   `yaml:"expose_hw_counter_metrics" env:"EXPOSE_HW_COUNTER_METRICS"`
+	HardwareCounters             string `yaml:"hw_counters" env:"HW_COUNTERS"`
+	ExposeIRQCounterMetrics      bool   `yaml:"expose_irq_counter_metrics" env:"EXPOSE_IRQ_COUNTER_METRICS"`
+	ExposeBlockIOCounterMetrics  bool   `yaml:"expose_block_io_counter_metrics" env:"EXPOSE_BLOCK_IO_COUNTER_METRICS"`
+	ExposeBPFMetrics             bool   `yaml:"expose_bpf_metrics" env:"EXPOSE_BPF_METRICS"`
+	ExposeComponentPower         bool   `yaml:"expose_component_power" env:"EXPOSE_COMPONENT_POWER"`
+	ExposeIdlePowerMetrics       bool   `yaml:"expose_estimated_idle_power_metrics" env:"EXPOSE_ESTIMATED_IDLE_POWER_METRICS"`
+	EnableAPIServer              bool   `yaml:"enable_api_server" env:"ENABLE_API_SERVER"`
+	MockACPIPowerPath            string `yaml:"mock_acpi_power_path" env:"MOCK_ACPI_POWER_PATH"`
+	MaxLookupRetry               int    `yaml:"max_lookup_retry" env:"MAX_LOOKUP_RETRY"`
+	KubeConfig                   string `yaml:"kube_config" env:"KUBE_CONFIG"`
+	BPFSampleRate                int    `yaml:"bpf_sample_rate" env:"EXPERIMENTAL_BPF_SAMPLE_RATE"`
+	EstimatorModel               string `yaml:"estimator_model" env:"ESTIMATOR_MODEL"`
+	EstimatorSelectFilter        string `yaml:"estimator_select_filter" env:"ESTIMATOR_SELECT_FILTER"`
+	CPUArchOverride              string `yaml:"cpu_arch_override" env:"CPU_ARCH_OVERRIDE"`
+	MachineSpecFilePath          string `yaml:"machine_spec_file_path" env:"MACHINE_SPEC_FILE_PATH"`
+	ExcludeSwapperProcess        bool   `yaml:"exclude_swapper_process" env:"EXCLUDE_SWAPPER_PROCESS"`
+	ReconcileEnergy              bool   `yaml:"reconcile_energy" env:"RECONCILE_ENERGY"`
 }
 type MetricsConfig struct {
-	CoreUsageMetric    string
-	DRAMUsageMetric    string
-	UncoreUsageMetric  string
-	GPUUsageMetric     string
-	GeneralUsageMetric string
+	CoreUsageMetric    string `yaml:"core_usage_metric" env:"CORE_USAGE_METRIC"`
+	DRAMUsageMetric    string `yaml:"dram_usage_metric" env:"DRAM_USAGE_METRIC"`
+	UncoreUsageMetric  string `yaml:"uncore_usage_metric" env:"UNCORE_USAGE_METRIC"`
+	GPUUsageMetric     string `yaml:"gpu_usage_metric" env:"GPU_USAGE_METRIC"`
+	GeneralUsageMetric string `yaml:"general_usage_metric" env:"GENERAL_USAGE_METRIC"`
 }
 
 type RedfishConfig struct {
-	CredFilePath           string
-	ProbeIntervalInSeconds string
-	SkipSSLVerify          bool
+	CredFilePath           string `yaml:"cred_file_path" env:"REDFISH_CRED_FILE_PATH"`
+	ProbeIntervalInSeconds string `yaml:"probe_interval_in_seconds" env:"REDFISH_PROBE_INTERVAL_IN_SECONDS"`
+	SkipSSLVerify          bool   `yaml:"skip_ssl_verify" env:"REDFISH_SKIP_SSL_VERIFY"`
 }
 
 type ModelConfig struct {
-	ModelServerEnable           bool
-	ModelServerEndpoint         string
-	ModelConfigValues           map[string]string
-	NodePlatformPowerKey        string
-	NodeComponentsPowerKey      string
-	ContainerPlatformPowerKey   string
-	ContainerComponentsPowerKey string
-	ProcessPlatformPowerKey     string
-	ProcessComponentsPowerKey   string
+	ModelServerEnable           bool              `yaml:"server_enable" env:"MODEL_SERVER_ENABLE"`
+	ModelServerEndpoint         string            `yaml:"server_endpoint" env:"MODEL_SERVER_ENDPOINT"`
+	ModelConfigValues           map[string]string `yaml:"config" env:"MODEL_CONFIG"`
+	NodePlatformPowerKey        string            `yaml:"node_total_power_key" env:"NODE_TOTAL_POWER_KEY"`
+	NodeComponentsPowerKey      string            `yaml:"node_components_power_key" env:"NODE_COMPONENTS_POWER_KEY"`
+	ContainerPlatformPowerKey   string            `yaml:"container_total_power_key" env:"CONTAINER_TOTAL_POWER_KEY"`
+	ContainerComponentsPowerKey string            `yaml:"container_components_power_key" env:"CONTAINER_COMPONENTS_POWER_KEY"`
+	ProcessPlatformPowerKey     string            `yaml:"process_total_power_key" env:"PROCESS_TOTAL_POWER_KEY"`
+	ProcessComponentsPowerKey   string            `yaml:"process_components_power_key" env:"PROCESS_COMPONENTS_POWER_KEY"`
 }
 
 type LibvirtConfig struct {
-	MetadataURI   string
-	MetadataToken string
+	MetadataURI   string `yaml:"metadata_uri" env:"LIBVIRT_METADATA_URI"`
+	MetadataToken string `yaml:"metadata_token" env:"LIBVIRT_METADATA_TOKEN"`
 }
 
 type HostConfig struct {
-	ProcDir string
-	SysDir  string
+	ProcDir string `yaml:"proc_dir" env:"PROC_DIR"`
+	SysDir  string `yaml:"sys_dir" env:"SYS_DIR"`
+}
+
+// OTLPConfig configures pushing the metrics to an OpenTelemetry collector, it is enabled if Endpoint is set.
+type OTLPConfig struct {
+	// Endpoint is host:port for grpc, or the collector URL for http/protobuf
+	Endpoint           string            `yaml:"endpoint" env:"OTLP_ENDPOINT"`
+	Protocol           string            `yaml:"protocol" env:"OTLP_PROTOCOL"`
+	Headers            map[string]string `yaml:"headers" env:"OTLP_HEADERS" mapsep:"," secret:"true"`
+	Insecure           bool              `yaml:"insecure" env:"OTLP_INSECURE"`
+	CAFile             string            `yaml:"ca_file" env:"OTLP_CA_FILE"`
+	CertFile           string            `yaml:"cert_file" env:"OTLP_CERT_FILE"`
+	KeyFile            string            `yaml:"key_file" env:"OTLP_KEY_FILE"`
+	InsecureSkipVerify bool              `yaml:"insecure_skip_verify" env:"OTLP_INSECURE_SKIP_VERIFY"`
+	TimeoutSec         int               `yaml:"timeout_sec" env:"OTLP_TIMEOUT_SEC"`
+}
+
+// RemoteWriteConfig configures pushing the metrics with the Prometheus remote-write protocol, it is enabled if URL is set.
+type RemoteWriteConfig struct {
+	URL     string            `yaml:"url" env:"REMOTE_WRITE_URL"`
+	Headers map[string]string `yaml:"headers" env:"REMOTE_WRITE_HEADERS" mapsep:"," secret:"true"`
+	// WALDir holds the samples that were not sent yet, so that they survive connectivity gaps and restarts
+	WALDir string `yaml:"wal_dir" env:"REMOTE_WRITE_WAL_DIR"`
+	// WALMaxSizeMB bounds the WAL size, the oldest samples are dropped when it is full
+	WALMaxSizeMB       int  `yaml:"wal_max_size_mb" env:"REMOTE_WRITE_WAL_MAX_SIZE_MB"`
+	TimeoutSec         int  `yaml:"timeout_sec" env:"REMOTE_WRITE_TIMEOUT_SEC"`
+	MinBackoffMs       int  `yaml:"min_backoff_ms" env:"REMOTE_WRITE_MIN_BACKOFF_MS"`
+	MaxBackoffMs       int  `yaml:"max_backoff_ms" env:"REMOTE_WRITE_MAX_BACKOFF_MS"`
+	InsecureSkipVerify bool `yaml:"insecure_skip_verify" env:"REMOTE_WRITE_INSECURE_SKIP_VERIFY"`
+}
+
+// PowerPollConfig sets how often each power meter is read, independently of the sample period.
+// A meter with an interval of 0 is read at every collection cycle. The energy of the meters read at
+// their own interval is resampled at the collection time.
+type PowerPollConfig struct {
+	ComponentsIntervalMs  int `yaml:"components_interval_ms" env:"COMPONENTS_POLL_INTERVAL_MS"`
+	PlatformIntervalMs    int `yaml:"platform_interval_ms" env:"PLATFORM_POLL_INTERVAL_MS"`
+	AcceleratorIntervalMs int `yaml:"accelerator_interval_ms" env:"ACCELERATOR_POLL_INTERVAL_MS"`
+}
+
+// TerminatedConfig keeps the energy of the processes and containers that are removed from the stats, so
+// that the sum of their counters never decreases. The removed entries export their final values for
+// GracePeriodScrapes scrapes of the metrics endpoint, and then their values are accounted into a terminated bucket.
+// The pushes to the OTLP and remote-write endpoints do not count as scrapes.
+type TerminatedConfig struct {
+	// BucketLevel groups the removed containers per container, pod or namespace, the buckets are disabled if it is empty.
+	// The removed processes are grouped per container.
+	BucketLevel        string `yaml:"bucket_level" env:"TERMINATED_BUCKET_LEVEL"`
+	GracePeriodScrapes int    `yaml:"grace_period_scrapes" env:"TERMINATED_GRACE_PERIOD_SCRAPES"`
+}
+
+// StateConfig checkpoints the aggregated node, container and VM values into a state file, e.g. /var/lib/kepler/state.json,
+// every CheckpointCycles collection cycles and on shutdown, and restores them on start so that the counters are not
+// reset by a restart. It is enabled if File is set, and only used by the exporter, not by the kepler subcommands.
+type StateConfig struct {
+	File             string `yaml:"file" env:"STATE_FILE"`
+	CheckpointCycles int    `yaml:"checkpoint_cycles" env:"STATE_CHECKPOINT_CYCLES"`
+	// MaxAgeSec expires the containers and VMs that were not active for longer, they are not restored
+	MaxAgeSec int `yaml:"max_age_sec" env:"STATE_MAX_AGE_SEC"`
 }
 
 type Config struct {
-	ModelServerService     string
-	KernelVersion          float32
-	Kepler                 KeplerConfig
-	SamplePeriodSec        uint64
-	Model                  ModelConfig
-	Metrics                MetricsConfig
-	Redfish                RedfishConfig
-	Libvirt                LibvirtConfig
-	Host                   HostConfig
-	DCGMHostEngineEndpoint string
+	ModelServerService     string            `yaml:"-"`
+	KernelVersion          float32           `yaml:"-"`
+	Kepler                 KeplerConfig      `yaml:"kepler"`
+	SamplePeriodSec        uint64            `yaml:"sample_period_sec" env:"SAMPLE_PERIOD_SEC"`
+	Model                  ModelConfig       `yaml:"model"`
+	Metrics                MetricsConfig     `yaml:"metrics"`
+	Redfish                RedfishConfig     `yaml:"redfish"`
+	Libvirt                LibvirtConfig     `yaml:"libvirt"`
+	Host                   HostConfig        `yaml:"host"`
+	OTLP                   OTLPConfig        `yaml:"otlp"`
+	RemoteWrite            RemoteWriteConfig `yaml:"remote_write"`
+	PowerPoll              PowerPollConfig   `yaml:"power_poll"`
+	Terminated             TerminatedConfig  `yaml:"terminated"`
+	State                  StateConfig       `yaml:"state"`
+	DCGMHostEngineEndpoint string            `yaml:"dcgm_host_engine_endpoint" env:"NVIDIA_HOSTENGINE_ENDPOINT"`
 }
 
 // newConfig creates and returns a new Config instance.
@@ -137,7 +205,12 @@ func newConfig() (*Config, error) {
 		return nil, fmt.Errorf("config-dir %s is not a directory", BaseDir)
 	}
 
-	return &Config{
+	// parse errors are collected while the config is built so that all of
+	// them can be reported at once instead of silently using the defaults
+	parseErrors = nil
+	loadingSources = map[string]Source{}
+	defer func() { loadingSources = nil }()
+	c := &Config{
 		ModelServerService:     fmt.Sprintf("kepler-model-server.%s.svc.cluster.local", getConfig("KEPLER_NAMESPACE", defaultNamespace)),
 		Kepler:                 getKeplerConfig(),
 		SamplePeriodSec:        uint64(getIntConfig("SAMPLE_PERIOD_SEC", defaultSamplePeriodSec)),
@@ -148,7 +221,20 @@ func newConfig() (*Config, error) {
 		DCGMHostEngineEndpoint: getConfig("NVIDIA_HOSTENGINE_ENDPOINT", defaultDCGMHostEngineEndpoint),
 		KernelVersion:          float32(0),
 		Host:                   getHostConfig(),
-	}, nil
+		OTLP:                   getOTLPConfig(),
+		RemoteWrite:            getRemoteWriteConfig(),
+		PowerPoll:              getPowerPollConfig(),
+		Terminated:             getTerminatedConfig(),
+		State:                  getStateConfig(),
+	}
+	errs := parseErrors
+	parseErrors = nil
+	errs = append(errs, validateConfig(c)...)
+	if len(errs) > 0 {
+		return nil, newValidationError(errs)
+	}
+	loadedSources = loadingSources
+	return c, nil
 }
 
 // Instance returns the singleton Config instance
@@ -158,10 +244,35 @@ func Instance() *Config {
 
 // Initialize initializes the global instance once and returns an error if
 func Initialize(baseDir string) (*Config, error) {
+	return InitializeWithConfigFile(baseDir, "")
+}
+
+// InitializeWithConfigFile initializes the global instance once from the
+// config-dir and the optional structured config file (YAML or JSON).
+// Every option is resolved with the following precedence, highest first:
+//
+//  1. CLI flags, applied by the exporter through the Set* functions
+//  2. environment variables, e.g. ENABLE_GPU
+//  3. one-value files in the config-dir, e.g. /etc/kepler/kepler.config/ENABLE_GPU
+//  4. the structured config file, e.g. kepler.enable_gpu
+//  5. the built-in defaults
+//
+// An error listing every unknown key, type error and conflicting option is
+// returned if the resulting configuration is invalid.
+func InitializeWithConfigFile(baseDir, configFile string) (*Config, error) {
 	var err error
 	once.Do(func() {
 		BaseDir = baseDir
-		instance, err = newConfig()
+		ConfigFile = configFile
+		if configFile != "" {
+			if fileValues, err = loadConfigFile(configFile); err != nil {
+				return
+			}
+		}
+		if instance, err = newConfig(); err == nil {
+			snapshotLoadedConfig(instance)
+			replaceSources(loadedSources)
+		}
 	})
 	return instance, err
 }
@@ -176,11 +287,13 @@ func getKeplerConfig() KeplerConfig {
 		ExposeContainerStats:         getBoolConfig("EXPOSE_CONTAINER_METRICS", true),
 		ExposeVMStats:                getBoolConfig("EXPOSE_VM_METRICS", true),
 		ExposeHardwareCounterMetrics: getBoolConfig("EXPOSE_HW_COUNTER_METRICS", true),
+		HardwareCounters:             getConfig("HW_COUNTERS", defaultHardwareCounters),
 		ExposeIRQCounterMetrics:      getBoolConfig("EXPOSE_IRQ_COUNTER_METRICS", true),
+		ExposeBlockIOCounterMetrics:  getBoolConfig("EXPOSE_BLOCK_IO_COUNTER_METRICS", true),
 		ExposeBPFMetrics:             getBoolConfig("EXPOSE_BPF_METRICS", true),
 		ExposeComponentPower:         getBoolConfig("EXPOSE_COMPONENT_POWER", true),
 		ExposeIdlePowerMetrics:       getBoolConfig("EXPOSE_ESTIMATED_IDLE_POWER_METRICS", false),
-		EnableAPIServer:              getBoolConfig("ENABLE_API_SERVER", false),
+		EnableAPIServer:              getBoolConfig("ENABLE_API_SERVER", defaultEnableAPIServer),
 		MockACPIPowerPath:            getConfig("MOCK_ACPI_POWER_PATH", ""),
 		MaxLookupRetry:               getIntConfig("MAX_LOOKUP_RETRY", defaultMaxLookupRetry),
 		KubeConfig:                   getConfig("KUBE_CONFIG", defaultKubeConfig),
@@ -188,7 +301,9 @@ func getKeplerConfig() KeplerConfig {
 		EstimatorModel:               getConfig("ESTIMATOR_MODEL", defaultMetricValue),
 		EstimatorSelectFilter:        getConfig("ESTIMATOR_SELECT_FILTER", defaultMetricValue), // no filter
 		CPUArchOverride:              getConfig("CPU_ARCH_OVERRIDE", defaultCPUArchOverride),
+		MachineSpecFilePath:          getConfig("MACHINE_SPEC_FILE_PATH", ""),
 		ExcludeSwapperProcess:        getBoolConfig("EXCLUDE_SWAPPER_PROCESS", defaultExcludeSwapperProcess),
+		ReconcileEnergy:              getBoolConfig("RECONCILE_ENERGY", false),
 	}
 }
 
@@ -239,6 +354,56 @@ func getHostConfig() HostConfig {
 	}
 }
 
+func getOTLPConfig() OTLPConfig {
+	return OTLPConfig{
+		Endpoint:           getConfig("OTLP_ENDPOINT", ""),
+		Protocol:           getConfig("OTLP_PROTOCOL", OTLPProtocolGRPC),
+		Headers:            getMapConfig("OTLP_HEADERS", ","),
+		Insecure:           getBoolConfig("OTLP_INSECURE", false),
+		CAFile:             getConfig("OTLP_CA_FILE", ""),
+		CertFile:           getConfig("OTLP_CERT_FILE", ""),
+		KeyFile:            getConfig("OTLP_KEY_FILE", ""),
+		InsecureSkipVerify: getBoolConfig("OTLP_INSECURE_SKIP_VERIFY", false),
+		TimeoutSec:         getIntConfig("OTLP_TIMEOUT_SEC", defaultOTLPTimeoutSec),
+	}
+}
+
+func getRemoteWriteConfig() RemoteWriteConfig {
+	return RemoteWriteConfig{
+		URL:                getConfig("REMOTE_WRITE_URL", ""),
+		Headers:            getMapConfig("REMOTE_WRITE_HEADERS", ","),
+		WALDir:             getConfig("REMOTE_WRITE_WAL_DIR", defaultRemoteWriteWALDir),
+		WALMaxSizeMB:       getIntConfig("REMOTE_WRITE_WAL_MAX_SIZE_MB", defaultRemoteWriteWALMaxSizeMB),
+		TimeoutSec:         getIntConfig("REMOTE_WRITE_TIMEOUT_SEC", defaultRemoteWriteTimeoutSec),
+		MinBackoffMs:       getIntConfig("REMOTE_WRITE_MIN_BACKOFF_MS", defaultRemoteWriteMinBackoffMs),
+		MaxBackoffMs:       getIntConfig("REMOTE_WRITE_MAX_BACKOFF_MS", defaultRemoteWriteMaxBackoffMs),
+		InsecureSkipVerify: getBoolConfig("REMOTE_WRITE_INSECURE_SKIP_VERIFY", false),
+	}
+}
+
+func getPowerPollConfig() PowerPollConfig {
+	return PowerPollConfig{
+		ComponentsIntervalMs:  getIntConfig("COMPONENTS_POLL_INTERVAL_MS", 0),
+		PlatformIntervalMs:    getIntConfig("PLATFORM_POLL_INTERVAL_MS", 0),
+		AcceleratorIntervalMs: getIntConfig("ACCELERATOR_POLL_INTERVAL_MS", 0),
+	}
+}
+
+func getTerminatedConfig() TerminatedConfig {
+	return TerminatedConfig{
+		BucketLevel:        getConfig("TERMINATED_BUCKET_LEVEL", ""),
+		GracePeriodScrapes: getIntConfig("TERMINATED_GRACE_PERIOD_SCRAPES", 0),
+	}
+}
+
+func getStateConfig() StateConfig {
+	return StateConfig{
+		File:             getConfig("STATE_FILE", ""),
+		CheckpointCycles: getIntConfig("STATE_CHECKPOINT_CYCLES", defaultStateCheckpointCycles),
+		MaxAgeSec:        getIntConfig("STATE_MAX_AGE_SEC", defaultStateMaxAgeSec),
+	}
+}
+
 func getLibvirtConfig() LibvirtConfig {
 	return LibvirtConfig{
 		MetadataURI:   getConfig("LIBVIRT_METADATA_URI", ""),
@@ -248,40 +413,56 @@ func getLibvirtConfig() LibvirtConfig {
 
 // Helper functions
 func getBoolConfig(configKey string, defaultBool bool) bool {
-	defaultValue := "false"
-	if defaultBool {
-		defaultValue = "true"
+	value := getConfig(configKey, strconv.FormatBool(defaultBool))
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		parseErrors = append(parseErrors, fmt.Errorf("%s: cannot parse %q as bool", configKey, value))
+		return defaultBool
 	}
-	return strings.ToLower(getConfig(configKey, defaultValue)) == "true"
+	return b
 }
 
 func getIntConfig(configKey string, defaultInt int) int {
-	defaultValue := strconv.Itoa(defaultInt)
-	value, err := strconv.Atoi(getConfig(configKey, defaultValue))
-	if err == nil {
-		return value
+	value := getConfig(configKey, strconv.Itoa(defaultInt))
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		parseErrors = append(parseErrors, fmt.Errorf("%s: cannot parse %q as int", configKey, value))
+		return defaultInt
 	}
-	return defaultInt
+	return i
 }
 
-// getConfig returns the value of the key by first looking in the environment
-// and then in the config file if it exists or else returns the default value.
+// getConfig returns the value of the key by first looking in the environment,
+// then in the config-dir file, then in the structured config file if they
+// exist or else returns the default value.
 func getConfig(key, defaultValue string) string {
 	// env var takes precedence over config file
 	if envValue, exists := os.LookupEnv(key); exists {
+		recordLoadingSource(key, SourceEnv)
 		return envValue
 	}
 
-	// return config file value if there is one
+	// return config-dir file value if there is one
 	configFile := filepath.Join(BaseDir, key)
 	if value, err := os.ReadFile(configFile); err == nil {
+		recordLoadingSource(key, SourceConfigDir)
 		return strings.TrimSpace(bytes.NewBuffer(value).String())
 	}
 
+	// return structured config file value if there is one
+	if value, exists := fileValues[key]; exists {
+		recordLoadingSource(key, SourceConfigFile)
+		return value
+	}
+
+	recordLoadingSource(key, SourceDefault)
 	return defaultValue
 }
 
 func setModelServerReqEndpoint() string {
+	if endpoint := getConfig("MODEL_SERVER_ENDPOINT", ""); endpoint != "" {
+		return endpoint
+	}
 	modelServerURL := getConfig("MODEL_SERVER_URL", "kepler-model-server")
 	if modelServerURL == "kepler-model-server" {
 		modelServerPort := strings.TrimSuffix(getConfig("MODEL_SERVER_PORT", defaultModelServerPort), "\n")
@@ -297,60 +478,63 @@ func GetDefaultPowerModelURL(modelOutputType, energySource string) string {
 	return fmt.Sprintf(`/var/lib/kepler/data/model_weight/%s_%sModel.json`, energySource, modelOutputType)
 }
 
-func logBoolConfigs() {
-	if klog.V(5).Enabled() {
-		klog.V(5).Infof("PROC_DIR: %s", instance.Host.ProcDir)
-		klog.V(5).Infof("SYS_DIR: %s", instance.Host.SysDir)
-		klog.V(5).Infof("ENABLE_EBPF_CGROUPID: %t", instance.Kepler.EnabledEBPFCgroupID)
-		klog.V(5).Infof("ENABLE_GPU: %t", instance.Kepler.EnabledGPU)
-		klog.V(5).Infof("ENABLE_PROCESS_METRICS: %t", instance.Kepler.EnableProcessStats)
-		klog.V(5).Infof("EXPOSE_HW_COUNTER_METRICS: %t", instance.Kepler.ExposeHardwareCounterMetrics)
-		klog.V(5).Infof("EXPOSE_IRQ_COUNTER_METRICS: %t", instance.Kepler.ExposeIRQCounterMetrics)
-		klog.V(5).Infof("EXPOSE_BPF_METRICS: %t", instance.Kepler.ExposeBPFMetrics)
-		klog.V(5).Infof("EXPOSE_COMPONENT_POWER: %t", instance.Kepler.ExposeComponentPower)
-		klog.V(5).Infof("EXPOSE_ESTIMATED_IDLE_POWER_METRICS: %t. This only impacts when the power is estimated using pre-prained models. Estimated idle power is meaningful only when Kepler is running on bare-metal or with a single virtual machine (VM) on the node.", instance.Kepler.ExposeIdlePowerMetrics)
-		klog.V(5).Infof("EXPERIMENTAL_BPF_SAMPLE_RATE: %d", instance.Kepler.BPFSampleRate)
-		klog.V(5).Infof("EXCLUDE_SWAPPER_PROCESS: %t", instance.Kepler.ExcludeSwapperProcess)
-	}
-}
-
+// LogConfigs logs every option of the running config with its source
 func LogConfigs() {
-	klog.V(5).Infof("config-dir: %s", BaseDir)
-	logBoolConfigs()
+	klog.Infof("config-dir: %s", BaseDir)
+	if ConfigFile != "" {
+		klog.Infof("config-file: %s", ConfigFile)
+	}
+	for _, v := range EffectiveConfig() {
+		if v.Source == SourceDefault {
+			klog.V(5).Infof("%s: %v (%s)", v.Key, v.Value, v.Source)
+		} else {
+			klog.Infof("%s: %v (%s)", v.Key, v.Value, v.Source)
+		}
+	}
 }
 
 func SetRedfishCredFilePath(credFilePath string) {
 	instance.Redfish.CredFilePath = credFilePath
+	setSource("REDFISH_CRED_FILE_PATH", SourceFlag)
 }
 
 func SetRedfishProbeIntervalInSeconds(interval string) {
 	instance.Redfish.ProbeIntervalInSeconds = interval
+	setSource("REDFISH_PROBE_INTERVAL_IN_SECONDS", SourceFlag)
 }
 
 func SetRedfishSkipSSLVerify(skipSSLVerify bool) {
 	instance.Redfish.SkipSSLVerify = skipSSLVerify
+	setSource("REDFISH_SKIP_SSL_VERIFY", SourceFlag)
 }
 
 // SetEnabledEBPFCgroupID enables or disables eBPF code to collect cgroup ID
 // based on kernel version and cgroup version.
 // SetEnabledEBPFCgroupID enables the eBPF code to collect cgroup id if the system has kernel version > 4.18
 func SetEnabledEBPFCgroupID(enabled bool) {
-	// set to false if any config source set it to false
-	enabled = enabled && instance.Kepler.EnabledEBPFCgroupID
 	klog.Infoln("using gCgroup ID in the BPF program:", enabled)
 	instance.KernelVersion = getKernelVersion(&realSystem{})
 	klog.Infoln("kernel version:", instance.KernelVersion)
+	previous := instance.Kepler.EnabledEBPFCgroupID
 	if (enabled) && (instance.KernelVersion >= cGroupIDMinKernelVersion) && (isCGroupV2(&realSystem{})) {
 		instance.Kepler.EnabledEBPFCgroupID = true
 	} else {
 		instance.Kepler.EnabledEBPFCgroupID = false
 	}
+	switch {
+	case instance.Kepler.EnabledEBPFCgroupID != enabled:
+		// the system does not support it
+		setSource("ENABLE_EBPF_CGROUPID", SourceRuntime)
+	case instance.Kepler.EnabledEBPFCgroupID != previous:
+		setSource("ENABLE_EBPF_CGROUPID", SourceFlag)
+	}
 }
 
 // SetEnabledHardwareCounterMetrics enables the exposure of hardware counter metrics
 func SetEnabledHardwareCounterMetrics(enabled bool) {
 	// set to false is any config source set it to false
 	instance.Kepler.ExposeHardwareCounterMetrics = enabled
+	setSource("EXPOSE_HW_COUNTER_METRICS", SourceFlag)
 }
 
 // SetEnabledIdlePower allows enabling idle power exposure in Kepler's metrics. When direct power metrics access is available,
@@ -362,8 +546,11 @@ func SetEnabledHardwareCounterMetrics(enabled bool) {
 // Know the number of running VMs becomes crucial for achieving a fair distribution of idle power, particularly when following the GHG (Greenhouse Gas) protocol.
 func SetEnabledIdlePower(enabled bool) {
 	// set to true is any config source set it to true or if system power metrics are available
+	reloadMx.Lock()
 	instance.Kepler.ExposeIdlePowerMetrics = enabled
-	if instance.Kepler.ExposeIdlePowerMetrics {
+	reloadMx.Unlock()
+	setSource("EXPOSE_ESTIMATED_IDLE_POWER_METRICS", SourceFlag)
+	if enabled {
 		klog.Infoln("The Idle power will be exposed. Are you running on Baremetal or using single VM per node?")
 	}
 }
@@ -371,38 +558,70 @@ func SetEnabledIdlePower(enabled bool) {
 // SetEnabledGPU enables the exposure of gpu metrics
 func SetEnabledGPU(enabled bool) {
 	instance.Kepler.EnabledGPU = enabled
+	setSource("ENABLE_GPU", SourceFlag)
 }
 
 func SetModelServerEnable(enabled bool) {
 	instance.Model.ModelServerEnable = enabled || instance.Model.ModelServerEnable
+	setSource("MODEL_SERVER_ENABLE", SourceFlag)
 }
 
 // SetEnabledMSR enables the exposure of MSR metrics
 func SetEnabledMSR(enabled bool) {
 	instance.Kepler.EnabledMSR = enabled
+	setSource("ENABLE_MSR", SourceFlag)
 }
 
 // SetKubeConfig set kubeconfig file
 func SetKubeConfig(k string) {
 	instance.Kepler.KubeConfig = k
+	setSource("KUBE_CONFIG", SourceFlag)
 }
 
 // SetEnableAPIServer enables Kepler to watch apiserver
 func SetEnableAPIServer(enabled bool) {
 	instance.Kepler.EnableAPIServer = enabled
+	setSource("ENABLE_API_SERVER", SourceFlag)
 }
 
 func SetEstimatorConfig(modelName, selectFilter string) {
 	instance.Kepler.EstimatorModel = modelName
 	instance.Kepler.EstimatorSelectFilter = selectFilter
+	setSource("ESTIMATOR_MODEL", SourceFlag)
+	setSource("ESTIMATOR_SELECT_FILTER", SourceFlag)
 }
 
 func SetModelServerEndpoint(serverEndpoint string) {
 	instance.Model.ModelServerEndpoint = serverEndpoint
+	setSource("MODEL_SERVER_ENDPOINT", SourceFlag)
 }
 
 func SetMachineSpecFilePath(specFilePath string) {
 	instance.Kepler.MachineSpecFilePath = specFilePath
+	setSource("MACHINE_SPEC_FILE_PATH", SourceFlag)
+}
+
+// SetEnabledReconcileEnergy enables redistributing the residual of the process energy attribution
+func SetEnabledReconcileEnergy(enabled bool) {
+	reloadMx.Lock()
+	instance.Kepler.ReconcileEnergy = enabled
+	reloadMx.Unlock()
+	setSource("RECONCILE_ENERGY", SourceRuntime)
+}
+
+// SetTerminatedConfig sets the terminated buckets level and the grace period of the removed processes and containers
+func SetTerminatedConfig(bucketLevel string, gracePeriodScrapes int) {
+	instance.Terminated = TerminatedConfig{BucketLevel: bucketLevel, GracePeriodScrapes: gracePeriodScrapes}
+	setSource("TERMINATED_BUCKET_LEVEL", SourceRuntime)
+	setSource("TERMINATED_GRACE_PERIOD_SCRAPES", SourceRuntime)
+}
+
+// SetStateConfig sets the state file of the aggregated values, the checkpoint frequency and the max age of the restored entries
+func SetStateConfig(file string, checkpointCycles, maxAgeSec int) {
+	instance.State = StateConfig{File: file, CheckpointCycles: checkpointCycles, MaxAgeSec: maxAgeSec}
+	setSource("STATE_FILE", SourceRuntime)
+	setSource("STATE_CHECKPOINT_CYCLES", SourceRuntime)
+	setSource("STATE_MAX_AGE_SEC", SourceRuntime)
 }
 
 // GetMachineSpec initializes a map of MachineSpecValues from MACHINE_SPEC
@@ -427,6 +646,7 @@ func GetBindAddress(cmdSet string) string {
 
 func SetGPUUsageMetric(metric string) {
 	instance.Metrics.GPUUsageMetric = metric
+	setSource("GPU_USAGE_METRIC", SourceRuntime)
 }
 
 type realSystem struct{}
@@ -484,6 +704,8 @@ func isCGroupV2(c Client) bool {
 
 // InitModelConfigMap initializes map of config from MODEL_CONFIG
 func InitModelConfigMap() {
+	reloadMx.Lock()
+	defer reloadMx.Unlock()
 	if instance.Model.ModelConfigValues == nil {
 		instance.Model.ModelConfigValues = GetModelConfigMap()
 	}
@@ -492,31 +714,51 @@ func InitModelConfigMap() {
 // IsIdlePowerEnabled always return true if Kepler has access to system power metrics.
 // However, if pre-trained power models are being used, Kepler should only expose metrics if the user is aware of the implications.
 func IsIdlePowerEnabled() bool {
+	reloadMx.RLock()
+	defer reloadMx.RUnlock()
 	return instance.Kepler.ExposeIdlePowerMetrics
 }
 
+// IsReconcileEnergyEnabled returns true if the residual between the node energy and the energy attributed to the processes
+// is redistributed to the processes, so that the process energy sums up to the node energy.
+func IsReconcileEnergyEnabled() bool {
+	reloadMx.RLock()
+	defer reloadMx.RUnlock()
+	return instance.Kepler.ReconcileEnergy
+}
+
 // IsExposeProcessStatsEnabled returns false if process metrics are disabled to minimize overhead in the Kepler standalone mode.
 func IsExposeProcessStatsEnabled() bool {
+	reloadMx.RLock()
+	defer reloadMx.RUnlock()
 	return instance.Kepler.EnableProcessStats
 }
 
 // IsExposeContainerStatsEnabled returns false if container metrics are disabled to minimize overhead in the Kepler standalone mode.
 func IsExposeContainerStatsEnabled() bool {
+	reloadMx.RLock()
+	defer reloadMx.RUnlock()
 	return instance.Kepler.ExposeContainerStats
 }
 
 // IsExposeVMStatsEnabled returns false if VM metrics are disabled to minimize overhead.
 func IsExposeVMStatsEnabled() bool {
+	reloadMx.RLock()
+	defer reloadMx.RUnlock()
 	return instance.Kepler.ExposeVMStats
 }
 
 // IsExposeBPFMetricsEnabled returns false if BPF Metrics metrics are disabled to minimize overhead.
 func IsExposeBPFMetricsEnabled() bool {
+	reloadMx.RLock()
+	defer reloadMx.RUnlock()
 	return instance.Kepler.ExposeBPFMetrics
 }
 
 // IsExposeComponentPowerEnabled returns false if component power metrics are disabled to minimize overhead.
 func IsExposeComponentPowerEnabled() bool {
+	reloadMx.RLock()
+	defer reloadMx.RUnlock()
 	return instance.Kepler.ExposeComponentPower
 }
 
@@ -547,6 +789,23 @@ func GetModelConfigMap() map[string]string {
 	return configMap
 }
 
+// getMapConfig parses a "KEY1=VALUE1<sep>KEY2=VALUE2" config value
+func getMapConfig(configKey, sep string) map[string]string {
+	configMap := make(map[string]string)
+	for _, pair := range strings.Split(getConfig(configKey, ""), sep) {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		k, v, found := strings.Cut(pair, "=")
+		if !found {
+			parseErrors = append(parseErrors, fmt.Errorf("%s: cannot parse %q as KEY=VALUE", configKey, pair))
+			continue
+		}
+		configMap[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return configMap
+}
+
 func GetLibvirtMetadataURI() string {
 	return instance.Libvirt.MetadataURI
 }
@@ -559,6 +818,10 @@ func ExposeIRQCounterMetrics() bool {
 	return instance.Kepler.ExposeIRQCounterMetrics
 }
 
+func ExposeBlockIOCounterMetrics() bool {
+	return instance.Kepler.ExposeBlockIOCounterMetrics
+}
+
 func GetBPFSampleRate() int {
 	return instance.Kepler.BPFSampleRate
 }
@@ -589,11 +852,43 @@ func ExposeHardwareCounterMetrics() bool {
 	return instance.Kepler.ExposeHardwareCounterMetrics
 }
 
+// HardwareCounters returns the configured hardware counters in the order they are opened
+func HardwareCounters() []string {
+	return parseHardwareCounters(instance.Kepler.HardwareCounters)
+}
+
+func parseHardwareCounters(value string) []string {
+	counters := []string{}
+	for _, counter := range strings.Split(value, ",") {
+		if counter = strings.TrimSpace(counter); counter != "" {
+			counters = append(counters, counter)
+		}
+	}
+	return counters
+}
+
+// IsKnownHardwareCounter returns true if the counter is a named hardware event or a raw PMU event code
+func IsKnownHardwareCounter(counter string) bool {
+	switch counter {
+	case CPUCycle, CPURefCycle, CPUInstruction, CacheMiss, CacheReference, BranchInstruction, BranchMiss,
+		StalledCycleFrontend, StalledCycleBackend:
+		return true
+	}
+	code, found := strings.CutPrefix(counter, RawHardwareCounterTag)
+	if !found {
+		return false
+	}
+	_, err := strconv.ParseUint(code, 16, 63)
+	return err == nil
+}
+
 func IsGPUEnabled() bool {
 	return instance.Kepler.EnabledGPU
 }
 
 func SamplePeriodSec() uint64 {
+	reloadMx.RLock()
+	defer reloadMx.RUnlock()
 	return instance.SamplePeriodSec
 }
 
@@ -638,6 +933,8 @@ func ContainerPlatformPowerKey() string {
 }
 
 func ModelConfigValues(k string) string {
+	reloadMx.RLock()
+	defer reloadMx.RUnlock()
 	return instance.Model.ModelConfigValues[k]
 }
 
@@ -672,3 +969,23 @@ func DCGMHostEngineEndpoint() string {
 func ExcludeSwapperProcess() bool {
 	return instance.Kepler.ExcludeSwapperProcess
 }
+
+func OTLP() OTLPConfig {
+	return instance.OTLP
+}
+
+func RemoteWrite() RemoteWriteConfig {
+	return instance.RemoteWrite
+}
+
+func PowerPoll() PowerPollConfig {
+	return instance.PowerPoll
+}
+
+func Terminated() TerminatedConfig {
+	return instance.Terminated
+}
+
+func State() StateConfig {
+	return instance.State
+}
@@ -0,0 +1,252 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	// fileValues holds the values set in the structured config file, keyed
+	// by the same name as the environment variable of the option.
+	fileValues map[string]string
+	// parseErrors collects the values that could not be parsed while building the config.
+	parseErrors []error
+)
+
+// ValidationError lists every problem found in the configuration.
+type ValidationError struct {
+	Errors []error
+}
+
+func newValidationError(errs []error) *ValidationError {
+	return &ValidationError{Errors: errs}
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = "  - " + err.Error()
+	}
+	return fmt.Sprintf("invalid configuration (%d errors):\n%s", len(e.Errors), strings.Join(msgs, "\n"))
+}
+
+func (e *ValidationError) Unwrap() []error {
+	return e.Errors
+}
+
+// loadConfigFile reads a YAML or JSON config file that maps onto Config and
+// returns the values it sets, keyed by the env name of each option.
+// Unknown keys and type errors are all reported in the returned error.
+func loadConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+	return parseConfigFile(data)
+}
+
+func parseConfigFile(data []byte) (map[string]string, error) {
+	values := map[string]string{}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, newValidationError([]error{fmt.Errorf("config file: %w", err)})
+	}
+	if len(doc.Content) == 0 {
+		// empty file
+		return values, nil
+	}
+
+	var c Config
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&c); err != nil && !errors.Is(err, io.EOF) {
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			return nil, newValidationError([]error{fmt.Errorf("config file: %w", err)})
+		}
+		errs := make([]error, len(typeErr.Errors))
+		for i, msg := range typeErr.Errors {
+			errs[i] = fmt.Errorf("config file: %s", strings.TrimPrefix(msg, "yaml: "))
+		}
+		return nil, newValidationError(errs)
+	}
+
+	collectFileValues(doc.Content[0], reflect.ValueOf(c), values)
+	return values, nil
+}
+
+// collectFileValues walks the mapping nodes of the document together with the
+// decoded struct and records the value of every field present in the document.
+func collectFileValues(node *yaml.Node, v reflect.Value, values map[string]string) {
+	if node.Kind != yaml.MappingNode || v.Kind() != reflect.Struct {
+		return
+	}
+	present := map[string]*yaml.Node{}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		present[node.Content[i].Value] = node.Content[i+1]
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := field.Tag.Get("yaml")
+		child, exists := present[name]
+		if !exists {
+			continue
+		}
+		if field.Type.Kind() == reflect.Struct {
+			collectFileValues(child, v.Field(i), values)
+			continue
+		}
+		if key := field.Tag.Get("env"); key != "" {
+			values[key] = formatConfigValue(v.Field(i), field.Tag.Get("mapsep"))
+		}
+	}
+}
+
+// formatConfigValue converts a decoded field into the string form used by env
+// and config-dir values, so that the same parsing applies to every source.
+// Maps are formatted as KEY=VALUE pairs separated by mapSep, or by a space if empty.
+func formatConfigValue(v reflect.Value, mapSep string) string {
+	switch v.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Map:
+		// e.g. MODEL_CONFIG format: "KEY1=VALUE1 KEY2=VALUE2"
+		if mapSep == "" {
+			mapSep = " "
+		}
+		pairs := make([]string, 0, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			pairs = append(pairs, fmt.Sprintf("%v=%v", iter.Key(), iter.Value()))
+		}
+		sort.Strings(pairs)
+		return strings.Join(pairs, mapSep)
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
+
+// validateConfig returns the options that are out of range or conflict with each other.
+func validateConfig(c *Config) []error {
+	var errs []error
+	if int64(c.SamplePeriodSec) <= 0 {
+		errs = append(errs, fmt.Errorf("SAMPLE_PERIOD_SEC: must be greater than 0, got %d", int64(c.SamplePeriodSec)))
+	}
+	if c.Kepler.BPFSampleRate < 0 {
+		errs = append(errs, fmt.Errorf("EXPERIMENTAL_BPF_SAMPLE_RATE: must not be negative, got %d", c.Kepler.BPFSampleRate))
+	}
+	if c.Kepler.MaxLookupRetry < 0 {
+		errs = append(errs, fmt.Errorf("MAX_LOOKUP_RETRY: must not be negative, got %d", c.Kepler.MaxLookupRetry))
+	}
+	if interval, err := strconv.Atoi(c.Redfish.ProbeIntervalInSeconds); err != nil || interval <= 0 {
+		errs = append(errs, fmt.Errorf("REDFISH_PROBE_INTERVAL_IN_SECONDS: must be a positive integer, got %q", c.Redfish.ProbeIntervalInSeconds))
+	}
+	if c.Kepler.EstimatorSelectFilter != "" && c.Kepler.EstimatorModel == "" {
+		errs = append(errs, errors.New("ESTIMATOR_SELECT_FILTER: is set but ESTIMATOR_MODEL is empty"))
+	}
+	if c.Libvirt.MetadataURI != "" && c.Libvirt.MetadataToken == "" {
+		errs = append(errs, errors.New("LIBVIRT_METADATA_TOKEN: must be set when LIBVIRT_METADATA_URI is set"))
+	}
+	if c.OTLP.Endpoint != "" {
+		if c.OTLP.Protocol != OTLPProtocolGRPC && c.OTLP.Protocol != OTLPProtocolHTTP {
+			errs = append(errs, fmt.Errorf("OTLP_PROTOCOL: must be %q or %q, got %q", OTLPProtocolGRPC, OTLPProtocolHTTP, c.OTLP.Protocol))
+		}
+		if (c.OTLP.CertFile == "") != (c.OTLP.KeyFile == "") {
+			errs = append(errs, errors.New("OTLP_CERT_FILE: OTLP_CERT_FILE and OTLP_KEY_FILE must be set together"))
+		}
+		if c.OTLP.Insecure && (c.OTLP.CAFile != "" || c.OTLP.CertFile != "") {
+			errs = append(errs, errors.New("OTLP_INSECURE: cannot be set together with the OTLP TLS files"))
+		}
+		if c.OTLP.TimeoutSec <= 0 {
+			errs = append(errs, fmt.Errorf("OTLP_TIMEOUT_SEC: must be greater than 0, got %d", c.OTLP.TimeoutSec))
+		}
+	}
+	for _, poll := range []struct {
+		env      string
+		interval int
+	}{
+		{"COMPONENTS_POLL_INTERVAL_MS", c.PowerPoll.ComponentsIntervalMs},
+		{"PLATFORM_POLL_INTERVAL_MS", c.PowerPoll.PlatformIntervalMs},
+		{"ACCELERATOR_POLL_INTERVAL_MS", c.PowerPoll.AcceleratorIntervalMs},
+	} {
+		if poll.interval < 0 {
+			errs = append(errs, fmt.Errorf("%s: must not be negative, got %d", poll.env, poll.interval))
+		}
+	}
+	counters := parseHardwareCounters(c.Kepler.HardwareCounters)
+	if len(counters) > MaxHardwareCounters {
+		errs = append(errs, fmt.Errorf("HW_COUNTERS: must not have more than %d counters, got %d", MaxHardwareCounters, len(counters)))
+	}
+	seen := map[string]bool{}
+	for _, counter := range counters {
+		if !IsKnownHardwareCounter(counter) {
+			errs = append(errs, fmt.Errorf("HW_COUNTERS: unknown counter %q, must be a named event or %s<hex event code>", counter, RawHardwareCounterTag))
+		} else if seen[counter] {
+			errs = append(errs, fmt.Errorf("HW_COUNTERS: duplicate counter %q", counter))
+		}
+		seen[counter] = true
+	}
+	switch c.Terminated.BucketLevel {
+	case "", TerminatedBucketContainer, TerminatedBucketPod, TerminatedBucketNamespace:
+	default:
+		errs = append(errs, fmt.Errorf("TERMINATED_BUCKET_LEVEL: must be empty, %q, %q or %q, got %q",
+			TerminatedBucketContainer, TerminatedBucketPod, TerminatedBucketNamespace, c.Terminated.BucketLevel))
+	}
+	if c.Terminated.GracePeriodScrapes < 0 {
+		errs = append(errs, fmt.Errorf("TERMINATED_GRACE_PERIOD_SCRAPES: must not be negative, got %d", c.Terminated.GracePeriodScrapes))
+	}
+	if c.State.File != "" {
+		if c.State.CheckpointCycles <= 0 {
+			errs = append(errs, fmt.Errorf("STATE_CHECKPOINT_CYCLES: must be greater than 0, got %d", c.State.CheckpointCycles))
+		}
+		if c.State.MaxAgeSec <= 0 {
+			errs = append(errs, fmt.Errorf("STATE_MAX_AGE_SEC: must be greater than 0, got %d", c.State.MaxAgeSec))
+		}
+	}
+	if c.RemoteWrite.URL != "" {
+		if c.RemoteWrite.WALDir == "" {
+			errs = append(errs, errors.New("REMOTE_WRITE_WAL_DIR: must be set when REMOTE_WRITE_URL is set"))
+		}
+		if c.RemoteWrite.WALMaxSizeMB <= 0 {
+			errs = append(errs, fmt.Errorf("REMOTE_WRITE_WAL_MAX_SIZE_MB: must be greater than 0, got %d", c.RemoteWrite.WALMaxSizeMB))
+		}
+		if c.RemoteWrite.TimeoutSec <= 0 {
+			errs = append(errs, fmt.Errorf("REMOTE_WRITE_TIMEOUT_SEC: must be greater than 0, got %d", c.RemoteWrite.TimeoutSec))
+		}
+		if c.RemoteWrite.MinBackoffMs <= 0 || c.RemoteWrite.MaxBackoffMs < c.RemoteWrite.MinBackoffMs {
+			errs = append(errs, fmt.Errorf("REMOTE_WRITE_MIN_BACKOFF_MS: must be greater than 0 and not greater than REMOTE_WRITE_MAX_BACKOFF_MS, got %d and %d", c.RemoteWrite.MinBackoffMs, c.RemoteWrite.MaxBackoffMs))
+		}
+	}
+	return errs
+}
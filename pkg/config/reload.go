@@ -0,0 +1,184 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+)
+
+// reloadableKeys are the options that can safely change while kepler is running.
+// Any other option requires a restart, e.g. the eBPF programs are only attached at startup.
+var reloadableKeys = sets.New[string](
+	"SAMPLE_PERIOD_SEC",
+	"ENABLE_PROCESS_METRICS",
+	"EXPOSE_CONTAINER_METRICS",
+	"EXPOSE_VM_METRICS",
+	"EXPOSE_BPF_METRICS",
+	"EXPOSE_COMPONENT_POWER",
+	"EXPOSE_ESTIMATED_IDLE_POWER_METRICS",
+	"MODEL_CONFIG",
+	"RECONCILE_ENERGY",
+)
+
+// loaded is the configuration as read from the config sources, before any
+// Set* override. Reload compares the sources against it to find what changed.
+var loaded *Config
+
+// ReloadResult lists the options that changed in a reload.
+type ReloadResult struct {
+	// Applied holds the changed options that were applied to the running config
+	Applied []string
+	// RestartRequired holds the changed options that only take effect after a restart
+	RestartRequired []string
+}
+
+// Changed returns true if the given option was applied by the reload.
+func (r *ReloadResult) Changed(key string) bool {
+	for _, k := range r.Applied {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Reload re-reads the config file, config-dir and environment and applies the
+// runtime-safe options to the running config. Options that cannot change at
+// runtime are left untouched and reported as requiring a restart.
+// The reloaded options are written while holding reloadMx, which their getters hold
+// for reading, so that the collectors can read them while Reload runs.
+func Reload() (*ReloadResult, error) {
+	if instance == nil || loaded == nil {
+		return nil, fmt.Errorf("config is not initialized")
+	}
+	newFileValues := map[string]string{}
+	if ConfigFile != "" {
+		var err error
+		if newFileValues, err = loadConfigFile(ConfigFile); err != nil {
+			return nil, err
+		}
+	}
+	oldFileValues := fileValues
+	fileValues = newFileValues
+	c, err := newConfig()
+	if err != nil {
+		fileValues = oldFileValues
+		return nil, err
+	}
+
+	result := &ReloadResult{}
+	reloadMx.Lock()
+	diffConfig(reflect.ValueOf(loaded).Elem(), reflect.ValueOf(c).Elem(), reflect.ValueOf(instance).Elem(), result)
+	reloadMx.Unlock()
+	sort.Strings(result.Applied)
+	sort.Strings(result.RestartRequired)
+	for _, key := range result.Applied {
+		klog.Infof("config %s reloaded", key)
+	}
+	if len(result.RestartRequired) > 0 {
+		klog.Warningf("config %v changed but requires a restart to take effect", result.RestartRequired)
+	}
+	return result, nil
+}
+
+// diffConfig compares the old and new values of every option. The reloadable
+// options that changed are copied into both the loaded and running configs.
+func diffConfig(old, updated, running reflect.Value, result *ReloadResult) {
+	t := old.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Type.Kind() == reflect.Struct {
+			diffConfig(old.Field(i), updated.Field(i), running.Field(i), result)
+			continue
+		}
+		key := field.Tag.Get("env")
+		if key == "" || formatConfigValue(old.Field(i), "") == formatConfigValue(updated.Field(i), "") {
+			continue
+		}
+		if !reloadableKeys.Has(key) {
+			result.RestartRequired = append(result.RestartRequired, key)
+			continue
+		}
+		old.Field(i).Set(updated.Field(i))
+		if getSource(key) == SourceFlag {
+			// CLI flags take precedence over the reloaded config sources
+			continue
+		}
+		running.Field(i).Set(updated.Field(i))
+		setSource(key, loadedSources[key])
+		result.Applied = append(result.Applied, key)
+	}
+}
+
+// snapshotLoadedConfig keeps a copy of the config read from the config sources.
+func snapshotLoadedConfig(c *Config) {
+	snapshot := *c
+	snapshot.Model.ModelConfigValues = make(map[string]string, len(c.Model.ModelConfigValues))
+	for k, v := range c.Model.ModelConfigValues {
+		snapshot.Model.ModelConfigValues[k] = v
+	}
+	loaded = &snapshot
+}
+
+// WatchConfigFiles polls the config file and the config-dir every interval and
+// calls onChange when any of them is modified, until stop is closed.
+func WatchConfigFiles(interval time.Duration, stop <-chan struct{}, onChange func()) {
+	last := configFilesFingerprint()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			current := configFilesFingerprint()
+			if current != last {
+				last = current
+				onChange()
+			}
+		}
+	}
+}
+
+// configFilesFingerprint summarizes the modification time and size of the config files.
+// Files are stat'ed through symlinks, so ConfigMap updates are detected as well.
+func configFilesFingerprint() string {
+	paths := []string{}
+	if ConfigFile != "" {
+		paths = append(paths, ConfigFile)
+	}
+	if entries, err := os.ReadDir(BaseDir); err == nil {
+		for _, entry := range entries {
+			paths = append(paths, filepath.Join(BaseDir, entry.Name()))
+		}
+	}
+	fingerprint := ""
+	for _, path := range paths {
+		if s, err := os.Stat(path); err == nil && !s.IsDir() {
+			fingerprint += fmt.Sprintf("%s:%d:%d;", path, s.ModTime().UnixNano(), s.Size())
+		}
+	}
+	return fingerprint
+}
@@ -32,12 +32,29 @@ const (
 	CPUInstruction = "cpu_instructions"
 	CacheMiss      = "cache_miss"
 
+	CacheReference        = "cache_references"
+	BranchInstruction     = "branch_instructions"
+	BranchMiss            = "branch_misses"
+	StalledCycleFrontend  = "stalled_cycles_frontend"
+	StalledCycleBackend   = "stalled_cycles_backend"
+	RawHardwareCounterTag = "raw_0x" // raw PMU event code, e.g. raw_0x11
+
 	// bpf - attacher package
 	CPUTime       = "bpf_cpu_time_ms"
 	PageCacheHit  = "bpf_page_cache_hit"
 	IRQNetTXLabel = "bpf_net_tx_irq"
 	IRQNetRXLabel = "bpf_net_rx_irq"
 	IRQBlockLabel = "bpf_block_irq"
+	BytesTx       = "bytes_tx"
+	BytesRx       = "bytes_rx"
+	PacketsTx     = "packets_tx"
+	PacketsRx     = "packets_rx"
+
+	BlockReadBytes     = "block_read_bytes"
+	BlockWriteBytes    = "block_write_bytes"
+	BlockReadRequests  = "block_read_requests"
+	BlockWriteRequests = "block_write_requests"
+	BlockServiceTime   = "block_service_time_us"
 
 	// GPU
 	GPUComputeUtilization = "gpu_compute_util"
@@ -89,12 +106,17 @@ const (
 	defaultMaxLookupRetry   = 500
 	// MaxIRQ is the maximum number of IRQs to be monitored
 	MaxIRQ = 10
+	// MaxHardwareCounters is the maximum number of hardware counters to be monitored,
+	// it must match MAX_HW_COUNTERS in the eBPF program
+	MaxHardwareCounters = 8
 	// defaultSamplePeriodSec is the time in seconds that the reader will wait before reading the metrics again
 	defaultSamplePeriodSec       = 3
 	defaultKubeConfig            = ""
+	defaultEnableAPIServer       = true
 	defaultBPFSampleRate         = 0
 	defaultCPUArchOverride       = ""
 	defaultExcludeSwapperProcess = false
+	defaultHardwareCounters      = CPUCycle + "," + CPURefCycle + "," + CPUInstruction + "," + CacheMiss
 	// model_parameter_prefix
 	defaultNodePlatformPowerKey        = "NODE_TOTAL"
 	defaultNodeComponentsPowerKey      = "NODE_COMPONENTS"
@@ -104,6 +126,24 @@ const (
 	defaultProcessComponentsPowerKey   = "PROCESS_COMPONENTS"
 	DefaultMachineSpecFilePath         = "/etc/kepler/models/machine/spec.json"
 	defaultDCGMHostEngineEndpoint      = "localhost:5555"
+	defaultOTLPTimeoutSec              = 10
+	defaultRemoteWriteWALDir           = "/var/lib/kepler/remote-write"
+	defaultRemoteWriteWALMaxSizeMB     = 256
+	defaultRemoteWriteTimeoutSec       = 30
+	defaultRemoteWriteMinBackoffMs     = 500
+	defaultRemoteWriteMaxBackoffMs     = 60000
+	defaultStateCheckpointCycles       = 10
+	defaultStateMaxAgeSec              = 3600
+	// OTLP protocols
+	OTLPProtocolGRPC = "grpc"
+	OTLPProtocolHTTP = "http/protobuf"
+	// levels of the terminated buckets
+	TerminatedBucketContainer = "container"
+	TerminatedBucketPod       = "pod"
+	TerminatedBucketNamespace = "namespace"
 )
 
 var BaseDir string = "/etc/kepler/kepler.config"
+
+// ConfigFile is the optional structured (YAML or JSON) config file.
+var ConfigFile string
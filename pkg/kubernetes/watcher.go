@@ -230,6 +230,11 @@ func (w *ObjListWatcher) Run() error {
 	return nil
 }
 
+// HasSynced returns true if the watcher has listed the pods of the node
+func (w *ObjListWatcher) HasSynced() bool {
+	return w.informer != nil && w.informer.HasSynced()
+}
+
 func (w *ObjListWatcher) runWorker() {
 	for w.processNextItem() {
 	}
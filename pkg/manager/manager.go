@@ -17,8 +17,14 @@ limitations under the License.
 package manager
 
 import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sustainable-computing-io/kepler/pkg/bpf"
 	"github.com/sustainable-computing-io/kepler/pkg/collector"
 	"github.com/sustainable-computing-io/kepler/pkg/config"
@@ -36,19 +42,64 @@ type CollectorManager struct {
 
 	// Watcher register in the kubernetes apiserver to watch for pod events to add or remove it from the ContainerStats map
 	Watcher *kubernetes.ObjListWatcher
+
+	// ticker triggers the metric collection every sample period
+	ticker *time.Ticker
+	// interval measures the time between the collection cycles, which drifts from the sample period
+	interval collector.IntervalTimer
+
+	// wg tracks the collection loop, which returns after the last cycle and flush
+	wg sync.WaitGroup
+	// pushCtx is the context of the pushes, cancelPushes aborts the pushes that are still
+	// running when the shutdown timeout expires
+	pushCtx      context.Context
+	cancelPushes context.CancelFunc
+
+	// reloadMx serializes config reloads
+	reloadMx sync.Mutex
+
+	// pushers push the metrics to external systems after every collection cycle
+	pushers []*pusher
+
+	// bpfSupportedMetrics holds the metrics collected by the bpf exporter
+	bpfSupportedMetrics bpf.SupportedMetrics
+
+	// lastUpdate is the time, in unix nanoseconds, when the last collection cycle completed
+	lastUpdate atomic.Int64
+}
+
+// Pusher pushes the collected metrics to an external system
+type Pusher interface {
+	Push(ctx context.Context) error
+}
+
+// Flusher is implemented by the pushers that buffer the metrics. Flush is called on shutdown,
+// after the push of the last collection cycle, and must return when ctx is done.
+type Flusher interface {
+	Flush(ctx context.Context) error
+}
+
+// shutdownTimeout bounds the wait for the running pushes and the last push and flush on shutdown
+var shutdownTimeout = 10 * time.Second
+
+type pusher struct {
+	name   string
+	pusher Pusher
+	// kick triggers a push, a cycle is skipped if the previous push is still running
+	kick chan struct{}
+	// done is closed when the pusher returns, after its kick channel is closed
+	done chan struct{}
 }
 
 func New(bpfExporter bpf.Exporter) *CollectorManager {
 	var err error
 	manager := &CollectorManager{}
 	supportedMetrics := bpfExporter.SupportedMetrics()
+	manager.bpfSupportedMetrics = supportedMetrics
 	manager.StatsCollector = collector.NewCollector(bpfExporter)
-	manager.PrometheusCollector = exporter.NewPrometheusExporter(supportedMetrics)
-	// the collector and prometheusExporter share structures and collections
-	manager.PrometheusCollector.NewProcessCollector(manager.StatsCollector.ProcessStats)
-	manager.PrometheusCollector.NewContainerCollector(manager.StatsCollector.ContainerStats)
-	manager.PrometheusCollector.NewVMCollector(manager.StatsCollector.VMStats)
-	manager.PrometheusCollector.NewNodeCollector(&manager.StatsCollector.NodeStats)
+	manager.StatsCollector.EnableState()
+	manager.PrometheusCollector = exporter.NewPrometheusExporter()
+	manager.newPrometheusCollectors()
 	// configure the watcher
 	if manager.Watcher, err = kubernetes.NewObjListWatcher(supportedMetrics); err != nil {
 		klog.Errorf("could not create the watcher, %v", err)
@@ -61,29 +112,198 @@ func New(bpfExporter bpf.Exporter) *CollectorManager {
 	return manager
 }
 
-func (m *CollectorManager) Start() error {
+// Start starts the collection loop, which collects the metrics every sample period until ctx is canceled.
+// On cancellation the loop runs a last collection cycle, pushes its metrics and flushes the pushers.
+func (m *CollectorManager) Start(ctx context.Context) error {
 	if err := m.StatsCollector.Initialize(); err != nil {
 		return err
 	}
 
-	samplePeriod := time.Duration((config.SamplePeriodSec()) * uint64(time.Second))
+	m.ticker = time.NewTicker(samplePeriod())
+
+	// the pushes are not canceled with ctx, so that the running pushes can complete during the shutdown
+	m.pushCtx, m.cancelPushes = context.WithCancel(context.Background())
+	for _, p := range m.pushers {
+		go p.run(m.pushCtx)
+	}
 
+	m.wg.Add(1)
 	go func() {
-		ticker := time.NewTicker(samplePeriod)
+		defer m.wg.Done()
 		for {
+			select {
+			case <-ctx.Done():
+				m.shutdown()
+				return
 			// wait x seconds before updating the metrics
-			<-ticker.C
-
-			// acquire the lock to wait prometheus finish the metric collection before updating the metrics
-			m.PrometheusCollector.Mx.Lock()
-			m.StatsCollector.Update()
-			m.PrometheusCollector.Mx.Unlock()
+			case <-m.ticker.C:
+				m.update()
+				m.kickPushers()
+			}
 		}
 	}()
 
 	return nil
 }
 
+// Run starts the collection loop and blocks until ctx is canceled and the last cycle is flushed
+func (m *CollectorManager) Run(ctx context.Context) error {
+	if err := m.Start(ctx); err != nil {
+		return err
+	}
+	m.Wait()
+	return nil
+}
+
+// Wait blocks until the collection loop returns, after the context of Start is canceled
+func (m *CollectorManager) Wait() {
+	m.wg.Wait()
+}
+
+func (m *CollectorManager) update() {
+	// acquire the lock to wait the watcher before updating the metrics, the scrapes and the API
+	// read the snapshot published by the update and do not wait for it
+	m.PrometheusCollector.Mx.Lock()
+	m.StatsCollector.Update(m.interval.Next(time.Now()))
+	m.PrometheusCollector.Mx.Unlock()
+	m.lastUpdate.Store(time.Now().UnixNano())
+}
+
+// shutdown runs the last collection cycle, so that the counters include the usage since the
+// previous cycle, and saves the state file. Then it waits for the running pushes and pushes
+// and flushes the last metrics, within shutdownTimeout. The pushes still running at the
+// timeout are canceled and their pushers are not called again.
+func (m *CollectorManager) shutdown() {
+	m.ticker.Stop()
+	klog.Infof("Running the last collection cycle")
+	m.update()
+	m.PrometheusCollector.Mx.Lock()
+	m.StatsCollector.SaveState()
+	m.PrometheusCollector.Mx.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	for _, p := range m.pushers {
+		close(p.kick)
+	}
+	for _, p := range m.pushers {
+		select {
+		case <-p.done:
+		case <-ctx.Done():
+		}
+	}
+	m.cancelPushes()
+
+	for _, p := range m.pushers {
+		select {
+		case <-p.done:
+		default:
+			klog.Errorf("the push to %s did not complete within %s, skipping the last push", p.name, shutdownTimeout)
+			continue
+		}
+		if err := p.pusher.Push(ctx); err != nil {
+			klog.Errorf("failed to push the last metrics to %s: %v", p.name, err)
+		}
+		if f, ok := p.pusher.(Flusher); ok {
+			if err := f.Flush(ctx); err != nil {
+				klog.Errorf("failed to flush %s: %v", p.name, err)
+			}
+		}
+	}
+}
+
+// AddPusher registers a Pusher that is called after every collection cycle, it must be called before Start.
+// A Pusher that also implements Flusher is flushed on shutdown.
+func (m *CollectorManager) AddPusher(name string, p Pusher) {
+	m.pushers = append(m.pushers, &pusher{
+		name:   name,
+		pusher: p,
+		kick:   make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	})
+}
+
+func (m *CollectorManager) kickPushers() {
+	for _, p := range m.pushers {
+		select {
+		case p.kick <- struct{}{}:
+		default:
+			klog.V(3).Infof("skipping %s push, the previous push is still running", p.name)
+		}
+	}
+}
+
+func (p *pusher) run(ctx context.Context) {
+	defer close(p.done)
+	for range p.kick {
+		if err := p.pusher.Push(ctx); err != nil {
+			klog.Errorf("failed to push metrics to %s: %v", p.name, err)
+		}
+	}
+}
+
 func (m *CollectorManager) Stop() {
 	m.Watcher.ShutDownWithDrain()
+	m.StatsCollector.Close()
+}
+
+// Reload re-reads the configuration and applies the options that can change at runtime.
+// The ticker picks up a new sample period, the power models are recreated when
+// their config changes, and the Prometheus collectors are recreated so that
+// their descriptors follow the EXPOSE_* options.
+func (m *CollectorManager) Reload() (*config.ReloadResult, error) {
+	m.reloadMx.Lock()
+	defer m.reloadMx.Unlock()
+
+	// acquire the lock to wait the metric update and collection to finish before changing the config
+	m.PrometheusCollector.Mx.Lock()
+	result, err := config.Reload()
+	if err != nil {
+		m.PrometheusCollector.Mx.Unlock()
+		return nil, err
+	}
+	if result.Changed("SAMPLE_PERIOD_SEC") && m.ticker != nil {
+		m.ticker.Reset(samplePeriod())
+	}
+	if result.Changed("MODEL_CONFIG") {
+		// the samplers keep running, so that their buffers and staleness are kept
+		m.StatsCollector.CreatePowerModels()
+	}
+	m.PrometheusCollector.Mx.Unlock()
+
+	if len(result.Applied) > 0 {
+		// the registry gathers the metrics when registering, which requires the lock to be released
+		m.PrometheusCollector.UnregisterMetrics()
+		m.newPrometheusCollectors()
+		m.PrometheusCollector.RegisterMetrics()
+	}
+	return result, nil
+}
+
+// MetricsHandler serves the metrics of the registry and counts the scrapes, which end the grace period of the
+// removed processes and containers. The pushers gather the same registry, but they do not count as scrapes.
+func (m *CollectorManager) MetricsHandler(reg *prometheus.Registry) http.Handler {
+	handler := promhttp.HandlerFor(
+		reg,
+		promhttp.HandlerOpts{
+			Registry: reg,
+		},
+	)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.StatsCollector.MarkScraped()
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// newPrometheusCollectors creates the Prometheus collectors for the collector stats
+func (m *CollectorManager) newPrometheusCollectors() {
+	// the prometheus collectors read the snapshot published after every update
+	m.PrometheusCollector.NewProcessCollector(m.StatsCollector.Snapshot)
+	m.PrometheusCollector.NewContainerCollector(m.StatsCollector.Snapshot)
+	m.PrometheusCollector.NewVMCollector(m.StatsCollector.Snapshot)
+	m.PrometheusCollector.NewNodeCollector(m.StatsCollector.Snapshot)
+}
+
+func samplePeriod() time.Duration {
+	return time.Duration(config.SamplePeriodSec() * uint64(time.Second))
 }
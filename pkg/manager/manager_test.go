@@ -1,12 +1,65 @@
 package manager
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"time"
+
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sustainable-computing-io/kepler/pkg/bpf"
 	"github.com/sustainable-computing-io/kepler/pkg/config"
 )
 
+type fakePusher struct {
+	pushed chan struct{}
+}
+
+// fakeFlusher records the pushes and flushes in order
+type fakeFlusher struct {
+	mx    sync.Mutex
+	calls []string
+}
+
+func (f *fakeFlusher) Push(ctx context.Context) error {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	f.calls = append(f.calls, "push")
+	return nil
+}
+
+func (f *fakeFlusher) Flush(ctx context.Context) error {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	f.calls = append(f.calls, "flush")
+	return nil
+}
+
+func (p *fakePusher) Push(ctx context.Context) error {
+	p.pushed <- struct{}{}
+	return nil
+}
+
+// hangingPusher blocks until its context is canceled, like a push to an endpoint that does not respond
+type hangingPusher struct {
+	started chan struct{}
+	pushes  atomic.Int32
+	err     chan error
+}
+
+func (p *hangingPusher) Push(ctx context.Context) error {
+	p.pushes.Add(1)
+	p.started <- struct{}{}
+	<-ctx.Done()
+	p.err <- ctx.Err()
+	return ctx.Err()
+}
+
 var _ = Describe("Manager", func() {
 
 	It("Should work properly", func() {
@@ -14,8 +67,170 @@ var _ = Describe("Manager", func() {
 		Expect(err).NotTo(HaveOccurred())
 		bpfExporter := bpf.NewMockExporter(bpf.DefaultSupportedMetrics())
 		CollectorManager := New(bpfExporter)
-		err = CollectorManager.Start()
+		err = CollectorManager.Start(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("Should reload the runtime-safe config", func() {
+		_, err := config.Initialize(".")
+		Expect(err).NotTo(HaveOccurred())
+		bpfExporter := bpf.NewMockExporter(bpf.DefaultSupportedMetrics())
+		CollectorManager := New(bpfExporter)
+		ctx, cancel := context.WithCancel(context.Background())
+		err = CollectorManager.Start(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(func() {
+			cancel()
+			CollectorManager.Wait()
+		})
+
+		// the cleanups run in reverse order, so the config is reloaded after the environment is restored
+		DeferCleanup(func() {
+			_, err := config.Reload()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(config.SamplePeriodSec()).NotTo(Equal(uint64(5)))
+			Expect(config.IsExposeProcessStatsEnabled()).To(BeFalse())
+		})
+		GinkgoT().Setenv("SAMPLE_PERIOD_SEC", "5")
+		GinkgoT().Setenv("ENABLE_PROCESS_METRICS", "true")
+		GinkgoT().Setenv("MODEL_CONFIG", "NODE_TOTAL_ESTIMATOR=true")
+		result, err := CollectorManager.Reload()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Applied).To(ConsistOf("SAMPLE_PERIOD_SEC", "ENABLE_PROCESS_METRICS", "MODEL_CONFIG"))
+		Expect(config.SamplePeriodSec()).To(Equal(uint64(5)))
+		Expect(config.IsExposeProcessStatsEnabled()).To(BeTrue())
+
+		// the process metrics are exposed after the collectors are registered again
+		descs := make(chan *prometheus.Desc, 1000)
+		CollectorManager.PrometheusCollector.ProcessStatsCollector.Describe(descs)
+		Expect(descs).NotTo(BeEmpty())
+	})
+
+	It("Should count the scrapes of the metrics endpoint but not the gathers of the pushers", func() {
+		_, err := config.Initialize(".")
+		Expect(err).NotTo(HaveOccurred())
+		bpfExporter := bpf.NewMockExporter(bpf.DefaultSupportedMetrics())
+		CollectorManager := New(bpfExporter)
+		reg := prometheus.NewRegistry()
+		reg.MustRegister(CollectorManager.PrometheusCollector.NodeStatsCollector)
+
+		// the pushers gather the registry after every collection cycle
+		_, err = reg.Gather()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(CollectorManager.StatsCollector.Scrapes()).To(BeZero())
+
+		handler := CollectorManager.MetricsHandler(reg)
+		for i := 0; i < 2; i++ {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+			Expect(w.Code).To(Equal(http.StatusOK))
+		}
+		Expect(CollectorManager.StatsCollector.Scrapes()).To(Equal(2))
+	})
+
+	It("Should push the metrics after a collection cycle", func() {
+		_, err := config.Initialize(".")
+		Expect(err).NotTo(HaveOccurred())
+		bpfExporter := bpf.NewMockExporter(bpf.DefaultSupportedMetrics())
+		CollectorManager := New(bpfExporter)
+		p := &fakePusher{pushed: make(chan struct{}, 1)}
+		CollectorManager.AddPusher("fake", p)
+		err = CollectorManager.Start(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+
+		CollectorManager.kickPushers()
+		Eventually(p.pushed).Should(Receive())
+	})
+
+	It("Should run a last collection cycle and flush the pushers on shutdown", func() {
+		_, err := config.Initialize(".")
+		Expect(err).NotTo(HaveOccurred())
+		bpfExporter := bpf.NewMockExporter(bpf.DefaultSupportedMetrics())
+		CollectorManager := New(bpfExporter)
+		f := &fakeFlusher{}
+		CollectorManager.AddPusher("fake", f)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() { done <- CollectorManager.Run(ctx) }()
+		Consistently(done, 100*time.Millisecond).ShouldNot(Receive())
+		Expect(CollectorManager.lastUpdate.Load()).To(BeZero())
+
+		cancel()
+		Eventually(done).Should(Receive(BeNil()))
+		Expect(CollectorManager.lastUpdate.Load()).NotTo(BeZero())
+		f.mx.Lock()
+		defer f.mx.Unlock()
+		Expect(f.calls).To(Equal([]string{"push", "flush"}))
+	})
+
+	It("Should cancel the pushes that are still running after the shutdown timeout", func() {
+		_, err := config.Initialize(".")
 		Expect(err).NotTo(HaveOccurred())
+		timeout := shutdownTimeout
+		shutdownTimeout = 200 * time.Millisecond
+		DeferCleanup(func() { shutdownTimeout = timeout })
+		bpfExporter := bpf.NewMockExporter(bpf.DefaultSupportedMetrics())
+		CollectorManager := New(bpfExporter)
+		h := &hangingPusher{started: make(chan struct{}, 1), err: make(chan error, 1)}
+		CollectorManager.AddPusher("hanging", h)
+		f := &fakeFlusher{}
+		CollectorManager.AddPusher("fake", f)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		Expect(CollectorManager.Start(ctx)).To(Succeed())
+		CollectorManager.kickPushers()
+		Eventually(h.started).Should(Receive())
+
+		start := time.Now()
+		cancel()
+		done := make(chan struct{})
+		go func() {
+			CollectorManager.Wait()
+			close(done)
+		}()
+		Eventually(done, 5*time.Second).Should(BeClosed())
+		Expect(time.Since(start)).To(BeNumerically("<", 2*time.Second))
+		Eventually(h.err).Should(Receive(MatchError(context.Canceled)))
+		// the last push of the hanging pusher is skipped, the other pushers are flushed
+		Expect(h.pushes.Load()).To(Equal(int32(1)))
+		f.mx.Lock()
+		defer f.mx.Unlock()
+		Expect(f.calls).To(Equal([]string{"push", "push", "flush"}))
+	})
+
+	It("Should report the readiness of the subsystems", func() {
+		_, err := config.Initialize(".")
+		Expect(err).NotTo(HaveOccurred())
+		bpfExporter := bpf.NewMockExporter(bpf.DefaultSupportedMetrics())
+		CollectorManager := New(bpfExporter)
+
+		// no collection cycle completed yet
+		readiness := CollectorManager.Readiness()
+		Expect(readiness.Ready).To(BeFalse())
+		Expect(readiness.Checks).To(ContainElement(Check{Name: "last-update", Status: CheckFailed, Message: "no collection cycle completed yet"}))
+
+		now := time.Now()
+		CollectorManager.lastUpdate.Store(now.Add(-time.Second).UnixNano())
+		Expect(CollectorManager.checkLastUpdate(now).Status).To(Equal(CheckOK))
+		CollectorManager.lastUpdate.Store(now.Add(-time.Hour).UnixNano())
+		Expect(CollectorManager.checkLastUpdate(now).Status).To(Equal(CheckFailed))
+
+		Expect(checkPowerSource("rapl-sysfs", "acpi").Status).To(Equal(CheckOK))
+		Expect(checkPowerSource("estimator", "none").Status).To(Equal(CheckDegraded))
+		Expect(checkModel("estimator", false, true).Status).To(Equal(CheckFailed))
+		Expect(checkModel("rapl-sysfs", false, true).Status).To(Equal(CheckOK))
+		Expect(checkModel("rapl-sysfs", true, false).Status).To(Equal(CheckFailed))
+		Expect(CollectorManager.checkBPF(errors.New("map lookup failed")).Status).To(Equal(CheckFailed))
+	})
+
+	It("Should aggregate the checks into the overall status", func() {
+		ok := Check{Name: "a", Status: CheckOK}
+		degraded := Check{Name: "b", Status: CheckDegraded}
+		failed := Check{Name: "c", Status: CheckFailed}
+		Expect(newReadiness([]Check{ok, ok})).To(Equal(Readiness{Status: CheckOK, Ready: true, Checks: []Check{ok, ok}}))
+		Expect(newReadiness([]Check{ok, degraded})).To(Equal(Readiness{Status: CheckDegraded, Ready: true, Checks: []Check{ok, degraded}}))
+		Expect(newReadiness([]Check{failed, degraded})).To(Equal(Readiness{Status: CheckFailed, Ready: false, Checks: []Check{failed, degraded}}))
 	})
 
 })
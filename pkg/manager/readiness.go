@@ -0,0 +1,161 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sustainable-computing-io/kepler/pkg/config"
+	"github.com/sustainable-computing-io/kepler/pkg/kubernetes"
+	"github.com/sustainable-computing-io/kepler/pkg/model"
+	"github.com/sustainable-computing-io/kepler/pkg/sensors/components"
+	"github.com/sustainable-computing-io/kepler/pkg/sensors/platform"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// CheckStatus is the result of a readiness check
+type CheckStatus string
+
+const (
+	CheckOK CheckStatus = "ok"
+	// CheckDegraded means kepler works but with less accurate metrics, e.g. the power is estimated
+	CheckDegraded CheckStatus = "degraded"
+	// CheckFailed means kepler does not report valid metrics
+	CheckFailed CheckStatus = "failed"
+
+	estimatorSourceName = "estimator"
+	// maxMissedUpdates is the number of sample periods without update after which the metrics are stale
+	maxMissedUpdates = 3
+)
+
+// Check is the readiness of a subsystem
+type Check struct {
+	Name    string      `json:"name"`
+	Status  CheckStatus `json:"status"`
+	Message string      `json:"message"`
+}
+
+// Readiness is the readiness of kepler and of each of its subsystems
+type Readiness struct {
+	// Status is failed if any check failed, degraded if any check is degraded, ok otherwise
+	Status CheckStatus `json:"status"`
+	// Ready is false if any check failed
+	Ready  bool    `json:"ready"`
+	Checks []Check `json:"checks"`
+}
+
+// Readiness checks the eBPF collection, the power sources, the kubernetes watcher,
+// the power models and the age of the last collection cycle.
+func (m *CollectorManager) Readiness() Readiness {
+	m.PrometheusCollector.Mx.Lock()
+	bpfErr := m.StatsCollector.BPFError()
+	m.PrometheusCollector.Mx.Unlock()
+
+	checks := []Check{
+		m.checkBPF(bpfErr),
+		checkPowerSource(components.GetSourceName(), platform.GetSourceName()),
+		m.checkWatcher(),
+		checkModel(components.GetSourceName(), model.IsNodeComponentPowerModelEnabled(), model.IsProcessPowerModelEnabled()),
+		m.checkLastUpdate(time.Now()),
+	}
+	return newReadiness(checks)
+}
+
+func newReadiness(checks []Check) Readiness {
+	r := Readiness{Status: CheckOK, Ready: true, Checks: checks}
+	for _, c := range checks {
+		switch c.Status {
+		case CheckFailed:
+			r.Status = CheckFailed
+			r.Ready = false
+		case CheckDegraded:
+			if r.Status == CheckOK {
+				r.Status = CheckDegraded
+			}
+		}
+	}
+	return r
+}
+
+func (m *CollectorManager) checkBPF(bpfErr error) Check {
+	c := Check{Name: "bpf"}
+	if bpfErr != nil {
+		c.Status = CheckFailed
+		c.Message = fmt.Sprintf("failed to read the eBPF tables: %v", bpfErr)
+		return c
+	}
+	c.Status = CheckOK
+	c.Message = fmt.Sprintf("attached, software counters %v, hardware counters %v",
+		sets.List(m.bpfSupportedMetrics.SoftwareCounters), sets.List(m.bpfSupportedMetrics.HardwareCounters))
+	return c
+}
+
+func checkPowerSource(componentsSource, platformSource string) Check {
+	c := Check{Name: "power-source", Status: CheckOK}
+	c.Message = fmt.Sprintf("components: %s, platform: %s", componentsSource, platformSource)
+	if componentsSource == estimatorSourceName {
+		c.Status = CheckDegraded
+		c.Message += ", the components power is estimated because no power meter is available"
+	}
+	return c
+}
+
+func (m *CollectorManager) checkWatcher() Check {
+	c := Check{Name: "kubernetes-watcher", Status: CheckOK}
+	switch {
+	case !kubernetes.IsWatcherEnabled:
+		c.Message = "disabled"
+	case m.Watcher == nil || !m.Watcher.HasSynced():
+		c.Status = CheckFailed
+		c.Message = "the pods of the node are not synced"
+	default:
+		c.Message = "synced"
+	}
+	return c
+}
+
+func checkModel(componentsSource string, nodeModelEnabled, processModelEnabled bool) Check {
+	c := Check{Name: "power-model", Status: CheckOK, Message: "enabled"}
+	switch {
+	case !processModelEnabled:
+		c.Status = CheckFailed
+		c.Message = "no process power model is enabled"
+	case componentsSource == estimatorSourceName && !nodeModelEnabled:
+		c.Status = CheckFailed
+		c.Message = "the node components power is estimated but the node power model is not enabled"
+	}
+	return c
+}
+
+func (m *CollectorManager) checkLastUpdate(now time.Time) Check {
+	c := Check{Name: "last-update", Status: CheckOK}
+	maxAge := maxMissedUpdates * time.Duration(config.SamplePeriodSec()) * time.Second
+	last := m.lastUpdate.Load()
+	if last == 0 {
+		c.Status = CheckFailed
+		c.Message = "no collection cycle completed yet"
+		return c
+	}
+	age := now.Sub(time.Unix(0, last)).Round(time.Millisecond)
+	c.Message = fmt.Sprintf("%s ago", age)
+	if age > maxAge {
+		c.Status = CheckFailed
+		c.Message += fmt.Sprintf(", the metrics are stale (more than %s)", maxAge)
+	}
+	return c
+}
@@ -0,0 +1,277 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package measure implements the "kepler measure -- <command>" subcommand, which runs a
+// command and reports the energy attributed to its process tree, like perf stat.
+package measure
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sustainable-computing-io/kepler/pkg/collector/stats"
+	"github.com/sustainable-computing-io/kepler/pkg/config"
+	"github.com/sustainable-computing-io/kepler/pkg/standalone"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// FormatTable and FormatJSON are the output formats
+	FormatTable = "table"
+	FormatJSON  = "json"
+
+	procPath = "/proc"
+	// scanInterval is how often the descendants of the command are looked up
+	scanInterval = 50 * time.Millisecond
+)
+
+// component maps the name of a power component to its dynamic and idle energy stats
+type component struct {
+	name string
+	dyn  string
+	idle string
+}
+
+var components = []component{
+	{"package", config.DynEnergyInPkg, config.IdleEnergyInPkg},
+	{"core", config.DynEnergyInCore, config.IdleEnergyInCore},
+	{"uncore", config.DynEnergyInUnCore, config.IdleEnergyInUnCore},
+	{"dram", config.DynEnergyInDRAM, config.IdleEnergyInDRAM},
+	{"gpu", config.DynEnergyInGPU, config.IdleEnergyInGPU},
+	{"platform", config.DynEnergyInPlatform, config.IdleEnergyInPlatform},
+}
+
+// Energy is the energy of a power component attributed to the command
+type Energy struct {
+	TotalJoules   float64 `json:"total_joules"`
+	DynamicJoules float64 `json:"dynamic_joules"`
+	IdleJoules    float64 `json:"idle_joules"`
+}
+
+// Result is the summary of a measurement
+type Result struct {
+	Command         []string  `json:"command"`
+	ExitCode        int       `json:"exit_code"`
+	Start           time.Time `json:"start"`
+	DurationSec     float64   `json:"duration_sec"`
+	SamplePeriodSec uint64    `json:"sample_period_sec"`
+	// Processes is the number of processes of the process tree of the command, the processes that
+	// start and exit within a collection cycle are only counted when the exit events are supported
+	Processes int               `json:"processes"`
+	Energy    map[string]Energy `json:"energy"`
+}
+
+func newResult(command []string) *Result {
+	r := &Result{
+		Command:         command,
+		SamplePeriodSec: config.SamplePeriodSec(),
+		Energy:          map[string]Energy{},
+	}
+	for _, c := range components {
+		r.Energy[c.name] = Energy{}
+	}
+	return r
+}
+
+// add accumulates the energy of the last collection cycle of the processes. The stats of a
+// process with another start time belong to a new process that reused the PID.
+func (r *Result) add(snapshot *stats.Snapshot, processes map[processID]bool) {
+	for id := range processes {
+		p, exists := snapshot.ProcessStats[id.pid]
+		if !exists || (id.startTime != 0 && p.StartTime != 0 && p.StartTime != id.startTime) {
+			continue
+		}
+		for _, comp := range components {
+			e := r.Energy[comp.name]
+			if dyn, exists := p.EnergyUsage[comp.dyn]; exists {
+				e.DynamicJoules += float64(dyn.SumAllDeltaValues()) / 1000
+			}
+			if idle, exists := p.EnergyUsage[comp.idle]; exists {
+				e.IdleJoules += float64(idle.SumAllDeltaValues()) / 1000
+			}
+			e.TotalJoules = e.DynamicJoules + e.IdleJoules
+			r.Energy[comp.name] = e
+		}
+	}
+}
+
+func (r *Result) write(out io.Writer, format string) error {
+	if format == FormatJSON {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(r)
+	}
+	fmt.Fprintf(out, "\n Energy stats for '%s':\n\n", strings.Join(r.Command, " "))
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "\tcomponent\ttotal (J)\tdynamic (J)\tidle (J)\t")
+	for _, c := range components {
+		e := r.Energy[c.name]
+		fmt.Fprintf(w, "\t%s\t%.3f\t%.3f\t%.3f\t\n", c.name, e.TotalJoules, e.DynamicJoules, e.IdleJoules)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(out, "\n %.3f seconds elapsed, %d processes, exit code %d\n\n", r.DurationSec, r.Processes, r.ExitCode)
+	return err
+}
+
+// run starts the command, updates the collector every sample period until the command
+// exits, and runs a last cycle for the usage of the command since the previous one.
+func run(c *standalone.Collector, command []string) (*Result, error) {
+	result := newResult(command)
+	// reset the usage and energy deltas so that the first cycle starts with the command
+	if err := c.Update(); err != nil {
+		klog.Errorf("%v", err)
+	}
+
+	// the orphaned descendants of the command are reparented to measure instead of init,
+	// so that they stay in the process tree
+	if err := becomeSubreaper(); err != nil {
+		klog.Errorf("failed to become the reaper of the orphaned processes: %v", err)
+	}
+	cmd := exec.Command(command[0], command[1:]...) //nolint:gosec // running the command is the purpose of measure
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	result.Start = time.Now()
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", command[0], err)
+	}
+	tree := newProcessTree(procPath, uint64(os.Getpid()), uint64(cmd.Process.Pid))
+
+	// the signals are for the command, measure stops when the command exits
+	signalChan := make(chan os.Signal, 1)
+	signal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signalChan)
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	update := func() {
+		if err := c.Update(); err != nil {
+			klog.Errorf("%v", err)
+		}
+		snapshot := c.StatsCollector.Snapshot()
+		tree.addExits(snapshot.ProcessExits)
+		result.add(snapshot, tree.processes)
+		snapshot.Release()
+		tree.removeExited()
+	}
+	scan := func() {
+		tree.scan()
+		reap(tree.orphans)
+	}
+	scanTicker := time.NewTicker(scanInterval)
+	defer scanTicker.Stop()
+	updateTicker := time.NewTicker(time.Duration(config.SamplePeriodSec()) * time.Second)
+	defer updateTicker.Stop()
+	var waitErr error
+loop:
+	for {
+		select {
+		case sig := <-signalChan:
+			_ = cmd.Process.Signal(sig)
+		case <-scanTicker.C:
+			scan()
+		case <-updateTicker.C:
+			scan()
+			update()
+		case waitErr = <-done:
+			break loop
+		}
+	}
+	result.DurationSec = time.Since(result.Start).Seconds()
+	scan()
+	update()
+	result.Processes = tree.count
+
+	var exitErr *exec.ExitError
+	switch {
+	case waitErr == nil:
+	case errors.As(waitErr, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+	default:
+		return nil, waitErr
+	}
+	return result, nil
+}
+
+// Main runs the measure subcommand with its command line arguments and returns the exit code
+// of the command, or 1 if it could not be measured
+func Main(args []string) int {
+	fs := flag.NewFlagSet("kepler measure", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: kepler measure [flags] -- <command> [args]\n\n"+
+			"The collector updates every SAMPLE_PERIOD_SEC, set it to 1 for short commands.\n\n")
+		fs.PrintDefaults()
+	}
+	baseDir := fs.String("config-dir", config.BaseDir, "path to config base directory")
+	configFile := fs.String("config-file", "", "path to a YAML or JSON config file")
+	format := fs.String("format", FormatTable, "output format, table or json")
+	output := fs.String("output", "", "file to write the summary to, instead of stderr")
+	klog.InitFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	if *format != FormatTable && *format != FormatJSON {
+		fmt.Fprintf(os.Stderr, "invalid format %q, must be %s or %s\n", *format, FormatTable, FormatJSON)
+		return 2
+	}
+	// the logs would be mixed with the output of the command
+	standalone.SilenceLogs(fs)
+
+	c, err := standalone.Start(*baseDir, *configFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	result, err := run(c, fs.Args())
+	c.Close()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	out := io.Writer(os.Stderr)
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer f.Close()
+		out = f
+	}
+	if err := result.write(out, *format); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write the summary: %v\n", err)
+		return 1
+	}
+	return result.ExitCode
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package measure
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/sustainable-computing-io/kepler/pkg/bpf"
+	"github.com/sustainable-computing-io/kepler/pkg/collector"
+	"github.com/sustainable-computing-io/kepler/pkg/collector/stats"
+	"github.com/sustainable-computing-io/kepler/pkg/config"
+)
+
+var _ = Describe("Test Measure", func() {
+	BeforeEach(func() {
+		_, err := config.Initialize(".")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	writeStat := func(procPath string, pid, ppid int, comm string, startTime uint64, state string) {
+		dir := filepath.Join(procPath, fmt.Sprint(pid))
+		Expect(os.MkdirAll(dir, 0o755)).To(Succeed())
+		stat := fmt.Sprintf("%d (%s) %s %d 1 1 0 -1 4194560 0 0 0 0 0 0 0 0 20 0 1 0 %d 0 0\n", pid, comm, state, ppid, startTime)
+		Expect(os.WriteFile(filepath.Join(dir, "stat"), []byte(stat), 0o644)).To(Succeed())
+	}
+
+	It("Should track the descendants of the command", func() {
+		procPath := GinkgoT().TempDir()
+		writeStat(procPath, 1, 0, "init", 1, "S")
+		writeStat(procPath, 100, 1, "sh", 10, "S")
+		// the grandchild is listed before the child
+		writeStat(procPath, 102, 200, "make (job) 1", 12, "R")
+		writeStat(procPath, 200, 100, "make", 11, "S")
+		writeStat(procPath, 300, 1, "other", 5, "S")
+
+		tree := newProcessTree(procPath, 50, 100)
+		tree.scan()
+		Expect(tree.processes).To(Equal(map[processID]bool{{100, 10}: true, {102, 12}: true, {200, 11}: true}))
+		Expect(tree.exited).To(BeEmpty())
+
+		// the exited process is tracked until its last cycle is counted
+		Expect(os.RemoveAll(filepath.Join(procPath, "102"))).To(Succeed())
+		tree.scan()
+		Expect(tree.processes).To(HaveKey(processID{102, 12}))
+		Expect(tree.exited).To(Equal(map[processID]bool{{102, 12}: true}))
+		tree.removeExited()
+		Expect(tree.processes).NotTo(HaveKey(processID{102, 12}))
+
+		// the reused PIDs are tracked only for the descendants
+		writeStat(procPath, 102, 300, "other child", 20, "R")
+		writeStat(procPath, 200, 100, "make", 21, "S")
+		tree.scan()
+		Expect(tree.processes).To(Equal(map[processID]bool{{100, 10}: true, {200, 11}: true, {200, 21}: true}))
+		Expect(tree.exited).To(Equal(map[processID]bool{{200, 11}: true}))
+		tree.removeExited()
+		Expect(tree.count).To(Equal(4))
+	})
+
+	It("Should track the orphans reparented to measure and reap the exited ones", func() {
+		procPath := GinkgoT().TempDir()
+		writeStat(procPath, 50, 1, "kepler", 1, "S")
+		writeStat(procPath, 100, 50, "sh", 10, "Z")
+		writeStat(procPath, 101, 50, "daemon", 11, "S")
+		writeStat(procPath, 102, 50, "worker", 12, "Z")
+
+		tree := newProcessTree(procPath, 50, 100)
+		tree.scan()
+		Expect(tree.processes).To(Equal(map[processID]bool{{100, 10}: true, {101, 11}: true, {102, 12}: true}))
+		// the command is reaped by cmd.Wait
+		Expect(tree.orphans).To(ConsistOf(uint64(102)))
+	})
+
+	It("Should add the descendants that exited between two scans", func() {
+		procPath := GinkgoT().TempDir()
+		writeStat(procPath, 100, 1, "make", 10, "S")
+		tree := newProcessTree(procPath, 50, 100)
+
+		tree.addExits([]stats.ProcessExit{
+			// the grandchild exited before its parent
+			{PID: 102, StartTime: 12, ParentPID: 101, ParentStartTime: 11},
+			{PID: 101, StartTime: 11, ParentPID: 100, ParentStartTime: 10},
+			// an orphan reparented to measure
+			{PID: 103, StartTime: 13, ParentPID: 50, ParentStartTime: 1},
+			// the parent PID was reused
+			{PID: 104, StartTime: 14, ParentPID: 100, ParentStartTime: 2},
+			{PID: 105, StartTime: 15, ParentPID: 1, ParentStartTime: 1},
+			// the source does not report the parent
+			{PID: 106, StartTime: 16},
+		})
+		Expect(tree.processes).To(Equal(map[processID]bool{{100, 10}: true, {101, 11}: true, {102, 12}: true, {103, 13}: true}))
+		Expect(tree.exited).To(Equal(map[processID]bool{{101, 11}: true, {102, 12}: true, {103, 13}: true}))
+		Expect(tree.count).To(Equal(4))
+
+		tree.addExits([]stats.ProcessExit{{PID: 100, StartTime: 10, ParentPID: 50, ParentStartTime: 1}})
+		Expect(tree.exited).To(HaveKey(processID{100, 10}))
+		Expect(tree.count).To(Equal(4))
+		tree.removeExited()
+		Expect(tree.processes).To(BeEmpty())
+	})
+
+	It("Should parse the stat when the command name has spaces and parentheses", func() {
+		procPath := GinkgoT().TempDir()
+		writeStat(procPath, 42, 7, "a) b (c", 1234, "Z")
+		stat, ok := readStat(filepath.Join(procPath, "42", "stat"))
+		Expect(ok).To(BeTrue())
+		Expect(stat).To(Equal(procStat{ppid: 7, startTime: 1234, zombie: true}))
+	})
+
+	It("Should accumulate the energy of the tracked processes", func() {
+		c := collector.NewCollector(bpf.NewMockExporter(bpf.DefaultSupportedMetrics()))
+		for pid := uint64(1); pid <= 4; pid++ {
+			p := stats.NewProcessStats(pid, pid, "", "", "command")
+			p.StartTime = pid * 10
+			p.EnergyUsage[config.DynEnergyInPkg].SetDeltaStat(stats.MockedSocketID, 2000)
+			p.EnergyUsage[config.IdleEnergyInPkg].SetDeltaStat(stats.MockedSocketID, 500)
+			p.EnergyUsage[config.DynEnergyInPlatform].SetDeltaStat(stats.MockedSocketID, 4000)
+			c.ProcessStats[pid] = p
+		}
+		c.PublishSnapshot()
+		r := newResult([]string{"stress-ng", "--cpu", "1"})
+		// the PID 3 was reused by another process
+		processes := map[processID]bool{{1, 10}: true, {2, 0}: true, {3, 5}: true, {99, 990}: true}
+		// two cycles
+		r.add(c.Snapshot(), processes)
+		r.add(c.Snapshot(), processes)
+		Expect(r.Energy["package"]).To(Equal(Energy{TotalJoules: 10, DynamicJoules: 8, IdleJoules: 2}))
+		Expect(r.Energy["platform"].DynamicJoules).To(Equal(16.0))
+		Expect(r.Energy["dram"]).To(Equal(Energy{}))
+		Expect(r.Energy).To(HaveLen(len(components)))
+	})
+
+	It("Should write the summary as a table or json", func() {
+		r := newResult([]string{"sleep", "1"})
+		r.DurationSec = 1.5
+		r.Processes = 1
+		r.ExitCode = 3
+		r.Energy["package"] = Energy{TotalJoules: 1.5, DynamicJoules: 1, IdleJoules: 0.5}
+
+		out := &bytes.Buffer{}
+		Expect(r.write(out, FormatTable)).To(Succeed())
+		Expect(out.String()).To(ContainSubstring("Energy stats for 'sleep 1'"))
+		Expect(out.String()).To(MatchRegexp(`package\s+1\.500\s+1\.000\s+0\.500`))
+		Expect(out.String()).To(ContainSubstring("1.500 seconds elapsed, 1 processes, exit code 3"))
+
+		out.Reset()
+		Expect(r.write(out, FormatJSON)).To(Succeed())
+		var decoded Result
+		Expect(json.Unmarshal(out.Bytes(), &decoded)).To(Succeed())
+		Expect(decoded.Command).To(Equal([]string{"sleep", "1"}))
+		Expect(decoded.ExitCode).To(Equal(3))
+		Expect(decoded.Energy["package"].TotalJoules).To(Equal(1.5))
+	})
+})
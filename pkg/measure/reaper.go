@@ -0,0 +1,36 @@
+//go:build !darwin
+// +build !darwin
+
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package measure
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// becomeSubreaper makes measure the reaper of its orphaned descendants
+func becomeSubreaper() error {
+	return unix.Prctl(unix.PR_SET_CHILD_SUBREAPER, 1, 0, 0, 0)
+}
+
+// reap collects the exit status of the orphans that exited, which are otherwise zombies until measure exits
+func reap(pids []uint64) {
+	for _, pid := range pids {
+		_, _ = unix.Wait4(int(pid), nil, unix.WNOHANG, nil)
+	}
+}
@@ -0,0 +1,197 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package measure
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/sustainable-computing-io/kepler/pkg/collector/stats"
+)
+
+// processID identifies a process by its PID and its start time in clock ticks since boot,
+// since the PIDs are reused. The start time is 0 if it is unknown.
+type processID struct {
+	pid       uint64
+	startTime uint64
+}
+
+// procStat holds the fields of /proc/<pid>/stat that the tree needs
+type procStat struct {
+	ppid      uint64
+	startTime uint64
+	zombie    bool
+}
+
+// processTree tracks a process and its descendants. The running descendants are found by
+// scanning procfs, the descendants that start and exit between two scans are found by the
+// exit events of the collector, which identify the parent of the process.
+type processTree struct {
+	procPath string
+	// reaper is the PID of measure, the orphaned descendants are reparented to it
+	reaper uint64
+	root   uint64
+	// processes are the tracked processes, the exited ones are removed once their last cycle is counted
+	processes map[processID]bool
+	// exited are the tracked processes that exited since the last cycle
+	exited map[processID]bool
+	// count is the number of processes tracked since the start
+	count int
+	// orphans are the exited orphans reparented to measure, which must be reaped
+	orphans []uint64
+}
+
+func newProcessTree(procPath string, reaper, root uint64) *processTree {
+	t := &processTree{
+		procPath:  procPath,
+		reaper:    reaper,
+		root:      root,
+		processes: map[processID]bool{},
+		exited:    map[processID]bool{},
+	}
+	var startTime uint64
+	if stat, ok := readStat(filepath.Join(procPath, strconv.FormatUint(root, 10), "stat")); ok {
+		startTime = stat.startTime
+	}
+	t.track(processID{pid: root, startTime: startTime})
+	return t
+}
+
+func (t *processTree) track(id processID) {
+	t.processes[id] = true
+	t.count++
+}
+
+// tracked returns true if the process is tracked. A process whose start time is unknown
+// matches any start time of its PID.
+func (t *processTree) tracked(id processID) bool {
+	return t.processes[id] || t.processes[processID{pid: id.pid}]
+}
+
+// isParent returns true if the parent is tracked, or is measure, which the orphans are reparented to
+func (t *processTree) isParent(parent processID) bool {
+	return parent.pid == t.reaper || t.tracked(parent)
+}
+
+// scan adds the running descendants of the tracked processes, and marks the tracked processes
+// that are not running anymore, or whose PID was reused, as exited.
+func (t *processTree) scan() {
+	entries, err := os.ReadDir(t.procPath)
+	if err != nil {
+		return
+	}
+	procs := map[uint64]procStat{}
+	for _, e := range entries {
+		pid, err := strconv.ParseUint(e.Name(), 10, 64)
+		if err != nil {
+			continue
+		}
+		if stat, ok := readStat(filepath.Join(t.procPath, e.Name(), "stat")); ok {
+			procs[pid] = stat
+		}
+	}
+
+	// repeat until no descendant is added, since the children can be listed before their parents
+	for added := true; added; {
+		added = false
+		for pid, stat := range procs {
+			id := processID{pid: pid, startTime: stat.startTime}
+			parent, exists := procs[stat.ppid]
+			if t.tracked(id) || !exists || !t.isParent(processID{pid: stat.ppid, startTime: parent.startTime}) {
+				continue
+			}
+			t.track(id)
+			added = true
+		}
+	}
+
+	t.orphans = t.orphans[:0]
+	for pid, stat := range procs {
+		if stat.zombie && stat.ppid == t.reaper && pid != t.root {
+			t.orphans = append(t.orphans, pid)
+		}
+	}
+	for id := range t.processes {
+		stat, exists := procs[id.pid]
+		if !exists || (id.startTime != 0 && stat.startTime != id.startTime) {
+			t.exited[id] = true
+		}
+	}
+}
+
+// addExits adds the processes that exited during the cycle and whose parent is tracked, which
+// includes the descendants that started and exited between two scans.
+func (t *processTree) addExits(exits []stats.ProcessExit) {
+	for added := true; added; {
+		added = false
+		for _, exit := range exits {
+			id := processID{pid: exit.PID, startTime: exit.StartTime}
+			if t.exited[id] {
+				continue
+			}
+			if t.tracked(id) {
+				t.exited[id] = true
+				continue
+			}
+			if exit.ParentPID == 0 || !t.isParent(processID{pid: exit.ParentPID, startTime: exit.ParentStartTime}) {
+				continue
+			}
+			t.track(id)
+			t.exited[id] = true
+			added = true
+		}
+	}
+}
+
+// removeExited stops tracking the processes that exited, once their last cycle is counted,
+// so that a new process that reuses their PID is not counted
+func (t *processTree) removeExited() {
+	for id := range t.exited {
+		delete(t.processes, id)
+	}
+	t.exited = map[processID]bool{}
+}
+
+// readStat reads the state, the parent PID and the start time, the third, fourth and 22nd
+// fields of /proc/<pid>/stat. The command name, the second field, is parenthesized and can
+// contain spaces and parentheses.
+func readStat(path string) (procStat, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return procStat{}, false
+	}
+	stat := string(data)
+	end := strings.LastIndexByte(stat, ')')
+	if end < 0 {
+		return procStat{}, false
+	}
+	fields := strings.Fields(stat[end+1:])
+	if len(fields) < 20 {
+		return procStat{}, false
+	}
+	ppid, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return procStat{}, false
+	}
+	startTime, err := strconv.ParseUint(fields[19], 10, 64)
+	if err != nil {
+		return procStat{}, false
+	}
+	return procStat{ppid: ppid, startTime: startTime, zombie: fields[0] == "Z"}, true
+}
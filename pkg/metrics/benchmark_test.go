@@ -0,0 +1,121 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sustainable-computing-io/kepler/pkg/bpf"
+	"github.com/sustainable-computing-io/kepler/pkg/collector"
+	"github.com/sustainable-computing-io/kepler/pkg/collector/stats"
+	"github.com/sustainable-computing-io/kepler/pkg/config"
+	"github.com/sustainable-computing-io/kepler/pkg/model"
+)
+
+// lockedCollector takes the lock of the collector update in Collect, as the prometheus
+// collectors did before they read the published snapshot
+type lockedCollector struct {
+	mx *sync.Mutex
+	prometheus.Collector
+}
+
+func (c lockedCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.Collector.Collect(ch)
+}
+
+// benchmarkUpdateWhileScraping runs the collection cycles while prometheus continuously scrapes the
+// metrics, and reports how long the cycles wait for the lock, which delays the sampling
+func benchmarkUpdateWhileScraping(b *testing.B, processNumber int, lockedScrape bool) {
+	_, _ = config.Initialize(".")
+	stats.SetMockedCollectorMetrics()
+	bpfExporter := bpf.NewMockExporter(bpf.DefaultSupportedMetrics())
+	metricCollector := collector.NewCollector(bpfExporter)
+	metricCollector.ProcessStats = stats.CreateMockedProcessStats(processNumber)
+	metricCollector.NodeStats = stats.CreateMockedNodeStats()
+	metricCollector.AggregateProcessResourceUtilizationMetrics()
+	model.CreatePowerEstimatorModels(stats.GetProcessFeatureNames())
+	metricCollector.PublishSnapshot()
+
+	exporter := NewPrometheusExporter()
+	// every mocked process is in its own container
+	exporter.NewContainerCollector(metricCollector.Snapshot)
+	exporter.NewNodeCollector(metricCollector.Snapshot)
+	registry := prometheus.NewRegistry()
+	for _, c := range []prometheus.Collector{exporter.ContainerStatsCollector, exporter.NodeStatsCollector} {
+		if lockedScrape {
+			c = lockedCollector{mx: &exporter.Mx, Collector: c}
+		}
+		registry.MustRegister(c)
+	}
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				_, _ = registry.Gather()
+			}
+		}
+	}()
+
+	waits := make([]time.Duration, 0, b.N)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		start := time.Now()
+		exporter.Mx.Lock()
+		waits = append(waits, time.Since(start))
+		metricCollector.UpdateProcessEnergyUtilizationMetrics(time.Duration(config.SamplePeriodSec()) * time.Second)
+		metricCollector.AggregateProcessEnergyUtilizationMetrics()
+		metricCollector.PublishSnapshot()
+		exporter.Mx.Unlock()
+	}
+	b.StopTimer()
+	close(stop)
+	wg.Wait()
+
+	sort.Slice(waits, func(i, j int) bool { return waits[i] < waits[j] })
+	b.ReportMetric(float64(waits[len(waits)*99/100].Nanoseconds()), "p99-lock-wait-ns")
+	b.ReportMetric(float64(waits[len(waits)-1].Nanoseconds()), "max-lock-wait-ns")
+}
+
+func BenchmarkUpdateWhileScrapingSnapshotWith1000Container(b *testing.B) {
+	benchmarkUpdateWhileScraping(b, 1000, false)
+}
+
+func BenchmarkUpdateWhileScrapingLockedWith1000Container(b *testing.B) {
+	benchmarkUpdateWhileScraping(b, 1000, true)
+}
+
+func BenchmarkUpdateWhileScrapingSnapshotWith4000Container(b *testing.B) {
+	benchmarkUpdateWhileScraping(b, 4000, false)
+}
+
+func BenchmarkUpdateWhileScrapingLockedWith4000Container(b *testing.B) {
+	benchmarkUpdateWhileScraping(b, 4000, true)
+}
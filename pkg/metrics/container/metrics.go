@@ -17,10 +17,7 @@ limitations under the License.
 package container
 
 import (
-	"sync"
-
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/sustainable-computing-io/kepler/pkg/bpf"
 	"github.com/sustainable-computing-io/kepler/pkg/collector/stats"
 	"github.com/sustainable-computing-io/kepler/pkg/config"
 	"github.com/sustainable-computing-io/kepler/pkg/metrics/consts"
@@ -32,27 +29,20 @@ const (
 	context = "container"
 )
 
-// collector implements prometheus.Collector. It collects metrics directly from container maps.
+// collector implements prometheus.Collector. It collects metrics from the published snapshot of the container stats.
 type collector struct {
 	descriptions map[string]*prometheus.Desc
 	collectors   map[string]metricfactory.PromMetric
 
-	// ContainerStats holds all containers energy and resource usage metrics
-	ContainerStats map[string]*stats.ContainerStats
-
-	// Lock to synchronize the collector update with prometheus exporter
-	Mx *sync.Mutex
-
-	bpfSupportedMetrics bpf.SupportedMetrics
+	// snapshot returns the stats published after the last update, which are read without lock
+	snapshot func() *stats.Snapshot
 }
 
-func NewContainerCollector(containerMetrics map[string]*stats.ContainerStats, mx *sync.Mutex, bpfSupportedMetrics bpf.SupportedMetrics) prometheus.Collector {
+func NewContainerCollector(snapshot func() *stats.Snapshot) prometheus.Collector {
 	c := &collector{
-		ContainerStats:      containerMetrics,
-		descriptions:        make(map[string]*prometheus.Desc),
-		collectors:          make(map[string]metricfactory.PromMetric),
-		Mx:                  mx,
-		bpfSupportedMetrics: bpfSupportedMetrics,
+		snapshot:     snapshot,
+		descriptions: make(map[string]*prometheus.Desc),
+		collectors:   make(map[string]metricfactory.PromMetric),
 	}
 	c.initMetrics()
 	return c
@@ -63,11 +53,7 @@ func (c *collector) initMetrics() {
 	if !config.IsExposeContainerStatsEnabled() {
 		return
 	}
-	for name, desc := range metricfactory.HCMetricsPromDesc(context, c.bpfSupportedMetrics) {
-		c.descriptions[name] = desc
-		c.collectors[name] = metricfactory.NewPromCounter(desc)
-	}
-	for name, desc := range metricfactory.SCMetricsPromDesc(context, c.bpfSupportedMetrics) {
+	for name, desc := range metricfactory.ResUtilizationMetricsPromDesc(context) {
 		c.descriptions[name] = desc
 		c.collectors[name] = metricfactory.NewPromCounter(desc)
 	}
@@ -75,10 +61,6 @@ func (c *collector) initMetrics() {
 		c.descriptions[name] = desc
 		c.collectors[name] = metricfactory.NewPromCounter(desc)
 	}
-	for name, desc := range metricfactory.GPUUsageMetricsPromDesc(context) {
-		c.descriptions[name] = desc
-		c.collectors[name] = metricfactory.NewPromCounter(desc)
-	}
 
 	desc := metricfactory.MetricsPromDesc(context, "joules", "_total", "", consts.ContainerEnergyLabels)
 	c.descriptions["total"] = desc
@@ -92,12 +74,20 @@ func (c *collector) Describe(ch chan<- *prometheus.Desc) {
 }
 
 func (c *collector) Collect(ch chan<- prometheus.Metric) {
-	c.Mx.Lock()
-	for _, container := range c.ContainerStats {
-		utils.CollectEnergyMetrics(ch, container, c.collectors)
-		utils.CollectResUtilizationMetrics(ch, container, c.collectors, c.bpfSupportedMetrics)
-		// update container total joules
-		utils.CollectTotalEnergyMetrics(ch, container, c.collectors)
+	snapshot := c.snapshot()
+	defer snapshot.Release()
+	for _, container := range snapshot.ContainerStats {
+		c.collect(ch, container)
 	}
-	c.Mx.Unlock()
+	// the buckets keep the values of the removed containers, so that the sum of the container counters does not decrease
+	for _, bucket := range snapshot.TerminatedContainerStats {
+		c.collect(ch, bucket)
+	}
+}
+
+func (c *collector) collect(ch chan<- prometheus.Metric, container *stats.ContainerStats) {
+	utils.CollectEnergyMetrics(ch, container, c.collectors)
+	utils.CollectResUtilizationMetrics(ch, container, c.collectors)
+	// update container total joules
+	utils.CollectTotalEnergyMetrics(ch, container, c.collectors)
 }
@@ -20,7 +20,7 @@ import (
 	"strings"
 
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/sustainable-computing-io/kepler/pkg/bpf"
+	"github.com/sustainable-computing-io/kepler/pkg/collector/stats"
 	"github.com/sustainable-computing-io/kepler/pkg/config"
 	"github.com/sustainable-computing-io/kepler/pkg/metrics/consts"
 	modeltypes "github.com/sustainable-computing-io/kepler/pkg/model/types"
@@ -68,29 +68,13 @@ func energyMetricsPromDesc(context, name, source string) (desc *prometheus.Desc)
 	return MetricsPromDesc(context, name, consts.EnergyMetricNameSuffix, source, labels)
 }
 
-func HCMetricsPromDesc(context string, bpfSupportedMetrics bpf.SupportedMetrics) (descriptions map[string]*prometheus.Desc) {
+// ResUtilizationMetricsPromDesc creates the descriptions of the resource utilization metrics of the registered
+// sources, the source label is the name of the source of each metric
+func ResUtilizationMetricsPromDesc(context string) (descriptions map[string]*prometheus.Desc) {
 	descriptions = make(map[string]*prometheus.Desc)
-	for name := range bpfSupportedMetrics.HardwareCounters {
-		descriptions[name] = resMetricsPromDesc(context, name, "bpf")
-	}
-	return descriptions
-}
-
-func SCMetricsPromDesc(context string, bpfSupportedMetrics bpf.SupportedMetrics) (descriptions map[string]*prometheus.Desc) {
-	descriptions = make(map[string]*prometheus.Desc)
-	for name := range bpfSupportedMetrics.SoftwareCounters {
-		descriptions[name] = resMetricsPromDesc(context, name, "bpf")
-	}
-	return descriptions
-}
-
-func GPUUsageMetricsPromDesc(context string) (descriptions map[string]*prometheus.Desc) {
-	descriptions = make(map[string]*prometheus.Desc)
-	if config.IsGPUEnabled() {
-		if gpu := acc.GetActiveAcceleratorByType(config.GPU); gpu != nil {
-			for _, name := range consts.GPUMetricNames {
-				descriptions[name] = resMetricsPromDesc(context, name, gpu.Device().Name())
-			}
+	for _, source := range stats.ResourceSources() {
+		for _, name := range source.SupportedMetrics() {
+			descriptions[name] = resMetricsPromDesc(context, name, source.Name())
 		}
 	}
 	return descriptions
@@ -17,10 +17,10 @@ limitations under the License.
 package node
 
 import (
-	"sync"
-
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sustainable-computing-io/kepler/pkg/collector/stats"
+	"github.com/sustainable-computing-io/kepler/pkg/config"
+	"github.com/sustainable-computing-io/kepler/pkg/metrics/consts"
 	"github.com/sustainable-computing-io/kepler/pkg/metrics/metricfactory"
 	"github.com/sustainable-computing-io/kepler/pkg/metrics/utils"
 	"github.com/sustainable-computing-io/kepler/pkg/sensors/components"
@@ -31,24 +31,20 @@ const (
 	context = "node"
 )
 
-// collector implements prometheus.Collector. It collects metrics directly from BPF maps.
+// collector implements prometheus.Collector. It collects metrics from the published snapshot of the node stats.
 type collector struct {
 	descriptions map[string]*prometheus.Desc
 	collectors   map[string]metricfactory.PromMetric
 
-	// NodeStats holds all node energy and resource usage metrics
-	NodeStats *stats.NodeStats
-
-	// Lock to synchronize the collector update with prometheus exporter
-	Mx *sync.Mutex
+	// snapshot returns the stats published after the last update, which are read without lock
+	snapshot func() *stats.Snapshot
 }
 
-func NewNodeCollector(nodeMetrics *stats.NodeStats, mx *sync.Mutex) prometheus.Collector {
+func NewNodeCollector(snapshot func() *stats.Snapshot) prometheus.Collector {
 	c := &collector{
-		NodeStats:    nodeMetrics,
+		snapshot:     snapshot,
 		descriptions: make(map[string]*prometheus.Desc),
 		collectors:   make(map[string]metricfactory.PromMetric),
-		Mx:           mx,
 	}
 	c.initMetrics()
 	return c
@@ -68,6 +64,35 @@ func (c *collector) initMetrics() {
 	})
 	c.descriptions["info"] = desc
 	c.collectors["info"] = metricfactory.NewPromCounter(desc)
+
+	desc = prometheus.NewDesc(
+		prometheus.BuildFQName(consts.MetricsNamespace, context, "energy_staleness_seconds"),
+		"Time in seconds since the power meter of the component was read, the energy is extrapolated meanwhile",
+		[]string{"component"},
+		nil,
+	)
+	c.descriptions["staleness"] = desc
+	c.collectors["staleness"] = metricfactory.NewPromGauge(desc)
+
+	desc = prometheus.NewDesc(
+		prometheus.BuildFQName(consts.MetricsNamespace, context, "attribution_residual_joules"),
+		"Energy in joules of the component during the last collection cycle that was not attributed to the processes, "+
+			"negative if the processes were attributed more energy than the node consumed",
+		[]string{"component", "mode"},
+		nil,
+	)
+	c.descriptions["residual"] = desc
+	c.collectors["residual"] = metricfactory.NewPromGauge(desc)
+
+	desc = prometheus.NewDesc(
+		prometheus.BuildFQName(consts.MetricsNamespace, context, "hw_counter_multiplexing_ratio"),
+		"Share of the time the hardware counter was counting during the last collection cycle, the counter values are "+
+			"extrapolated if it is below 1 because the PMU is multiplexing more events than it can count at once",
+		[]string{"counter"},
+		nil,
+	)
+	c.descriptions["multiplexing"] = desc
+	c.collectors["multiplexing"] = metricfactory.NewPromGauge(desc)
 }
 
 func (c *collector) Describe(ch chan<- *prometheus.Desc) {
@@ -77,16 +102,41 @@ func (c *collector) Describe(ch chan<- *prometheus.Desc) {
 }
 
 func (c *collector) Collect(ch chan<- prometheus.Metric) {
-	c.Mx.Lock()
-	utils.CollectEnergyMetrics(ch, c.NodeStats, c.collectors)
+	snapshot := c.snapshot()
+	defer snapshot.Release()
+	nodeStats := snapshot.NodeStats
+	utils.CollectEnergyMetrics(ch, nodeStats, c.collectors)
 	// we export different node resource utilization metrics than process, container and vms
 	// TODO: verify if the resource utilization metrics are needed
-	c.Mx.Unlock()
 
 	// update node info
 	ch <- c.collectors["info"].MustMetric(1,
-		c.NodeStats.CPUArchitecture(),
+		nodeStats.CPUArchitecture(),
 		components.GetSourceName(),
 		platform.GetSourceName(),
 	)
+
+	for component, staleness := range nodeStats.Staleness {
+		ch <- c.collectors["staleness"].MustMetric(staleness, component)
+	}
+
+	for counter, ratio := range nodeStats.MultiplexingRatio {
+		ch <- c.collectors["multiplexing"].MustMetric(ratio, counter)
+	}
+
+	for i, component := range consts.EnergyMetricNames {
+		if component == config.GPU && !config.IsGPUEnabled() {
+			continue
+		}
+		c.collectResidual(ch, nodeStats, component, consts.DynEnergyMetricNames[i], "dynamic")
+		if config.IsIdlePowerEnabled() {
+			c.collectResidual(ch, nodeStats, component, consts.IdleEnergyMetricNames[i], "idle")
+		}
+	}
+}
+
+func (c *collector) collectResidual(ch chan<- prometheus.Metric, nodeStats *stats.NodeStats, component, metric, mode string) {
+	if residual, exists := nodeStats.AttributionResidual[metric]; exists {
+		ch <- c.collectors["residual"].MustMetric(float64(residual)/utils.JouleMillijouleConversionFactor, component, mode)
+	}
 }
@@ -0,0 +1,198 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package otlp
+
+import (
+	"sort"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/sustainable-computing-io/kepler/pkg/build"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	scopeName   = "github.com/sustainable-computing-io/kepler"
+	serviceName = "kepler"
+
+	// Prometheus labels that are moved to the resource attributes
+	podLabel       = "pod_name"
+	namespaceLabel = "container_namespace"
+
+	// OpenTelemetry semantic conventions
+	serviceNameAttr   = "service.name"
+	hostNameAttr      = "host.name"
+	k8sNodeNameAttr   = "k8s.node.name"
+	k8sPodNameAttr    = "k8s.pod.name"
+	k8sNamespaceAttr  = "k8s.namespace.name"
+	serviceVersionKey = "service.version"
+)
+
+// resourceKey identifies the resource the series belong to, series without pod belong to the node
+type resourceKey struct {
+	namespace string
+	pod       string
+}
+
+// toRequest converts the gathered Prometheus metric families into an OTLP export request.
+// The series of each pod are grouped in their own resource with the node, pod and
+// namespace attributes, and all the other series are grouped in the node resource.
+func toRequest(families []*dto.MetricFamily, nodeName string, start, now time.Time) *colmetricspb.ExportMetricsServiceRequest {
+	startNano := uint64(start.UnixNano())
+	nowNano := uint64(now.UnixNano())
+
+	// metrics per resource, in the order of the metric families
+	metrics := map[resourceKey]map[string]*metricspb.Metric{}
+	order := map[resourceKey][]string{}
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			key, attrs := splitLabels(m.GetLabel())
+			point, ok := toDataPoint(family.GetType(), m, attrs, startNano, nowNano)
+			if !ok {
+				klog.V(5).Infof("skipping OTLP export of %s with unsupported type %s", family.GetName(), family.GetType())
+				break
+			}
+			if _, exists := metrics[key]; !exists {
+				metrics[key] = map[string]*metricspb.Metric{}
+			}
+			metric, exists := metrics[key][family.GetName()]
+			if !exists {
+				metric = newMetric(family)
+				metrics[key][family.GetName()] = metric
+				order[key] = append(order[key], family.GetName())
+			}
+			addDataPoint(metric, point)
+		}
+	}
+
+	keys := make([]resourceKey, 0, len(metrics))
+	for key := range metrics {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].namespace != keys[j].namespace {
+			return keys[i].namespace < keys[j].namespace
+		}
+		return keys[i].pod < keys[j].pod
+	})
+
+	request := &colmetricspb.ExportMetricsServiceRequest{}
+	for _, key := range keys {
+		scopeMetrics := &metricspb.ScopeMetrics{
+			Scope: &commonpb.InstrumentationScope{Name: scopeName, Version: build.Version},
+		}
+		for _, name := range order[key] {
+			scopeMetrics.Metrics = append(scopeMetrics.Metrics, metrics[key][name])
+		}
+		request.ResourceMetrics = append(request.ResourceMetrics, &metricspb.ResourceMetrics{
+			Resource:     newResource(key, nodeName),
+			ScopeMetrics: []*metricspb.ScopeMetrics{scopeMetrics},
+		})
+	}
+	return request
+}
+
+func newResource(key resourceKey, nodeName string) *resourcepb.Resource {
+	attrs := []*commonpb.KeyValue{
+		stringAttr(serviceNameAttr, serviceName),
+		stringAttr(serviceVersionKey, build.Version),
+		stringAttr(hostNameAttr, nodeName),
+		stringAttr(k8sNodeNameAttr, nodeName),
+	}
+	if key.namespace != "" {
+		attrs = append(attrs, stringAttr(k8sNamespaceAttr, key.namespace))
+	}
+	if key.pod != "" {
+		attrs = append(attrs, stringAttr(k8sPodNameAttr, key.pod))
+	}
+	return &resourcepb.Resource{Attributes: attrs}
+}
+
+// splitLabels moves the pod and namespace labels to the resource key and
+// returns the remaining labels as data point attributes.
+func splitLabels(labels []*dto.LabelPair) (resourceKey, []*commonpb.KeyValue) {
+	key := resourceKey{}
+	attrs := make([]*commonpb.KeyValue, 0, len(labels))
+	for _, label := range labels {
+		switch label.GetName() {
+		case podLabel:
+			key.pod = label.GetValue()
+		case namespaceLabel:
+			key.namespace = label.GetValue()
+		default:
+			attrs = append(attrs, stringAttr(label.GetName(), label.GetValue()))
+		}
+	}
+	return key, attrs
+}
+
+func newMetric(family *dto.MetricFamily) *metricspb.Metric {
+	metric := &metricspb.Metric{
+		Name:        family.GetName(),
+		Description: family.GetHelp(),
+	}
+	switch family.GetType() {
+	case dto.MetricType_COUNTER:
+		metric.Data = &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+			AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+			IsMonotonic:            true,
+		}}
+	default:
+		metric.Data = &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{}}
+	}
+	return metric
+}
+
+func toDataPoint(metricType dto.MetricType, m *dto.Metric, attrs []*commonpb.KeyValue, startNano, nowNano uint64) (*metricspb.NumberDataPoint, bool) {
+	point := &metricspb.NumberDataPoint{
+		Attributes:   attrs,
+		TimeUnixNano: nowNano,
+	}
+	switch metricType {
+	case dto.MetricType_COUNTER:
+		point.StartTimeUnixNano = startNano
+		point.Value = &metricspb.NumberDataPoint_AsDouble{AsDouble: m.GetCounter().GetValue()}
+	case dto.MetricType_GAUGE:
+		point.Value = &metricspb.NumberDataPoint_AsDouble{AsDouble: m.GetGauge().GetValue()}
+	case dto.MetricType_UNTYPED:
+		point.Value = &metricspb.NumberDataPoint_AsDouble{AsDouble: m.GetUntyped().GetValue()}
+	default:
+		// kepler does not expose histograms or summaries
+		return nil, false
+	}
+	return point, true
+}
+
+func addDataPoint(metric *metricspb.Metric, point *metricspb.NumberDataPoint) {
+	switch data := metric.Data.(type) {
+	case *metricspb.Metric_Sum:
+		data.Sum.DataPoints = append(data.Sum.DataPoints, point)
+	case *metricspb.Metric_Gauge:
+		data.Gauge.DataPoints = append(data.Gauge.DataPoints, point)
+	}
+}
+
+func stringAttr(key, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{
+		Key:   key,
+		Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}},
+	}
+}
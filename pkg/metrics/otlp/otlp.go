@@ -0,0 +1,245 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sustainable-computing-io/kepler/pkg/config"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+	"k8s.io/klog/v2"
+)
+
+const (
+	defaultHTTPPath     = "/v1/metrics"
+	protobufContentType = "application/x-protobuf"
+)
+
+// client sends an OTLP export request to the collector
+type client interface {
+	export(ctx context.Context, request *colmetricspb.ExportMetricsServiceRequest) error
+	close() error
+}
+
+// Exporter pushes the metrics gathered from the Prometheus registry to an
+// OpenTelemetry collector over OTLP.
+type Exporter struct {
+	gatherer  prometheus.Gatherer
+	client    client
+	nodeName  string
+	timeout   time.Duration
+	startTime time.Time
+}
+
+// NewExporter creates an OTLP exporter for the metrics of the gatherer
+func NewExporter(cfg config.OTLPConfig, gatherer prometheus.Gatherer, nodeName string) (*Exporter, error) {
+	var c client
+	var err error
+	switch cfg.Protocol {
+	case config.OTLPProtocolGRPC:
+		c, err = newGRPCClient(cfg)
+	case config.OTLPProtocolHTTP:
+		c, err = newHTTPClient(cfg)
+	default:
+		err = fmt.Errorf("unsupported OTLP protocol %q", cfg.Protocol)
+	}
+	if err != nil {
+		return nil, err
+	}
+	klog.Infof("pushing metrics over OTLP %s to %s", cfg.Protocol, cfg.Endpoint)
+	return &Exporter{
+		gatherer:  gatherer,
+		client:    c,
+		nodeName:  nodeName,
+		timeout:   time.Duration(cfg.TimeoutSec) * time.Second,
+		startTime: time.Now(),
+	}, nil
+}
+
+// Push gathers the current metrics and exports them to the collector
+func (e *Exporter) Push(ctx context.Context) error {
+	families, err := e.gatherer.Gather()
+	if err != nil {
+		// the gatherer returns the metrics that could be gathered with the error
+		klog.V(3).Infof("failed to gather some metrics: %v", err)
+	}
+	request := toRequest(families, e.nodeName, e.startTime, time.Now())
+	if len(request.ResourceMetrics) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	if err := e.client.export(ctx, request); err != nil {
+		return fmt.Errorf("failed to export metrics over OTLP: %w", err)
+	}
+	return nil
+}
+
+// Shutdown closes the connection to the collector
+func (e *Exporter) Shutdown() error {
+	return e.client.close()
+}
+
+func tlsConfig(cfg config.OTLPConfig) (*tls.Config, error) {
+	c := &tls.Config{
+		InsecureSkipVerify: cfg.InsecureSkipVerify, //nolint:gosec // explicitly configured by the user
+		MinVersion:         tls.VersionTLS12,
+	}
+	if cfg.CAFile != "" {
+		ca, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read OTLP CA file: %w", err)
+		}
+		c.RootCAs = x509.NewCertPool()
+		if !c.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("failed to parse OTLP CA file %s", cfg.CAFile)
+		}
+	}
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load OTLP client certificate: %w", err)
+		}
+		c.Certificates = []tls.Certificate{cert}
+	}
+	return c, nil
+}
+
+type grpcClient struct {
+	conn    *grpc.ClientConn
+	client  colmetricspb.MetricsServiceClient
+	headers metadata.MD
+}
+
+func newGRPCClient(cfg config.OTLPConfig) (*grpcClient, error) {
+	creds := insecure.NewCredentials()
+	if !cfg.Insecure {
+		c, err := tlsConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		creds = credentials.NewTLS(c)
+	}
+	conn, err := grpc.NewClient(cfg.Endpoint, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP grpc client: %w", err)
+	}
+	return &grpcClient{
+		conn:    conn,
+		client:  colmetricspb.NewMetricsServiceClient(conn),
+		headers: metadata.New(cfg.Headers),
+	}, nil
+}
+
+func (c *grpcClient) export(ctx context.Context, request *colmetricspb.ExportMetricsServiceRequest) error {
+	ctx = metadata.NewOutgoingContext(ctx, c.headers)
+	response, err := c.client.Export(ctx, request)
+	if err != nil {
+		return err
+	}
+	logPartialSuccess(response)
+	return nil
+}
+
+func (c *grpcClient) close() error {
+	return c.conn.Close()
+}
+
+type httpClient struct {
+	url     string
+	client  *http.Client
+	headers map[string]string
+}
+
+func newHTTPClient(cfg config.OTLPConfig) (*httpClient, error) {
+	u, err := url.Parse(cfg.Endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid OTLP http endpoint %q, expected a URL such as http://collector:4318", cfg.Endpoint)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = defaultHTTPPath
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if u.Scheme == "https" {
+		if transport.TLSClientConfig, err = tlsConfig(cfg); err != nil {
+			return nil, err
+		}
+	}
+	return &httpClient{
+		url:     u.String(),
+		client:  &http.Client{Transport: transport},
+		headers: cfg.Headers,
+	}, nil
+}
+
+func (c *httpClient) export(ctx context.Context, request *colmetricspb.ExportMetricsServiceRequest) error {
+	body, err := proto.Marshal(request)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", protobufContentType)
+	for k, v := range c.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s: %s", resp.Status, respBody)
+	}
+	response := &colmetricspb.ExportMetricsServiceResponse{}
+	if err := proto.Unmarshal(respBody, response); err == nil {
+		logPartialSuccess(response)
+	}
+	return nil
+}
+
+func (c *httpClient) close() error {
+	c.client.CloseIdleConnections()
+	return nil
+}
+
+func logPartialSuccess(response *colmetricspb.ExportMetricsServiceResponse) {
+	if p := response.GetPartialSuccess(); p != nil && p.GetRejectedDataPoints() > 0 {
+		klog.Warningf("OTLP collector rejected %d data points: %s", p.GetRejectedDataPoints(), p.GetErrorMessage())
+	}
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package otlp
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sustainable-computing-io/kepler/pkg/config"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+// receiver is a local OTLP grpc receiver that records the export requests
+type receiver struct {
+	colmetricspb.UnimplementedMetricsServiceServer
+	requests chan *colmetricspb.ExportMetricsServiceRequest
+	headers  chan metadata.MD
+}
+
+func (r *receiver) Export(ctx context.Context, request *colmetricspb.ExportMetricsServiceRequest) (*colmetricspb.ExportMetricsServiceResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	r.headers <- md
+	r.requests <- request
+	return &colmetricspb.ExportMetricsServiceResponse{}, nil
+}
+
+func newTestRegistry() *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	containerJoules := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kepler_container_package_joules_total",
+		Help: "container package energy",
+	}, []string{"container_id", "pod_name", "container_name", "container_namespace", "mode"})
+	containerJoules.WithLabelValues("c1", "pod1", "app", "ns1", "dynamic").Add(10)
+	containerJoules.WithLabelValues("c2", "pod2", "app", "ns2", "dynamic").Add(20)
+	nodeJoules := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kepler_node_package_joules_total",
+		Help: "node package energy",
+	}, []string{"package", "instance", "mode"})
+	nodeJoules.WithLabelValues("0", "node1", "dynamic").Add(100)
+	nodeInfo := prometheus.NewGauge(prometheus.GaugeOpts{Name: "kepler_node_info", Help: "node info"})
+	nodeInfo.Set(1)
+	registry.MustRegister(containerJoules, nodeJoules, nodeInfo)
+	return registry
+}
+
+func attrValue(attrs []*commonpb.KeyValue, key string) string {
+	for _, attr := range attrs {
+		if attr.Key == key {
+			return attr.Value.GetStringValue()
+		}
+	}
+	return ""
+}
+
+func findResource(request *colmetricspb.ExportMetricsServiceRequest, pod string) *metricspb.ResourceMetrics {
+	for _, rm := range request.ResourceMetrics {
+		if attrValue(rm.Resource.Attributes, k8sPodNameAttr) == pod {
+			return rm
+		}
+	}
+	return nil
+}
+
+func expectRequest(request *colmetricspb.ExportMetricsServiceRequest) {
+	// one resource for the node and one per pod
+	Expect(request.ResourceMetrics).To(HaveLen(3))
+
+	node := findResource(request, "")
+	Expect(node).NotTo(BeNil())
+	Expect(attrValue(node.Resource.Attributes, k8sNodeNameAttr)).To(Equal("node1"))
+	Expect(node.ScopeMetrics[0].Metrics).To(HaveLen(2))
+
+	pod := findResource(request, "pod1")
+	Expect(pod).NotTo(BeNil())
+	Expect(attrValue(pod.Resource.Attributes, k8sNamespaceAttr)).To(Equal("ns1"))
+	Expect(attrValue(pod.Resource.Attributes, k8sNodeNameAttr)).To(Equal("node1"))
+	metric := pod.ScopeMetrics[0].Metrics[0]
+	Expect(metric.Name).To(Equal("kepler_container_package_joules_total"))
+	sum := metric.GetSum()
+	Expect(sum).NotTo(BeNil())
+	Expect(sum.IsMonotonic).To(BeTrue())
+	Expect(sum.AggregationTemporality).To(Equal(metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE))
+	Expect(sum.DataPoints).To(HaveLen(1))
+	Expect(sum.DataPoints[0].GetAsDouble()).To(Equal(float64(10)))
+	Expect(attrValue(sum.DataPoints[0].Attributes, "container_id")).To(Equal("c1"))
+	Expect(attrValue(sum.DataPoints[0].Attributes, podLabel)).To(BeEmpty())
+}
+
+var _ = Describe("Test OTLP Exporter", func() {
+	It("should push the metrics to an OTLP grpc receiver", func() {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		server := grpc.NewServer()
+		r := &receiver{
+			requests: make(chan *colmetricspb.ExportMetricsServiceRequest, 1),
+			headers:  make(chan metadata.MD, 1),
+		}
+		colmetricspb.RegisterMetricsServiceServer(server, r)
+		go func() { _ = server.Serve(listener) }()
+		defer server.Stop()
+
+		exporter, err := NewExporter(config.OTLPConfig{
+			Endpoint:   listener.Addr().String(),
+			Protocol:   config.OTLPProtocolGRPC,
+			Headers:    map[string]string{"x-tenant": "kepler"},
+			Insecure:   true,
+			TimeoutSec: 5,
+		}, newTestRegistry(), "node1")
+		Expect(err).NotTo(HaveOccurred())
+		defer exporter.Shutdown()
+
+		Expect(exporter.Push(context.Background())).To(Succeed())
+		Expect((<-r.headers).Get("x-tenant")).To(Equal([]string{"kepler"}))
+		expectRequest(<-r.requests)
+	})
+
+	It("should push the metrics to an OTLP http receiver", func() {
+		requests := make(chan *colmetricspb.ExportMetricsServiceRequest, 1)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			defer GinkgoRecover()
+			Expect(req.URL.Path).To(Equal(defaultHTTPPath))
+			Expect(req.Header.Get("Content-Type")).To(Equal(protobufContentType))
+			Expect(req.Header.Get("Authorization")).To(Equal("Bearer token"))
+			body, err := io.ReadAll(req.Body)
+			Expect(err).NotTo(HaveOccurred())
+			request := &colmetricspb.ExportMetricsServiceRequest{}
+			Expect(proto.Unmarshal(body, request)).To(Succeed())
+			requests <- request
+			w.Header().Set("Content-Type", protobufContentType)
+		}))
+		defer server.Close()
+
+		exporter, err := NewExporter(config.OTLPConfig{
+			Endpoint:   server.URL,
+			Protocol:   config.OTLPProtocolHTTP,
+			Headers:    map[string]string{"Authorization": "Bearer token"},
+			TimeoutSec: 5,
+		}, newTestRegistry(), "node1")
+		Expect(err).NotTo(HaveOccurred())
+		defer exporter.Shutdown()
+
+		Expect(exporter.Push(context.Background())).To(Succeed())
+		expectRequest(<-requests)
+	})
+
+	It("should return an error if the receiver rejects the request", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+
+		exporter, err := NewExporter(config.OTLPConfig{
+			Endpoint:   server.URL,
+			Protocol:   config.OTLPProtocolHTTP,
+			TimeoutSec: 5,
+		}, newTestRegistry(), "node1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exporter.Push(context.Background())).NotTo(Succeed())
+	})
+})
@@ -17,10 +17,7 @@ limitations under the License.
 package process
 
 import (
-	"sync"
-
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/sustainable-computing-io/kepler/pkg/bpf"
 	"github.com/sustainable-computing-io/kepler/pkg/collector/stats"
 	"github.com/sustainable-computing-io/kepler/pkg/config"
 	"github.com/sustainable-computing-io/kepler/pkg/metrics/consts"
@@ -32,27 +29,20 @@ const (
 	context = "process"
 )
 
-// collector implements prometheus.Collector. It collects metrics directly from process maps.
+// collector implements prometheus.Collector. It collects metrics from the published snapshot of the process stats.
 type collector struct {
 	descriptions map[string]*prometheus.Desc
 	collectors   map[string]metricfactory.PromMetric
 
-	// ProcessStats holds all processes energy and resource usage metrics
-	ProcessStats map[uint64]*stats.ProcessStats
-
-	// Lock to synchronize the collector update with prometheus exporter
-	Mx *sync.Mutex
-
-	bpfSupportedMetrics bpf.SupportedMetrics
+	// snapshot returns the stats published after the last update, which are read without lock
+	snapshot func() *stats.Snapshot
 }
 
-func NewProcessCollector(processMetrics map[uint64]*stats.ProcessStats, mx *sync.Mutex, bpfSupportedMetrics bpf.SupportedMetrics) prometheus.Collector {
+func NewProcessCollector(snapshot func() *stats.Snapshot) prometheus.Collector {
 	c := &collector{
-		ProcessStats:        processMetrics,
-		descriptions:        make(map[string]*prometheus.Desc),
-		collectors:          make(map[string]metricfactory.PromMetric),
-		Mx:                  mx,
-		bpfSupportedMetrics: bpfSupportedMetrics,
+		snapshot:     snapshot,
+		descriptions: make(map[string]*prometheus.Desc),
+		collectors:   make(map[string]metricfactory.PromMetric),
 	}
 	c.initMetrics()
 	return c
@@ -63,11 +53,7 @@ func (c *collector) initMetrics() {
 	if !config.IsExposeProcessStatsEnabled() {
 		return
 	}
-	for name, desc := range metricfactory.HCMetricsPromDesc(context, c.bpfSupportedMetrics) {
-		c.descriptions[name] = desc
-		c.collectors[name] = metricfactory.NewPromCounter(desc)
-	}
-	for name, desc := range metricfactory.SCMetricsPromDesc(context, c.bpfSupportedMetrics) {
+	for name, desc := range metricfactory.ResUtilizationMetricsPromDesc(context) {
 		c.descriptions[name] = desc
 		c.collectors[name] = metricfactory.NewPromCounter(desc)
 	}
@@ -75,10 +61,6 @@ func (c *collector) initMetrics() {
 		c.descriptions[name] = desc
 		c.collectors[name] = metricfactory.NewPromCounter(desc)
 	}
-	for name, desc := range metricfactory.GPUUsageMetricsPromDesc(context) {
-		c.descriptions[name] = desc
-		c.collectors[name] = metricfactory.NewPromCounter(desc)
-	}
 	desc := metricfactory.MetricsPromDesc(context, "joules", "_total", "", consts.ProcessEnergyLabels)
 	c.descriptions["total"] = desc
 	c.collectors["total"] = metricfactory.NewPromCounter(desc)
@@ -91,11 +73,19 @@ func (c *collector) Describe(ch chan<- *prometheus.Desc) {
 }
 
 func (c *collector) Collect(ch chan<- prometheus.Metric) {
-	c.Mx.Lock()
-	for _, process := range c.ProcessStats {
-		utils.CollectEnergyMetrics(ch, process, c.collectors)
-		utils.CollectResUtilizationMetrics(ch, process, c.collectors, c.bpfSupportedMetrics)
-		utils.CollectTotalEnergyMetrics(ch, process, c.collectors)
+	snapshot := c.snapshot()
+	defer snapshot.Release()
+	for _, process := range snapshot.ProcessStats {
+		c.collect(ch, process)
 	}
-	c.Mx.Unlock()
+	// the buckets keep the values of the removed processes, so that the sum of the process counters does not decrease
+	for _, bucket := range snapshot.TerminatedProcessStats {
+		c.collect(ch, bucket)
+	}
+}
+
+func (c *collector) collect(ch chan<- prometheus.Metric, process *stats.ProcessStats) {
+	utils.CollectEnergyMetrics(ch, process, c.collectors)
+	utils.CollectResUtilizationMetrics(ch, process, c.collectors)
+	utils.CollectTotalEnergyMetrics(ch, process, c.collectors)
 }
@@ -20,7 +20,6 @@ import (
 	"sync"
 
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/sustainable-computing-io/kepler/pkg/bpf"
 	"github.com/sustainable-computing-io/kepler/pkg/collector/stats"
 	"github.com/sustainable-computing-io/kepler/pkg/config"
 	"github.com/sustainable-computing-io/kepler/pkg/metrics/container"
@@ -42,37 +41,38 @@ type PrometheusExporter struct {
 	VMStatsCollector        prometheus.Collector
 	NodeStatsCollector      prometheus.Collector
 
-	// Lock to synchronize the collector update with prometheus exporter
+	// Lock to synchronize the collector update with the kubernetes watcher, the API and the readiness checks.
+	// The prometheus collectors read the published snapshot of the stats and do not take it.
 	Mx sync.Mutex
 
-	bpfSupportedMetrics bpf.SupportedMetrics
+	// registered holds the collectors registered by RegisterMetrics
+	registered []prometheus.Collector
 }
 
-// NewPrometheusExporter creates a new prometheus exporter
-func NewPrometheusExporter(bpfSupportedMetrics bpf.SupportedMetrics) *PrometheusExporter {
-	return &PrometheusExporter{
-		bpfSupportedMetrics: bpfSupportedMetrics,
-	}
+// NewPrometheusExporter creates a new prometheus exporter, the resource utilization metrics
+// are the metrics of the resource sources registered when the collectors are created
+func NewPrometheusExporter() *PrometheusExporter {
+	return &PrometheusExporter{}
 }
 
-// NewProcessCollector creates a new prometheus collector for process metrics
-func (e *PrometheusExporter) NewProcessCollector(processMetrics map[uint64]*stats.ProcessStats) {
-	e.ProcessStatsCollector = process.NewProcessCollector(processMetrics, &e.Mx, e.bpfSupportedMetrics)
+// NewProcessCollector creates a new prometheus collector for process metrics, which reads the published snapshot
+func (e *PrometheusExporter) NewProcessCollector(snapshot func() *stats.Snapshot) {
+	e.ProcessStatsCollector = process.NewProcessCollector(snapshot)
 }
 
-// NewContainerCollector creates a new prometheus collector for container metrics
-func (e *PrometheusExporter) NewContainerCollector(containerMetrics map[string]*stats.ContainerStats) {
-	e.ContainerStatsCollector = container.NewContainerCollector(containerMetrics, &e.Mx, e.bpfSupportedMetrics)
+// NewContainerCollector creates a new prometheus collector for container metrics, which reads the published snapshot
+func (e *PrometheusExporter) NewContainerCollector(snapshot func() *stats.Snapshot) {
+	e.ContainerStatsCollector = container.NewContainerCollector(snapshot)
 }
 
-// NewVMCollector creates a new prometheus collector for vm metrics
-func (e *PrometheusExporter) NewVMCollector(vmMetrics map[string]*stats.VMStats) {
-	e.VMStatsCollector = virtualmachine.NewVMCollector(vmMetrics, &e.Mx, e.bpfSupportedMetrics)
+// NewVMCollector creates a new prometheus collector for vm metrics, which reads the published snapshot
+func (e *PrometheusExporter) NewVMCollector(snapshot func() *stats.Snapshot) {
+	e.VMStatsCollector = virtualmachine.NewVMCollector(snapshot)
 }
 
-// NewNodeCollector creates a new prometheus collector for node metrics
-func (e *PrometheusExporter) NewNodeCollector(nodeMetrics *stats.NodeStats) {
-	e.NodeStatsCollector = node.NewNodeCollector(nodeMetrics, &e.Mx)
+// NewNodeCollector creates a new prometheus collector for node metrics, which reads the published snapshot
+func (e *PrometheusExporter) NewNodeCollector(snapshot func() *stats.Snapshot) {
+	e.NodeStatsCollector = node.NewNodeCollector(snapshot)
 }
 
 func GetRegistry() *prometheus.Registry {
@@ -86,21 +86,21 @@ func (e *PrometheusExporter) RegisterMetrics() *prometheus.Registry {
 	r := GetRegistry()
 
 	if config.IsExposeProcessStatsEnabled() {
-		r.MustRegister(e.ProcessStatsCollector)
+		e.register(r, e.ProcessStatsCollector)
 		klog.Infoln("Registered Process Prometheus metrics")
 	}
 
 	if config.IsExposeContainerStatsEnabled() {
-		r.MustRegister(e.ContainerStatsCollector)
+		e.register(r, e.ContainerStatsCollector)
 		klog.Infoln("Registered Container Prometheus metrics")
 	}
 
 	if config.IsExposeVMStatsEnabled() {
-		r.MustRegister(e.VMStatsCollector)
+		e.register(r, e.VMStatsCollector)
 		klog.Infoln("Registered VM Prometheus metrics")
 	}
 
-	r.MustRegister(e.NodeStatsCollector)
+	e.register(r, e.NodeStatsCollector)
 	klog.Infoln("Registered Node Prometheus metrics")
 
 	// log prometheus errors
@@ -111,3 +111,18 @@ func (e *PrometheusExporter) RegisterMetrics() *prometheus.Registry {
 
 	return r
 }
+
+func (e *PrometheusExporter) register(r *prometheus.Registry, c prometheus.Collector) {
+	r.MustRegister(c)
+	e.registered = append(e.registered, c)
+}
+
+// UnregisterMetrics removes the collectors registered by RegisterMetrics, so
+// that they can be recreated with new descriptors after a config reload.
+func (e *PrometheusExporter) UnregisterMetrics() {
+	r := GetRegistry()
+	for _, c := range e.registered {
+		r.Unregister(c)
+	}
+	e.registered = nil
+}
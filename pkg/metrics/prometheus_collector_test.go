@@ -27,6 +27,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -45,6 +46,7 @@ const (
 	nodeEnergyMetric             = "kepler_node_platform_joules_total"
 	nodePackageEnergyMetric      = "kepler_node_package_joules_total"
 	containerCPUCoreEnergyMetric = "kepler_container_package_joules_total"
+	nodeMultiplexingMetric       = "kepler_node_hw_counter_multiplexing_ratio"
 
 	SampleCurr = 100
 	SampleAggr = 1000
@@ -79,18 +81,20 @@ var _ = Describe("Test Prometheus Collector Unit", func() {
 		// aggregate processes' resource utilization metrics to containers, virtual machines and nodes
 		metricCollector.AggregateProcessResourceUtilizationMetrics()
 
-		// the collector and prometheusExporter share structures and collections
-		bpfSupportedMetrics := bpfExporter.SupportedMetrics()
-		exporter := NewPrometheusExporter(bpfSupportedMetrics)
-		exporter.NewProcessCollector(metricCollector.ProcessStats)
-		exporter.NewContainerCollector(metricCollector.ContainerStats)
-		exporter.NewVMCollector(metricCollector.VMStats)
-		exporter.NewNodeCollector(&metricCollector.NodeStats)
+		// the prometheusExporter reads the snapshot published by the collector
+		exporter := NewPrometheusExporter()
+		exporter.NewProcessCollector(metricCollector.Snapshot)
+		exporter.NewContainerCollector(metricCollector.Snapshot)
+		exporter.NewVMCollector(metricCollector.Snapshot)
+		exporter.NewNodeCollector(metricCollector.Snapshot)
 
 		nodeStats.UpdateDynEnergy()
 
 		model.CreatePowerEstimatorModels(stats.GetProcessFeatureNames())
-		model.UpdateProcessEnergy(processStats, &nodeStats)
+		model.UpdateProcessEnergy(processStats, &nodeStats, time.Duration(config.SamplePeriodSec())*time.Second)
+		metricCollector.AggregateProcessEnergyUtilizationMetrics()
+		metricCollector.NodeStats.MultiplexingRatio[config.CPUCycle] = 0.5
+		metricCollector.PublishSnapshot()
 
 		// get metrics from prometheus
 		err := prometheus.Register(exporter.ProcessStatsCollector)
@@ -126,5 +130,10 @@ var _ = Describe("Test Prometheus Collector Unit", func() {
 		Expect(err).NotTo(HaveOccurred())
 		// The pkg dynamic energy is 30J, the container cpu usage is 50%, so the dynamic energy is 15J
 		Expect(val).To(Equal(float64(15))) // J
+
+		// the cycles were counted half of the time
+		val, err = convertPromToValue(body, nodeMultiplexingMetric)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(val).To(Equal(0.5))
 	})
 })
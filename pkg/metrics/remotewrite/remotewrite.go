@@ -0,0 +1,391 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package remotewrite
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/sustainable-computing-io/kepler/pkg/config"
+	"github.com/sustainable-computing-io/kepler/pkg/metrics/consts"
+	"k8s.io/klog/v2"
+)
+
+const (
+	subsystem = "remote_write"
+
+	// labels added to every series, unless the series already has them, so that
+	// the series pushed by different nodes do not collide
+	jobLabel      = "job"
+	jobName       = "kepler"
+	instanceLabel = "instance"
+
+	maxErrorBodyBytes = 512
+	// flushPollInterval is how often Flush checks whether the WAL is sent
+	flushPollInterval = 100 * time.Millisecond
+)
+
+// recoverableError is a failure that is retried, e.g. the endpoint is unreachable or overloaded
+type recoverableError struct {
+	error
+}
+
+// Writer pushes the metrics gathered from the Prometheus registry to a remote-write
+// endpoint. Every push is first stored in a bounded on-disk WAL and a background
+// sender replays the WAL in order, retrying with an exponential backoff.
+type Writer struct {
+	gatherer   prometheus.Gatherer
+	wal        *wal
+	client     *http.Client
+	url        string
+	headers    map[string]string
+	nodeName   string
+	minBackoff time.Duration
+	maxBackoff time.Duration
+
+	notify chan struct{}
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	queueRequests   prometheus.GaugeFunc
+	queueBytes      prometheus.GaugeFunc
+	backoffSeconds  prometheus.Gauge
+	sentRequests    prometheus.Counter
+	retries         prometheus.Counter
+	failedRequests  prometheus.Counter
+	droppedRequests prometheus.Counter
+}
+
+// NewWriter creates a remote-write client for the metrics of the gatherer and
+// starts replaying the requests left in the WAL by a previous run.
+func NewWriter(cfg config.RemoteWriteConfig, gatherer prometheus.Gatherer, nodeName string) (*Writer, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid remote-write URL %q", cfg.URL)
+	}
+	w, err := openWAL(cfg.WALDir, int64(cfg.WALMaxSizeMB)*1024*1024)
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{
+		InsecureSkipVerify: cfg.InsecureSkipVerify, //nolint:gosec // explicitly configured by the user
+		MinVersion:         tls.VersionTLS12,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	rw := &Writer{
+		gatherer: gatherer,
+		wal:      w,
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   time.Duration(cfg.TimeoutSec) * time.Second,
+		},
+		url:        cfg.URL,
+		headers:    cfg.Headers,
+		nodeName:   nodeName,
+		minBackoff: time.Duration(cfg.MinBackoffMs) * time.Millisecond,
+		maxBackoff: time.Duration(cfg.MaxBackoffMs) * time.Millisecond,
+		notify:     make(chan struct{}, 1),
+		cancel:     cancel,
+		done:       make(chan struct{}),
+	}
+	rw.initMetrics()
+	klog.Infof("pushing metrics with remote-write to %s", u.Redacted())
+
+	// replay what is left in the WAL
+	rw.notify <- struct{}{}
+	go rw.run(ctx)
+	return rw, nil
+}
+
+func (rw *Writer) initMetrics() {
+	rw.queueRequests = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: consts.MetricsNamespace,
+		Subsystem: subsystem,
+		Name:      "queue_requests",
+		Help:      "Number of remote-write requests waiting in the WAL to be sent",
+	}, func() float64 {
+		requests, _ := rw.wal.depth()
+		return float64(requests)
+	})
+	rw.queueBytes = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: consts.MetricsNamespace,
+		Subsystem: subsystem,
+		Name:      "queue_bytes",
+		Help:      "Size in bytes of the remote-write requests waiting in the WAL to be sent",
+	}, func() float64 {
+		_, size := rw.wal.depth()
+		return float64(size)
+	})
+	rw.backoffSeconds = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: consts.MetricsNamespace,
+		Subsystem: subsystem,
+		Name:      "backoff_seconds",
+		Help:      "Current delay before retrying a failed remote-write request, 0 when the endpoint is healthy",
+	})
+	rw.sentRequests = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: consts.MetricsNamespace,
+		Subsystem: subsystem,
+		Name:      "sent_requests_total",
+		Help:      "Number of remote-write requests successfully sent",
+	})
+	rw.retries = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: consts.MetricsNamespace,
+		Subsystem: subsystem,
+		Name:      "retries_total",
+		Help:      "Number of remote-write requests that failed with a recoverable error and are retried",
+	})
+	rw.failedRequests = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: consts.MetricsNamespace,
+		Subsystem: subsystem,
+		Name:      "failed_requests_total",
+		Help:      "Number of remote-write requests rejected by the endpoint and discarded",
+	})
+	rw.droppedRequests = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: consts.MetricsNamespace,
+		Subsystem: subsystem,
+		Name:      "dropped_requests_total",
+		Help:      "Number of remote-write requests dropped from the WAL because it was full",
+	})
+}
+
+func (rw *Writer) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		rw.queueRequests, rw.queueBytes, rw.backoffSeconds,
+		rw.sentRequests, rw.retries, rw.failedRequests, rw.droppedRequests,
+	}
+}
+
+// Describe implements the prometheus.Collector interface for the remote-write self-metrics
+func (rw *Writer) Describe(ch chan<- *prometheus.Desc) {
+	for _, c := range rw.collectors() {
+		c.Describe(ch)
+	}
+}
+
+// Collect implements the prometheus.Collector interface for the remote-write self-metrics
+func (rw *Writer) Collect(ch chan<- prometheus.Metric) {
+	for _, c := range rw.collectors() {
+		c.Collect(ch)
+	}
+}
+
+// Push gathers the current metrics and queues them in the WAL to be sent
+func (rw *Writer) Push(ctx context.Context) error {
+	families, err := rw.gatherer.Gather()
+	if err != nil {
+		// the gatherer returns the metrics that could be gathered with the error
+		klog.V(3).Infof("failed to gather some metrics: %v", err)
+	}
+	request := toWriteRequest(families, rw.nodeName, time.Now())
+	if len(request.Timeseries) == 0 {
+		return nil
+	}
+	data, err := request.Marshal()
+	if err != nil {
+		return fmt.Errorf("failed to marshal remote-write request: %w", err)
+	}
+	dropped, err := rw.wal.append(snappy.Encode(nil, data))
+	if err != nil {
+		return err
+	}
+	if dropped > 0 {
+		rw.droppedRequests.Add(float64(dropped))
+		klog.Warningf("remote-write WAL is full, dropped the %d oldest requests", dropped)
+	}
+	select {
+	case rw.notify <- struct{}{}:
+	default:
+		// a drain of the WAL is already pending
+	}
+	return nil
+}
+
+// Flush waits until the WAL is sent. The requests left when ctx is done stay in the WAL for the next run.
+func (rw *Writer) Flush(ctx context.Context) error {
+	ticker := time.NewTicker(flushPollInterval)
+	defer ticker.Stop()
+	for {
+		requests, _ := rw.wal.depth()
+		if requests == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%d requests are left in the WAL: %w", requests, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// Shutdown stops the sender, the requests not sent yet stay in the WAL for the next run
+func (rw *Writer) Shutdown() error {
+	rw.cancel()
+	<-rw.done
+	rw.client.CloseIdleConnections()
+	return nil
+}
+
+// run sends the WAL requests in order, until ctx is canceled
+func (rw *Writer) run(ctx context.Context) {
+	defer close(rw.done)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-rw.notify:
+		}
+		if !rw.drain(ctx) {
+			return
+		}
+	}
+}
+
+// drain sends the WAL requests until it is empty. It returns false if ctx was canceled.
+func (rw *Writer) drain(ctx context.Context) bool {
+	backoff := rw.minBackoff
+	for {
+		seq, data, ok, err := rw.wal.oldest()
+		if !ok {
+			return true
+		}
+		if err != nil {
+			klog.Warningf("failed to read remote-write WAL, discarding the request: %v", err)
+			rw.wal.remove(seq)
+			rw.failedRequests.Inc()
+			continue
+		}
+		err = rw.send(ctx, data)
+		if ctx.Err() != nil {
+			return false
+		}
+		var recoverable recoverableError
+		switch {
+		case err == nil:
+			rw.wal.remove(seq)
+			rw.sentRequests.Inc()
+			rw.backoffSeconds.Set(0)
+			backoff = rw.minBackoff
+			continue
+		case !errors.As(err, &recoverable):
+			klog.Warningf("remote-write request rejected, discarding it: %v", err)
+			rw.wal.remove(seq)
+			rw.failedRequests.Inc()
+			continue
+		}
+		klog.V(3).Infof("remote-write request failed, retrying in %s: %v", backoff, err)
+		rw.retries.Inc()
+		rw.backoffSeconds.Set(backoff.Seconds())
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > rw.maxBackoff {
+			backoff = rw.maxBackoff
+		}
+	}
+}
+
+func (rw *Writer) send(ctx context.Context, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rw.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	req.Header.Set("User-Agent", "kepler")
+	for k, v := range rw.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := rw.client.Do(req)
+	if err != nil {
+		return recoverableError{err}
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyBytes))
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	err = fmt.Errorf("unexpected status %s: %s", resp.Status, bytes.TrimSpace(body))
+	if resp.StatusCode/100 == 5 || resp.StatusCode == http.StatusTooManyRequests {
+		return recoverableError{err}
+	}
+	return err
+}
+
+// toWriteRequest converts the gathered Prometheus metric families into a remote-write request
+func toWriteRequest(families []*dto.MetricFamily, nodeName string, now time.Time) *prompb.WriteRequest {
+	timestamp := now.UnixMilli()
+	request := &prompb.WriteRequest{}
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			var value float64
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				value = m.GetCounter().GetValue()
+			case dto.MetricType_GAUGE:
+				value = m.GetGauge().GetValue()
+			case dto.MetricType_UNTYPED:
+				value = m.GetUntyped().GetValue()
+			default:
+				// kepler does not expose histograms or summaries
+				klog.V(5).Infof("skipping remote-write of %s with unsupported type %s", family.GetName(), family.GetType())
+				continue
+			}
+			request.Timeseries = append(request.Timeseries, prompb.TimeSeries{
+				Labels:  toLabels(family.GetName(), m.GetLabel(), nodeName),
+				Samples: []prompb.Sample{{Value: value, Timestamp: timestamp}},
+			})
+		}
+	}
+	return request
+}
+
+// toLabels returns the labels of a series sorted by name, as required by the remote-write protocol
+func toLabels(name string, pairs []*dto.LabelPair, nodeName string) []prompb.Label {
+	labels := make([]prompb.Label, 0, len(pairs)+3)
+	labels = append(labels, prompb.Label{Name: "__name__", Value: name})
+	hasJob, hasInstance := false, false
+	for _, pair := range pairs {
+		labels = append(labels, prompb.Label{Name: pair.GetName(), Value: pair.GetValue()})
+		hasJob = hasJob || pair.GetName() == jobLabel
+		hasInstance = hasInstance || pair.GetName() == instanceLabel
+	}
+	if !hasJob {
+		labels = append(labels, prompb.Label{Name: jobLabel, Value: jobName})
+	}
+	if !hasInstance {
+		labels = append(labels, prompb.Label{Name: instanceLabel, Value: nodeName})
+	}
+	sort.Slice(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+	return labels
+}
@@ -0,0 +1,220 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package remotewrite
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/sustainable-computing-io/kepler/pkg/config"
+)
+
+// receiver is a remote-write endpoint that records the value of the test counter
+// in every request it accepts, and fails while it is down
+type receiver struct {
+	mx       sync.Mutex
+	down     bool
+	status   int
+	values   []float64
+	requests []*http.Request
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.requests = append(r.requests, req)
+	if r.down {
+		w.WriteHeader(r.status)
+		return
+	}
+	body, err := io.ReadAll(req.Body)
+	Expect(err).NotTo(HaveOccurred())
+	data, err := snappy.Decode(nil, body)
+	Expect(err).NotTo(HaveOccurred())
+	request := &prompb.WriteRequest{}
+	Expect(request.Unmarshal(data)).To(Succeed())
+	for _, ts := range request.Timeseries {
+		if ts.Labels[0].Value == "test_joules_total" {
+			r.values = append(r.values, ts.Samples[0].Value)
+		}
+	}
+}
+
+func (r *receiver) setDown(down bool, status int) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.down = down
+	r.status = status
+}
+
+func (r *receiver) received() []float64 {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	return append([]float64{}, r.values...)
+}
+
+func (r *receiver) lastRequest() *http.Request {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	return r.requests[len(r.requests)-1]
+}
+
+var _ = Describe("Test Remote Write", func() {
+	var (
+		registry *prometheus.Registry
+		counter  prometheus.Counter
+		recv     *receiver
+		server   *httptest.Server
+		cfg      config.RemoteWriteConfig
+	)
+
+	BeforeEach(func() {
+		registry = prometheus.NewRegistry()
+		counter = prometheus.NewCounter(prometheus.CounterOpts{Name: "test_joules_total", Help: "test"})
+		registry.MustRegister(counter)
+		recv = &receiver{}
+		server = httptest.NewServer(recv)
+		DeferCleanup(server.Close)
+		cfg = config.RemoteWriteConfig{
+			URL:          server.URL + "/api/v1/write",
+			Headers:      map[string]string{"Authorization": "Bearer token"},
+			WALDir:       GinkgoT().TempDir(),
+			WALMaxSizeMB: 1,
+			TimeoutSec:   5,
+			MinBackoffMs: 1,
+			MaxBackoffMs: 10,
+		}
+	})
+
+	newWriter := func() *Writer {
+		w, err := NewWriter(cfg, registry, "node-1")
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(w.Shutdown)
+		return w
+	}
+
+	push := func(w *Writer, value float64) {
+		counter.Add(value - testutil.ToFloat64(counter))
+		Expect(w.Push(context.Background())).To(Succeed())
+	}
+
+	It("Should send the metrics with the remote-write protocol", func() {
+		w := newWriter()
+		push(w, 1)
+		Eventually(recv.received).Should(Equal([]float64{1}))
+
+		req := recv.lastRequest()
+		Expect(req.URL.Path).To(Equal("/api/v1/write"))
+		Expect(req.Header.Get("Content-Encoding")).To(Equal("snappy"))
+		Expect(req.Header.Get("Content-Type")).To(Equal("application/x-protobuf"))
+		Expect(req.Header.Get("X-Prometheus-Remote-Write-Version")).To(Equal("0.1.0"))
+		Expect(req.Header.Get("Authorization")).To(Equal("Bearer token"))
+		Eventually(func() float64 { return testutil.ToFloat64(w.sentRequests) }).Should(Equal(1.0))
+	})
+
+	It("Should replay the queued samples in order after an outage", func() {
+		recv.setDown(true, http.StatusServiceUnavailable)
+		w := newWriter()
+		for i := 1; i <= 5; i++ {
+			push(w, float64(i))
+		}
+		Eventually(func() float64 { return testutil.ToFloat64(w.retries) }).Should(BeNumerically(">", 0))
+		Expect(recv.received()).To(BeEmpty())
+		Expect(testutil.ToFloat64(w.queueRequests)).To(Equal(5.0))
+
+		recv.setDown(false, 0)
+		Eventually(recv.received).Should(Equal([]float64{1, 2, 3, 4, 5}))
+		Eventually(func() float64 { return testutil.ToFloat64(w.queueRequests) }).Should(Equal(0.0))
+		Expect(testutil.ToFloat64(w.backoffSeconds)).To(Equal(0.0))
+	})
+
+	It("Should discard the requests rejected by the endpoint", func() {
+		recv.setDown(true, http.StatusBadRequest)
+		w := newWriter()
+		push(w, 1)
+		Eventually(func() float64 { return testutil.ToFloat64(w.failedRequests) }).Should(Equal(1.0))
+		Expect(testutil.ToFloat64(w.retries)).To(Equal(0.0))
+		Expect(testutil.ToFloat64(w.queueRequests)).To(Equal(0.0))
+	})
+
+	It("Should flush the WAL until it is sent or the deadline expires", func() {
+		recv.setDown(true, http.StatusServiceUnavailable)
+		w := newWriter()
+		push(w, 1)
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		Expect(w.Flush(ctx)).To(MatchError(context.DeadlineExceeded))
+
+		recv.setDown(false, 0)
+		Expect(w.Flush(context.Background())).To(Succeed())
+		Expect(recv.received()).To(Equal([]float64{1}))
+	})
+
+	It("Should replay the WAL left by a previous run", func() {
+		cfg.URL = "http://127.0.0.1:1/api/v1/write"
+		w, err := NewWriter(cfg, registry, "node-1")
+		Expect(err).NotTo(HaveOccurred())
+		push(w, 1)
+		push(w, 2)
+		Expect(w.Shutdown()).To(Succeed())
+
+		cfg.URL = server.URL
+		w = newWriter()
+		push(w, 3)
+		Eventually(recv.received).Should(Equal([]float64{1, 2, 3}))
+	})
+})
+
+var _ = Describe("Test Remote Write WAL", func() {
+	It("Should drop the oldest requests when full", func() {
+		dir := GinkgoT().TempDir()
+		w, err := openWAL(dir, 10)
+		Expect(err).NotTo(HaveOccurred())
+		for _, data := range []string{"aaaa", "bbbb", "cccc"} {
+			_, err := w.append([]byte(data))
+			Expect(err).NotTo(HaveOccurred())
+		}
+		requests, size := w.depth()
+		Expect(requests).To(Equal(2))
+		Expect(size).To(Equal(int64(8)))
+
+		// reopening keeps the order and the sequence numbers
+		w, err = openWAL(dir, 10)
+		Expect(err).NotTo(HaveOccurred())
+		dropped, err := w.append([]byte("dddd"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(dropped).To(Equal(1))
+		seq, data, ok, err := w.oldest()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeTrue())
+		Expect(string(data)).To(Equal("cccc"))
+		w.remove(seq)
+		_, data, _, _ = w.oldest()
+		Expect(string(data)).To(Equal("dddd"))
+	})
+})
@@ -0,0 +1,169 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package remotewrite
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	walSuffix = ".wal"
+	tmpSuffix = ".tmp"
+)
+
+// wal is a bounded on-disk queue of the remote-write requests that were not sent yet.
+// Each request is stored in its own file named after its sequence number, so that
+// the requests are replayed in order after a connectivity gap or a restart.
+// When the queue exceeds maxBytes, the oldest requests are dropped.
+type wal struct {
+	mx       sync.Mutex
+	dir      string
+	maxBytes int64
+	seqs     []uint64
+	sizes    map[uint64]int64
+	bytes    int64
+	nextSeq  uint64
+}
+
+// openWAL loads the requests left in dir by a previous run.
+func openWAL(dir string, maxBytes int64) (*wal, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create remote-write WAL dir: %w", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read remote-write WAL dir: %w", err)
+	}
+	w := &wal{
+		dir:      dir,
+		maxBytes: maxBytes,
+		sizes:    map[uint64]int64{},
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasSuffix(name, tmpSuffix) {
+			// a write that was interrupted before being committed
+			_ = os.Remove(filepath.Join(dir, name))
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, walSuffix), 10, 64)
+		if err != nil || !strings.HasSuffix(name, walSuffix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		w.seqs = append(w.seqs, seq)
+		w.sizes[seq] = info.Size()
+		w.bytes += info.Size()
+		if seq >= w.nextSeq {
+			w.nextSeq = seq + 1
+		}
+	}
+	sort.Slice(w.seqs, func(i, j int) bool { return w.seqs[i] < w.seqs[j] })
+	if len(w.seqs) > 0 {
+		klog.Infof("remote-write WAL has %d pending requests (%d bytes) to replay", len(w.seqs), w.bytes)
+	}
+	return w, nil
+}
+
+func (w *wal) path(seq uint64) string {
+	return filepath.Join(w.dir, fmt.Sprintf("%020d%s", seq, walSuffix))
+}
+
+// append stores a request at the end of the queue and returns the number of
+// old requests dropped to keep the queue within its size limit.
+func (w *wal) append(data []byte) (int, error) {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+	seq := w.nextSeq
+	path := w.path(seq)
+	tmp := path + tmpSuffix
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		_ = os.Remove(tmp)
+		return 0, fmt.Errorf("failed to write remote-write WAL: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return 0, fmt.Errorf("failed to commit remote-write WAL: %w", err)
+	}
+	w.nextSeq++
+	w.seqs = append(w.seqs, seq)
+	w.sizes[seq] = int64(len(data))
+	w.bytes += int64(len(data))
+
+	dropped := 0
+	// always keep the newest request, even if it alone exceeds the limit
+	for w.bytes > w.maxBytes && len(w.seqs) > 1 {
+		w.removeLocked(w.seqs[0])
+		dropped++
+	}
+	return dropped, nil
+}
+
+// oldest returns the sequence number and content of the first request in the queue.
+func (w *wal) oldest() (seq uint64, data []byte, ok bool, err error) {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+	if len(w.seqs) == 0 {
+		return 0, nil, false, nil
+	}
+	seq = w.seqs[0]
+	data, err = os.ReadFile(w.path(seq))
+	return seq, data, true, err
+}
+
+// remove deletes a request from the queue, it is a no-op if the request was already dropped.
+func (w *wal) remove(seq uint64) {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+	w.removeLocked(seq)
+}
+
+func (w *wal) removeLocked(seq uint64) {
+	size, exists := w.sizes[seq]
+	if !exists {
+		return
+	}
+	if err := os.Remove(w.path(seq)); err != nil && !os.IsNotExist(err) {
+		klog.V(3).Infof("failed to remove remote-write WAL file: %v", err)
+	}
+	delete(w.sizes, seq)
+	w.bytes -= size
+	for i, s := range w.seqs {
+		if s == seq {
+			w.seqs = append(w.seqs[:i], w.seqs[i+1:]...)
+			break
+		}
+	}
+}
+
+// depth returns the number of pending requests and their size in bytes.
+func (w *wal) depth() (requests int, bytes int64) {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+	return len(w.seqs), w.bytes
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package telemetry holds the self-metrics of the collector, which tell how long the collection cycles take
+// and how often the power meters, the power models and the counters fail.
+package telemetry
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sustainable-computing-io/kepler/pkg/metrics/consts"
+)
+
+const subsystem = "collector"
+
+// phases of a collection cycle, the resource sources are observed with their own name
+const (
+	PhaseResourceAggregation = "resource_aggregation"
+	PhaseNodeEnergy          = "node_energy"
+	PhaseProcessEnergy       = "process_energy"
+	PhaseReconcile           = "reconcile"
+	PhaseEnergyAggregation   = "energy_aggregation"
+	PhaseTotal               = "total"
+)
+
+// reasons of the counter resets
+const (
+	ResetOverflow = "overflow"
+	ResetDecrease = "decrease"
+)
+
+var (
+	updatePhaseDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: consts.MetricsNamespace,
+		Subsystem: subsystem,
+		Name:      "update_phase_duration_seconds",
+		Help:      "Duration of the phases of the collection cycle, a resource source phase is named after the source",
+		Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 14),
+	}, []string{"phase"})
+	trackedEntries = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: consts.MetricsNamespace,
+		Subsystem: subsystem,
+		Name:      "tracked_entries",
+		Help:      "Number of processes, containers and VMs tracked after the last collection cycle",
+	}, []string{"kind"})
+	idleProcessesEvicted = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: consts.MetricsNamespace,
+		Subsystem: subsystem,
+		Name:      "idle_processes_evicted_total",
+		Help:      "Number of idle processes removed because they do not exist anymore",
+	})
+	processExitsDropped = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: consts.MetricsNamespace,
+		Subsystem: subsystem,
+		Name:      "process_exit_events_dropped_total",
+		Help:      "Number of process exit events dropped because the BPF ring buffer was full, the processes are removed when they are found idle",
+	})
+	bpfMapEntriesRead = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: consts.MetricsNamespace,
+		Subsystem: subsystem,
+		Name:      "bpf_map_entries_read",
+		Help:      "Number of entries read from the BPF process map in the last collection cycle",
+	})
+	powerSourceErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: consts.MetricsNamespace,
+		Subsystem: subsystem,
+		Name:      "power_source_errors_total",
+		Help:      "Number of failed reads of each power meter",
+	}, []string{"source"})
+	modelPredictionFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: consts.MetricsNamespace,
+		Subsystem: subsystem,
+		Name:      "model_prediction_failures_total",
+		Help:      "Number of failed power estimations of each power model",
+	}, []string{"model"})
+	counterResets = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: consts.MetricsNamespace,
+		Subsystem: subsystem,
+		Name:      "counter_resets_total",
+		Help:      "Number of aggregated values that were reset because they overflowed or the counter read decreased",
+	}, []string{"reason"})
+)
+
+// Collectors returns the self-metrics of the collector to be registered
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		updatePhaseDuration, trackedEntries, idleProcessesEvicted, processExitsDropped, bpfMapEntriesRead,
+		powerSourceErrors, modelPredictionFailures, counterResets,
+	}
+}
+
+// ObservePhase observes the duration of a phase of the collection cycle that started at start
+func ObservePhase(phase string, start time.Time) {
+	updatePhaseDuration.WithLabelValues(phase).Observe(time.Since(start).Seconds())
+}
+
+// SetTrackedEntries sets the number of tracked processes, containers and VMs
+func SetTrackedEntries(processes, containers, vms int) {
+	trackedEntries.WithLabelValues("process").Set(float64(processes))
+	trackedEntries.WithLabelValues("container").Set(float64(containers))
+	trackedEntries.WithLabelValues("vm").Set(float64(vms))
+}
+
+// IncIdleProcessesEvicted counts an idle process that was removed
+func IncIdleProcessesEvicted() {
+	idleProcessesEvicted.Inc()
+}
+
+// AddProcessExitsDropped counts the process exit events that were dropped
+func AddProcessExitsDropped(dropped uint64) {
+	processExitsDropped.Add(float64(dropped))
+}
+
+// SetBPFMapEntriesRead sets the number of entries read from the BPF process map
+func SetBPFMapEntriesRead(entries int) {
+	bpfMapEntriesRead.Set(float64(entries))
+}
+
+// IncPowerSourceErrors counts a failed read of a power meter
+func IncPowerSourceErrors(source string) {
+	powerSourceErrors.WithLabelValues(source).Inc()
+}
+
+// IncModelPredictionFailures counts a failed estimation of a power model
+func IncModelPredictionFailures(model string) {
+	modelPredictionFailures.WithLabelValues(model).Inc()
+}
+
+// IncCounterResets counts an aggregated value that was reset
+func IncCounterResets(reason string) {
+	counterResets.WithLabelValues(reason).Inc()
+}
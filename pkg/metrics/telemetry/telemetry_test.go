@@ -0,0 +1,66 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package telemetry
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var _ = Describe("Collector self-metrics", func() {
+	It("registers all self-metrics", func() {
+		registry := prometheus.NewRegistry()
+		for _, c := range Collectors() {
+			Expect(registry.Register(c)).To(Succeed())
+		}
+	})
+
+	It("observes the duration of the phases", func() {
+		ObservePhase(PhaseNodeEnergy, time.Now().Add(-time.Second))
+		Expect(testutil.CollectAndCount(updatePhaseDuration)).To(BeNumerically(">=", 1))
+	})
+
+	It("sets the tracked entries and counts the events", func() {
+		SetTrackedEntries(3, 2, 1)
+		Expect(testutil.ToFloat64(trackedEntries.WithLabelValues("process"))).To(Equal(3.0))
+		Expect(testutil.ToFloat64(trackedEntries.WithLabelValues("vm"))).To(Equal(1.0))
+
+		before := testutil.ToFloat64(powerSourceErrors.WithLabelValues("platform"))
+		IncPowerSourceErrors("platform")
+		Expect(testutil.ToFloat64(powerSourceErrors.WithLabelValues("platform"))).To(Equal(before + 1))
+
+		before = testutil.ToFloat64(modelPredictionFailures.WithLabelValues("node_components"))
+		IncModelPredictionFailures("node_components")
+		Expect(testutil.ToFloat64(modelPredictionFailures.WithLabelValues("node_components"))).To(Equal(before + 1))
+
+		before = testutil.ToFloat64(counterResets.WithLabelValues(ResetDecrease))
+		IncCounterResets(ResetDecrease)
+		Expect(testutil.ToFloat64(counterResets.WithLabelValues(ResetDecrease))).To(Equal(before + 1))
+
+		before = testutil.ToFloat64(processExitsDropped)
+		AddProcessExitsDropped(3)
+		Expect(testutil.ToFloat64(processExitsDropped)).To(Equal(before + 3))
+
+		SetBPFMapEntriesRead(42)
+		Expect(testutil.ToFloat64(bpfMapEntriesRead)).To(Equal(42.0))
+	})
+})
@@ -20,13 +20,12 @@ import (
 	"strconv"
 
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/sustainable-computing-io/kepler/pkg/bpf"
 	"github.com/sustainable-computing-io/kepler/pkg/collector/stats"
 	"github.com/sustainable-computing-io/kepler/pkg/config"
 	"github.com/sustainable-computing-io/kepler/pkg/metrics/consts"
 	"github.com/sustainable-computing-io/kepler/pkg/metrics/metricfactory"
 	"github.com/sustainable-computing-io/kepler/pkg/model/utils"
-	acc "github.com/sustainable-computing-io/kepler/pkg/sensors/accelerator"
+	kutils "github.com/sustainable-computing-io/kepler/pkg/utils"
 	"k8s.io/klog/v2"
 )
 
@@ -48,20 +47,12 @@ func CollectEnergyMetrics(ch chan<- prometheus.Metric, instance interface{}, col
 	}
 }
 
-func CollectResUtilizationMetrics(ch chan<- prometheus.Metric, instance interface{}, collectors map[string]metricfactory.PromMetric, bpfSupportedMetrics bpf.SupportedMetrics) {
+func CollectResUtilizationMetrics(ch chan<- prometheus.Metric, instance interface{}, collectors map[string]metricfactory.PromMetric) {
 	if config.IsExposeBPFMetricsEnabled() {
-		// collect the BPF Software Counters
-		for collectorName := range bpfSupportedMetrics.SoftwareCounters {
-			CollectResUtil(ch, instance, collectorName, collectors[collectorName])
-		}
-		for collectorName := range bpfSupportedMetrics.HardwareCounters {
-			CollectResUtil(ch, instance, collectorName, collectors[collectorName])
-		}
-		if config.IsGPUEnabled() {
-			if gpu := acc.GetActiveAcceleratorByType(config.GPU); gpu != nil {
-				for _, collectorName := range consts.GPUMetricNames {
-					CollectResUtil(ch, instance, collectorName, collectors[collectorName])
-				}
+		// collect the metrics of the registered resource sources, e.g. the BPF counters and the GPU utilization
+		for _, collectorName := range stats.AvailableResourceMetrics() {
+			if collector, exists := collectors[collectorName]; exists {
+				CollectResUtil(ch, instance, collectorName, collector)
 			}
 		}
 	}
@@ -105,7 +96,7 @@ func handleProcessStats(ch chan<- prometheus.Metric, collection *stats.ProcessSt
 	energy += collection.EnergyUsage[config.DynEnergyInGPU].SumAllAggrValues()
 	energyInJoules := float64(energy) / utils.JouleMillijouleConversionFactor
 
-	labelValues := []string{strconv.FormatUint(collection.PID, 10), collection.ContainerID, collection.VMID, collection.Command, "dynamic"}
+	labelValues := []string{pidLabel(collection), collection.ContainerID, collection.VMID, collection.Command, "dynamic"}
 
 	ch <- collectors["total"].MustMetric(energyInJoules, labelValues...)
 
@@ -114,10 +105,18 @@ func handleProcessStats(ch chan<- prometheus.Metric, collection *stats.ProcessSt
 	energy += collection.EnergyUsage[config.IdleEnergyInOther].SumAllAggrValues()
 	energy += collection.EnergyUsage[config.IdleEnergyInGPU].SumAllAggrValues()
 	energyInJoules = float64(energy) / utils.JouleMillijouleConversionFactor
-	labelValues = []string{strconv.FormatUint(collection.PID, 10), collection.ContainerID, collection.VMID, collection.Command, "idle"}
+	labelValues = []string{pidLabel(collection), collection.ContainerID, collection.VMID, collection.Command, "idle"}
 	ch <- collectors["total"].MustMetric(energyInJoules, labelValues...)
 }
 
+// pidLabel returns the pid label of a process, which is "terminated" for the bucket of the removed processes of a container
+func pidLabel(process *stats.ProcessStats) string {
+	if process.Terminated {
+		return kutils.TerminatedName
+	}
+	return strconv.FormatUint(process.PID, 10)
+}
+
 func collect(ch chan<- prometheus.Metric, collector metricfactory.PromMetric, value float64, labelValues []string) {
 	ch <- collector.MustMetric(value, labelValues...)
 }
@@ -135,7 +134,7 @@ func collectEnergy(ch chan<- prometheus.Metric, instance interface{}, metricName
 	case *stats.ProcessStats:
 		process := instance.(*stats.ProcessStats)
 		value = float64(process.EnergyUsage[metricName].SumAllAggrValues()) / JouleMillijouleConversionFactor
-		labelValues = []string{strconv.FormatUint(process.PID, 10), process.ContainerID, process.VMID, process.Command, mode}
+		labelValues = []string{pidLabel(process), process.ContainerID, process.VMID, process.Command, mode}
 		collect(ch, collector, value, labelValues)
 
 	case *stats.VMStats:
@@ -160,6 +159,16 @@ func collectEnergy(ch chan<- prometheus.Metric, instance interface{}, metricName
 	}
 }
 
+// isGPUMetric returns true for the GPU metrics, which are reported per device
+func isGPUMetric(metricName string) bool {
+	for _, m := range consts.GPUMetricNames {
+		if metricName == m {
+			return true
+		}
+	}
+	return false
+}
+
 func CollectResUtil(ch chan<- prometheus.Metric, instance interface{}, metricName string, collector metricfactory.PromMetric) {
 	var value float64
 	var labelValues []string
@@ -167,14 +176,7 @@ func CollectResUtil(ch chan<- prometheus.Metric, instance interface{}, metricNam
 	case *stats.ContainerStats:
 		container := instance.(*stats.ContainerStats)
 		// special case for GPU devices, the metrics are reported per device
-		isGPUMetric := false
-		for _, m := range consts.GPUMetricNames {
-			if metricName == m {
-				isGPUMetric = true
-				break
-			}
-		}
-		if isGPUMetric {
+		if isGPUMetric(metricName) {
 			for deviceID, utilization := range container.ResourceUsage[metricName] {
 				value = float64(utilization.GetAggr())
 				labelValues = []string{container.ContainerID, container.PodName, container.ContainerName, container.Namespace, deviceID}
@@ -193,14 +195,22 @@ func CollectResUtil(ch chan<- prometheus.Metric, instance interface{}, metricNam
 	case *stats.ProcessStats:
 		process := instance.(*stats.ProcessStats)
 		value = float64(process.ResourceUsage[metricName].SumAllAggrValues())
-		labelValues = []string{strconv.FormatUint(process.PID, 10), process.ContainerID, process.VMID, process.Command}
+		labelValues = []string{pidLabel(process), process.ContainerID, process.VMID, process.Command}
 		collect(ch, collector, value, labelValues)
 
 	case *stats.VMStats:
 		vm := instance.(*stats.VMStats)
-		value = float64(vm.ResourceUsage[metricName].SumAllAggrValues())
-		labelValues = []string{vm.VMID}
-		collect(ch, collector, value, labelValues)
+		if isGPUMetric(metricName) {
+			for deviceID, utilization := range vm.ResourceUsage[metricName] {
+				value = float64(utilization.GetAggr())
+				labelValues = []string{vm.VMID, deviceID}
+				collect(ch, collector, value, labelValues)
+			}
+		} else {
+			value = float64(vm.ResourceUsage[metricName].SumAllAggrValues())
+			labelValues = []string{vm.VMID}
+			collect(ch, collector, value, labelValues)
+		}
 
 	// only node metrics report metrics per device, process, container and VM reports the aggregation
 	case *stats.NodeStats:
@@ -17,10 +17,7 @@ limitations under the License.
 package virtualmachine
 
 import (
-	"sync"
-
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/sustainable-computing-io/kepler/pkg/bpf"
 	"github.com/sustainable-computing-io/kepler/pkg/collector/stats"
 	"github.com/sustainable-computing-io/kepler/pkg/config"
 	"github.com/sustainable-computing-io/kepler/pkg/metrics/metricfactory"
@@ -31,27 +28,20 @@ const (
 	context = "vm"
 )
 
-// collector implements prometheus.Collector. It collects metrics directly from vm maps.
+// collector implements prometheus.Collector. It collects metrics from the published snapshot of the vm stats.
 type collector struct {
 	descriptions map[string]*prometheus.Desc
 	collectors   map[string]metricfactory.PromMetric
 
-	// VMStats holds all vms energy and resource usage metrics
-	VMStats map[string]*stats.VMStats
-
-	// Lock to synchronize the collector update with prometheus exporter
-	Mx *sync.Mutex
-
-	bpfSupportedMetrics bpf.SupportedMetrics
+	// snapshot returns the stats published after the last update, which are read without lock
+	snapshot func() *stats.Snapshot
 }
 
-func NewVMCollector(vmMetrics map[string]*stats.VMStats, mx *sync.Mutex, bpfSupportedMetrics bpf.SupportedMetrics) prometheus.Collector {
+func NewVMCollector(snapshot func() *stats.Snapshot) prometheus.Collector {
 	c := &collector{
-		VMStats:             vmMetrics,
-		descriptions:        make(map[string]*prometheus.Desc),
-		collectors:          make(map[string]metricfactory.PromMetric),
-		Mx:                  mx,
-		bpfSupportedMetrics: bpfSupportedMetrics,
+		snapshot:     snapshot,
+		descriptions: make(map[string]*prometheus.Desc),
+		collectors:   make(map[string]metricfactory.PromMetric),
 	}
 	c.initMetrics()
 	return c
@@ -62,11 +52,7 @@ func (c *collector) initMetrics() {
 	if !config.IsExposeVMStatsEnabled() {
 		return
 	}
-	for name, desc := range metricfactory.HCMetricsPromDesc(context, c.bpfSupportedMetrics) {
-		c.descriptions[name] = desc
-		c.collectors[name] = metricfactory.NewPromCounter(desc)
-	}
-	for name, desc := range metricfactory.SCMetricsPromDesc(context, c.bpfSupportedMetrics) {
+	for name, desc := range metricfactory.ResUtilizationMetricsPromDesc(context) {
 		c.descriptions[name] = desc
 		c.collectors[name] = metricfactory.NewPromCounter(desc)
 	}
@@ -83,10 +69,10 @@ func (c *collector) Describe(ch chan<- *prometheus.Desc) {
 }
 
 func (c *collector) Collect(ch chan<- prometheus.Metric) {
-	c.Mx.Lock()
-	for _, vm := range c.VMStats {
+	snapshot := c.snapshot()
+	defer snapshot.Release()
+	for _, vm := range snapshot.VMStats {
 		utils.CollectEnergyMetrics(ch, vm, c.collectors)
-		utils.CollectResUtilizationMetrics(ch, vm, c.collectors, c.bpfSupportedMetrics)
+		utils.CollectResUtilizationMetrics(ch, vm, c.collectors)
 	}
-	c.Mx.Unlock()
 }
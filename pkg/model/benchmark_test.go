@@ -18,6 +18,7 @@ package model_test
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/sustainable-computing-io/kepler/pkg/bpf"
 	"github.com/sustainable-computing-io/kepler/pkg/collector"
@@ -50,7 +51,7 @@ func benchmarkNtesting(b *testing.B, processNumber int) {
 	// update container and node metrics
 	b.ReportAllocs()
 	b.ResetTimer()
-	metricCollector.UpdateProcessEnergyUtilizationMetrics()
+	metricCollector.UpdateProcessEnergyUtilizationMetrics(time.Duration(config.SamplePeriodSec()) * time.Second)
 	metricCollector.AggregateProcessEnergyUtilizationMetrics()
 	b.StopTimer()
 }
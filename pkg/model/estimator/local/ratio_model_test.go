@@ -17,6 +17,8 @@ limitations under the License.
 package local
 
 import (
+	"time"
+
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
@@ -93,13 +95,13 @@ var _ = Describe("Test Ratio Unit", func() {
 			// add samples to estimate the components (CPU and DRAM) power
 			if model.IsEnabled() {
 				// Add process metrics
-				featureValues := c.ToEstimatorValues(model.GetProcessFeatureNamesList(), true) // add node features with normalized values
+				featureValues := c.ToEstimatorValues(model.GetProcessFeatureNamesList(), time.Duration(config.SamplePeriodSec())*time.Second) // add node features with normalized values
 				model.AddProcessFeatureValues(featureValues)
 			}
 		}
 		// Add node metrics.
 		if model.IsEnabled() {
-			featureValues := nodeStats.ToEstimatorValues(model.GetNodeFeatureNamesList(), true) // add node features with normalized values
+			featureValues := nodeStats.ToEstimatorValues(model.GetNodeFeatureNamesList(), time.Duration(config.SamplePeriodSec())*time.Second) // add node features with normalized values
 			model.AddNodeFeatureValues(featureValues)
 		}
 
@@ -19,6 +19,7 @@ package model
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/sustainable-computing-io/kepler/pkg/config"
 	"github.com/sustainable-computing-io/kepler/pkg/model/estimator/local"
@@ -37,6 +38,12 @@ const (
 	estimatorSidecarSocket = "/tmp/estimator.sock"
 )
 
+// powerToEnergy converts a power in mW into the energy in mJ consumed during the measured interval
+// since the previous collection, which can differ from the sample period when the ticker drifts
+func powerToEnergy(power uint64, interval time.Duration) uint64 {
+	return uint64(float64(power) * interval.Seconds())
+}
+
 // PowerModelInterface defines the power model skeleton
 type PowerModelInterface interface {
 	// AddProcessFeatureValues adds the new x as a point for training or prediction. Where x are explanatory variable (or the independent variable).
@@ -18,9 +18,11 @@ package model
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/sustainable-computing-io/kepler/pkg/collector/stats"
 	"github.com/sustainable-computing-io/kepler/pkg/config"
+	"github.com/sustainable-computing-io/kepler/pkg/metrics/telemetry"
 	"github.com/sustainable-computing-io/kepler/pkg/model/types"
 	"github.com/sustainable-computing-io/kepler/pkg/node"
 	"github.com/sustainable-computing-io/kepler/pkg/sensors/components"
@@ -72,8 +74,8 @@ func IsNodeComponentPowerModelEnabled() bool {
 	return nodeComponentPowerModel.IsEnabled()
 }
 
-// GetNodeComponentPowers returns estimated RAPL power for the node
-func GetNodeComponentPowers(nodeMetrics *stats.NodeStats, isIdlePower bool) (nodeComponentsEnergy map[int]source.NodeComponentsEnergy) {
+// GetNodeComponentPowers returns estimated RAPL power for the node, the resource usage is normalized by the measured interval
+func GetNodeComponentPowers(nodeMetrics *stats.NodeStats, isIdlePower bool, interval time.Duration) (nodeComponentsEnergy map[int]source.NodeComponentsEnergy) {
 	if nodeComponentPowerModel == nil {
 		klog.Errorln("Node Component Power Model was not created")
 	}
@@ -82,11 +84,12 @@ func GetNodeComponentPowers(nodeMetrics *stats.NodeStats, isIdlePower bool) (nod
 	if !isIdlePower {
 		// reset power model features sample list for new estimation
 		nodeComponentPowerModel.ResetSampleIdx()
-		featureValues := nodeMetrics.ToEstimatorValues(nodeComponentPowerModel.GetNodeFeatureNamesList(), true) // add container features with normalized values
-		nodeComponentPowerModel.AddNodeFeatureValues(featureValues)                                             // add samples to estimation
+		featureValues := nodeMetrics.ToEstimatorValues(nodeComponentPowerModel.GetNodeFeatureNamesList(), interval) // add container features with normalized values
+		nodeComponentPowerModel.AddNodeFeatureValues(featureValues)                                                 // add samples to estimation
 	}
 	powers, err := nodeComponentPowerModel.GetComponentsPower(isIdlePower)
 	if err != nil {
+		telemetry.IncModelPredictionFailures("node_components")
 		klog.Infof("Failed to get node components power %v\n", err)
 		return
 	}
@@ -98,21 +101,21 @@ func GetNodeComponentPowers(nodeMetrics *stats.NodeStats, isIdlePower bool) (nod
 }
 
 // UpdateNodeComponentEnergy sets the power model samples, get absolute powers, and set gauge value for each component energy
-func UpdateNodeComponentEnergy(nodeMetrics *stats.NodeStats) {
-	addEnergy(nodeMetrics, nodeMetrics.AbsEnergyMetrics(), absPower)
+func UpdateNodeComponentEnergy(nodeMetrics *stats.NodeStats, interval time.Duration) {
+	addEnergy(nodeMetrics, nodeMetrics.AbsEnergyMetrics(), absPower, interval)
 }
 
 // UpdateNodeComponentIdleEnergy sets the power model samples to zeros, get idle powers, and set gauge value for each component idle energy
-func UpdateNodeComponentIdleEnergy(nodeMetrics *stats.NodeStats) {
-	addEnergy(nodeMetrics, nodeMetrics.IdleEnergyMetrics(), idlePower)
+func UpdateNodeComponentIdleEnergy(nodeMetrics *stats.NodeStats, interval time.Duration) {
+	addEnergy(nodeMetrics, nodeMetrics.IdleEnergyMetrics(), idlePower, interval)
 }
 
-func addEnergy(nodeMetrics *stats.NodeStats, metrics []string, isIdle bool) {
-	for socket, power := range GetNodeComponentPowers(nodeMetrics, isIdle) {
+func addEnergy(nodeMetrics *stats.NodeStats, metrics []string, isIdle bool, interval time.Duration) {
+	for socket, power := range GetNodeComponentPowers(nodeMetrics, isIdle, interval) {
 		strID := fmt.Sprintf("%d", socket)
-		nodeMetrics.EnergyUsage[metrics[0]].SetDeltaStat(strID, power.Core*config.SamplePeriodSec())
-		nodeMetrics.EnergyUsage[metrics[1]].SetDeltaStat(strID, power.DRAM*config.SamplePeriodSec())
-		nodeMetrics.EnergyUsage[metrics[2]].SetDeltaStat(strID, power.Uncore*config.SamplePeriodSec())
-		nodeMetrics.EnergyUsage[metrics[3]].SetDeltaStat(strID, power.Pkg*config.SamplePeriodSec())
+		nodeMetrics.EnergyUsage[metrics[0]].SetDeltaStat(strID, powerToEnergy(power.Core, interval))
+		nodeMetrics.EnergyUsage[metrics[1]].SetDeltaStat(strID, powerToEnergy(power.DRAM, interval))
+		nodeMetrics.EnergyUsage[metrics[2]].SetDeltaStat(strID, powerToEnergy(power.Uncore, interval))
+		nodeMetrics.EnergyUsage[metrics[3]].SetDeltaStat(strID, powerToEnergy(power.Pkg, interval))
 	}
 }
@@ -18,9 +18,11 @@ package model
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/sustainable-computing-io/kepler/pkg/collector/stats"
 	"github.com/sustainable-computing-io/kepler/pkg/config"
+	"github.com/sustainable-computing-io/kepler/pkg/metrics/telemetry"
 	"github.com/sustainable-computing-io/kepler/pkg/model/types"
 	"github.com/sustainable-computing-io/kepler/pkg/node"
 	"github.com/sustainable-computing-io/kepler/pkg/sensors/platform"
@@ -65,8 +67,8 @@ func IsNodePlatformPowerModelEnabled() bool {
 	return nodePlatformPowerModel.IsEnabled()
 }
 
-// GetNodePlatformPower returns a single estimated value of node total power
-func GetNodePlatformPower(nodeMetrics *stats.NodeStats, isIdlePower bool) (platformEnergy map[string]uint64) {
+// GetNodePlatformPower returns a single estimated value of node total power, the resource usage is normalized by the measured interval
+func GetNodePlatformPower(nodeMetrics *stats.NodeStats, isIdlePower bool, interval time.Duration) (platformEnergy map[string]uint64) {
 	if nodePlatformPowerModel == nil {
 		klog.Errorln("Node Platform Power Model was not created")
 	}
@@ -76,11 +78,12 @@ func GetNodePlatformPower(nodeMetrics *stats.NodeStats, isIdlePower bool) (platf
 		nodePlatformPowerModel.ResetSampleIdx()
 		// converts to node metrics map to array to add the samples to the power model
 		// the featureList is defined in the container power model file and the features varies accordingly to the selected power model
-		featureValues := nodeMetrics.ToEstimatorValues(nodePlatformPowerModel.GetNodeFeatureNamesList(), true) // add container features with normalized values
-		nodePlatformPowerModel.AddNodeFeatureValues(featureValues)                                             // add samples to estimation
+		featureValues := nodeMetrics.ToEstimatorValues(nodePlatformPowerModel.GetNodeFeatureNamesList(), interval) // add container features with normalized values
+		nodePlatformPowerModel.AddNodeFeatureValues(featureValues)                                                 // add samples to estimation
 	}
 	powers, err := nodePlatformPowerModel.GetPlatformPower(isIdlePower)
 	if err != nil {
+		telemetry.IncModelPredictionFailures("node_platform")
 		klog.Infof("Failed to get node platform power %v\n", err)
 		return
 	}
@@ -92,17 +95,17 @@ func GetNodePlatformPower(nodeMetrics *stats.NodeStats, isIdlePower bool) (platf
 }
 
 // UpdateNodePlatformEnergy sets the power model samples, get absolute powers, and set platform energy
-func UpdateNodePlatformEnergy(nodeMetrics *stats.NodeStats) {
-	platformPower := GetNodePlatformPower(nodeMetrics, absPower)
+func UpdateNodePlatformEnergy(nodeMetrics *stats.NodeStats, interval time.Duration) {
+	platformPower := GetNodePlatformPower(nodeMetrics, absPower, interval)
 	for sourceID, power := range platformPower {
-		nodeMetrics.EnergyUsage[config.AbsEnergyInPlatform].SetDeltaStat(sourceID, power*config.SamplePeriodSec())
+		nodeMetrics.EnergyUsage[config.AbsEnergyInPlatform].SetDeltaStat(sourceID, powerToEnergy(power, interval))
 	}
 }
 
 // UpdateNodePlatformIdleEnergy sets the power model samples to zeros, get idle powers, and set platform energy
-func UpdateNodePlatformIdleEnergy(nodeMetrics *stats.NodeStats) {
-	platformPower := GetNodePlatformPower(nodeMetrics, idlePower)
+func UpdateNodePlatformIdleEnergy(nodeMetrics *stats.NodeStats, interval time.Duration) {
+	platformPower := GetNodePlatformPower(nodeMetrics, idlePower, interval)
 	for sourceID, power := range platformPower {
-		nodeMetrics.EnergyUsage[config.IdleEnergyInPlatform].SetDeltaStat(sourceID, power*config.SamplePeriodSec())
+		nodeMetrics.EnergyUsage[config.IdleEnergyInPlatform].SetDeltaStat(sourceID, powerToEnergy(power, interval))
 	}
 }
@@ -18,10 +18,12 @@ package model
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/sustainable-computing-io/kepler/pkg/bpf"
 	"github.com/sustainable-computing-io/kepler/pkg/collector/stats"
 	"github.com/sustainable-computing-io/kepler/pkg/config"
+	"github.com/sustainable-computing-io/kepler/pkg/metrics/telemetry"
 	"github.com/sustainable-computing-io/kepler/pkg/model/types"
 	"github.com/sustainable-computing-io/kepler/pkg/node"
 	acc "github.com/sustainable-computing-io/kepler/pkg/sensors/accelerator"
@@ -133,8 +135,9 @@ func CreateProcessPowerEstimatorModel(processFeatureNames []string) {
 	}
 }
 
-// UpdateProcessEnergy resets the power model samples, add new samples to the power models, then estimates the idle and dynamic energy
-func UpdateProcessEnergy(processesMetrics map[uint64]*stats.ProcessStats, nodeMetrics *stats.NodeStats) {
+// UpdateProcessEnergy resets the power model samples, add new samples to the power models, then estimates the idle and dynamic energy.
+// The interval is the measured time since the previous collection, which normalizes the usage and converts the power into energy.
+func UpdateProcessEnergy(processesMetrics map[uint64]*stats.ProcessStats, nodeMetrics *stats.NodeStats, interval time.Duration) {
 	if processPlatformPowerModel == nil {
 		klog.Errorln("Process Platform Power Model was not created")
 	}
@@ -146,26 +149,32 @@ func UpdateProcessEnergy(processesMetrics map[uint64]*stats.ProcessStats, nodeMe
 	processComponentPowerModel.ResetSampleIdx()
 
 	// add features values for prediction
-	processIDList := addSamplesToPowerModels(processesMetrics, nodeMetrics)
-	addEstimatedEnergy(processIDList, processesMetrics, idlePower)
-	addEstimatedEnergy(processIDList, processesMetrics, absPower)
+	processIDList := addSamplesToPowerModels(processesMetrics, nodeMetrics, interval)
+	addEstimatedEnergy(processIDList, processesMetrics, idlePower, interval)
+	addEstimatedEnergy(processIDList, processesMetrics, absPower, interval)
+}
+
+// IsProcessPowerModelEnabled returns if the process platform or components power model has been enabled
+func IsProcessPowerModelEnabled() bool {
+	return (processPlatformPowerModel != nil && processPlatformPowerModel.IsEnabled()) ||
+		(processComponentPowerModel != nil && processComponentPowerModel.IsEnabled())
 }
 
 // addSamplesToPowerModels converts process's metrics to array to add the samples to the power model
-func addSamplesToPowerModels(processesMetrics map[uint64]*stats.ProcessStats, nodeMetrics *stats.NodeStats) []uint64 {
+func addSamplesToPowerModels(processesMetrics map[uint64]*stats.ProcessStats, nodeMetrics *stats.NodeStats, interval time.Duration) []uint64 {
 	processIDList := []uint64{}
 	// Add process metrics
 	for processID, c := range processesMetrics {
 		// add samples to estimate the platform power
 		if processPlatformPowerModel.IsEnabled() {
-			featureValues := c.ToEstimatorValues(processPlatformPowerModel.GetProcessFeatureNamesList(), true) // add process features with normalized values
+			featureValues := c.ToEstimatorValues(processPlatformPowerModel.GetProcessFeatureNamesList(), interval) // add process features with normalized values
 			processPlatformPowerModel.AddProcessFeatureValues(featureValues)
 		}
 
 		// add samples to estimate the components (CPU and DRAM) power
 		if processComponentPowerModel.IsEnabled() {
 			// Add process metrics
-			featureValues := c.ToEstimatorValues(processComponentPowerModel.GetProcessFeatureNamesList(), true) // add node features with normalized values
+			featureValues := c.ToEstimatorValues(processComponentPowerModel.GetProcessFeatureNamesList(), interval) // add node features with normalized values
 			processComponentPowerModel.AddProcessFeatureValues(featureValues)
 		}
 
@@ -173,18 +182,18 @@ func addSamplesToPowerModels(processesMetrics map[uint64]*stats.ProcessStats, no
 	}
 	// Add node metrics.
 	if processPlatformPowerModel.IsEnabled() {
-		featureValues := nodeMetrics.ToEstimatorValues(processPlatformPowerModel.GetNodeFeatureNamesList(), true) // add node features with normalized values
+		featureValues := nodeMetrics.ToEstimatorValues(processPlatformPowerModel.GetNodeFeatureNamesList(), interval) // add node features with normalized values
 		processPlatformPowerModel.AddNodeFeatureValues(featureValues)
 	}
 	if processComponentPowerModel.IsEnabled() {
-		featureValues := nodeMetrics.ToEstimatorValues(processComponentPowerModel.GetNodeFeatureNamesList(), true) // add node features with normalized values
+		featureValues := nodeMetrics.ToEstimatorValues(processComponentPowerModel.GetNodeFeatureNamesList(), interval) // add node features with normalized values
 		processComponentPowerModel.AddNodeFeatureValues(featureValues)
 	}
 	return processIDList
 }
 
 // addEstimatedEnergy estimates the idle power consumption
-func addEstimatedEnergy(processIDList []uint64, processesMetrics map[uint64]*stats.ProcessStats, isIdlePower bool) {
+func addEstimatedEnergy(processIDList []uint64, processesMetrics map[uint64]*stats.ProcessStats, isIdlePower bool, interval time.Duration) {
 	var processGPUPower []uint64
 	var processPlatformPower []uint64
 	var processComponentsPower []source.NodeComponentsEnergy
@@ -197,6 +206,7 @@ func addEstimatedEnergy(processIDList []uint64, processesMetrics map[uint64]*sta
 	if processComponentPowerModel.IsEnabled() {
 		processComponentsPower, errComp = processComponentPowerModel.GetComponentsPower(isIdlePower)
 		if errComp != nil {
+			telemetry.IncModelPredictionFailures("process_components")
 			klog.V(5).Infoln("Could not estimate the Process Components Power")
 		}
 		// estimate the associated power consumption of GPU for each process
@@ -204,6 +214,7 @@ func addEstimatedEnergy(processIDList []uint64, processesMetrics map[uint64]*sta
 			if gpu := acc.GetActiveAcceleratorByType(config.GPU); gpu != nil {
 				processGPUPower, errGPU = processComponentPowerModel.GetGPUPower(isIdlePower)
 				if errGPU != nil {
+					telemetry.IncModelPredictionFailures("process_gpu")
 					klog.V(5).Infoln("Could not estimate the Process GPU Power")
 				}
 			}
@@ -213,6 +224,7 @@ func addEstimatedEnergy(processIDList []uint64, processesMetrics map[uint64]*sta
 	if processPlatformPowerModel.IsEnabled() {
 		processPlatformPower, errPlat = processPlatformPowerModel.GetPlatformPower(isIdlePower)
 		if errPlat != nil {
+			telemetry.IncModelPredictionFailures("process_platform")
 			klog.V(5).Infoln("Could not estimate the Process Platform Power")
 		}
 	}
@@ -221,8 +233,9 @@ func addEstimatedEnergy(processIDList []uint64, processesMetrics map[uint64]*sta
 	for i, processID := range processIDList {
 		if errComp == nil {
 			// add PKG power consumption
-			// since Kepler collects metrics at intervals of SamplePeriodSec, which is greater than 1 second, it is necessary to calculate the energy consumption for the entire waiting period
-			energy = processComponentsPower[i].Pkg * config.SamplePeriodSec()
+			// since Kepler collects metrics at intervals of SamplePeriodSec, which is greater than 1 second, it is necessary to calculate the energy consumption
+			// for the entire waiting period, which is measured since the ticker can drift or an update can overrun
+			energy = powerToEnergy(processComponentsPower[i].Pkg, interval)
 			if isIdlePower {
 				processesMetrics[processID].EnergyUsage[config.IdleEnergyInPkg].SetDeltaStat(utils.GenericSocketID, energy)
 			} else {
@@ -230,7 +243,7 @@ func addEstimatedEnergy(processIDList []uint64, processesMetrics map[uint64]*sta
 			}
 
 			// add CORE power consumption
-			energy = processComponentsPower[i].Core * config.SamplePeriodSec()
+			energy = powerToEnergy(processComponentsPower[i].Core, interval)
 			if isIdlePower {
 				processesMetrics[processID].EnergyUsage[config.IdleEnergyInCore].SetDeltaStat(utils.GenericSocketID, energy)
 			} else {
@@ -238,7 +251,7 @@ func addEstimatedEnergy(processIDList []uint64, processesMetrics map[uint64]*sta
 			}
 
 			// add DRAM power consumption
-			energy = processComponentsPower[i].DRAM * config.SamplePeriodSec()
+			energy = powerToEnergy(processComponentsPower[i].DRAM, interval)
 			if isIdlePower {
 				processesMetrics[processID].EnergyUsage[config.IdleEnergyInDRAM].SetDeltaStat(utils.GenericSocketID, energy)
 			} else {
@@ -246,7 +259,7 @@ func addEstimatedEnergy(processIDList []uint64, processesMetrics map[uint64]*sta
 			}
 
 			// add Uncore power consumption
-			energy = processComponentsPower[i].Uncore * config.SamplePeriodSec()
+			energy = powerToEnergy(processComponentsPower[i].Uncore, interval)
 			if isIdlePower {
 				processesMetrics[processID].EnergyUsage[config.IdleEnergyInUnCore].SetDeltaStat(utils.GenericSocketID, energy)
 			} else {
@@ -255,7 +268,7 @@ func addEstimatedEnergy(processIDList []uint64, processesMetrics map[uint64]*sta
 
 			// add GPU power consumption
 			if errGPU == nil {
-				energy = processGPUPower[i] * (config.SamplePeriodSec())
+				energy = powerToEnergy(processGPUPower[i], interval)
 				if isIdlePower {
 					processesMetrics[processID].EnergyUsage[config.IdleEnergyInGPU].SetDeltaStat(utils.GenericSocketID, energy)
 				} else {
@@ -265,7 +278,7 @@ func addEstimatedEnergy(processIDList []uint64, processesMetrics map[uint64]*sta
 		}
 
 		if errPlat == nil {
-			energy = processPlatformPower[i] * config.SamplePeriodSec()
+			energy = powerToEnergy(processPlatformPower[i], interval)
 			if isIdlePower {
 				processesMetrics[processID].EnergyUsage[config.IdleEnergyInPlatform].SetDeltaStat(utils.GenericSocketID, energy)
 			} else {
@@ -282,7 +295,7 @@ func addEstimatedEnergy(processIDList []uint64, processesMetrics map[uint64]*sta
 			} else {
 				otherPower = processPlatformPower[i] - processComponentsPower[i].Pkg - processComponentsPower[i].DRAM
 			}
-			energy = otherPower * config.SamplePeriodSec()
+			energy = powerToEnergy(otherPower, interval)
 			if isIdlePower {
 				processesMetrics[processID].EnergyUsage[config.IdleEnergyInOther].SetDeltaStat(utils.GenericSocketID, energy)
 			} else {
@@ -18,6 +18,7 @@ package model
 
 import (
 	"os"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -85,7 +86,7 @@ var _ = Describe("ProcessPower", func() {
 			nodeStats.UpdateDynEnergy()
 
 			// calculate process energy consumption
-			UpdateProcessEnergy(processStats, &nodeStats)
+			UpdateProcessEnergy(processStats, &nodeStats, time.Duration(config.SamplePeriodSec())*time.Second)
 
 			// The default process power model is the Ratio, then process energy consumption will be as follows:
 			// The node components dynamic power were set to 35000mJ, since the kepler interval is 3s, the power is 11667mJ
@@ -114,7 +115,7 @@ var _ = Describe("ProcessPower", func() {
 			nodeStats.UpdateDynEnergy()
 
 			// calculate process energy consumption
-			UpdateProcessEnergy(processStats, &nodeStats)
+			UpdateProcessEnergy(processStats, &nodeStats, time.Duration(config.SamplePeriodSec())*time.Second)
 
 			// The default process power model is the Ratio, then process energy consumption will be as follows:
 			// The node components dynamic power were set to 35000mJ, since the kepler interval is 3s, the power is 11667mJ
@@ -125,6 +126,28 @@ var _ = Describe("ProcessPower", func() {
 			Expect(processStats[uint64(1)].EnergyUsage[config.DynEnergyInPlatform][utils.GenericSocketID].GetDelta()).To(Equal(uint64(17502)))
 		})
 
+		It("Get process energy with Ratio power model when the collection interval drifts", func() {
+			configStr := "CONTAINER_COMPONENTS_ESTIMATOR=false\n"
+			os.Setenv("MODEL_CONFIG", configStr)
+			CreatePowerEstimatorModels(stats.GetProcessFeatureNames())
+
+			nodeStats.EnergyUsage[config.AbsEnergyInPkg].SetDeltaStat(utils.GenericSocketID, 10000)
+			nodeStats.UpdateIdleEnergyWithMinValue(true)
+			nodeStats.EnergyUsage[config.AbsEnergyInPkg].SetDeltaStat(utils.GenericSocketID, 45000)
+			nodeStats.UpdateDynEnergy()
+
+			// the ticks are early, late, or skipped when an update overruns the sample period, the node dynamic energy
+			// of 35000mJ is consumed during the measured interval, so the energy of each of the 2 processes is the
+			// same whatever the interval, only the power is different
+			for _, interval := range []time.Duration{1500 * time.Millisecond, 3 * time.Second, 4200 * time.Millisecond, 7 * time.Second} {
+				UpdateProcessEnergy(processStats, &nodeStats, interval)
+				for _, pid := range []uint64{1, 2} {
+					energy := processStats[pid].EnergyUsage[config.DynEnergyInPkg][utils.GenericSocketID].GetDelta()
+					Expect(energy).To(BeNumerically("~", 17500, 5), "interval %s", interval)
+				}
+			}
+		})
+
 		// TODO: Get process power with no dependency and no node power.
 		// The current LR model has some problems, all the model weights are negative, which means that the energy consumption will decrease with larger resource utilization.
 		// Consequently the dynamic power will be 0 since the idle power with 0 resource utilization will be higher than the absolute power with non zero utilization
@@ -59,6 +59,7 @@ type gpuDcgm struct {
 	fieldGroupName      string
 	fieldGroupHandle    dcgm.FieldHandle
 	cleanup             func()
+	lastEnergyRead      time.Time // time of the previous power read, to calculate the energy since then
 }
 
 func dcgmCheck(r *Registry) {
@@ -313,15 +314,16 @@ func (d *gpuDcgm) Shutdown() bool {
 
 func (d *gpuDcgm) AbsEnergyFromDevice() []uint32 {
 	gpuEnergy := []uint32{}
+	// the power is read every polling interval, which is greater than 1 second by default, so it is
+	// necessary to calculate the energy consumption for the entire waiting period
+	elapsed := elapsedSec(&d.lastEnergyRead)
 	for _, dev := range d.devs {
 		power, ret := dev.DeviceHandler.(nvml.Device).GetPowerUsage()
 		if ret != nvml.SUCCESS {
 			klog.Errorf("failed to get power usage on device %v: %v\n", dev, nvml.ErrorString(ret))
 			continue
 		}
-		// since Kepler collects metrics at intervals of SamplePeriodSec, which is greater than 1 second, it is
-		// necessary to calculate the energy consumption for the entire waiting period
-		energy := uint32(uint64(power) * config.SamplePeriodSec())
+		energy := uint32(float64(power) * elapsed)
 		gpuEnergy = append(gpuEnergy, energy)
 	}
 	return gpuEnergy
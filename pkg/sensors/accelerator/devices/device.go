@@ -46,6 +46,18 @@ type (
 	}
 )
 
+// elapsedSec returns the seconds since the previous read of the device power, or the sample period for the first
+// read, and sets the time of the previous read. The energy of a power read covers the time since the previous one,
+// so that it is correct whatever the polling interval of the device is.
+func elapsedSec(lastRead *time.Time) float64 {
+	now := time.Now()
+	defer func() { *lastRead = now }()
+	if lastRead.IsZero() {
+		return float64(config.SamplePeriodSec())
+	}
+	return now.Sub(*lastRead).Seconds()
+}
+
 func (d DeviceType) String() string {
 	return [...]string{"MOCK", "HABANA", "DCGM", "NVML", "GRACE HOPPER"}[d]
 }
@@ -69,7 +81,7 @@ type Device interface {
 	DevicesByName() map[string]any
 	// DeviceInstances returns a map with instances of each Device
 	DeviceInstances() map[int]map[int]any
-	// AbsEnergyFromDevice returns a map with mJ in each gpu device. Absolute energy is the sum of Idle + Dynamic energy.
+	// AbsEnergyFromDevice returns a map with mJ in each gpu device since the previous call. Absolute energy is the sum of Idle + Dynamic energy.
 	AbsEnergyFromDevice() []uint32
 	// DeviceUtilizationStats returns a map with any additional device stats.
 	DeviceUtilizationStats(dev any) (map[any]any, error)
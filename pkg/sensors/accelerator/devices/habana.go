@@ -42,6 +42,7 @@ var (
 type gpuHabana struct {
 	collectionSupported bool
 	devices             map[int]interface{}
+	lastEnergyRead      time.Time // time of the previous power read, to calculate the energy since then
 }
 
 func habanaCheck(r *Registry) {
@@ -110,13 +111,14 @@ func (g *gpuHabana) Shutdown() bool {
 
 func (g *gpuHabana) AbsEnergyFromDevice() []uint32 {
 	gpuEnergy := []uint32{}
+	elapsed := elapsedSec(&g.lastEnergyRead)
 	for _, dev := range g.devices {
 		power, ret := dev.(GPUDevice).DeviceHandler.(hlml.Device).PowerUsage()
 		if ret != nil {
 			klog.Errorf("failed to get power usage on device %v: %v\n", dev, ret)
 			continue
 		}
-		energy := uint32(uint64(power) * config.SamplePeriodSec())
+		energy := uint32(float64(power) * elapsed)
 		gpuEnergy = append(gpuEnergy, energy)
 
 		dname, _ := dev.(GPUDevice).DeviceHandler.(hlml.Device).Name()
@@ -43,6 +43,7 @@ type gpuNvml struct {
 	collectionSupported         bool
 	devices                     map[int]GPUDevice // List of GPU identifiers for the device
 	processUtilizationSupported bool              // bool to check if the process utilization collection is supported
+	lastEnergyRead              time.Time         // time of the previous power read, to calculate the energy since then
 }
 
 func nvmlCheck(r *Registry) {
@@ -177,15 +178,16 @@ func (n *gpuNvml) DeviceInstances() map[int]map[int]interface{} {
 // GetAbsEnergyFromGPU returns a map with mJ in each gpu device
 func (n *gpuNvml) AbsEnergyFromDevice() []uint32 {
 	gpuEnergy := []uint32{}
+	// the power is read every polling interval, which is greater than 1 second by default, so it is
+	// necessary to calculate the energy consumption for the entire waiting period
+	elapsed := elapsedSec(&n.lastEnergyRead)
 	for _, dev := range n.devices {
 		power, ret := dev.DeviceHandler.(nvml.Device).GetPowerUsage()
 		if ret != nvml.SUCCESS {
 			klog.Errorf("failed to get power usage on device %v: %v\n", dev, nvml.ErrorString(ret))
 			continue
 		}
-		// since Kepler collects metrics at intervals of SamplePeriodSec, which is greater than 1 second, it is
-		// necessary to calculate the energy consumption for the entire waiting period
-		energy := uint32(uint64(power) * config.SamplePeriodSec())
+		energy := uint32(float64(power) * elapsed)
 		gpuEnergy = append(gpuEnergy, energy)
 	}
 	return gpuEnergy
@@ -34,8 +34,8 @@ type powerInterface interface {
 	GetAbsEnergyFromUncore() (uint64, error)
 	// GetAbsEnergyFromPackage returns mJ in CPU package
 	GetAbsEnergyFromPackage() (uint64, error)
-	// GetAbsEnergyFromNodeComponents returns set of mJ per RAPL components
-	GetAbsEnergyFromNodeComponents() map[int]source.NodeComponentsEnergy
+	// GetAbsEnergyFromNodeComponents returns set of mJ per RAPL components, or an error if the power meter cannot be read
+	GetAbsEnergyFromNodeComponents() (map[int]source.NodeComponentsEnergy, error)
 	// StopPower stops the collection
 	StopPower()
 	// IsSystemCollectionSupported returns if it is possible to use this collector
@@ -107,7 +107,7 @@ func GetAbsEnergyFromPackage() (uint64, error) {
 	return powerImpl.GetAbsEnergyFromPackage()
 }
 
-func GetAbsEnergyFromNodeComponents() map[int]source.NodeComponentsEnergy {
+func GetAbsEnergyFromNodeComponents() (map[int]source.NodeComponentsEnergy, error) {
 	return powerImpl.GetAbsEnergyFromNodeComponents()
 }
 
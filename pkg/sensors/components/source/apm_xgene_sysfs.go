@@ -99,7 +99,7 @@ func (r *ApmXgeneSysfs) GetAbsEnergyFromPackage() (uint64, error) {
 	return 0, nil
 }
 
-func (r *ApmXgeneSysfs) GetAbsEnergyFromNodeComponents() map[int]NodeComponentsEnergy {
+func (r *ApmXgeneSysfs) GetAbsEnergyFromNodeComponents() (map[int]NodeComponentsEnergy, error) {
 	coreEnergy, _ := r.GetAbsEnergyFromCore()
 	dramEnergy, _ := r.GetAbsEnergyFromDram()
 	componentsEnergies := make(map[int]NodeComponentsEnergy)
@@ -109,7 +109,7 @@ func (r *ApmXgeneSysfs) GetAbsEnergyFromNodeComponents() map[int]NodeComponentsE
 		Uncore: 0,
 		Pkg:    coreEnergy,
 	}
-	return componentsEnergies
+	return componentsEnergies, nil
 }
 
 func (r *ApmXgeneSysfs) StopPower() {
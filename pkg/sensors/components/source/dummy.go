@@ -50,7 +50,7 @@ func (r *PowerDummy) GetAbsEnergyFromPackage() (uint64, error) {
 	return 8, nil
 }
 
-func (r *PowerDummy) GetAbsEnergyFromNodeComponents() map[int]NodeComponentsEnergy {
+func (r *PowerDummy) GetAbsEnergyFromNodeComponents() (map[int]NodeComponentsEnergy, error) {
 	componentsEnergies := make(map[int]NodeComponentsEnergy)
 	machineSocketID := 0
 	componentsEnergies[machineSocketID] = NodeComponentsEnergy{
@@ -58,5 +58,5 @@ func (r *PowerDummy) GetAbsEnergyFromNodeComponents() map[int]NodeComponentsEner
 		Core: 5,
 		DRAM: 1,
 	}
-	return componentsEnergies
+	return componentsEnergies, nil
 }
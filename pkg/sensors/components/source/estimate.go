@@ -74,7 +74,7 @@ func (r *PowerEstimate) GetAbsEnergyFromPackage() (uint64, error) {
 }
 
 // No node components information, consider as 1 socket
-func (r *PowerEstimate) GetAbsEnergyFromNodeComponents() map[int]NodeComponentsEnergy {
+func (r *PowerEstimate) GetAbsEnergyFromNodeComponents() (map[int]NodeComponentsEnergy, error) {
 	coreEnergy, _ := r.GetAbsEnergyFromCore()
 	dramEnergy, _ := r.GetAbsEnergyFromDram()
 	componentsEnergies := make(map[int]NodeComponentsEnergy)
@@ -84,5 +84,5 @@ func (r *PowerEstimate) GetAbsEnergyFromNodeComponents() map[int]NodeComponentsE
 		Uncore: 0,
 		Pkg:    coreEnergy,
 	}
-	return componentsEnergies
+	return componentsEnergies, nil
 }
@@ -190,12 +190,18 @@ func (g *GraceACPI) GetAbsEnergyFromPackage() (uint64, error) {
 	return totalEnergy, nil
 }
 
-func (g *GraceACPI) GetAbsEnergyFromNodeComponents() map[int]NodeComponentsEnergy {
+func (g *GraceACPI) GetAbsEnergyFromNodeComponents() (map[int]NodeComponentsEnergy, error) {
 	componentsEnergies := make(map[int]NodeComponentsEnergy)
 
 	for socketNum, paths := range g.sockets {
-		pkgEnergy, _ := g.readPowerFile(paths.totalPowerPath)
-		coreEnergy, _ := g.readPowerFile(paths.cpuPowerPath)
+		pkgEnergy, err := g.readPowerFile(paths.totalPowerPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the total power of socket %d: %w", socketNum, err)
+		}
+		coreEnergy, err := g.readPowerFile(paths.cpuPowerPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the CPU power of socket %d: %w", socketNum, err)
+		}
 
 		componentsEnergies[socketNum] = NodeComponentsEnergy{
 			Core: coreEnergy,
@@ -203,7 +209,7 @@ func (g *GraceACPI) GetAbsEnergyFromNodeComponents() map[int]NodeComponentsEnerg
 			// DRAM is included in package power
 		}
 	}
-	return componentsEnergies
+	return componentsEnergies, nil
 }
 
 func (g *GraceACPI) StopPower() {
@@ -42,7 +42,7 @@ func (r *PowerMSR) GetAbsEnergyFromPackage() (uint64, error) {
 	return ReadAllPower(ReadPkgPower)
 }
 
-func (r *PowerMSR) GetAbsEnergyFromNodeComponents() map[int]NodeComponentsEnergy {
+func (r *PowerMSR) GetAbsEnergyFromNodeComponents() (map[int]NodeComponentsEnergy, error) {
 	return GetRAPLEnergyByMSR(ReadCorePower, ReadDramPower, ReadUncorePower, ReadPkgPower)
 }
 
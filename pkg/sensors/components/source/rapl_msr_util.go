@@ -189,13 +189,18 @@ func ReadAllPower(f func(n int) (uint64, error)) (uint64, error) {
 	return energy, nil
 }
 
-func GetRAPLEnergyByMSR(coreFunc, dramFunc, uncoreFunc, pkgFunc func(n int) (uint64, error)) map[int]NodeComponentsEnergy {
+// GetRAPLEnergyByMSR returns the energy of the components per package. The core, DRAM and uncore MSRs are not
+// supported by all CPUs and are 0 if they cannot be read, but the read of the package MSR must succeed.
+func GetRAPLEnergyByMSR(coreFunc, dramFunc, uncoreFunc, pkgFunc func(n int) (uint64, error)) (map[int]NodeComponentsEnergy, error) {
 	packageEnergies := make(map[int]NodeComponentsEnergy)
 	for i := 0; i < numPackages; i++ {
+		pkgEnergy, err := pkgFunc(i)
+		if err != nil {
+			return nil, err
+		}
 		coreEnergy, _ := coreFunc(i)
 		dramEnergy, _ := dramFunc(i)
 		uncoreEnergy, _ := uncoreFunc(i)
-		pkgEnergy, _ := pkgFunc(i)
 		packageEnergies[i] = NodeComponentsEnergy{
 			Core:   coreEnergy,
 			DRAM:   dramEnergy,
@@ -203,5 +208,5 @@ func GetRAPLEnergyByMSR(coreFunc, dramFunc, uncoreFunc, pkgFunc func(n int) (uin
 			Pkg:    pkgEnergy,
 		}
 	}
-	return packageEnergies
+	return packageEnergies, nil
 }
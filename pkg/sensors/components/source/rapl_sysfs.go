@@ -52,7 +52,10 @@ var (
 func getEnergy(event string) (uint64, error) {
 	energy := uint64(0)
 	if hasEvent(event) {
-		energyMap := readEventEnergy(event)
+		energyMap, err := readEventEnergy(event)
+		if err != nil {
+			return energy, err
+		}
 		for _, e := range energyMap {
 			energy += e
 		}
@@ -61,7 +64,8 @@ func getEnergy(event string) (uint64, error) {
 	return energy, fmt.Errorf("could not read RAPL energy for %s", event)
 }
 
-func readEventEnergy(eventName string) map[string]uint64 {
+// readEventEnergy returns the energy of an event per package, or an error if the energy of a package cannot be read
+func readEventEnergy(eventName string) (map[string]uint64, error) {
 	energy := map[string]uint64{}
 	for pkID, subTree := range eventPaths {
 		for event, path := range subTree {
@@ -73,18 +77,16 @@ func readEventEnergy(eventName string) map[string]uint64 {
 			var data []byte
 
 			if data, err = os.ReadFile(path + energyFile); err != nil {
-				klog.V(3).Infoln(err)
-				continue
+				return nil, err
 			}
 			if e, err = strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64); err != nil {
-				klog.V(3).Infoln(err)
-				continue
+				return nil, fmt.Errorf("failed to parse the RAPL energy of %s in %s: %w", event, pkID, err)
 			}
 			e /= 1000 /*mJ*/
 			energy[pkID] = e
 		}
 	}
-	return energy
+	return energy, nil
 }
 
 func getMaxEnergyRange(eventName string) (uint64, error) {
@@ -149,13 +151,19 @@ func (r *PowerSysfs) GetAbsEnergyFromPackage() (uint64, error) {
 	return getEnergy(packageEvent)
 }
 
-func (r *PowerSysfs) GetAbsEnergyFromNodeComponents() map[int]NodeComponentsEnergy {
+func (r *PowerSysfs) GetAbsEnergyFromNodeComponents() (map[int]NodeComponentsEnergy, error) {
 	packageEnergies := make(map[int]NodeComponentsEnergy)
 
-	pkgEnergies := readEventEnergy(packageEvent)
-	coreEnergies := readEventEnergy(coreEvent)
-	dramEnergies := readEventEnergy(dramEvent)
-	uncoreEnergies := readEventEnergy(uncoreEvent)
+	// the events that are not supported have no path, only the energy of the existing events must be read
+	var energies [4]map[string]uint64
+	for i, event := range []string{packageEvent, coreEvent, dramEvent, uncoreEvent} {
+		e, err := readEventEnergy(event)
+		if err != nil {
+			return nil, err
+		}
+		energies[i] = e
+	}
+	pkgEnergies, coreEnergies, dramEnergies, uncoreEnergies := energies[0], energies[1], energies[2], energies[3]
 
 	for pkgID, pkgEnergy := range pkgEnergies {
 		coreEnergy := coreEnergies[pkgID]
@@ -171,7 +179,7 @@ func (r *PowerSysfs) GetAbsEnergyFromNodeComponents() map[int]NodeComponentsEner
 		}
 	}
 
-	return packageEnergies
+	return packageEnergies, nil
 }
 
 func (r *PowerSysfs) StopPower() {
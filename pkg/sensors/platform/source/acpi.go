@@ -44,6 +44,8 @@ var numCPUS int32 = int32(runtime.NumCPU())
 type ACPI struct {
 	CollectEnergy bool
 	powerPath     string
+	// lastRead is the time of the previous power read, to calculate the energy since then
+	lastRead time.Time
 }
 
 func NewACPIPowerMeter(mockpath string) *ACPI {
@@ -160,6 +162,14 @@ func (a *ACPI) IsHWMONCollectionSupported() bool {
 // GetEnergyFromHost returns the accumulated energy consumption
 func (a *ACPI) GetAbsEnergyFromPlatform() (map[string]float64, error) {
 	power := map[string]float64{}
+	// since the power is read every polling interval, which is greater than 1 second by default, it is
+	// necessary to calculate the energy consumption for the entire waiting period
+	now := time.Now()
+	elapsed := float64(config.SamplePeriodSec())
+	if !a.lastRead.IsZero() {
+		elapsed = now.Sub(a.lastRead).Seconds()
+	}
+	a.lastRead = now
 
 	for i := int32(1); i <= numCPUS; i++ {
 		path := a.powerPath + acpiPowerFilePrefix + strconv.Itoa(int(i)) + acpiPowerFileSuffix
@@ -170,9 +180,7 @@ func (a *ACPI) GetAbsEnergyFromPlatform() (map[string]float64, error) {
 		// currPower is in microWatt
 		currPower, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
 		if err == nil {
-			// since Kepler collects metrics at intervals of SamplePeriodSec, which is greater than 1 second, it is
-			// necessary to calculate the energy consumption for the entire waiting period
-			power[sensorIDPrefix+strconv.Itoa(int(i))] = float64(currPower / 1000 * config.SamplePeriodSec()) /*miliJoules*/
+			power[sensorIDPrefix+strconv.Itoa(int(i))] = float64(currPower/1000) * elapsed /*miliJoules*/
 		} else {
 			return power, err
 		}
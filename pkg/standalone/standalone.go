@@ -0,0 +1,111 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package standalone runs the collector in-process, without the exporter, for the
+// kepler subcommands. It requires the same privileges as the exporter.
+package standalone
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/sustainable-computing-io/kepler/pkg/bpf"
+	"github.com/sustainable-computing-io/kepler/pkg/collector"
+	"github.com/sustainable-computing-io/kepler/pkg/config"
+	"github.com/sustainable-computing-io/kepler/pkg/sensors/accelerator"
+	"github.com/sustainable-computing-io/kepler/pkg/sensors/components"
+	"github.com/sustainable-computing-io/kepler/pkg/sensors/platform"
+	"k8s.io/klog/v2"
+)
+
+// Collector owns the power meters, the eBPF exporter and the stats collector
+type Collector struct {
+	// Mx must be held to read the stats of the collector
+	Mx             sync.Mutex
+	StatsCollector *collector.Collector
+	bpfExporter    bpf.Exporter
+	interval       collector.IntervalTimer
+}
+
+// Start initializes the config, the power meters and the eBPF exporter, and creates the power models
+func Start(baseDir, configFile string) (*Collector, error) {
+	if _, err := config.InitializeWithConfigFile(baseDir, configFile); err != nil {
+		return nil, fmt.Errorf("failed to initialize config: %w", err)
+	}
+	components.InitPowerImpl()
+	platform.InitPowerImpl()
+	if config.IsGPUEnabled() {
+		r := accelerator.GetRegistry()
+		if a, err := accelerator.New(config.GPU, true); err == nil {
+			r.MustRegister(a)
+		} else {
+			klog.Errorf("failed to init GPU accelerators: %v", err)
+		}
+	}
+
+	bpfExporter, err := bpf.NewExporter()
+	if err != nil {
+		stopPower()
+		return nil, fmt.Errorf("failed to create eBPF exporter: %w", err)
+	}
+	c := &Collector{
+		StatsCollector: collector.NewCollector(bpfExporter),
+		bpfExporter:    bpfExporter,
+	}
+	if err := c.StatsCollector.Initialize(); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Update runs a collection cycle
+func (c *Collector) Update() error {
+	c.Mx.Lock()
+	defer c.Mx.Unlock()
+	c.StatsCollector.Update(c.interval.Next(time.Now()))
+	if err := c.StatsCollector.BPFError(); err != nil {
+		return fmt.Errorf("failed to read the eBPF tables: %w", err)
+	}
+	return nil
+}
+
+// Close detaches the eBPF programs and stops the power meters
+func (c *Collector) Close() {
+	c.StatsCollector.Close()
+	c.bpfExporter.Detach()
+	stopPower()
+}
+
+func stopPower() {
+	components.StopPower()
+	platform.StopPower()
+	if config.IsGPUEnabled() {
+		accelerator.Shutdown()
+	}
+}
+
+// SilenceLogs discards the logs, which would be mixed with the output of the subcommands,
+// unless the log file is set in the klog flags of fs.
+func SilenceLogs(fs *flag.FlagSet) {
+	if f := fs.Lookup("log_file"); f == nil || f.Value.String() == "" {
+		klog.LogToStderr(false)
+		klog.SetOutput(io.Discard)
+	}
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package top
+
+import (
+	"context"
+	"time"
+
+	"github.com/sustainable-computing-io/kepler/pkg/api"
+	"github.com/sustainable-computing-io/kepler/pkg/config"
+	"github.com/sustainable-computing-io/kepler/pkg/standalone"
+)
+
+// localSource runs the collector in-process
+type localSource struct {
+	collector   *standalone.Collector
+	snapshotter *api.Snapshotter
+	lastUpdate  time.Time
+}
+
+// NewLocalSource starts the collector in-process, and runs a first collection
+// cycle so that the first snapshot has the usage of a whole sample period.
+func NewLocalSource(baseDir, configFile string) (Source, error) {
+	c, err := standalone.Start(baseDir, configFile)
+	if err != nil {
+		return nil, err
+	}
+	s := &localSource{
+		collector:   c,
+		snapshotter: api.NewSnapshotter(c.StatsCollector.Snapshot),
+	}
+	// the first cycle reads the usage since the eBPF programs were attached
+	_ = c.Update()
+	s.lastUpdate = time.Now()
+	return s, nil
+}
+
+func (s *localSource) Snapshot(ctx context.Context, view View, namespace string) (*Snapshot, error) {
+	interval := time.Duration(config.SamplePeriodSec()) * time.Second
+	if err := wait(ctx, s.lastUpdate.Add(interval)); err != nil {
+		return nil, err
+	}
+	err := s.collector.Update()
+	s.lastUpdate = time.Now()
+	if err != nil {
+		return nil, err
+	}
+
+	f := api.Filter{Namespace: namespace}
+	snapshot := &Snapshot{Node: s.snapshotter.Node()}
+	snapshot.Timestamp = snapshot.Node.Timestamp
+	snapshot.SamplePeriodSec = snapshot.Node.SamplePeriodSec
+	if view == ProcessView {
+		snapshot.Processes = s.snapshotter.Processes(f).Items
+	} else {
+		snapshot.Containers = s.snapshotter.Containers(f).Items
+	}
+	return snapshot, nil
+}
+
+func (s *localSource) Close() {
+	s.collector.Close()
+}
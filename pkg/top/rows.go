@@ -0,0 +1,193 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package top
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/sustainable-computing-io/kepler/pkg/api"
+	"github.com/sustainable-computing-io/kepler/pkg/config"
+)
+
+// View is the kind of workload listed by top
+type View string
+
+const (
+	ProcessView   View = "processes"
+	ContainerView View = "containers"
+	PodView       View = "pods"
+)
+
+// SortKey is the column the rows are sorted by
+type SortKey string
+
+const (
+	SortByWatts        SortKey = "watts"
+	SortByCPUTime      SortKey = "cpu"
+	SortByInstructions SortKey = "instructions"
+	SortByCacheMisses  SortKey = "cache-misses"
+	SortByName         SortKey = "name"
+)
+
+// shortIDLength is the length of the container IDs in the table, as docker and crictl print them
+const shortIDLength = 12
+
+// powerComponents are summed to compute the power of a workload. The core and uncore
+// are part of the package, and the platform energy includes all the other components.
+var powerComponents = []string{"package", "dram", "gpu", "other"}
+
+// Row is a line of the top table
+type Row struct {
+	// ID is the PID, the container ID or the pod name
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	Pod       string `json:"pod,omitempty"`
+	// Containers is the number of containers of a pod
+	Containers int `json:"containers,omitempty"`
+	// Watts is the dynamic plus idle power during the last sample period
+	Watts float64 `json:"watts"`
+	// CPUTimeMs, Instructions and CacheMisses are the usage during the last sample period
+	CPUTimeMs    uint64 `json:"cpu_time_ms"`
+	Instructions uint64 `json:"instructions"`
+	CacheMisses  uint64 `json:"cache_misses"`
+}
+
+func (v View) validate() error {
+	switch v {
+	case ProcessView, ContainerView, PodView:
+		return nil
+	}
+	return fmt.Errorf("invalid view %q, must be one of %s, %s or %s", v, ProcessView, ContainerView, PodView)
+}
+
+func (k SortKey) validate() error {
+	switch k {
+	case SortByWatts, SortByCPUTime, SortByInstructions, SortByCacheMisses, SortByName:
+		return nil
+	}
+	return fmt.Errorf("invalid sort key %q, must be one of %s, %s, %s, %s or %s",
+		k, SortByWatts, SortByCPUTime, SortByInstructions, SortByCacheMisses, SortByName)
+}
+
+// watts sums the dynamic and idle power of the components
+func watts(e api.EnergyUsage) float64 {
+	total := 0.0
+	for _, c := range powerComponents {
+		total += e.Dynamic[c].Watts + e.Idle[c].Watts
+	}
+	return total
+}
+
+func newRow(id, name, namespace, pod string, e api.EnergyUsage, usage map[string]uint64) Row {
+	return Row{
+		ID:           id,
+		Name:         name,
+		Namespace:    namespace,
+		Pod:          pod,
+		Watts:        watts(e),
+		CPUTimeMs:    usage[config.CPUTime],
+		Instructions: usage[config.CPUInstruction],
+		CacheMisses:  usage[config.CacheMiss],
+	}
+}
+
+// Rows builds the rows of the view from the snapshot
+func Rows(s *Snapshot, view View) []Row {
+	rows := []Row{}
+	switch view {
+	case ProcessView:
+		for i := range s.Processes {
+			p := &s.Processes[i]
+			rows = append(rows, newRow(fmt.Sprint(p.PID), p.Command, p.Namespace, p.PodName, p.Energy, p.ResourceUsage))
+		}
+	case ContainerView:
+		for i := range s.Containers {
+			c := &s.Containers[i]
+			rows = append(rows, newRow(shortID(c.ContainerID), c.ContainerName, c.Namespace, c.PodName, c.Energy, c.ResourceUsage))
+		}
+	case PodView:
+		rows = podRows(s.Containers)
+	}
+	return rows
+}
+
+// podRows aggregates the containers per pod, the containers that are not in a pod, e.g.
+// the system processes, are aggregated in a row named after their container
+func podRows(containers []api.ContainerSnapshot) []Row {
+	pods := map[string]*Row{}
+	keys := []string{}
+	for i := range containers {
+		c := &containers[i]
+		pod := c.PodName
+		if pod == "" {
+			pod = c.ContainerName
+		}
+		key := c.Namespace + "/" + pod
+		row, exists := pods[key]
+		if !exists {
+			row = &Row{ID: pod, Name: pod, Namespace: c.Namespace, Pod: pod}
+			pods[key] = row
+			keys = append(keys, key)
+		}
+		r := newRow("", "", "", "", c.Energy, c.ResourceUsage)
+		row.Containers++
+		row.Watts += r.Watts
+		row.CPUTimeMs += r.CPUTimeMs
+		row.Instructions += r.Instructions
+		row.CacheMisses += r.CacheMisses
+	}
+	rows := make([]Row, 0, len(keys))
+	for _, key := range keys {
+		rows = append(rows, *pods[key])
+	}
+	return rows
+}
+
+// SortRows sorts the rows in descending order of the key, or by name in ascending order.
+// Ties are sorted by ID so that the rows do not jump around between refreshes.
+func SortRows(rows []Row, key SortKey) {
+	less := func(a, b *Row) (less, equal bool) {
+		switch key {
+		case SortByCPUTime:
+			return a.CPUTimeMs > b.CPUTimeMs, a.CPUTimeMs == b.CPUTimeMs
+		case SortByInstructions:
+			return a.Instructions > b.Instructions, a.Instructions == b.Instructions
+		case SortByCacheMisses:
+			return a.CacheMisses > b.CacheMisses, a.CacheMisses == b.CacheMisses
+		case SortByName:
+			return a.Name < b.Name, a.Name == b.Name
+		default:
+			return a.Watts > b.Watts, a.Watts == b.Watts
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		l, eq := less(&rows[i], &rows[j])
+		if eq {
+			return rows[i].ID < rows[j].ID
+		}
+		return l
+	})
+}
+
+func shortID(id string) string {
+	if len(id) > shortIDLength {
+		return id[:shortIDLength]
+	}
+	return id
+}
@@ -0,0 +1,190 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package top
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sustainable-computing-io/kepler/pkg/api"
+)
+
+const (
+	// pageSize is the number of items requested per API call, the maximum the API accepts
+	pageSize = 1000
+	// defaultInterval is the refresh interval until the sample period of the exporter is known
+	defaultInterval = 3 * time.Second
+)
+
+// Snapshot holds the node and the workloads of a collection cycle
+type Snapshot struct {
+	Timestamp       time.Time
+	SamplePeriodSec uint64
+	Node            api.NodeSnapshot
+	Processes       []api.ProcessSnapshot
+	Containers      []api.ContainerSnapshot
+}
+
+// Source provides the snapshots displayed by top
+type Source interface {
+	// Snapshot waits for the next refresh and returns the workloads of the view in the namespace,
+	// an empty namespace returns the workloads of all the namespaces
+	Snapshot(ctx context.Context, view View, namespace string) (*Snapshot, error)
+	Close()
+}
+
+// wait blocks until the deadline or the context is done
+func wait(ctx context.Context, deadline time.Time) error {
+	d := time.Until(deadline)
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// remoteSource reads the snapshots from the JSON API of a running exporter
+type remoteSource struct {
+	endpoint string
+	client   *http.Client
+	// interval overrides the sample period of the exporter when it is set
+	interval time.Duration
+	// samplePeriod is the sample period of the exporter read by the last fetch
+	samplePeriod time.Duration
+	nextFetch    time.Time
+}
+
+// NewRemoteSource creates a Source for the exporter listening on the endpoint, e.g. http://localhost:8888.
+// The basic auth credentials can be set in the endpoint URL.
+func NewRemoteSource(endpoint string, interval time.Duration, insecureSkipVerify bool) (Source, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid endpoint %q, must be an http or https URL", endpoint)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: insecureSkipVerify} //nolint:gosec // opt-in for self-signed certificates
+	return &remoteSource{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		client:   &http.Client{Transport: transport, Timeout: 30 * time.Second},
+		interval: interval,
+	}, nil
+}
+
+// Snapshot fetches the snapshot once per interval, a failed fetch is also retried after an interval
+func (s *remoteSource) Snapshot(ctx context.Context, view View, namespace string) (*Snapshot, error) {
+	if err := wait(ctx, s.nextFetch); err != nil {
+		return nil, err
+	}
+	defer func() {
+		s.nextFetch = time.Now().Add(s.refreshInterval())
+	}()
+	snapshot := &Snapshot{}
+	if err := s.get(ctx, "node", nil, &snapshot.Node); err != nil {
+		return nil, err
+	}
+	snapshot.Timestamp = snapshot.Node.Timestamp
+	snapshot.SamplePeriodSec = snapshot.Node.SamplePeriodSec
+	s.samplePeriod = time.Duration(snapshot.SamplePeriodSec) * time.Second
+
+	var err error
+	if view == ProcessView {
+		snapshot.Processes, err = getAll[api.ProcessSnapshot](ctx, s, "processes", namespace)
+	} else {
+		snapshot.Containers, err = getAll[api.ContainerSnapshot](ctx, s, "containers", namespace)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// refreshInterval returns the configured interval, or the sample period of the exporter
+func (s *remoteSource) refreshInterval() time.Duration {
+	switch {
+	case s.interval > 0:
+		return s.interval
+	case s.samplePeriod > 0:
+		return s.samplePeriod
+	default:
+		return defaultInterval
+	}
+}
+
+// getAll reads all the pages of a list
+func getAll[T any](ctx context.Context, s *remoteSource, path, namespace string) ([]T, error) {
+	items := []T{}
+	for offset := 0; ; offset += pageSize {
+		query := url.Values{}
+		query.Set("offset", strconv.Itoa(offset))
+		query.Set("limit", strconv.Itoa(pageSize))
+		if namespace != "" {
+			query.Set("namespace", namespace)
+		}
+		var list api.List[T]
+		if err := s.get(ctx, path, query, &list); err != nil {
+			return nil, err
+		}
+		items = append(items, list.Items...)
+		if len(list.Items) == 0 || offset+len(list.Items) >= list.Total {
+			return items, nil
+		}
+	}
+}
+
+func (s *remoteSource) get(ctx context.Context, path string, query url.Values, v interface{}) error {
+	u := s.endpoint + api.Prefix + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, http.NoBody)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to get %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		apiErr := struct {
+			Error string `json:"error"`
+		}{}
+		_ = json.NewDecoder(resp.Body).Decode(&apiErr)
+		return fmt.Errorf("failed to get %s: %s %s", path, resp.Status, apiErr.Error)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	return nil
+}
+
+func (s *remoteSource) Close() {
+	s.client.CloseIdleConnections()
+}
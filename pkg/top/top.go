@@ -0,0 +1,247 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package top implements the "kepler top" subcommand, which lists the processes,
+// containers or pods of the node sorted by their power, like top(1).
+package top
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sustainable-computing-io/kepler/pkg/config"
+	"github.com/sustainable-computing-io/kepler/pkg/standalone"
+	"golang.org/x/term"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// FormatTable and FormatJSON are the output formats
+	FormatTable = "table"
+	FormatJSON  = "json"
+
+	// clearScreen moves the cursor to the top left corner and clears the terminal
+	clearScreen = "\033[H\033[2J"
+)
+
+// Options configure top
+type Options struct {
+	View      View
+	SortBy    SortKey
+	Namespace string
+	// MaxRows limits the rows of the table, 0 shows all the rows
+	MaxRows int
+	// Batch disables the screen refresh, the tables are printed one after the other
+	Batch bool
+	// Iterations stops top after the number of refreshes, 0 runs until interrupted
+	Iterations int
+	Format     string
+}
+
+func (o *Options) validate() error {
+	if err := o.View.validate(); err != nil {
+		return err
+	}
+	if err := o.SortBy.validate(); err != nil {
+		return err
+	}
+	if o.Format != FormatTable && o.Format != FormatJSON {
+		return fmt.Errorf("invalid format %q, must be %s or %s", o.Format, FormatTable, FormatJSON)
+	}
+	if o.MaxRows < 0 || o.Iterations < 0 {
+		return errors.New("the number of rows and iterations must not be negative")
+	}
+	return nil
+}
+
+// Run refreshes the view until the context is done or the iterations are reached.
+// In interactive mode an error is displayed until the next refresh, which the source delays by
+// an interval, in batch mode it stops top.
+func Run(ctx context.Context, src Source, opts Options, out io.Writer) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	for i := 0; opts.Iterations == 0 || i < opts.Iterations; i++ {
+		snapshot, err := src.Snapshot(ctx, opts.View, opts.Namespace)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			if opts.Batch {
+				return err
+			}
+			fmt.Fprintf(out, "%s%s: %v\n", clearScreen, time.Now().Format(time.TimeOnly), err)
+			continue
+		}
+		rows := Rows(snapshot, opts.View)
+		SortRows(rows, opts.SortBy)
+		if err := render(out, snapshot, rows, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// jsonOutput is a refresh in the json format
+type jsonOutput struct {
+	Timestamp       time.Time `json:"timestamp"`
+	SamplePeriodSec uint64    `json:"sample_period_sec"`
+	NodeName        string    `json:"node_name"`
+	View            View      `json:"view"`
+	Rows            []Row     `json:"rows"`
+}
+
+func render(out io.Writer, s *Snapshot, rows []Row, opts Options) error {
+	total := len(rows)
+	if opts.MaxRows > 0 && len(rows) > opts.MaxRows {
+		rows = rows[:opts.MaxRows]
+	}
+	if opts.Format == FormatJSON {
+		// one object per line, so that scripts can stream the refreshes
+		return json.NewEncoder(out).Encode(jsonOutput{
+			Timestamp:       s.Timestamp,
+			SamplePeriodSec: s.SamplePeriodSec,
+			NodeName:        s.Node.NodeName,
+			View:            opts.View,
+			Rows:            rows,
+		})
+	}
+
+	if !opts.Batch {
+		fmt.Fprint(out, clearScreen)
+	}
+	fmt.Fprintf(out, "kepler top - %s  node %s  sample period %ds  %s %d  node power %.2fW\n\n",
+		s.Timestamp.Format(time.TimeOnly), s.Node.NodeName, s.SamplePeriodSec, opts.View, total, nodeWatts(s))
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	switch opts.View {
+	case ProcessView:
+		fmt.Fprintln(w, "PID\tCOMMAND\tNAMESPACE\tPOD\tWATTS\tCPU(ms)\tINSTRUCTIONS\tCACHE-MISSES")
+	case ContainerView:
+		fmt.Fprintln(w, "CONTAINER\tNAME\tNAMESPACE\tPOD\tWATTS\tCPU(ms)\tINSTRUCTIONS\tCACHE-MISSES")
+	case PodView:
+		fmt.Fprintln(w, "POD\tCONTAINERS\tNAMESPACE\tWATTS\tCPU(ms)\tINSTRUCTIONS\tCACHE-MISSES")
+	}
+	for i := range rows {
+		r := &rows[i]
+		usage := fmt.Sprintf("%.2f\t%d\t%d\t%d", r.Watts, r.CPUTimeMs, r.Instructions, r.CacheMisses)
+		if opts.View == PodView {
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", r.ID, r.Containers, dash(r.Namespace), usage)
+		} else {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.ID, r.Name, dash(r.Namespace), dash(r.Pod), usage)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if opts.Batch {
+		fmt.Fprintln(out)
+	}
+	return nil
+}
+
+// nodeWatts is the power measured on the node, the platform power if it is available
+func nodeWatts(s *Snapshot) float64 {
+	if e, exists := s.Node.Energy.Absolute["platform"]; exists && e.Watts > 0 {
+		return e.Watts
+	}
+	total := 0.0
+	for _, c := range powerComponents {
+		total += s.Node.Energy.Absolute[c].Watts
+	}
+	return total
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// Main runs the top subcommand with its command line arguments and returns the exit code
+func Main(args []string) int {
+	fs := flag.NewFlagSet("kepler top", flag.ContinueOnError)
+	endpoint := fs.String("endpoint", "", "URL of a running exporter, e.g. http://localhost:8888, the collector runs in-process if it is not set")
+	insecureSkipVerify := fs.Bool("insecure-skip-verify", false, "do not verify the certificate of the exporter")
+	interval := fs.Duration("interval", 0, "refresh interval when connected to an exporter, defaults to its sample period; the in-process collector refreshes every SAMPLE_PERIOD_SEC")
+	baseDir := fs.String("config-dir", config.BaseDir, "path to config base directory of the in-process collector")
+	configFile := fs.String("config-file", "", "path to a YAML or JSON config file of the in-process collector")
+	view := fs.String("view", string(ProcessView), "workloads to list: processes, containers or pods")
+	sortBy := fs.String("sort", string(SortByWatts), "sort by watts, cpu, instructions, cache-misses or name")
+	namespace := fs.String("namespace", "", "only list the workloads of the namespace")
+	maxRows := fs.Int("n", 20, "maximum number of rows, 0 shows all the rows")
+	batch := fs.Bool("batch", false, "batch mode for scripts, print the tables one after the other instead of refreshing the screen; set when the output is not a terminal")
+	iterations := fs.Int("iterations", 0, "number of refreshes before exiting, 0 runs until interrupted; defaults to 1 in batch mode")
+	format := fs.String("format", FormatTable, "output format, table or json")
+	klog.InitFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	// the logs would garble the screen
+	standalone.SilenceLogs(fs)
+
+	opts := Options{
+		View:       View(*view),
+		SortBy:     SortKey(*sortBy),
+		Namespace:  *namespace,
+		MaxRows:    *maxRows,
+		Batch:      *batch || !term.IsTerminal(int(os.Stdout.Fd())),
+		Iterations: *iterations,
+		Format:     *format,
+	}
+	iterationsSet := false
+	fs.Visit(func(f *flag.Flag) { iterationsSet = iterationsSet || f.Name == "iterations" })
+	if opts.Batch && !iterationsSet {
+		opts.Iterations = 1
+	}
+	if err := opts.validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	var src Source
+	var err error
+	if *endpoint != "" {
+		src, err = NewRemoteSource(*endpoint, *interval, *insecureSkipVerify)
+	} else {
+		src, err = NewLocalSource(*baseDir, *configFile)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer src.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if err := Run(ctx, src, opts, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,191 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package top
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/sustainable-computing-io/kepler/pkg/api"
+	"github.com/sustainable-computing-io/kepler/pkg/bpf"
+	"github.com/sustainable-computing-io/kepler/pkg/collector"
+	"github.com/sustainable-computing-io/kepler/pkg/collector/stats"
+	"github.com/sustainable-computing-io/kepler/pkg/config"
+)
+
+var _ = Describe("Test Top", func() {
+	var server *httptest.Server
+
+	BeforeEach(func() {
+		_, err := config.Initialize(".")
+		Expect(err).NotTo(HaveOccurred())
+
+		// pod1 has container1 and container2, pod2 has container3 in another namespace
+		c := collector.NewCollector(bpf.NewMockExporter(bpf.DefaultSupportedMetrics()))
+		c.NodeStats = stats.CreateMockedNodeStats()
+		pods := map[int]string{1: "pod1", 2: "pod1", 3: "pod2"}
+		for i := 1; i <= 3; i++ {
+			id := fmt.Sprintf("container%d", i)
+			namespace := "ns-a"
+			if i == 3 {
+				namespace = "ns-b"
+			}
+			container := stats.NewContainerStats(id, pods[i], namespace, id)
+			container.EnergyUsage[config.DynEnergyInPkg].SetDeltaStat(stats.MockedSocketID, uint64(i)*3000)
+			container.ResourceUsage[config.CPUTime].SetDeltaStat(stats.MockedSocketID, uint64(10-i))
+			c.ContainerStats[id] = container
+
+			process := stats.NewProcessStats(uint64(i), uint64(i), id, "", fmt.Sprintf("command%d", i))
+			process.EnergyUsage[config.DynEnergyInPkg].SetDeltaStat(stats.MockedSocketID, uint64(i)*3000)
+			process.EnergyUsage[config.IdleEnergyInDRAM].SetDeltaStat(stats.MockedSocketID, 3000)
+			process.ResourceUsage[config.CPUTime].SetDeltaStat(stats.MockedSocketID, uint64(10-i))
+			process.ResourceUsage[config.CPUInstruction].SetDeltaStat(stats.MockedSocketID, 100)
+			c.ProcessStats[uint64(i)] = process
+		}
+		c.PublishSnapshot()
+		server = httptest.NewServer(api.NewHandler(c.Snapshot))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	snapshot := func(view View, namespace string) *Snapshot {
+		src, err := NewRemoteSource(server.URL, 0, false)
+		Expect(err).NotTo(HaveOccurred())
+		defer src.Close()
+		s, err := src.Snapshot(context.Background(), view, namespace)
+		Expect(err).NotTo(HaveOccurred())
+		return s
+	}
+
+	It("Should sort the processes by watts with their resource usage", func() {
+		rows := Rows(snapshot(ProcessView, ""), ProcessView)
+		SortRows(rows, SortByWatts)
+		Expect(rows).To(HaveLen(3))
+		Expect(rows[0].ID).To(Equal("3"))
+		Expect(rows[0].Name).To(Equal("command3"))
+		Expect(rows[0].Namespace).To(Equal("ns-b"))
+		Expect(rows[0].Pod).To(Equal("pod2"))
+		// 9J of dynamic package energy and 3J of idle dram energy
+		period := float64(config.SamplePeriodSec())
+		Expect(rows[0].Watts).To(BeNumerically("~", 12/period))
+		Expect(rows[0].CPUTimeMs).To(Equal(uint64(7)))
+		Expect(rows[0].Instructions).To(Equal(uint64(100)))
+		Expect(rows[2].ID).To(Equal("1"))
+	})
+
+	It("Should sort the rows by the other keys", func() {
+		rows := Rows(snapshot(ProcessView, ""), ProcessView)
+		SortRows(rows, SortByCPUTime)
+		Expect(rows[0].ID).To(Equal("1"))
+		SortRows(rows, SortByName)
+		Expect(rows[0].Name).To(Equal("command1"))
+		// ties are sorted by ID
+		SortRows(rows, SortByInstructions)
+		Expect([]string{rows[0].ID, rows[1].ID, rows[2].ID}).To(Equal([]string{"1", "2", "3"}))
+	})
+
+	It("Should filter the workloads by namespace", func() {
+		rows := Rows(snapshot(ContainerView, "ns-a"), ContainerView)
+		Expect(rows).To(HaveLen(2))
+		for _, r := range rows {
+			Expect(r.Namespace).To(Equal("ns-a"))
+		}
+		Expect(Rows(snapshot(ProcessView, "ns-b"), ProcessView)).To(HaveLen(1))
+	})
+
+	It("Should aggregate the containers per pod", func() {
+		rows := Rows(snapshot(PodView, ""), PodView)
+		SortRows(rows, SortByWatts)
+		Expect(rows).To(HaveLen(2))
+		Expect(rows[0].ID).To(Equal("pod1"))
+		Expect(rows[0].Containers).To(Equal(2))
+		Expect(rows[0].CPUTimeMs).To(Equal(uint64(9 + 8)))
+		Expect(rows[0].Watts).To(BeNumerically("~", 9/float64(config.SamplePeriodSec())))
+	})
+
+	It("Should print the tables in batch mode", func() {
+		src, err := NewRemoteSource(server.URL, 0, false)
+		Expect(err).NotTo(HaveOccurred())
+		defer src.Close()
+		out := &bytes.Buffer{}
+		opts := Options{View: ProcessView, SortBy: SortByWatts, MaxRows: 2, Batch: true, Iterations: 1, Format: FormatTable}
+		Expect(Run(context.Background(), src, opts, out)).To(Succeed())
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		Expect(lines[0]).To(ContainSubstring("processes 3"))
+		Expect(lines[2]).To(HavePrefix("PID"))
+		Expect(lines).To(HaveLen(5))
+		Expect(lines[3]).To(HavePrefix("3 "))
+		Expect(out.String()).NotTo(ContainSubstring(clearScreen))
+	})
+
+	It("Should print a json object per refresh", func() {
+		src, err := NewRemoteSource(server.URL, 0, false)
+		Expect(err).NotTo(HaveOccurred())
+		defer src.Close()
+		out := &bytes.Buffer{}
+		opts := Options{View: PodView, SortBy: SortByName, Batch: true, Iterations: 1, Format: FormatJSON}
+		Expect(Run(context.Background(), src, opts, out)).To(Succeed())
+		var result jsonOutput
+		Expect(json.Unmarshal(out.Bytes(), &result)).To(Succeed())
+		Expect(result.View).To(Equal(PodView))
+		Expect(result.Rows).To(HaveLen(2))
+		Expect(result.Rows[0].ID).To(Equal("pod1"))
+	})
+
+	It("Should wait an interval before retrying after an error", func() {
+		requests := 0
+		failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			http.Error(w, `{"error":"not ready"}`, http.StatusServiceUnavailable)
+		}))
+		defer failing.Close()
+		interval := 100 * time.Millisecond
+		src, err := NewRemoteSource(failing.URL, interval, false)
+		Expect(err).NotTo(HaveOccurred())
+		defer src.Close()
+
+		out := &bytes.Buffer{}
+		opts := Options{View: ProcessView, SortBy: SortByWatts, Format: FormatTable, Iterations: 3}
+		start := time.Now()
+		Expect(Run(context.Background(), src, opts, out)).To(Succeed())
+		Expect(time.Since(start)).To(BeNumerically(">=", 2*interval))
+		Expect(requests).To(Equal(3))
+		Expect(out.String()).To(ContainSubstring("503"))
+	})
+
+	It("Should reject invalid options and endpoints", func() {
+		_, err := NewRemoteSource("localhost:8888", 0, false)
+		Expect(err).To(HaveOccurred())
+		src, err := NewRemoteSource(server.URL, 0, false)
+		Expect(err).NotTo(HaveOccurred())
+		opts := Options{View: "nodes", SortBy: SortByWatts, Format: FormatTable}
+		Expect(Run(context.Background(), src, opts, &bytes.Buffer{})).NotTo(Succeed())
+		opts = Options{View: ProcessView, SortBy: "power", Format: FormatTable}
+		Expect(Run(context.Background(), src, opts, &bytes.Buffer{})).NotTo(Succeed())
+	})
+})
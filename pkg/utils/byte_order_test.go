@@ -2,6 +2,8 @@ package utils
 
 import (
 	"encoding/binary"
+	"os"
+	"path/filepath"
 	"runtime"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -29,4 +31,13 @@ var _ = Describe("Byte order test", func() {
 		_, err := GetPathFromPID("", 1)
 		Expect(err).To(HaveOccurred())
 	})
+
+	It("GetProcessStartTime", func() {
+		procDir := GinkgoT().TempDir()
+		Expect(os.Mkdir(filepath.Join(procDir, "42"), 0o755)).To(Succeed())
+		stat := "42 (a (b) c) S 1 42 42 0 -1 4194560 100 0 0 0 1 2 0 0 20 0 1 0 12345 1000 100"
+		Expect(os.WriteFile(filepath.Join(procDir, "42", "stat"), []byte(stat), 0o600)).To(Succeed())
+		Expect(GetProcessStartTime(procDir, 42)).To(Equal(uint64(12345)))
+		Expect(GetProcessStartTime(procDir, 43)).To(BeZero())
+	})
 })
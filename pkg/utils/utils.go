@@ -23,6 +23,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 	"unsafe"
 
@@ -67,6 +68,7 @@ const (
 	KernelProcessNamespace string = "kernel"
 	SystemProcessName      string = "system_processes"
 	SystemProcessNamespace string = "system"
+	TerminatedName         string = "terminated"
 	EmptyString            string = ""
 	GenericSocketID        string = "socket0"
 	GenericGPUID           string = "gpu"
@@ -90,6 +92,48 @@ func GetPathFromPID(searchPath string, pid uint64) (string, error) {
 	return "", fmt.Errorf("could not find cgroup description entry for pid %d", pid)
 }
 
+// atClockTick is AT_CLKTCK, the key of USER_HZ in the auxiliary vector
+const atClockTick = 17
+
+// ClockTicksPerSecond returns USER_HZ, the unit of the times in <procDir>/<pid>/stat, which the kernel
+// passes in the auxiliary vector like sysconf(_SC_CLK_TCK) reads it. It returns 100 if it is not found.
+func ClockTicksPerSecond() uint64 {
+	auxv, err := unix.Auxv()
+	if err != nil {
+		return 100
+	}
+	for _, entry := range auxv {
+		if entry[0] == atClockTick && entry[1] > 0 {
+			return uint64(entry[1])
+		}
+	}
+	return 100
+}
+
+// GetProcessStartTime reads the start time of the process in clock ticks since boot, the 22nd field of
+// <procDir>/<pid>/stat, or returns 0 if the process does not exist anymore. The command name, the second
+// field, is parenthesized and can contain spaces and parentheses.
+func GetProcessStartTime(procDir string, pid uint64) uint64 {
+	data, err := os.ReadFile(fmt.Sprintf("%s/%d/stat", procDir, pid))
+	if err != nil {
+		return 0
+	}
+	stat := string(data)
+	end := strings.LastIndexByte(stat, ')')
+	if end < 0 {
+		return 0
+	}
+	fields := strings.Fields(stat[end+1:])
+	if len(fields) < 20 {
+		return 0
+	}
+	startTime, err := strconv.ParseUint(fields[19], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return startTime
+}
+
 func GetCgroupIDFromPath(byteOrder binary.ByteOrder, path string) (uint64, error) {
 	handle, _, err := unix.NameToHandleAt(unix.AT_FDCWD, path, 0)
 	if err != nil {
@@ -65,6 +65,7 @@ const (
 	KernelProcessNamespace string = "kernel"
 	SystemProcessName      string = "system_processes"
 	SystemProcessNamespace string = "system"
+	TerminatedName         string = "terminated"
 	EmptyString            string = ""
 	GenericSocketID        string = "socket0"
 	GenericGPUID           string = "gpu"
@@ -88,6 +89,14 @@ func GetPathFromPID(searchPath string, pid uint64) (string, error) {
 	return "", fmt.Errorf("could not find cgroup description entry for pid %d", pid)
 }
 
+func ClockTicksPerSecond() uint64 {
+	return 100
+}
+
+func GetProcessStartTime(procDir string, pid uint64) uint64 {
+	return 0
+}
+
 func GetCgroupIDFromPath(byteOrder binary.ByteOrder, path string) (uint64, error) {
 	return uint64(0), nil
 }
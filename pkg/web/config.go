@@ -0,0 +1,258 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package web secures the kepler HTTP server with TLS, client certificates and
+// basic auth, configured with the Prometheus exporter-toolkit web config format,
+// and with bearer tokens.
+package web
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
+)
+
+// TLSConfig is the tls_server_config section of the web config file
+type TLSConfig struct {
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+	// ClientAuthType is the policy for client certificates, e.g. RequireAndVerifyClientCert
+	ClientAuthType   string   `yaml:"client_auth_type"`
+	ClientCAFile     string   `yaml:"client_ca_file"`
+	MinVersion       string   `yaml:"min_version"`
+	MaxVersion       string   `yaml:"max_version"`
+	CipherSuites     []string `yaml:"cipher_suites"`
+	CurvePreferences []string `yaml:"curve_preferences"`
+	// PreferServerCipherSuites is accepted for compatibility, Go ignores it since 1.18
+	PreferServerCipherSuites bool `yaml:"prefer_server_cipher_suites"`
+}
+
+// TLSServerConfig is the web config file, e.g.
+//
+//	tls_server_config:
+//	  cert_file: server.crt
+//	  key_file: server.key
+//	  client_auth_type: RequireAndVerifyClientCert
+//	  client_ca_file: ca.crt
+//	  min_version: TLS12
+//	basic_auth_users:
+//	  prometheus: $2y$10$...
+//	bearer_token_file: tokens
+type TLSServerConfig struct {
+	TLSConfig TLSConfig `yaml:"tls_server_config"`
+	// Users maps the basic auth user names to their bcrypt hashed passwords
+	Users map[string]string `yaml:"basic_auth_users"`
+	// BearerTokenFile holds the accepted bearer tokens, one per line. It is not part
+	// of the exporter-toolkit format, which has no bearer token support.
+	BearerTokenFile string `yaml:"bearer_token_file"`
+
+	// tokens are the sha256 digests of the tokens read from BearerTokenFile
+	tokens [][sha256.Size]byte
+}
+
+var (
+	clientAuthTypes = map[string]tls.ClientAuthType{
+		"":                           tls.NoClientCert,
+		"NoClientCert":               tls.NoClientCert,
+		"RequestClientCert":          tls.RequestClientCert,
+		"RequireAnyClientCert":       tls.RequireAnyClientCert,
+		"VerifyClientCertIfGiven":    tls.VerifyClientCertIfGiven,
+		"RequireAndVerifyClientCert": tls.RequireAndVerifyClientCert,
+	}
+	tlsVersions = map[string]uint16{
+		"TLS10": tls.VersionTLS10,
+		"TLS11": tls.VersionTLS11,
+		"TLS12": tls.VersionTLS12,
+		"TLS13": tls.VersionTLS13,
+	}
+	curves = map[string]tls.CurveID{
+		"CurveP256": tls.CurveP256,
+		"CurveP384": tls.CurveP384,
+		"CurveP521": tls.CurveP521,
+		"X25519":    tls.X25519,
+	}
+)
+
+// LoadConfig reads and validates a web config file. Relative file paths
+// are resolved from the directory of the config file.
+func LoadConfig(path string) (*TLSServerConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read web config file: %w", err)
+	}
+	c := &TLSServerConfig{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse web config file %s: %w", path, err)
+	}
+	c.resolvePaths(filepath.Dir(path))
+	if err := c.validate(); err != nil {
+		return nil, fmt.Errorf("invalid web config file %s: %w", path, err)
+	}
+	if c.BearerTokenFile != "" {
+		if c.tokens, err = readBearerTokens(c.BearerTokenFile); err != nil {
+			return nil, fmt.Errorf("invalid web config file %s: %w", path, err)
+		}
+	}
+	return c, nil
+}
+
+// readBearerTokens reads the tokens of the bearer token file, skipping the blank
+// lines and the # comments. Only their digests are kept in memory.
+func readBearerTokens(path string) ([][sha256.Size]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bearer_token_file: %w", err)
+	}
+	var tokens [][sha256.Size]byte
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		token := strings.TrimSpace(scanner.Text())
+		if token == "" || strings.HasPrefix(token, "#") {
+			continue
+		}
+		tokens = append(tokens, sha256.Sum256([]byte(token)))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read bearer_token_file: %w", err)
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("bearer_token_file %s has no token", path)
+	}
+	return tokens, nil
+}
+
+// AuthEnabled returns true if the requests must send basic auth credentials or a bearer token
+func (c *TLSServerConfig) AuthEnabled() bool {
+	return len(c.Users) > 0 || len(c.tokens) > 0
+}
+
+func (c *TLSServerConfig) resolvePaths(dir string) {
+	for _, f := range []*string{&c.TLSConfig.CertFile, &c.TLSConfig.KeyFile, &c.TLSConfig.ClientCAFile, &c.BearerTokenFile} {
+		if *f != "" && !filepath.IsAbs(*f) {
+			*f = filepath.Join(dir, *f)
+		}
+	}
+}
+
+// TLSEnabled returns true if the server certificate is configured
+func (c *TLSServerConfig) TLSEnabled() bool {
+	return c.TLSConfig.CertFile != "" && c.TLSConfig.KeyFile != ""
+}
+
+func (c *TLSServerConfig) validate() error {
+	t := c.TLSConfig
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		return errors.New("cert_file and key_file must be set together")
+	}
+	if !c.TLSEnabled() && (t.ClientAuthType != "" || t.ClientCAFile != "" || t.MinVersion != "" ||
+		t.MaxVersion != "" || len(t.CipherSuites) > 0 || len(t.CurvePreferences) > 0) {
+		return errors.New("tls_server_config requires cert_file and key_file")
+	}
+	for user, hash := range c.Users {
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return fmt.Errorf("basic_auth_users: the password of %q is not a bcrypt hash: %w", user, err)
+		}
+	}
+	if c.TLSEnabled() {
+		// build the TLS config to check the policies, versions, ciphers and curves
+		if _, err := newTLSConfig(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newTLSConfig loads the certificates and builds the server TLS config
+func newTLSConfig(t TLSConfig) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the server certificate: %w", err)
+	}
+	cfg.Certificates = []tls.Certificate{cert}
+
+	clientAuth, ok := clientAuthTypes[t.ClientAuthType]
+	if !ok {
+		return nil, fmt.Errorf("invalid client_auth_type %q", t.ClientAuthType)
+	}
+	cfg.ClientAuth = clientAuth
+	verifies := clientAuth == tls.VerifyClientCertIfGiven || clientAuth == tls.RequireAndVerifyClientCert
+	switch {
+	case verifies && t.ClientCAFile == "":
+		return nil, fmt.Errorf("client_auth_type %s requires client_ca_file", t.ClientAuthType)
+	case !verifies && t.ClientCAFile != "":
+		return nil, errors.New("client_ca_file requires client_auth_type VerifyClientCertIfGiven or RequireAndVerifyClientCert")
+	case t.ClientCAFile != "":
+		ca, err := os.ReadFile(t.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client_ca_file: %w", err)
+		}
+		cfg.ClientCAs = x509.NewCertPool()
+		if !cfg.ClientCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("failed to parse client_ca_file %s", t.ClientCAFile)
+		}
+	}
+
+	if t.MinVersion != "" {
+		if cfg.MinVersion, ok = tlsVersions[t.MinVersion]; !ok {
+			return nil, fmt.Errorf("invalid min_version %q", t.MinVersion)
+		}
+	}
+	if t.MaxVersion != "" {
+		if cfg.MaxVersion, ok = tlsVersions[t.MaxVersion]; !ok {
+			return nil, fmt.Errorf("invalid max_version %q", t.MaxVersion)
+		}
+		if cfg.MaxVersion < cfg.MinVersion {
+			return nil, errors.New("max_version must not be lower than min_version")
+		}
+	}
+
+	if len(t.CipherSuites) > 0 {
+		ciphers := map[string]uint16{}
+		for _, s := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+			ciphers[s.Name] = s.ID
+		}
+		for _, name := range t.CipherSuites {
+			id, ok := ciphers[name]
+			if !ok {
+				return nil, fmt.Errorf("invalid cipher_suites entry %q", name)
+			}
+			cfg.CipherSuites = append(cfg.CipherSuites, id)
+		}
+	}
+	for _, name := range t.CurvePreferences {
+		id, ok := curves[name]
+		if !ok {
+			return nil, fmt.Errorf("invalid curve_preferences entry %q", name)
+		}
+		cfg.CurvePreferences = append(cfg.CurvePreferences, id)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,261 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package web
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// reloadInterval limits how often the files are checked for changes
+	reloadInterval = time.Second
+	// maxAuthCacheSize bounds the cache of the verified basic auth credentials
+	maxAuthCacheSize = 1000
+)
+
+// unauthenticatedPaths are the probe endpoints, which the kubelet calls without credentials
+var unauthenticatedPaths = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+}
+
+// dummyHash is compared when the user does not exist, so that the response
+// time does not reveal which users exist
+var dummyHash = []byte("$2a$10$LuKUybLeolO0Kw0JQPG1Fum7bdkCjQ4Fz9yuMp18vJv3mjWt30LCa")
+
+// Server applies a web config file to an HTTP server. The config file, the
+// certificates and the client CA are reloaded when they change.
+type Server struct {
+	path string
+
+	mx          sync.RWMutex
+	config      *TLSServerConfig
+	tlsConfig   *tls.Config
+	fingerprint string
+	lastCheck   time.Time
+
+	authMx    sync.Mutex
+	authCache map[[sha256.Size]byte]bool
+}
+
+// NewServer loads the web config file
+func NewServer(path string) (*Server, error) {
+	s := &Server{
+		path:      path,
+		authCache: map[[sha256.Size]byte]bool{},
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Server) load() error {
+	c, err := LoadConfig(s.path)
+	if err != nil {
+		return err
+	}
+	var tlsConfig *tls.Config
+	if c.TLSEnabled() {
+		if tlsConfig, err = newTLSConfig(c.TLSConfig); err != nil {
+			return err
+		}
+	}
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.config != nil && s.config.TLSEnabled() != c.TLSEnabled() {
+		return fmt.Errorf("enabling or disabling TLS requires a restart")
+	}
+	s.config = c
+	s.tlsConfig = tlsConfig
+	s.fingerprint = filesFingerprint(s.path, c)
+	s.lastCheck = time.Now()
+	return nil
+}
+
+// reloadIfChanged reloads the config when the config file or the files it refers to
+// changed. An invalid config is logged and the previous config is kept.
+func (s *Server) reloadIfChanged() {
+	s.mx.RLock()
+	fresh := time.Since(s.lastCheck) < reloadInterval
+	s.mx.RUnlock()
+	if fresh {
+		return
+	}
+	s.mx.Lock()
+	if time.Since(s.lastCheck) < reloadInterval {
+		s.mx.Unlock()
+		return
+	}
+	s.lastCheck = time.Now()
+	changed := filesFingerprint(s.path, s.config) != s.fingerprint
+	s.mx.Unlock()
+	if !changed {
+		return
+	}
+	if err := s.load(); err != nil {
+		klog.Errorf("failed to reload the web config, keeping the previous one: %v", err)
+		return
+	}
+	klog.Infof("reloaded the web config %s", s.path)
+}
+
+// filesFingerprint summarizes the modification time and size of the config files
+func filesFingerprint(path string, c *TLSServerConfig) string {
+	fingerprint := ""
+	for _, f := range []string{path, c.TLSConfig.CertFile, c.TLSConfig.KeyFile, c.TLSConfig.ClientCAFile, c.BearerTokenFile} {
+		if f == "" {
+			continue
+		}
+		if info, err := os.Stat(f); err == nil {
+			fingerprint += fmt.Sprintf("%s:%d:%d;", f, info.ModTime().UnixNano(), info.Size())
+		}
+	}
+	return fingerprint
+}
+
+// TLSEnabled returns true if the server must serve TLS
+func (s *Server) TLSEnabled() bool {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return s.config.TLSEnabled()
+}
+
+// TLSConfig returns the server TLS config, which picks up the reloaded certificates on every handshake
+func (s *Server) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			s.reloadIfChanged()
+			s.mx.RLock()
+			defer s.mx.RUnlock()
+			return s.tlsConfig, nil
+		},
+		// GetConfigForClient provides the certificate, GetCertificate is only
+		// set so that the server does not require the certificate files
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			s.mx.RLock()
+			defer s.mx.RUnlock()
+			return &s.tlsConfig.Certificates[0], nil
+		},
+	}
+}
+
+// Handler requires basic auth or a bearer token, if users or tokens are configured,
+// for all the endpoints but the probes
+func (s *Server) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.reloadIfChanged()
+		s.mx.RLock()
+		config := s.config
+		s.mx.RUnlock()
+		if !config.AuthEnabled() || unauthenticatedPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !s.authorized(config, r) {
+			if len(config.Users) > 0 {
+				w.Header().Add("WWW-Authenticate", `Basic realm="kepler"`)
+			}
+			if len(config.tokens) > 0 {
+				w.Header().Add("WWW-Authenticate", `Bearer realm="kepler"`)
+			}
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authorized checks the bearer token or the basic auth credentials of the request
+func (s *Server) authorized(config *TLSServerConfig, r *http.Request) bool {
+	if token, ok := bearerToken(r); ok {
+		return len(config.tokens) > 0 && validToken(config.tokens, token)
+	}
+	user, password, ok := r.BasicAuth()
+	return ok && len(config.Users) > 0 && s.authenticate(config.Users, user, password)
+}
+
+// bearerToken returns the token of the Authorization header, if it is a bearer token
+func bearerToken(r *http.Request) (string, bool) {
+	auth := r.Header.Get("Authorization")
+	const prefix = "Bearer "
+	if len(auth) < len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
+		return "", false
+	}
+	return strings.TrimSpace(auth[len(prefix):]), true
+}
+
+// validToken compares the digest of the token with every configured token in constant time
+func validToken(tokens [][sha256.Size]byte, token string) bool {
+	digest := sha256.Sum256([]byte(token))
+	valid := 0
+	for i := range tokens {
+		valid |= subtle.ConstantTimeCompare(digest[:], tokens[i][:])
+	}
+	return valid == 1
+}
+
+// authenticate checks the password against the bcrypt hash of the user. The verified
+// credentials are cached since bcrypt is deliberately slow and scrapers send them on every request.
+func (s *Server) authenticate(users map[string]string, user, password string) bool {
+	hash, exists := users[user]
+	key := sha256.Sum256([]byte(user + "\x00" + hash + "\x00" + password))
+	s.authMx.Lock()
+	cached := s.authCache[key]
+	s.authMx.Unlock()
+	if cached && exists {
+		return true
+	}
+
+	hashBytes := dummyHash
+	if exists {
+		hashBytes = []byte(hash)
+	}
+	if err := bcrypt.CompareHashAndPassword(hashBytes, []byte(password)); err != nil || !exists {
+		return false
+	}
+	s.authMx.Lock()
+	if len(s.authCache) >= maxAuthCacheSize {
+		s.authCache = map[[sha256.Size]byte]bool{}
+	}
+	s.authCache[key] = true
+	s.authMx.Unlock()
+	return true
+}
+
+// ListenAndServe serves TLS if it is configured, plain HTTP otherwise
+func (s *Server) ListenAndServe(srv *http.Server) error {
+	if !s.TLSEnabled() {
+		klog.Infof("Starting server without TLS")
+		return srv.ListenAndServe()
+	}
+	klog.Infof("Starting server with TLS")
+	srv.TLSConfig = s.TLSConfig()
+	return srv.ListenAndServeTLS("", "")
+}
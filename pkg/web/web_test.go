@@ -0,0 +1,279 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package web
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// certificate is a test certificate signed by a test CA
+type certificate struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newCertificate(commonName string, parent *certificate) *certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	Expect(err).NotTo(HaveOccurred())
+	cert, err := x509.ParseCertificate(der)
+	Expect(err).NotTo(HaveOccurred())
+	return &certificate{cert: cert, key: key}
+}
+
+func (c *certificate) write(dir, name string) {
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw})
+	Expect(os.WriteFile(filepath.Join(dir, name+".crt"), certPEM, 0o600)).To(Succeed())
+	der, err := x509.MarshalECPrivateKey(c.key)
+	Expect(err).NotTo(HaveOccurred())
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+	Expect(os.WriteFile(filepath.Join(dir, name+".key"), keyPEM, 0o600)).To(Succeed())
+}
+
+func (c *certificate) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.cert.Raw}, PrivateKey: c.key}
+}
+
+func writeConfig(dir, content string) string {
+	path := filepath.Join(dir, "web-config.yml")
+	Expect(os.WriteFile(path, []byte(content), 0o600)).To(Succeed())
+	return path
+}
+
+var _ = Describe("Test Web Config", func() {
+	var dir string
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+		ca := newCertificate("ca", nil)
+		ca.write(dir, "ca")
+		newCertificate("localhost", ca).write(dir, "server")
+		Expect(os.WriteFile(filepath.Join(dir, "tokens"), []byte("# scrapers\nfirst\n\nsecond\n"), 0o600)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, "empty"), []byte("# no token\n"), 0o600)).To(Succeed())
+	})
+
+	It("Should load a valid config and resolve the relative paths", func() {
+		c, err := LoadConfig(writeConfig(dir, `
+tls_server_config:
+  cert_file: server.crt
+  key_file: server.key
+  client_auth_type: RequireAndVerifyClientCert
+  client_ca_file: ca.crt
+  min_version: TLS12
+  max_version: TLS13
+  cipher_suites: [TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256]
+  curve_preferences: [X25519, CurveP256]
+basic_auth_users:
+  prometheus: $2a$10$LuKUybLeolO0Kw0JQPG1Fum7bdkCjQ4Fz9yuMp18vJv3mjWt30LCa
+bearer_token_file: tokens
+`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.TLSEnabled()).To(BeTrue())
+		Expect(c.TLSConfig.CertFile).To(Equal(filepath.Join(dir, "server.crt")))
+		Expect(c.Users).To(HaveKey("prometheus"))
+		Expect(c.BearerTokenFile).To(Equal(filepath.Join(dir, "tokens")))
+		Expect(c.tokens).To(HaveLen(2))
+		Expect(c.AuthEnabled()).To(BeTrue())
+	})
+
+	DescribeTable("Should reject an invalid config", func(content, message string) {
+		_, err := LoadConfig(writeConfig(dir, content))
+		Expect(err).To(MatchError(ContainSubstring(message)))
+	},
+		Entry("cert without key", "tls_server_config:\n  cert_file: server.crt\n", "must be set together"),
+		Entry("client CA without TLS", "tls_server_config:\n  client_ca_file: ca.crt\n", "requires cert_file and key_file"),
+		Entry("unknown client auth type", "tls_server_config:\n  cert_file: server.crt\n  key_file: server.key\n  client_auth_type: Always\n", "invalid client_auth_type"),
+		Entry("verification without CA", "tls_server_config:\n  cert_file: server.crt\n  key_file: server.key\n  client_auth_type: RequireAndVerifyClientCert\n", "requires client_ca_file"),
+		Entry("CA without verification", "tls_server_config:\n  cert_file: server.crt\n  key_file: server.key\n  client_ca_file: ca.crt\n", "client_ca_file requires client_auth_type"),
+		Entry("unknown TLS version", "tls_server_config:\n  cert_file: server.crt\n  key_file: server.key\n  min_version: SSL3\n", "invalid min_version"),
+		Entry("unknown cipher", "tls_server_config:\n  cert_file: server.crt\n  key_file: server.key\n  cipher_suites: [RC4]\n", "invalid cipher_suites"),
+		Entry("password not hashed", "basic_auth_users:\n  prometheus: secret\n", "not a bcrypt hash"),
+		Entry("missing token file", "bearer_token_file: missing\n", "failed to read bearer_token_file"),
+		Entry("token file without token", "bearer_token_file: empty\n", "has no token"),
+		Entry("unknown field", "tls_config:\n  cert_file: server.crt\n", "field tls_config not found"),
+	)
+})
+
+var _ = Describe("Test Web Server", func() {
+	var (
+		dir    string
+		ca     *certificate
+		client *certificate
+		ok     http.Handler
+	)
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+		ca = newCertificate("ca", nil)
+		ca.write(dir, "ca")
+		newCertificate("localhost", ca).write(dir, "server")
+		client = newCertificate("prometheus", ca)
+		ok = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+	})
+
+	It("Should require basic auth except for the probes", func() {
+		hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+		Expect(err).NotTo(HaveOccurred())
+		s, err := NewServer(writeConfig(dir, "basic_auth_users:\n  prometheus: "+string(hash)+"\n"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(s.TLSEnabled()).To(BeFalse())
+		handler := s.Handler(ok)
+
+		serve := func(path, user, password string) int {
+			req := httptest.NewRequest(http.MethodGet, path, http.NoBody)
+			if user != "" {
+				req.SetBasicAuth(user, password)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			return rec.Code
+		}
+		Expect(serve("/metrics", "", "")).To(Equal(http.StatusUnauthorized))
+		Expect(serve("/metrics", "prometheus", "wrong")).To(Equal(http.StatusUnauthorized))
+		Expect(serve("/metrics", "unknown", "secret")).To(Equal(http.StatusUnauthorized))
+		Expect(serve("/metrics", "prometheus", "secret")).To(Equal(http.StatusOK))
+		// served from the cache
+		Expect(serve("/metrics", "prometheus", "secret")).To(Equal(http.StatusOK))
+		Expect(serve("/healthz", "", "")).To(Equal(http.StatusOK))
+		Expect(serve("/readyz", "", "")).To(Equal(http.StatusOK))
+	})
+
+	It("Should require a bearer token or basic auth and reload the tokens", func() {
+		hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+		Expect(err).NotTo(HaveOccurred())
+		tokens := filepath.Join(dir, "tokens")
+		Expect(os.WriteFile(tokens, []byte("first\n"), 0o600)).To(Succeed())
+		s, err := NewServer(writeConfig(dir, "basic_auth_users:\n  prometheus: "+string(hash)+"\nbearer_token_file: tokens\n"))
+		Expect(err).NotTo(HaveOccurred())
+		handler := s.Handler(ok)
+
+		serve := func(path, authorization string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodGet, path, http.NoBody)
+			if authorization != "" {
+				req.Header.Set("Authorization", authorization)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			return rec
+		}
+		rec := serve("/metrics", "")
+		Expect(rec.Code).To(Equal(http.StatusUnauthorized))
+		Expect(rec.Header().Values("WWW-Authenticate")).To(ConsistOf(`Basic realm="kepler"`, `Bearer realm="kepler"`))
+		Expect(serve("/metrics", "Bearer wrong").Code).To(Equal(http.StatusUnauthorized))
+		Expect(serve("/metrics", "Bearer ").Code).To(Equal(http.StatusUnauthorized))
+		Expect(serve("/metrics", "Bearer first").Code).To(Equal(http.StatusOK))
+		Expect(serve("/metrics", "bearer first").Code).To(Equal(http.StatusOK))
+		req := httptest.NewRequest(http.MethodGet, "/metrics", http.NoBody)
+		req.SetBasicAuth("prometheus", "secret")
+		Expect(serve("/metrics", req.Header.Get("Authorization")).Code).To(Equal(http.StatusOK))
+		Expect(serve("/healthz", "").Code).To(Equal(http.StatusOK))
+
+		// rotate the token
+		Expect(os.WriteFile(tokens, []byte("second token\n"), 0o600)).To(Succeed())
+		Expect(os.Chtimes(tokens, time.Now(), time.Now().Add(time.Minute))).To(Succeed())
+		s.mx.Lock()
+		s.lastCheck = time.Time{}
+		s.mx.Unlock()
+		Expect(serve("/metrics", "Bearer first").Code).To(Equal(http.StatusUnauthorized))
+		Expect(serve("/metrics", "Bearer second token").Code).To(Equal(http.StatusOK))
+	})
+
+	It("Should require a client certificate signed by the client CA and reload the certificates", func() {
+		s, err := NewServer(writeConfig(dir, `
+tls_server_config:
+  cert_file: server.crt
+  key_file: server.key
+  client_auth_type: RequireAndVerifyClientCert
+  client_ca_file: ca.crt
+`))
+		Expect(err).NotTo(HaveOccurred())
+		server := httptest.NewUnstartedServer(s.Handler(ok))
+		server.TLS = s.TLSConfig()
+		server.StartTLS()
+		defer server.Close()
+
+		get := func(clientCert *certificate) (*http.Response, error) {
+			roots := x509.NewCertPool()
+			roots.AddCert(ca.cert)
+			config := &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}
+			if clientCert != nil {
+				config.Certificates = []tls.Certificate{clientCert.tlsCertificate()}
+			}
+			c := &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+			defer c.CloseIdleConnections()
+			return c.Get(server.URL + "/metrics")
+		}
+
+		_, err = get(nil)
+		Expect(err).To(HaveOccurred())
+		_, err = get(newCertificate("intruder", newCertificate("other-ca", nil)))
+		Expect(err).To(HaveOccurred())
+		resp, err := get(client)
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+		// rotate the server certificate
+		rotated := newCertificate("localhost", ca)
+		rotated.write(dir, "server")
+		s.mx.Lock()
+		s.lastCheck = time.Time{}
+		s.mx.Unlock()
+		resp, err = get(client)
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+		Expect(resp.TLS.PeerCertificates[0].SerialNumber).To(Equal(rotated.cert.SerialNumber))
+	})
+})